@@ -0,0 +1,234 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultMetricPrefix starts every /metrics name unless --metric-prefix
+// replaces it.
+const defaultMetricPrefix = "mole_"
+
+// Bounds on one --listen request, so a client that sends its headers slowly
+// or never reads the reply cannot hold a connection open indefinitely.
+const (
+	apiHeaderTimeout = 5 * time.Second
+	apiWriteTimeout  = 10 * time.Second
+	apiIdleTimeout   = time.Minute
+)
+
+var (
+	promMetricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	promLabelName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// promLabelsUsed are the labels /metrics sets itself; a static label of the
+// same name would make a series carry it twice.
+var promLabelsUsed = []string{"host", "iface", "kind", "target"}
+
+// promNaming adapts /metrics to an existing Prometheus setup: the prefix
+// replaces "mole_" and the labels are added to every series.
+type promNaming struct {
+	prefix string      // Empty = defaultMetricPrefix.
+	labels [][2]string // Static key/value pairs, in flag order.
+}
+
+// checkMetricPrefix rejects a prefix that would not make valid metric names.
+func checkMetricPrefix(prefix string) error {
+	if !promMetricName.MatchString(prefix) {
+		return fmt.Errorf("invalid --metric-prefix %q: want letters, digits, _ and :, not starting with a digit", prefix)
+	}
+	return nil
+}
+
+// parseMetricLabels adds the labels of one --metric-label "dc=us-east,rack=r4"
+// to labels. Names follow the Prometheus rules and may not use the reserved
+// __ prefix, a label /metrics already sets or one given before; values may
+// be any non-empty UTF-8 without commas.
+func parseMetricLabels(labels [][2]string, value string) ([][2]string, error) {
+	for _, item := range splitList(value) {
+		name, val, ok := strings.Cut(item, "=")
+		switch {
+		case !ok || val == "":
+			return nil, fmt.Errorf("invalid --metric-label %q: want name=value", item)
+		case !promLabelName.MatchString(name):
+			return nil, fmt.Errorf("invalid --metric-label name %q: want letters, digits and _, not starting with a digit", name)
+		case strings.HasPrefix(name, "__"):
+			return nil, fmt.Errorf("invalid --metric-label name %q: names starting with __ are reserved", name)
+		case slices.Contains(promLabelsUsed, name):
+			return nil, fmt.Errorf("invalid --metric-label name %q: /metrics already sets it", name)
+		case !utf8.ValidString(val):
+			return nil, fmt.Errorf("invalid --metric-label value for %q: not UTF-8", name)
+		case slices.ContainsFunc(labels, func(l [2]string) bool { return l[0] == name }):
+			return nil, fmt.Errorf("duplicate --metric-label %q", name)
+		}
+		labels = append(slices.Clip(labels), [2]string{name, val})
+	}
+	return labels, nil
+}
+
+// formatMetricLabels is the --metric-label value that parses back to labels.
+func formatMetricLabels(labels [][2]string) string {
+	items := make([]string, len(labels))
+	for i, l := range labels {
+		items[i] = l[0] + "=" + l[1]
+	}
+	return strings.Join(items, ",")
+}
+
+// apiServer serves the latest snapshot over HTTP (--listen) while the
+// dashboard or line output runs as usual:
+//
+//	/api/snapshot          the full MetricsSnapshot, as --json prints it
+//	/api/history/network   the aggregate rx/tx history arrays, oldest first
+//	/metrics               the headline gauges in Prometheus text format
+//
+// /api/snapshot is also what --source-url expects, so one host can watch
+// another.
+type apiServer struct {
+	cors   string        // Access-Control-Allow-Origin value; empty sends none.
+	bucket time.Duration // History resolution served (--history-bucket); 0 = every sample.
+	naming promNaming    // Metric prefix and static labels for /metrics.
+
+	mu   sync.Mutex
+	last MetricsSnapshot
+}
+
+// newAPIServer binds addr up front, so a bad or busy address fails at start
+// instead of in the background, then serves until the process exits.
+func newAPIServer(addr, cors string, bucket time.Duration, naming promNaming) (*apiServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("--listen: %w", err)
+	}
+	s := &apiServer{cors: cors, bucket: bucket, naming: naming}
+	srv := &http.Server{
+		Handler:           s.handler(),
+		ReadHeaderTimeout: apiHeaderTimeout,
+		WriteTimeout:      apiWriteTimeout,
+		IdleTimeout:       apiIdleTimeout,
+	}
+	go srv.Serve(ln)
+	return s, nil
+}
+
+// record keeps snap as the one the endpoints return.
+func (s *apiServer) record(snap MetricsSnapshot) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.last = snap
+	s.mu.Unlock()
+}
+
+func (s *apiServer) latest() (MetricsSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return exportSnapshot(s.last, s.bucket), !s.last.CollectedAt.IsZero()
+}
+
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		s.serveJSON(w, func(snap MetricsSnapshot) any { return snap })
+	})
+	mux.HandleFunc("GET /api/history/network", func(w http.ResponseWriter, r *http.Request) {
+		s.serveJSON(w, func(snap MetricsSnapshot) any { return snap.NetworkHistory })
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		snap, ok := s.latest()
+		if !ok {
+			http.Error(w, "no sample yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, formatPrometheus(snap, s.naming))
+	})
+	return s.withCORS(mux)
+}
+
+// serveJSON writes part of the latest snapshot, or 503 before the first one.
+func (s *apiServer) serveJSON(w http.ResponseWriter, part func(MetricsSnapshot) any) {
+	snap, ok := s.latest()
+	if !ok {
+		http.Error(w, "no sample yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(part(snap))
+}
+
+// withCORS lets browser dashboards on another origin read the API when
+// --cors-origin is set, answering preflight requests itself.
+func (s *apiServer) withCORS(next http.Handler) http.Handler {
+	if s.cors == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", s.cors)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// formatPrometheus renders metricGroups as gauges named
+// mole_<measurement>_<field> (or naming's prefix instead of mole_), with the
+// host, naming's static labels and the group tags as labels.
+func formatPrometheus(s MetricsSnapshot, naming promNaming) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	prefix := cmp.Or(naming.prefix, defaultMetricPrefix)
+	series := make(map[string][]string)
+	for _, g := range metricGroups(s) {
+		var labels []string
+		if s.Host != "" {
+			labels = append(labels, `host="`+escape.Replace(s.Host)+`"`)
+		}
+		for _, l := range naming.labels {
+			labels = append(labels, l[0]+`="`+escape.Replace(l[1])+`"`)
+		}
+		for _, t := range g.tags {
+			if t[1] != "" {
+				labels = append(labels, t[0]+`="`+escape.Replace(t[1])+`"`)
+			}
+		}
+		suffix := ""
+		if len(labels) > 0 {
+			suffix = "{" + strings.Join(labels, ",") + "}"
+		}
+		for _, f := range g.fields {
+			name := prefix + g.measurement + "_" + f[0]
+			series[name] = append(series[name], name+suffix+" "+f[1])
+		}
+	}
+	// Each metric's samples must be grouped under one TYPE line.
+	names := make([]string, 0, len(series))
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString("# TYPE " + name + " gauge\n")
+		for _, line := range series[name] {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
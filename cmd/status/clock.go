@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// monoClock reports time elapsed since the collector started, read from the
+// monotonic clock. Rate math uses it instead of wall-clock differences, which
+// jump or go negative when NTP or the user steps the system time, and which
+// lose their monotonic reading once a time.Time is serialized or rounded.
+type monoClock func() time.Duration
+
+func newMonoClock() monoClock {
+	start := time.Now()
+	return func() time.Duration { return time.Since(start) }
+}
+
+// rateSnapTolerance is how far, as a fraction of the nominal interval, a
+// measured gap may stray and still be treated as exactly one interval.
+const rateSnapTolerance = 0.1
+
+// rateWindow tracks the spacing between successive counter samples.
+type rateWindow struct {
+	last    time.Duration
+	started bool
+	// nominal, when set (--steady-rates), replaces gaps within
+	// rateSnapTolerance of it so scheduler jitter does not wobble rates.
+	nominal time.Duration
+}
+
+// advance records a sample taken at tick and returns the seconds since the
+// previous one; ok is false for the first sample, which only sets a baseline.
+// Two samples in the same instant have no meaningful rate either, so a
+// non-positive gap also reports ok=false and the sample becomes the new
+// baseline; callers keep showing their previous result for that cycle.
+func (w *rateWindow) advance(tick time.Duration) (elapsed float64, ok bool) {
+	prev, started := w.last, w.started
+	w.last, w.started = tick, true
+	if !started {
+		return 0, false
+	}
+	elapsed = (tick - prev).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	// Gaps well off the interval (a forced refresh, a laptop waking up) keep
+	// their measured length so the rate stays an honest average.
+	if nominal := w.nominal.Seconds(); nominal > 0 && math.Abs(elapsed-nominal) <= nominal*rateSnapTolerance {
+		elapsed = nominal
+	}
+	return elapsed, true
+}
+
+// cadenceBehindCycles is how many late samples in a row mean sampling is
+// behind rather than hit by one slow collector run.
+const cadenceBehindCycles = 5
+
+// cadenceWatch notices samples arriving further apart than the interval
+// asks for: a collector cycle, such as a slow connections enumeration, that
+// keeps running over budget (--behind-margin).
+type cadenceWatch struct {
+	margin float64 // Fraction over the target a gap may run; 0 = off.
+	last   time.Time
+	late   []time.Duration // The current run of late gaps, the latest cadenceBehindCycles.
+	behind time.Duration   // Mean of those gaps once the run is long enough; 0 = on time.
+	target time.Duration   // The interval the latest gap was drawn against.
+}
+
+// observe records a sample arriving at now, drawn against target. It
+// reports whether the watch went behind or caught up with this sample.
+func (w *cadenceWatch) observe(now time.Time, target time.Duration) (changed bool) {
+	if w == nil || w.margin <= 0 {
+		return false
+	}
+	prev := w.last
+	w.last = now
+	if prev.IsZero() {
+		return false
+	}
+	was := w.behind > 0
+	w.target = target
+	if gap := now.Sub(prev); float64(gap) > float64(target)*(1+w.margin) {
+		w.late = append(w.late, gap)
+		if len(w.late) > cadenceBehindCycles {
+			w.late = w.late[1:]
+		}
+	} else {
+		w.late = w.late[:0]
+	}
+	w.behind = 0
+	if len(w.late) == cadenceBehindCycles {
+		var sum time.Duration
+		for _, gap := range w.late {
+			sum += gap
+		}
+		w.behind = sum / cadenceBehindCycles
+	}
+	return was != (w.behind > 0)
+}
+
+// String is the warning while behind, empty otherwise.
+func (w *cadenceWatch) String() string {
+	if w == nil || w.behind <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("sampling behind (actual %s vs target %s)", w.behind.Round(100*time.Millisecond), w.target)
+}
+
+// reset forgets the previous sample, after a pause that is not lateness.
+func (w *cadenceWatch) reset() {
+	if w != nil {
+		w.last, w.late, w.behind = time.Time{}, nil, 0
+	}
+}
+
+// A sample below all of these counts as idle for --adaptive.
+const (
+	idleCPUPercent = 10
+	idleNetMBs     = 0.05 // Combined rx+tx across interfaces.
+	idleDiskMBs    = 0.5  // Combined read+write.
+)
+
+// adaptiveInterval stretches the dashboard refresh while the machine is idle
+// and drops back to min as soon as anything happens (--adaptive). Rates are
+// already measured over the actual gap between samples, so a changing
+// interval needs no re-baselining; longer gaps simply average over more time.
+type adaptiveInterval struct {
+	min, max time.Duration
+}
+
+// next returns the delay before the sample after s, given the current one:
+// doubled while idle, up to max, otherwise min.
+func (a adaptiveInterval) next(cur time.Duration, s MetricsSnapshot) time.Duration {
+	if !sampleIdle(s) {
+		return a.min
+	}
+	return min(max(cur*2, a.min), a.max)
+}
+
+func sampleIdle(s MetricsSnapshot) bool {
+	var net float64
+	for _, n := range s.Network {
+		if n.Bond == "" {
+			net += n.RxRateMBs + n.TxRateMBs
+		}
+	}
+	return s.CPU.Usage < idleCPUPercent && net < idleNetMBs && s.DiskIO.ReadRate+s.DiskIO.WriteRate < idleDiskMBs
+}
+
+// idleDimmer dims the dashboard once samples have stayed idle, by the
+// --adaptive measure, for after (--dim-after), and brightens it with the
+// first busy sample or key press. A nil *idleDimmer never dims.
+type idleDimmer struct {
+	after time.Duration
+	since time.Time // Start of the current idle stretch; zero while busy.
+	on    bool
+}
+
+// observe records a sample taken at now and reports whether the dashboard
+// dimmed or brightened with it. The warm-up sample has no rates to judge.
+func (d *idleDimmer) observe(now time.Time, s MetricsSnapshot) (changed bool) {
+	if d == nil || s.NetworkWarmup {
+		return false
+	}
+	was := d.on
+	if !sampleIdle(s) {
+		d.since, d.on = time.Time{}, false
+		return was
+	}
+	if d.since.IsZero() {
+		d.since = now
+	}
+	d.on = now.Sub(d.since) >= d.after
+	return d.on != was
+}
+
+// wake brightens at once and starts the idle stretch over.
+func (d *idleDimmer) wake(now time.Time) {
+	if d == nil {
+		return
+	}
+	d.since, d.on = now, false
+}
+
+// dimmed reports whether the dashboard is drawn dimmed.
+func (d *idleDimmer) dimmed() bool {
+	return d != nil && d.on
+}
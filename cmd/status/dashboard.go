@@ -0,0 +1,608 @@
+//go:build !agent
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	refreshedNoteFor = 1500 * time.Millisecond // How long the footer confirms an r refresh.
+	eventNoteFor     = 30 * time.Second        // How long the latest event stays in the footer.
+)
+
+// tickMsg triggers a scheduled sample. gen ties it to the schedule that
+// created it so a manual refresh can retire the pending tick.
+type tickMsg struct{ gen int }
+type animTickMsg struct{}
+type durationDoneMsg struct{}
+
+type metricsMsg struct {
+	data  MetricsSnapshot
+	err   error
+	spike string // Why data trips the freeze trigger; empty if it doesn't.
+}
+
+type model struct {
+	source      snapshotSource
+	width       int
+	height      int
+	metrics     MetricsSnapshot
+	errMessage  string
+	ready       bool
+	lastUpdated time.Time
+	collecting  bool
+	animFrame   int
+	catHidden   bool // true = hidden, false = visible
+	display     viewState
+	summary     []string // Fields for the summary line; empty hides it.
+	snapshots   *snapshotWriter
+	session     *sessionStats // Shared across model copies; feeds the exit summary.
+	duration    time.Duration // Quit automatically after this long; 0 = run until q.
+	samples     int           // Quit after this many samples past the baseline; 0 = run until q.
+	sampled     int
+	warmup      time.Duration // Gap of the startup baseline before the first frame; 0 = none.
+
+	zombieThreshold  int  // Alert above this many zombie processes; 0 disables.
+	notify           bool // Also raise alerts as desktop notifications.
+	quietHours       quietHours
+	quietPref        string // quiet_hours from the prefs file, written back unchanged.
+	zombieAlerted    bool   // Alert already raised; re-armed once the count drops.
+	ephemeralAlerted bool
+
+	interval       time.Duration     // Delay until the next scheduled sample.
+	adaptive       *adaptiveInterval // Adjusts interval per sample (--adaptive); nil = fixed.
+	tickGen        int               // Current tick schedule; older tickMsgs are dropped.
+	forced         bool              // The sample in flight was requested with r.
+	pauseUnfocused bool              // Stop the schedule on a blur event (--pause-unfocused).
+	unfocused      bool              // Paused until the terminal regains focus.
+	rebaseline     bool              // The next sample starts fresh rate baselines.
+	cadence        *cadenceWatch     // Warns while samples keep arriving late (--behind-margin).
+	dim            *idleDimmer       // Dims the view after a stretch of idle samples (--dim-after); nil = never.
+	dimLevel       float64           // Brightness kept by colors while dimmed (--dim-level).
+	refreshedUntil time.Time         // Show the "refreshed" note in the footer until then.
+
+	trigger spikeTrigger
+	frozen  *spikeCapture // Display held on this sample until f resumes.
+	spiking bool          // Last sample was over a threshold; re-arm only once it drops.
+
+	kiosk       bool          // Read-only wall-display mode (--kiosk).
+	kioskEvery  time.Duration // How long each panel keeps focus.
+	kioskFocus  string        // Panel id currently expanded.
+	kioskQuitAt time.Time     // First ctrl+c of the double press that exits.
+
+	graphStyle string // Glyph set to restore when n leaves digit mode.
+
+	layouts []dashboardLayout // Named layouts from the prefs file, cycled with L.
+	layout  string            // Name of the active layout; empty = none.
+
+	events      *eventRing // Shared with the source when it keeps one.
+	showEvents  bool       // Event log panel open (e).
+	eventScroll int        // Events scrolled back from the newest.
+	showHelp    bool       // Key help overlay open (?).
+
+	// The : prompt. A comparison or field entered there stays in the
+	// footer, re-run on every sample, until an empty query clears it.
+	queryOpen   bool
+	queryInput  string
+	queryErr    string // Parse error for queryInput; the prompt stays open.
+	query       *query
+	queryResult string // Rendered outcome of query on the current snapshot.
+}
+
+func newModel(opts options, source snapshotSource) model {
+	prefs := loadPrefs()
+	m := model{
+		catHidden: prefs.catHidden,
+		display: viewState{
+			hiddenIfaces:   toSet(prefs.hiddenIfaces),
+			pinned:         toSet(prefs.pinnedIfaces),
+			excludeHidden:  opts.excludeHidden,
+			minRate:        opts.minRate,
+			showTotals:     opts.showTotals,
+			alignRates:     opts.aligned,
+			rateWidth:      opts.rateWidth,
+			netColumns:     opts.netColumns,
+			dirRatio:       opts.dirRatio,
+			dirTotals:      opts.dirTotals,
+			diskSort:       opts.diskSort,
+			connGroup:      opts.connGroup,
+			ifaceGraphs:    opts.ifaceGraphs,
+			ephemeralAlert: opts.ephemeralThreshold,
+			asymRatio:      opts.asymRatio,
+			asymWindow:     opts.asymWindow,
+			thresholds:     opts.thresholds,
+			ifaceLevels:    prefs.thresholds,
+		},
+	}
+	m.source = source
+	m.snapshots = opts.snapshotWriter()
+	m.summary = opts.summaryFields
+	m.session = newSessionStats(time.Now())
+	m.duration = opts.duration
+	m.samples = opts.samples
+	m.warmup = opts.warmup
+	m.zombieThreshold = opts.zombieThreshold
+	m.notify = opts.notify
+	m.quietPref = prefs.quietHours
+	m.quietHours = opts.quietHours
+	if !m.quietHours.set {
+		// An unparsable prefs entry is ignored like any other unknown value.
+		m.quietHours, _ = parseQuietHours(prefs.quietHours)
+	}
+	m.interval = refreshInterval
+	if opts.adaptive {
+		m.interval = opts.adaptiveMin
+		m.adaptive = &adaptiveInterval{min: opts.adaptiveMin, max: opts.adaptiveMax}
+	}
+	m.pauseUnfocused = opts.pauseUnfocused
+	m.cadence = &cadenceWatch{margin: opts.behindMargin / 100}
+	if opts.dimAfter > 0 {
+		m.dim, m.dimLevel = &idleDimmer{after: opts.dimAfter}, opts.dimLevel
+	}
+	m.trigger = opts.trigger
+	m.kiosk = opts.kiosk
+	m.kioskEvery = opts.kioskCycle
+	if m.events = eventsOf(source); m.events == nil {
+		m.events = &eventRing{}
+	}
+	m.layouts = prefs.layouts
+	for _, l := range m.layouts {
+		if l.name == prefs.layout {
+			m.applyLayout(l)
+		}
+	}
+	return m
+}
+
+// applyLayout switches to l, changing only the settings it names.
+func (m *model) applyLayout(l dashboardLayout) {
+	m.layout = l.name
+	m.display.collapsed = nil
+	if len(l.panels) > 0 {
+		m.display.collapsed = toSet(panelIDs)
+		for _, id := range l.panels {
+			delete(m.display.collapsed, id)
+		}
+	}
+	if l.diskSort != nil {
+		m.display.diskSort = *l.diskSort
+	}
+	if l.connGroup != nil {
+		m.display.connGroup = *l.connGroup
+	}
+	if l.ifaceGraphs != nil {
+		m.display.ifaceGraphs = *l.ifaceGraphs
+	}
+	if l.groupByKind != nil {
+		m.display.groupByKind = *l.groupByKind
+	}
+	if l.procsByCPU != nil {
+		m.display.procsByCPU = *l.procsByCPU
+	}
+	if l.minRate != nil {
+		m.display.minRate = *l.minRate
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	cmds := []tea.Cmd{tickAfter(0, 0), animTick()}
+	if m.duration > 0 {
+		cmds = append(cmds, tea.Tick(m.duration, func(time.Time) tea.Msg { return durationDoneMsg{} }))
+	}
+	if m.kiosk {
+		cmds = append(cmds, kioskCycle(m.kioskEvery))
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		m.dim.wake(time.Now())
+		if m.kiosk {
+			return m.kioskKey(msg)
+		}
+		if m.showEvents {
+			return m.eventLogKey(msg)
+		}
+		if m.showHelp {
+			return m.helpKey(msg)
+		}
+		if m.queryOpen {
+			return m.queryKey(msg)
+		}
+		if b, ok := lookupKey(msg.String()); ok {
+			cmd := b.action(&m, msg)
+			return m, cmd
+		}
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+	case tea.BlurMsg:
+		// Terminals that never report focus never pause.
+		m.unfocused = m.pauseUnfocused
+		return m, nil
+	case tea.FocusMsg:
+		if !m.unfocused {
+			return m, nil
+		}
+		// Resume at once on a new schedule. Rates over the pause would be
+		// one long average, so the counters start from a fresh baseline.
+		m.unfocused = false
+		m.rebaseline = true
+		m.cadence.reset()
+		m.session.resume()
+		m.tickGen++
+		return m, tickAfter(0, m.tickGen)
+	case tickMsg:
+		if msg.gen != m.tickGen || m.collecting || m.unfocused {
+			return m, nil
+		}
+		m.collecting = true
+		cmd := m.collectCmd()
+		m.rebaseline = false
+		return m, cmd
+	case metricsMsg:
+		if msg.err != nil {
+			m.errMessage = msg.err.Error()
+		} else {
+			m.errMessage = ""
+		}
+		m.session.add(msg.data)
+		m.display.mark.observe(msg.data.Network)
+		if m.display.showTotals {
+			m.display.totalRxBytes, m.display.totalTxBytes = m.session.totals()
+		}
+		if m.display.dirTotals {
+			m.display.sessionBytes = m.session.ifaceBytes()
+		}
+		if m.frozen == nil {
+			m.metrics = msg.data
+			m.lastUpdated = msg.data.CollectedAt
+			m.runQuery()
+			if m.display.follow != nil {
+				m.display.selectedIface = m.display.follow.pick(m.metrics.Network, selectableInterfaces(m.metrics.Network, m.display), m.display.selectedIface)
+			}
+			if msg.spike != "" && !m.spiking {
+				m.frozen = &spikeCapture{snapshot: msg.data, reason: msg.spike}
+			}
+		}
+		m.spiking = msg.spike != ""
+		m.collecting = false
+		if m.forced {
+			m.forced = false
+			m.refreshedUntil = time.Now().Add(refreshedNoteFor)
+		}
+		// Mark ready after first successful data collection.
+		if !m.ready {
+			m.ready = true
+		}
+		if m.cadence.observe(time.Now(), cmp.Or(m.interval, refreshInterval)) {
+			if behind := m.cadence.String(); behind != "" {
+				m.events.add(severityWarn, categorySystem, behind)
+			} else {
+				m.events.add(severityInfo, categorySystem, "sampling back on schedule")
+			}
+		}
+		if m.adaptive != nil {
+			m.interval = m.adaptive.next(m.interval, msg.data)
+		}
+		m.dim.observe(time.Now(), msg.data)
+		// A rate baseline does not count, as in --line. With --warmup the
+		// first sample already has rates.
+		if !msg.data.NetworkWarmup {
+			m.sampled++
+		}
+		if m.samples > 0 && m.sampled >= m.samples {
+			return m, tea.Quit
+		}
+		return m, tea.Batch(tickAfter(cmp.Or(m.interval, refreshInterval), m.tickGen), m.checkAlerts())
+	case durationDoneMsg:
+		return m, tea.Quit
+	case kioskCycleMsg:
+		m.kioskFocus = nextKioskFocus(buildCards(m.metrics, 80, m.display), m.kioskFocus)
+		m.display.collapsed = kioskCollapsed(m.kioskFocus)
+		return m, kioskCycle(m.kioskEvery)
+	case animTickMsg:
+		m.animFrame++
+		return m, animTickWithSpeed(m.metrics.CPU.Usage)
+	}
+	return m, nil
+}
+
+func (m model) View() string {
+	out := m.view()
+	if m.dim.dimmed() {
+		out = dimView(out, m.dimLevel)
+	}
+	if asciiOutput {
+		return toASCII(out)
+	}
+	return out
+}
+
+func (m model) view() string {
+	if !m.ready {
+		return "Loading..."
+	}
+
+	termWidth := m.width
+	if termWidth <= 0 {
+		termWidth = 80
+	}
+	if termWidth < minViewWidth {
+		return renderNarrowView(m.metrics, termWidth)
+	}
+	if m.showHelp {
+		return renderKeyHelp(keymap, termWidth)
+	}
+	if m.showEvents {
+		return renderEventLog(m.events.recent(), m.eventScroll, termWidth, m.height)
+	}
+
+	header, mole := renderHeader(m.metrics, m.errMessage, m.animFrame, termWidth, m.catHidden)
+
+	if termWidth <= 80 {
+		cardWidth := termWidth
+		if cardWidth > 2 {
+			cardWidth -= 2
+		}
+		cards := buildCards(m.metrics, cardWidth, m.display)
+
+		var rendered []string
+		for i, c := range cards {
+			if i > 0 {
+				rendered = append(rendered, "")
+			}
+			rendered = append(rendered, renderCard(c, cardWidth, 0))
+		}
+		// Combine header, mole, and cards with consistent spacing
+		var content []string
+		content = append(content, m.headerLines(header)...)
+		if mole != "" {
+			content = append(content, mole)
+		}
+		content = append(content, lipgloss.JoinVertical(lipgloss.Left, rendered...))
+		content = append(content, "", m.footer())
+		return lipgloss.JoinVertical(lipgloss.Left, content...)
+	}
+
+	cardWidth := max(24, termWidth/2-4)
+	cards := buildCards(m.metrics, cardWidth, m.display)
+	twoCol := renderTwoColumns(cards, termWidth)
+	// Combine header, mole, and cards with consistent spacing
+	var content []string
+	content = append(content, m.headerLines(header)...)
+	if mole != "" {
+		content = append(content, mole)
+	}
+	content = append(content, twoCol, "", m.footer())
+	return lipgloss.JoinVertical(lipgloss.Left, content...)
+}
+
+// headerLines returns the header followed by the summary line when enabled.
+func (m model) headerLines(header string) []string {
+	if len(m.summary) == 0 {
+		return []string{header}
+	}
+	return []string{header, renderSummaryLine(m.metrics, m.summary)}
+}
+
+func (m model) footer() string {
+	if m.queryOpen {
+		prompt := primaryStyle.Render(":"+m.queryInput) + "▏"
+		if m.queryErr != "" {
+			prompt += " " + dangerStyle.Render(m.queryErr)
+		}
+		return prompt + subtleStyle.Render(" · enter runs, esc cancels")
+	}
+	now := time.Now()
+	footer := renderFooter(m.lastUpdated, now, cmp.Or(m.interval, refreshInterval))
+	if m.queryResult != "" {
+		footer += subtleStyle.Render(" · ") + m.queryResult
+	}
+	if m.adaptive != nil {
+		footer += subtleStyle.Render(" · every " + m.interval.String())
+	}
+	if m.layout != "" {
+		footer += subtleStyle.Render(" · layout " + m.layout)
+	}
+	if m.unfocused {
+		footer += subtleStyle.Render(" · paused while unfocused")
+	}
+	if behind := m.cadence.String(); behind != "" {
+		footer += subtleStyle.Render(" · ") + warnStyle.Render(behind)
+	}
+	if m.kiosk && now.Sub(m.kioskQuitAt) <= kioskQuitWindow {
+		footer += subtleStyle.Render(" · ") + warnStyle.Render("ctrl+c again to exit")
+	}
+	if m.frozen != nil {
+		at := m.frozen.snapshot.CollectedAt.Format("15:04:05")
+		footer += subtleStyle.Render(" · ") + dangerStyle.Render(fmt.Sprintf("Frozen on %s at %s, f resumes", m.frozen.reason, at))
+	}
+	if events := m.metrics.Events; len(events) > 0 {
+		if last := events[len(events)-1]; now.Sub(last.At) < eventNoteFor {
+			footer += subtleStyle.Render(" · ") + severityStyle(last.Severity).Render(last.Message) + subtleStyle.Render(" "+last.At.Format("15:04:05"))
+		}
+	}
+	if now.Before(m.refreshedUntil) {
+		footer += subtleStyle.Render(" · ") + okStyle.Render("refreshed")
+	}
+	if alert := renderAlerts(m.metrics, m.zombieThreshold, m.display.ephemeralAlert); alert != "" {
+		footer += subtleStyle.Render(" · ") + alert
+	}
+	return footer
+}
+
+// runQuery re-evaluates the standing query against the current snapshot.
+func (m *model) runQuery() {
+	if m.query == nil {
+		m.queryResult = ""
+		return
+	}
+	value, holds, err := m.query.eval(m.metrics)
+	switch {
+	case err != nil:
+		m.queryResult = warnStyle.Render(m.query.String() + ": " + err.Error())
+	case m.query.op == "":
+		m.queryResult = m.query.String() + " = " + primaryStyle.Render(value)
+	case holds:
+		m.queryResult = okStyle.Render("yes") + subtleStyle.Render(" "+m.query.String()+" ("+value+")")
+	default:
+		m.queryResult = dangerStyle.Render("no") + subtleStyle.Render(" "+m.query.String()+" ("+value+")")
+	}
+}
+
+// checkAlerts raises a notification when the zombie count or the ephemeral
+// port usage first crosses its threshold, and re-arms once it falls back.
+func (m *model) checkAlerts() tea.Cmd {
+	zombies := m.metrics.ProcessStates[procStateZombie]
+	zombieCmd := m.raiseAlert(&m.zombieAlerted, m.zombieThreshold > 0 && zombies > m.zombieThreshold,
+		func() string { return fmt.Sprintf("%d zombie processes", zombies) })
+	e := m.metrics.Connections.Ephemeral
+	ephemeralCmd := m.raiseAlert(&m.ephemeralAlerted, ephemeralOver(e, m.display.ephemeralAlert),
+		func() string { return ephemeralAlertText(e) })
+	return tea.Batch(zombieCmd, ephemeralCmd)
+}
+
+// raiseAlert logs the message and notifies once when over turns true, and
+// clears alerted when it turns false again.
+func (m *model) raiseAlert(alerted *bool, over bool, message func() string) tea.Cmd {
+	if !over {
+		*alerted = false
+		return nil
+	}
+	if *alerted {
+		return nil
+	}
+	*alerted = true
+	text := message()
+	m.events.add(severityWarn, categorySystem, text)
+	if !m.notify || m.quietHours.contains(time.Now()) {
+		return nil // The footer still shows it.
+	}
+	return notifyCmd("Mole", text+" on "+m.metrics.Host)
+}
+
+// eventLogKey handles keys while the event log panel is open: arrows scroll,
+// e or esc close it, q and ctrl+c still quit.
+func (m model) eventLogKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	rows := eventLogRows(m.height)
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "e", "esc":
+		m.showEvents = false
+	case "up":
+		m.eventScroll = clampEventScroll(m.eventScroll+1, len(m.events.recent()), rows)
+	case "down":
+		m.eventScroll = clampEventScroll(m.eventScroll-1, len(m.events.recent()), rows)
+	}
+	return m, nil
+}
+
+func (m model) savePrefs() {
+	savePrefs(statusPrefs{
+		catHidden:    m.catHidden,
+		hiddenIfaces: sortedKeys(m.display.hiddenIfaces),
+		pinnedIfaces: sortedKeys(m.display.pinned),
+		quietHours:   m.quietPref,
+		thresholds:   m.display.ifaceLevels,
+		layouts:      m.layouts,
+		layout:       m.layout,
+	})
+}
+
+func (m model) collectCmd() tea.Cmd {
+	// Copy the exclusion and pin lists now; the collector runs on another goroutine.
+	collector, local := localCollector(m.source)
+	// Before the first frame, take the baseline here so the dashboard opens
+	// with rates instead of a warming-up network panel.
+	prime := local && !m.ready && m.warmup > 0
+	rebaseline := local && m.rebaseline
+	trigger := m.trigger
+	var excluded map[string]bool
+	if m.display.excludeHidden {
+		excluded = toSet(sortedKeys(m.display.hiddenIfaces))
+	}
+	pinned := toSet(sortedKeys(m.display.pinned))
+	return func() tea.Msg {
+		if local {
+			collector.totalsExcluded = excluded
+			collector.pinned = pinned
+		}
+		if rebaseline {
+			collector.rebaseline()
+		}
+		if prime || rebaseline && m.warmup > 0 {
+			collector.prime(m.warmup)
+		}
+		data, err := m.source.Collect()
+		if werr := m.snapshots.maybeWrite(data); werr != nil {
+			if err == nil {
+				err = werr
+			} else {
+				err = fmt.Errorf("%v; %w", err, werr)
+			}
+		}
+		return metricsMsg{data: data, err: err, spike: trigger.check(data)}
+	}
+}
+
+func tickAfter(delay time.Duration, gen int) tea.Cmd {
+	return tea.Tick(delay, func(time.Time) tea.Msg { return tickMsg{gen: gen} })
+}
+
+func animTick() tea.Cmd {
+	return tea.Tick(200*time.Millisecond, func(time.Time) tea.Msg { return animTickMsg{} })
+}
+
+func animTickWithSpeed(cpuUsage float64) tea.Cmd {
+	// Higher CPU = faster animation.
+	interval := max(300-int(cpuUsage*2.5), 50)
+	return tea.Tick(time.Duration(interval)*time.Millisecond, func(time.Time) tea.Msg { return animTickMsg{} })
+}
+
+// runDashboard runs the interactive dashboard until quit, then prints the
+// session recap.
+func runDashboard(opts options, source snapshotSource) error {
+	programOpts := []tea.ProgramOption{tea.WithAltScreen()}
+	if opts.pauseUnfocused {
+		programOpts = append(programOpts, tea.WithReportFocus())
+	}
+	p := tea.NewProgram(newModel(opts, source), programOpts...)
+	final, err := p.Run()
+	// Printed after the alt screen is gone, so it stays in the scrollback.
+	if fm, ok := final.(model); ok && err == nil && !opts.noSummary {
+		fmt.Print(fm.session.render(time.Now()))
+	}
+	return err
+}
+
+// notifyCmd sends a notification off the UI goroutine; failures are ignored
+// since the alert is already visible in the footer.
+func notifyCmd(title, body string) tea.Cmd {
+	return func() tea.Msg {
+		_ = notify(context.Background(), title, body)
+		return nil
+	}
+}
+
+// checkMark colors a doctor result mark.
+func checkMark(r checkResult, mark string) string {
+	switch r {
+	case checkWarn:
+		return warnStyle.Render(mark)
+	case checkFail:
+		return dangerStyle.Render(mark)
+	}
+	return okStyle.Render(mark)
+}
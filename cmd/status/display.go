@@ -0,0 +1,307 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The display settings below are chosen by flags and the prefs file. They
+// live apart from the dashboard so the headless agent build (-tags agent),
+// which leaves out Bubble Tea and lipgloss, still parses the same options.
+
+// defaultKioskCycle is how long each panel keeps focus with --kiosk.
+const defaultKioskCycle = 10 * time.Second
+
+// diskSort orders the rows of the disk panel.
+type diskSort int
+
+const (
+	diskBySize  diskSort = iota // Internal first, then largest; the collector's order.
+	diskByFree                  // Least free space first, to spot nearly full disks.
+	diskByMount                 // Alphabetical by mount point.
+)
+
+var diskSortNames = []string{"size", "free", "mount"}
+
+func (s diskSort) String() string { return diskSortNames[s] }
+
+// next cycles to the following disk order.
+func (s diskSort) next() diskSort {
+	return (s + 1) % diskSort(len(diskSortNames))
+}
+
+func parseDiskSort(name string) (diskSort, error) {
+	for i, n := range diskSortNames {
+		if n == name {
+			return diskSort(i), nil
+		}
+	}
+	return diskBySize, fmt.Errorf("unknown --disk-sort %q (want %s)", name, strings.Join(diskSortNames, ", "))
+}
+
+// sortDisks returns disks in the requested order without touching the input.
+func sortDisks(disks []DiskStatus, by diskSort) []DiskStatus {
+	sorted := slices.Clone(disks)
+	switch by {
+	case diskByFree:
+		slices.SortStableFunc(sorted, func(a, b DiskStatus) int {
+			return cmp.Compare(a.Total-a.Used, b.Total-b.Used)
+		})
+	case diskByMount:
+		slices.SortStableFunc(sorted, func(a, b DiskStatus) int { return strings.Compare(a.Mount, b.Mount) })
+	}
+	return sorted
+}
+
+// connGroup picks how the connections panel buckets sockets.
+type connGroup int
+
+const (
+	connByState  connGroup = iota // ESTABLISHED, LISTEN, TIME_WAIT, ...
+	connByProto                   // TCP, UDP and their IPv6 variants.
+	connByRemote                  // Remote hosts with the most connections.
+)
+
+var connGroupNames = []string{"state", "proto", "remote"}
+
+func (g connGroup) String() string { return connGroupNames[g] }
+
+// next cycles to the following grouping.
+func (g connGroup) next() connGroup {
+	return (g + 1) % connGroup(len(connGroupNames))
+}
+
+func parseConnGroup(name string) (connGroup, error) {
+	for i, n := range connGroupNames {
+		if n == name {
+			return connGroup(i), nil
+		}
+	}
+	return connByState, fmt.Errorf("unknown --conn-group %q (want %s)", name, strings.Join(connGroupNames, ", "))
+}
+
+// ifaceGraphs picks whether interface rows get their own sparkline and how
+// those are scaled.
+type ifaceGraphs int
+
+const (
+	ifaceGraphsOff    ifaceGraphs = iota
+	ifaceGraphsOwn                // Each scaled to its own peak, so a quiet LAN still shows detail.
+	ifaceGraphsShared             // One scale for all, for comparing interfaces directly.
+)
+
+var ifaceGraphNames = []string{"off", "own", "shared"}
+
+func (g ifaceGraphs) String() string { return ifaceGraphNames[g] }
+
+// next cycles to the following mode.
+func (g ifaceGraphs) next() ifaceGraphs {
+	return (g + 1) % ifaceGraphs(len(ifaceGraphNames))
+}
+
+func parseIfaceGraphs(name string) (ifaceGraphs, error) {
+	for i, n := range ifaceGraphNames {
+		if n == name {
+			return ifaceGraphs(i), nil
+		}
+	}
+	return ifaceGraphsOff, fmt.Errorf("unknown --iface-graphs %q (want %s)", name, strings.Join(ifaceGraphNames, ", "))
+}
+
+// netColumnNames lists the columns the interface table can show (--net-columns).
+var netColumnNames = []string{"name", "ip", "rx", "tx", "total", "dir", "errors", "util", "kind"}
+
+// defaultNetColumns is the classic row: the name, download and upload.
+var defaultNetColumns = []string{"name", "rx", "tx"}
+
+// summaryFields lists the fields the dashboard summary line can show, in default order.
+var summaryFields = []string{"down", "up", "cpu", "mem", "conns", "proxy"}
+
+// Sparkline glyph sets, selected with --sparkline-style.
+const (
+	sparkBlocks  = "blocks"
+	sparkBraille = "braille"
+	sparkASCII   = "ascii"
+	sparkDigits  = "digits" // The latest values as numbers instead of a graph (n).
+)
+
+// showTrends draws the ▲/▼/▬ trend arrows next to CPU, memory and network
+// (t, --no-trends).
+var showTrends = true
+
+// sparkStyle is the active glyph set.
+var sparkStyle = sparkBlocks
+
+// sparkGlyphs map a value normalized to [0, 1] to a graph cell.
+var sparkGlyphs = map[string]func(float64) rune{
+	sparkBlocks:  blockGlyph,
+	sparkBraille: brailleGlyph,
+	sparkASCII:   asciiGlyph,
+	sparkDigits:  asciiGlyph, // For the histogram, which has no values to print.
+}
+
+var (
+	blockRunes   = []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+	brailleRunes = []rune{'⡀', '⣀', '⣄', '⣤', '⣦', '⣶', '⣷', '⣿'}
+	asciiRunes   = []rune{'_', '.', ',', '-', '~', '=', '*', '#'}
+)
+
+func blockGlyph(n float64) rune   { return glyphAt(blockRunes, n) }
+func brailleGlyph(n float64) rune { return glyphAt(brailleRunes, n) }
+func asciiGlyph(n float64) rune   { return glyphAt(asciiRunes, n) }
+
+// sparkWindow returns the most recent width points, left-padded with zeros.
+func sparkWindow(history []float64, width int) []float64 {
+	data := make([]float64, 0, width)
+	if len(history) > 0 {
+		// Take the most recent points.
+		start := 0
+		if len(history) > width {
+			start = len(history) - width
+		}
+		data = append(data, history[start:]...)
+	}
+	// padding with zeros at the start
+	for len(data) < width {
+		data = append([]float64{0}, data...)
+	}
+	if len(data) > width {
+		data = data[len(data)-width:]
+	}
+	return data
+}
+
+func glyphAt(runes []rune, n float64) rune {
+	if math.IsNaN(n) {
+		return runes[0] // Converting NaN to int is undefined; draw the baseline.
+	}
+	// Clamp before converting, which is just as undefined for ±Inf.
+	level := int(min(max(n, 0), 1) * float64(len(runes)-1))
+	return runes[level]
+}
+
+// asciiOutput swaps the dashboard's Unicode glyphs for ASCII ones (--ascii,
+// or a locale that is not UTF-8), for terminals that draw them as boxes.
+var asciiOutput bool
+
+// asciiReplacements stand in for the glyphs the dashboard draws, one
+// character for one so the layout keeps its widths.
+var asciiReplacements = map[rune]rune{
+	'·': '-', '↓': 'v', '↑': '^', '…': '.', '→': '>', '▲': '^', '▼': 'v', '▬': '=',
+	'⚠': '!', '▮': '#', '▯': '.', '█': '#', '░': '.', '▏': '|', '—': '-', '–': '-',
+	'−': '-', '°': ' ', '●': '*', '▸': '>', '▾': 'v', '╌': '-', '⚡': '+',
+	'├': '|', '└': '`',
+	'◉': '*', '◫': '*', '◧': '*', '▥': '*', '⇅': '*', '◪': '*', '◈': '*', '❊': '*',
+}
+
+// toASCII rewrites a rendered frame for asciiOutput. Block and braille
+// graph cells become the matching --sparkline-style ascii level; anything
+// else outside ASCII, such as a process name, becomes '?'.
+func toASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x80:
+			return r
+		case r >= '▁' && r <= '▇':
+			return asciiRunes[r-'▁']
+		case r >= '⠀' && r <= '⣿':
+			return asciiRunes[max(bits.OnesCount(uint(r-'⠀'))-1, 0)]
+		}
+		if a, ok := asciiReplacements[r]; ok {
+			return a
+		}
+		return '?'
+	}, s)
+}
+
+// localeIsUTF8 reports whether the locale the environment selects, the
+// first of LC_ALL, LC_CTYPE and LANG that is set, uses UTF-8. With none set
+// it assumes so: Windows never sets them, and neither do many SSH sessions
+// into terminals that cope fine.
+func localeIsUTF8(getenv func(string) string) bool {
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := getenv(key); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return true
+}
+
+// dashboardLayout is a named set of display settings from a layout.NAME=
+// line of the prefs file, switched to with L. Settings it leaves out stay as
+// they are when it is applied.
+type dashboardLayout struct {
+	name        string
+	spec        string   // As written, so savePrefs can write it back unchanged.
+	panels      []string // Panels left expanded, by card id; the rest collapse. Empty = all.
+	diskSort    *diskSort
+	connGroup   *connGroup
+	ifaceGraphs *ifaceGraphs
+	groupByKind *bool
+	procsByCPU  *bool
+	minRate     *float64
+}
+
+// parseLayout reads the space-separated settings of a layout, e.g.
+// "panels=network,connections disk_sort=free iface_graphs=own group=true
+// procs=cpu min_rate=0.01". panels=all expands every panel.
+func parseLayout(name, spec string) (dashboardLayout, error) {
+	l := dashboardLayout{name: name, spec: spec}
+	for _, field := range strings.Fields(spec) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return l, fmt.Errorf("layout %s: %q: want key=value", name, field)
+		}
+		var err error
+		switch key {
+		case "panels":
+			if value != "all" {
+				l.panels = splitList(value)
+			}
+		case "disk_sort":
+			var s diskSort
+			s, err = parseDiskSort(value)
+			l.diskSort = &s
+		case "conn_group":
+			var g connGroup
+			g, err = parseConnGroup(value)
+			l.connGroup = &g
+		case "iface_graphs":
+			var g ifaceGraphs
+			g, err = parseIfaceGraphs(value)
+			l.ifaceGraphs = &g
+		case "group":
+			group := value == "true"
+			if !group && value != "false" {
+				err = fmt.Errorf("group %q: want true or false", value)
+			}
+			l.groupByKind = &group
+		case "procs":
+			byCPU := value == "cpu"
+			if !byCPU && value != "memory" {
+				err = fmt.Errorf("procs %q: want cpu or memory", value)
+			}
+			l.procsByCPU = &byCPU
+		case "min_rate":
+			var rate float64
+			rate, err = strconv.ParseFloat(value, 64)
+			if err == nil && rate < 0 {
+				err = fmt.Errorf("min_rate %q must not be negative", value)
+			}
+			l.minRate = &rate
+		default:
+			err = fmt.Errorf("unknown setting %q (want panels, disk_sort, conn_group, iface_graphs, group, procs or min_rate)", key)
+		}
+		if err != nil {
+			return l, fmt.Errorf("layout %s: %w", name, err)
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// checkResult grades one mo status doctor check.
+type checkResult int
+
+const (
+	checkPass checkResult = iota
+	checkWarn             // Works partially, or an optional panel will be empty.
+	checkFail             // A core collector is broken; the dashboard is unusable.
+)
+
+func (r checkResult) String() string {
+	switch r {
+	case checkWarn:
+		return "warn"
+	case checkFail:
+		return "fail"
+	default:
+		return "pass"
+	}
+}
+
+type doctorCheck struct {
+	name   string
+	result checkResult
+	detail string
+}
+
+// doctorTool is an external command an optional collector shells out to.
+type doctorTool struct {
+	name string
+	goos string // Only checked on this platform; empty = everywhere but Windows.
+	use  string
+}
+
+var doctorTools = []doctorTool{
+	{"ps", "", "CPU fallback, process states"},
+	{"ping", "", "ICMP for --ping"},
+	{"scutil", "darwin", "system proxy"},
+	{"gsettings", "linux", "system proxy (GNOME)"},
+	{"reg", "windows", "system proxy"},
+	{"pmset", "darwin", "battery"},
+	{"system_profiler", "darwin", "GPU, Bluetooth, hardware"},
+	{"diskutil", "darwin", "external disk detection"},
+	{"vm_stat", "darwin", "memory breakdown"},
+	{"memory_pressure", "darwin", "memory pressure"},
+	{"powermetrics", "darwin", "thermal pressure (as root)"},
+	{"nvidia-smi", "linux", "NVIDIA GPU"},
+	{"bluetoothctl", "linux", "Bluetooth"},
+	{"notify-send", "linux", "--notify"},
+	{"nft", "linux", "--cgroup-traffic"},
+	{"git", "", "--app-proxies"},
+}
+
+// runDoctor prints which collectors will work on this machine. It returns an
+// error when a core collector (CPU, memory, network, disks) is broken.
+func runDoctor(w io.Writer, helper *helperClient) error {
+	checks := doctorChecks(context.Background(), helper)
+	fmt.Fprint(w, formatDoctor(checks))
+	var broken []string
+	for _, c := range checks {
+		if c.result == checkFail {
+			broken = append(broken, c.name)
+		}
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("broken: %s", strings.Join(broken, ", "))
+	}
+	return nil
+}
+
+func doctorChecks(ctx context.Context, helper *helperClient) []doctorCheck {
+	ctx = withCmdTimeout(ctx, defaultCmdTimeout)
+	check := func(name string, err error, failLevel checkResult, ok string) doctorCheck {
+		if err != nil {
+			return doctorCheck{name: name, result: failLevel, detail: err.Error()}
+		}
+		return doctorCheck{name: name, result: checkPass, detail: ok}
+	}
+
+	var checks []doctorCheck
+	_, err := collectCPU(ctx)
+	checks = append(checks, check("CPU", err, checkFail, "usage readable"))
+	_, err = collectMemory(ctx)
+	checks = append(checks, check("Memory", err, checkFail, "usage readable"))
+
+	counters, err := netIOCounters(true)
+	if err == nil && len(counters) == 0 {
+		err = errors.New("no interface counters")
+	}
+	checks = append(checks, check("Network", err, checkFail, fmt.Sprintf("%d interface counters", len(counters))))
+
+	disks, err := collectDisks(0)
+	if err == nil && len(disks) == 0 {
+		checks = append(checks, doctorCheck{name: "Disks", result: checkWarn, detail: "no volumes over 1 GB found"})
+	} else {
+		checks = append(checks, check("Disks", err, checkFail, fmt.Sprintf("%d volumes", len(disks))))
+	}
+
+	conns, err := collectConnections()
+	checks = append(checks, check("Connections", err, checkWarn, fmt.Sprintf("%d sockets visible", conns.Total)))
+	if err == nil && runtime.GOOS == "linux" && os.Geteuid() != 0 {
+		checks[len(checks)-1].detail += " (run as root to see every user's)"
+	}
+
+	if helper != nil {
+		resp, err := helper.call("ping")
+		c := check("Helper", err, checkWarn, "")
+		if err == nil && resp.Ping != nil {
+			c.detail = fmt.Sprintf("pid %d on %s", resp.Ping.PID, helper.path)
+			if resp.Ping.UID != 0 {
+				c.result, c.detail = checkWarn, c.detail+" is not running as root"
+			}
+		}
+		checks = append(checks, c)
+	}
+
+	_, err = collectProcessStates(ctx)
+	checks = append(checks, check("Process states", err, checkWarn, "zombie alerts available"))
+
+	for _, tool := range doctorTools {
+		if tool.goos != "" && tool.goos != runtime.GOOS || tool.goos == "" && runtime.GOOS == "windows" {
+			continue
+		}
+		c := doctorCheck{name: tool.name, result: checkPass, detail: tool.use}
+		if !commandExists(tool.name) {
+			c.result = checkWarn
+			c.detail = "not found; no " + tool.use
+		}
+		checks = append(checks, c)
+	}
+
+	tty := doctorCheck{name: "Terminal", result: checkPass, detail: "stdout is a terminal"}
+	if !isTerminal(os.Stdout) {
+		tty = doctorCheck{name: "Terminal", result: checkWarn, detail: "stdout is not a terminal; the dashboard falls back to --line"}
+	}
+	return append(checks, tty)
+}
+
+// formatDoctor renders the checklist, one check per line.
+func formatDoctor(checks []doctorCheck) string {
+	var b strings.Builder
+	for _, c := range checks {
+		mark := "[pass]"
+		switch c.result {
+		case checkWarn:
+			mark = "[warn]"
+		case checkFail:
+			mark = "[fail]"
+		}
+		fmt.Fprintf(&b, "%s %-16s %s\n", checkMark(c.result, mark), c.name, c.detail)
+	}
+	return b.String()
+}
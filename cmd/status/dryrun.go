@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// prefsKeys are the plain keys the prefs file may hold; thresholds.<iface>,
+// layout.<name> and group.<name> entries are checked separately.
+var prefsKeys = []string{"cat_hidden", "hidden_ifaces", "pinned_ifaces", "quiet_hours", "layout"}
+
+// runDryRun validates the configuration without collecting anything
+// (--dry-run). Flags were already checked by parseOptions; this adds the
+// prefs file, the interfaces settings name and the addresses and paths the
+// outputs would use. Any problem makes it fail.
+func runDryRun(w io.Writer, opts options) error {
+	var prefsText string
+	if path := getConfigPath(); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			prefsText = string(data)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("prefs file: %w", err)
+		}
+	}
+	var ifaces []string
+	// Interfaces elsewhere, or in another namespace, are not ours to list.
+	if opts.sourceURL == "" && opts.netns == "" {
+		list, err := net.Interfaces()
+		if err != nil {
+			return err
+		}
+		for _, iface := range list {
+			ifaces = append(ifaces, iface.Name)
+		}
+	}
+	problems, warnings := dryRunCheck(opts, prefsText, ifaces)
+	for _, warning := range warnings {
+		fmt.Fprintln(w, "warning: "+warning)
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(w, "problem: "+problem)
+	}
+	switch len(problems) {
+	case 0:
+		fmt.Fprintln(w, "configuration OK")
+		return nil
+	case 1:
+		return fmt.Errorf("1 problem")
+	}
+	return fmt.Errorf("%d problems", len(problems))
+}
+
+// dryRunCheck returns what would fail at startup or later, and what merely
+// looks stale. ifaces lists the interfaces present; nil skips those checks.
+// Interfaces named in the prefs file only warn: hiding or pinning one that is
+// away, such as a VPN that is down, is normal.
+func dryRunCheck(opts options, prefsText string, ifaces []string) (problems, warnings []string) {
+	problemf := func(format string, args ...any) { problems = append(problems, fmt.Sprintf(format, args...)) }
+
+	for _, addr := range []struct{ flag, value string }{
+		{"--listen", opts.listenAddr},
+		{"--statsd", opts.statsdAddr},
+		{"--speedtest-serve", opts.speedtestServe},
+	} {
+		if addr.value == "" {
+			continue
+		}
+		if err := checkHostPort(addr.value); err != nil {
+			problemf("%s %q: %v", addr.flag, addr.value, err)
+		}
+	}
+	if t := opts.speedtest; t != "" && !strings.HasPrefix(t, "http://") && !strings.HasPrefix(t, "https://") {
+		if err := checkHostPort(t); err != nil {
+			problemf("--speedtest %q: %v (want host:port or an http(s) URL)", t, err)
+		}
+	}
+	if opts.sourceURL != "" {
+		if _, err := newRemoteSource(opts.sourceURL); err != nil {
+			problemf("%v", err)
+		}
+	}
+
+	for _, file := range []struct{ flag, path string }{
+		{"--record", opts.record},
+		{"--log-csv", opts.logCSV},
+		{"--history-csv", opts.historyCSV},
+		{"--log-events", opts.logEvents},
+		{"--debug-net", opts.debugNet},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if err := checkDir(filepath.Dir(file.path)); err != nil {
+			problemf("%s %s: %v", file.flag, file.path, err)
+		}
+	}
+	if opts.snapshotEvery > 0 {
+		if err := checkDir(opts.snapshotDir); err != nil {
+			problemf("--snapshot-dir %s: %v", opts.snapshotDir, err)
+		}
+	}
+	if opts.helper {
+		if err := checkDir(filepath.Dir(opts.helperSocket)); err != nil {
+			problemf("--helper-socket %s: %v", opts.helperSocket, err)
+		}
+	}
+
+	var layouts []string
+	for i, line := range strings.Split(prefsText, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		at := fmt.Sprintf("prefs line %d", i+1)
+		if iface, isThreshold := strings.CutPrefix(key, "thresholds."); ok && isThreshold && iface != "" {
+			if _, err := parseRateThresholds(value); err != nil {
+				problemf("%s: thresholds.%s: %v", at, iface, err)
+			}
+			continue
+		}
+		if name, isLayout := strings.CutPrefix(key, "layout."); ok && isLayout && name != "" {
+			if _, err := parseLayout(name, value); err != nil {
+				problemf("%s: layout.%s: %v", at, name, err)
+			}
+			layouts = append(layouts, name)
+			continue
+		}
+		if name, isGroup := strings.CutPrefix(key, "group."); ok && isGroup && name != "" {
+			if _, err := parseIfaceGroup(name, value); err != nil {
+				problemf("%s: %v", at, err)
+			}
+			continue
+		}
+		switch {
+		case !ok:
+			problemf("%s: %q is not key=value", at, line)
+		case !slices.Contains(prefsKeys, key):
+			problemf("%s: unknown key %q", at, key)
+		case key == "quiet_hours":
+			if _, err := parseQuietHours(value); err != nil {
+				problemf("%s: quiet_hours: %v", at, err)
+			}
+		case key == "cat_hidden" && value != "true" && value != "false":
+			problemf("%s: cat_hidden is %q, want true or false", at, value)
+		}
+	}
+	prefs := parsePrefs(prefsText)
+	if prefs.layout != "" && !slices.Contains(layouts, prefs.layout) {
+		problemf("prefs: layout=%s names no layout.%s entry", prefs.layout, prefs.layout)
+	}
+
+	if ifaces != nil {
+		for _, name := range opts.totalsExclude {
+			if !slices.Contains(ifaces, name) {
+				problemf("--totals-exclude: no interface %q here (have %s)", name, strings.Join(ifaces, ", "))
+			}
+		}
+		named := slices.Concat(prefs.hiddenIfaces, prefs.pinnedIfaces, slices.Sorted(maps.Keys(prefs.thresholds)))
+		for _, name := range slices.Compact(slices.Sorted(slices.Values(named))) {
+			if !slices.Contains(ifaces, name) {
+				warnings = append(warnings, fmt.Sprintf("prefs name %q, an interface not present now", name))
+			}
+		}
+	}
+	return problems, warnings
+}
+
+// checkHostPort checks a host:port listen or dial address, with the host
+// optional and the port a number or a service name.
+func checkHostPort(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err == nil {
+		if n < 0 || n > 65535 {
+			return fmt.Errorf("port %d out of range", n)
+		}
+		return nil
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return fmt.Errorf("unknown port %q", port)
+	}
+	return nil
+}
+
+// checkDir checks that dir exists and is a directory.
+func checkDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventRingSize bounds how many recent events the collector remembers.
+const eventRingSize = 50
+
+// Event severities, lowest first.
+const (
+	severityInfo  = "info"
+	severityWarn  = "warn"
+	severityError = "error"
+)
+
+// Event categories, by the part of the system that raised them.
+const (
+	categoryNetwork = "network"
+	categoryProxy   = "proxy"
+	categorySystem  = "system"
+)
+
+// StatusEvent is a notable change seen while collecting, such as an
+// interface coming up. The latest is shown in the dashboard footer and the
+// rest in the event log panel (e).
+type StatusEvent struct {
+	At       time.Time `json:"at"`
+	Severity string    `json:"severity"` // info, warn or error.
+	Category string    `json:"category"` // network, proxy or system.
+	Message  string    `json:"message"`
+}
+
+// eventRing keeps the most recent events, oldest first, and appends each one
+// to log as a JSON line when set (--log-events). Collectors run concurrently,
+// so access is locked.
+type eventRing struct {
+	mu     sync.Mutex
+	events []StatusEvent
+	log    io.Writer
+}
+
+func (r *eventRing) add(severity, category, msg string) {
+	r.addEvent(StatusEvent{At: time.Now(), Severity: severity, Category: category, Message: msg})
+}
+
+// addEvent records ev as it is, keeping its time: one relayed from a remote
+// agent happened there, not now.
+func (r *eventRing) addEvent(ev StatusEvent) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+	if len(r.events) > eventRingSize {
+		r.events = r.events[len(r.events)-eventRingSize:]
+	}
+	if r.log != nil {
+		// A full disk should not take the dashboard down; the event is
+		// still in the ring.
+		line, _ := json.Marshal(ev)
+		_, _ = r.log.Write(append(line, '\n'))
+	}
+}
+
+// recent returns a copy of the remembered events.
+func (r *eventRing) recent() []StatusEvent {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]StatusEvent(nil), r.events...)
+}
+
+// setLog starts appending events to w.
+func (r *eventRing) setLog(w io.Writer) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log = w
+}
+
+// eventsOf returns the event log behind src, or nil if it keeps none.
+func eventsOf(src snapshotSource) *eventRing {
+	if s, ok := src.(sinkSource); ok {
+		src = s.snapshotSource
+	}
+	switch s := src.(type) {
+	case *Collector:
+		return s.events
+	case *remoteSource:
+		return s.events
+	}
+	return nil
+}
+
+// Sustained CPU load worth an event: above cpuHotPercent for cpuHotFor.
+const (
+	cpuHotPercent = 95
+	cpuHotFor     = 30 * time.Second
+)
+
+// watchCPU raises one event once usage has stayed above cpuHotPercent for
+// cpuHotFor, and re-arms when it drops back.
+func (c *Collector) watchCPU(now time.Time, usage float64) {
+	if usage < cpuHotPercent {
+		c.cpuHotSince, c.cpuHotRaised = time.Time{}, false
+		return
+	}
+	if c.cpuHotSince.IsZero() {
+		c.cpuHotSince = now
+	}
+	if !c.cpuHotRaised && now.Sub(c.cpuHotSince) >= cpuHotFor {
+		c.cpuHotRaised = true
+		c.events.add(severityWarn, categorySystem, "CPU above 95% for 30s")
+	}
+}
+
+// watchThermal records the CPU starting and stopping being thermally
+// throttled.
+func (c *Collector) watchThermal(t ThermalStatus) {
+	if t.Throttled == c.throttling {
+		return
+	}
+	c.throttling = t.Throttled
+	if !t.Throttled {
+		c.events.add(severityInfo, categorySystem, "CPU no longer thermally throttled")
+		return
+	}
+	msg := "CPU thermally throttled"
+	if t.CPUSpeedLimit > 0 && t.CPUSpeedLimit < 100 {
+		msg += fmt.Sprintf(", speed limited to %d%%", t.CPUSpeedLimit)
+	} else if t.ThermalPressure != "" {
+		msg += ", pressure " + t.ThermalPressure
+	}
+	c.events.add(severityWarn, categorySystem, msg)
+}
+
+// watchProxy records the system proxy being switched on, off or elsewhere.
+func (c *Collector) watchProxy(p ProxyStatus) {
+	key := ""
+	if p.Enabled {
+		key = p.Type + " " + p.Host
+	}
+	if !c.proxySeen {
+		c.proxySeen, c.lastProxy = true, key
+		return
+	}
+	if key == c.lastProxy {
+		return
+	}
+	c.lastProxy = key
+	if key == "" {
+		c.events.add(severityInfo, categoryProxy, "proxy disabled")
+	} else {
+		c.events.add(severityInfo, categoryProxy, "proxy enabled: "+key)
+	}
+}
+
+// watchContainer records the --container target stopping, starting or
+// disappearing. An empty state (Docker unreachable) is not a transition.
+func (c *Collector) watchContainer(state string) {
+	if state == "" || state == c.containerState {
+		return
+	}
+	prev := c.containerState
+	c.containerState = state
+	switch {
+	case prev == "":
+		return // First sighting sets the baseline.
+	case state == "running":
+		c.events.add(severityInfo, categorySystem, "container "+c.containerName+" running")
+	case state == containerGone:
+		c.events.add(severityWarn, categorySystem, "container "+c.containerName+" removed")
+	default:
+		c.events.add(severityWarn, categorySystem, "container "+c.containerName+" "+state)
+	}
+}
+
+// watchUnit records the --unit service starting, stopping or vanishing.
+func (c *Collector) watchUnit(state string) {
+	if state == "" || state == c.unitState {
+		return
+	}
+	prev := c.unitState
+	c.unitState = state
+	switch {
+	case prev == "":
+		return // First sighting sets the baseline.
+	case state == "active/running":
+		c.events.add(severityInfo, categorySystem, "unit "+c.unitName+" running")
+	case state == unitGone:
+		c.events.add(severityWarn, categorySystem, "unit "+c.unitName+" not found")
+	default:
+		c.events.add(severityWarn, categorySystem, "unit "+c.unitName+" "+state)
+	}
+}
+
+// watchRoutes records a jump of routeJump or more in the route count, the
+// usual footprint of a VPN connecting or dropping.
+// A zero count is unknown and keeps the previous one as the baseline.
+func (c *Collector) watchRoutes(n int) {
+	if n == 0 {
+		return
+	}
+	prev := c.lastRoutes
+	c.lastRoutes = n
+	if prev == 0 || max(n-prev, prev-n) < routeJump {
+		return
+	}
+	msg := fmt.Sprintf("routes %d → %d", prev, n)
+	if c.vpnIface != "" {
+		msg += " with " + c.vpnIface + " up"
+	}
+	c.events.add(severityInfo, categoryNetwork, msg)
+}
+
+// watchGateways records each gateway becoming unreachable and recovering.
+func (c *Collector) watchGateways(gateways map[string]GatewayStatus) {
+	if c.gatewayDown == nil {
+		c.gatewayDown = make(map[string]bool)
+	}
+	for iface, gw := range gateways {
+		down := !gw.Reachable
+		if down == c.gatewayDown[iface] {
+			continue
+		}
+		c.gatewayDown[iface] = down
+		if down {
+			c.events.add(severityError, categoryNetwork, "gateway "+gw.IP+" on "+iface+" unreachable")
+		} else {
+			c.events.add(severityInfo, categoryNetwork, "gateway "+gw.IP+" on "+iface+" reachable again")
+		}
+	}
+}
+
+// An interface that goes up or down flapTransitions times within flapWindow
+// is flapping (a bad cable, a port renegotiating): its transitions collapse
+// into one event until it has been steady for a whole window.
+const (
+	flapWindow      = 60 * time.Second
+	flapTransitions = 4
+)
+
+// ifaceFlaps is the recent up/down history of one interface.
+type ifaceFlaps struct {
+	at       []time.Duration // Transitions within flapWindow, by collector tick.
+	flapping bool
+	total    int // Transitions since it started flapping.
+}
+
+// watchLink logs an interface coming up or going down at tick, unless it is
+// flapping, which is logged once as "en5 flapping (4 transitions in 60s)".
+func (c *Collector) watchLink(tick time.Duration, name string, up bool) {
+	if c.linkFlaps == nil {
+		c.linkFlaps = make(map[string]*ifaceFlaps)
+	}
+	f := c.linkFlaps[name]
+	if f == nil {
+		f = &ifaceFlaps{}
+		c.linkFlaps[name] = f
+	}
+	f.at = append(slices.DeleteFunc(f.at, func(t time.Duration) bool { return tick-t >= flapWindow }), tick)
+	switch {
+	case f.flapping:
+		f.total++
+	case len(f.at) >= flapTransitions:
+		f.flapping, f.total = true, len(f.at)
+		c.events.add(severityWarn, categoryNetwork, fmt.Sprintf("%s flapping (%d transitions in %ds)", name, len(f.at), int(flapWindow.Seconds())))
+	case up:
+		c.events.add(severityInfo, categoryNetwork, "interface up: "+name)
+	default:
+		c.events.add(severityWarn, categoryNetwork, "interface down: "+name)
+	}
+}
+
+// settleLinks ends the flapping of interfaces with no transition for a full
+// flapWindow, logging how many it went through, and forgets quiet ones.
+func (c *Collector) settleLinks(tick time.Duration) {
+	for name, f := range c.linkFlaps {
+		if len(f.at) > 0 && tick-f.at[len(f.at)-1] < flapWindow {
+			continue
+		}
+		if f.flapping {
+			c.events.add(severityInfo, categoryNetwork, fmt.Sprintf("%s stopped flapping after %d transitions", name, f.total))
+		}
+		delete(c.linkFlaps, name)
+	}
+}
+
+// arpSighting is the MAC history of one neighbor entry, for --arp-check.
+type arpSighting struct {
+	mac, prevMAC string
+	changedAt    time.Time
+	flappedAt    time.Time // Last swing back to prevMAC; zero if none.
+}
+
+// watchARP logs address conflicts from an --arp-check scan: addresses the
+// system or arping flagged, and neighbor entries that swing back to a MAC
+// they had within arpFlapWindow. Each is logged once while it persists.
+func (c *Collector) watchARP(scan arpScan) {
+	if c.arpMACs == nil {
+		c.arpMACs = make(map[string]arpSighting)
+	}
+	active := make(map[string]bool)
+	raise := func(key, msg string) {
+		active[key] = true
+		if !c.arpRaised[key] {
+			c.events.add(severityWarn, categoryNetwork, msg)
+		}
+	}
+	for _, msg := range scan.conflicts {
+		raise(msg, msg)
+	}
+	for key, mac := range scan.macs {
+		s, seen := c.arpMACs[key]
+		if !seen {
+			s.mac = mac
+		} else if mac != s.mac {
+			if mac == s.prevMAC && scan.at.Sub(s.changedAt) < arpFlapWindow {
+				s.flappedAt = scan.at
+			}
+			s.prevMAC, s.mac, s.changedAt = s.mac, mac, scan.at
+		}
+		c.arpMACs[key] = s
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.arpMACs)) {
+		if s := c.arpMACs[key]; !s.flappedAt.IsZero() && scan.at.Sub(s.flappedAt) < arpFlapWindow {
+			ip, iface, _ := strings.Cut(key, "%")
+			raise("flap "+key, "IP conflict: "+ip+" on "+iface+" answered by "+s.prevMAC+" and "+s.mac)
+		}
+	}
+	c.arpRaised = active
+}
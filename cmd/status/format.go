@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// valuePrecision is the number of decimal places used for rates and percentages.
+// A negative value keeps the default per-field precision.
+var valuePrecision = -1
+
+// formatPercent renders a percentage right-aligned to the width of "100" plus decimals.
+func formatPercent(v float64) string {
+	if valuePrecision < 0 {
+		return fmt.Sprintf("%5.1f%%", v)
+	}
+	width := 3
+	if valuePrecision > 0 {
+		width += valuePrecision + 1
+	}
+	return fmt.Sprintf("%*.*f%%", width, valuePrecision, v)
+}
+
+// formatIORate renders a disk throughput in MB/s.
+func formatIORate(mb float64) string {
+	if valuePrecision < 0 {
+		return fmt.Sprintf("%.1f MB/s", mb)
+	}
+	return fmt.Sprintf("%.*f MB/s", valuePrecision, mb)
+}
+
+// Rate units selected with --rate-units.
+const (
+	rateUnitsAuto = "auto" // B/s up to GB/s, whichever fits the value.
+	rateUnitsMB   = "mb"   // Always MB/s.
+)
+
+// adaptiveRateUnits makes formatRate pick the unit per value (--rate-units
+// auto) instead of printing every rate in MB/s.
+var adaptiveRateUnits bool
+
+// formatRate renders a rate given in MB/s, the unit NetworkStatus keeps.
+func formatRate(mb float64) string {
+	if adaptiveRateUnits {
+		return formatByteRate(mb * 1024 * 1024)
+	}
+	if valuePrecision >= 0 {
+		text := strconv.FormatFloat(mb, 'f', valuePrecision, 64)
+		if strings.Trim(text, "0.") == "" {
+			return "0 MB/s"
+		}
+		return text + " MB/s"
+	}
+	if mb < 0.01 {
+		return "0 MB/s"
+	}
+	if mb < 1 {
+		return fmt.Sprintf("%.2f MB/s", mb)
+	}
+	if mb < 10 {
+		return fmt.Sprintf("%.1f MB/s", mb)
+	}
+	return fmt.Sprintf("%.0f MB/s", mb)
+}
+
+// byteRateUnits are the formatByteRate suffixes, each 1024 times the previous.
+var byteRateUnits = []string{"B/s", "KB/s", "MB/s", "GB/s"}
+
+// formatByteRate renders bytes per second in the largest unit that keeps
+// the value at 1 or more, so an idle SSH session reads "180 B/s" rather than
+// "0 MB/s": "300 KB/s", "1.5 MB/s". Like formatBytes it shows one decimal
+// below 10 and whole numbers above, or --precision decimals; bytes are
+// always whole. Rates past the GB/s range stay in GB/s.
+func formatByteRate(bps float64) string {
+	if bps < 1 {
+		return "0 B/s"
+	}
+	v, unit := bps, 0
+	for unit < len(byteRateUnits)-1 && v >= 1024 {
+		v /= 1024
+		unit++
+	}
+	// Round first so 1023.96 KB/s reads "1.0 MB/s" rather than "1024 KB/s".
+	if unit < len(byteRateUnits)-1 && math.Round(v) >= 1024 {
+		v /= 1024
+		unit++
+	}
+	switch {
+	case unit == 0:
+		return fmt.Sprintf("%.0f %s", v, byteRateUnits[unit])
+	case valuePrecision >= 0:
+		return strconv.FormatFloat(v, 'f', valuePrecision, 64) + " " + byteRateUnits[unit]
+	case math.Round(v*10)/10 < 10:
+		return fmt.Sprintf("%.1f %s", v, byteRateUnits[unit])
+	}
+	return fmt.Sprintf("%.0f %s", v, byteRateUnits[unit])
+}
+
+// byteUnits are the formatBytes suffixes, each 1024 times the previous.
+var byteUnits = []string{"B", "KB", "MB", "GB", "TB"}
+
+// formatBytes renders a cumulative byte count in powers of 1024, e.g. "1.2 GB"
+// or "340 MB": one decimal below 10, whole numbers above. Counts past the TB
+// range stay in TB. Rates use formatRate instead.
+func formatBytes(n uint64) string {
+	if n < 1024 {
+		return strconv.FormatUint(n, 10) + " B"
+	}
+	v := float64(n)
+	unit := 0
+	for unit < len(byteUnits)-1 && v >= 1024 {
+		v /= 1024
+		unit++
+	}
+	// Round first so 1023.96 KB reads "1.0 MB" rather than "1024 KB".
+	if unit < len(byteUnits)-1 && math.Round(v) >= 1024 {
+		v /= 1024
+		unit++
+	}
+	if math.Round(v*10)/10 < 10 {
+		return fmt.Sprintf("%.1f %s", v, byteUnits[unit])
+	}
+	return fmt.Sprintf("%.0f %s", v, byteUnits[unit])
+}
+
+func humanBytes(v uint64) string {
+	switch {
+	case v > 1<<40:
+		return fmt.Sprintf("%.1f TB", float64(v)/(1<<40))
+	case v > 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(v)/(1<<30))
+	case v > 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(v)/(1<<20))
+	case v > 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(v)/(1<<10))
+	default:
+		return strconv.FormatUint(v, 10) + " B"
+	}
+}
+
+func humanBytesShort(v uint64) string {
+	switch {
+	case v >= 1<<40:
+		return fmt.Sprintf("%.0fT", float64(v)/(1<<40))
+	case v >= 1<<30:
+		return fmt.Sprintf("%.0fG", float64(v)/(1<<30))
+	case v >= 1<<20:
+		return fmt.Sprintf("%.0fM", float64(v)/(1<<20))
+	case v >= 1<<10:
+		return fmt.Sprintf("%.0fK", float64(v)/(1<<10))
+	default:
+		return strconv.FormatUint(v, 10)
+	}
+}
+
+func humanBytesCompact(v uint64) string {
+	switch {
+	case v >= 1<<40:
+		return fmt.Sprintf("%.1fT", float64(v)/(1<<40))
+	case v >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(v)/(1<<30))
+	case v >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(v)/(1<<20))
+	case v >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(v)/(1<<10))
+	default:
+		return strconv.FormatUint(v, 10)
+	}
+}
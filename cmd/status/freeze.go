@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// spikeTrigger pauses the dashboard on the first sample that crosses a
+// threshold, like an oscilloscope trigger (--freeze-cpu, --freeze-rate).
+// Zero disables a threshold.
+type spikeTrigger struct {
+	cpu  float64 // CPU usage percent.
+	rate float64 // Any interface's receive or send rate, MB/s.
+}
+
+// check returns why s trips the trigger, or "" when it doesn't.
+func (t spikeTrigger) check(s MetricsSnapshot) string {
+	if t.cpu > 0 && s.CPU.Usage >= t.cpu {
+		return "CPU " + strings.TrimSpace(formatPercent(s.CPU.Usage))
+	}
+	if t.rate > 0 {
+		for _, n := range s.Network {
+			switch {
+			case n.RxRateMBs >= t.rate:
+				return fmt.Sprintf("%s ↓ %s", n.Name, formatRate(n.RxRateMBs))
+			case n.TxRateMBs >= t.rate:
+				return fmt.Sprintf("%s ↑ %s", n.Name, formatRate(n.TxRateMBs))
+			}
+		}
+	}
+	return ""
+}
+
+// spikeCapture is the sample that tripped the trigger. Its history slices are
+// copies, so the lead-up stays on screen while collection carries on.
+type spikeCapture struct {
+	snapshot MetricsSnapshot
+	reason   string
+}
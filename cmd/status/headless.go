@@ -0,0 +1,16 @@
+//go:build agent
+
+package main
+
+import "errors"
+
+// The agent build (go build -tags agent) leaves out the dashboard and with it
+// Bubble Tea and lipgloss; the collectors, mo status agent and the --json,
+// --flat, --line and --influx-lp outputs work as in the full build.
+
+func runDashboard(options, snapshotSource) error {
+	return errors.New("this build has no dashboard (built with -tags agent); use mo status agent, --line or --json")
+}
+
+// checkMark leaves doctor marks plain; there are no styles to apply.
+func checkMark(_ checkResult, mark string) string { return mark }
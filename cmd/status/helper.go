@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultHelperSocket is where `mo status helper` listens and the dashboard
+// looks for it unless --helper-socket says otherwise.
+const defaultHelperSocket = "/var/run/mo-status.sock"
+
+const (
+	helperVersion     = 1
+	helperDialTimeout = 500 * time.Millisecond
+	helperCallTimeout = 5 * time.Second // powermetrics alone takes about a second.
+	helperIdleTimeout = 30 * time.Second
+	helperMaxConns    = 8           // Connections served at once; more wait to be accepted.
+	helperCacheTTL    = time.Second // A collector's answer is reused this long.
+)
+
+// The helper protocol is one JSON object per line each way over the socket.
+// A request names one collector:
+//
+//	{"v":1,"collect":"connections"}
+//
+// and the response carries its result under the same name, or an error:
+//
+//	{"v":1,"connections":{"total":42,...}}
+//	{"v":1,"error":"unknown collector \"disks\""}
+//
+// Collectors are "connections" (ConnectionStatus, with every user's sockets
+// and listener processes), "thermal" (powermetrics pressure, macOS), "cgroup"
+// (nftables cgroup counters, Linux) and "ping" (the helper's pid and uid).
+// A client may send several requests on one connection.
+type helperRequest struct {
+	V       int    `json:"v"`
+	Collect string `json:"collect"`
+}
+
+type helperResponse struct {
+	V           int                       `json:"v"`
+	Error       string                    `json:"error,omitempty"`
+	Connections *ConnectionStatus         `json:"connections,omitempty"`
+	Thermal     *helperThermal            `json:"thermal,omitempty"`
+	Cgroup      map[string]helperCounters `json:"cgroup,omitempty"`
+	Ping        *helperPing               `json:"ping,omitempty"`
+}
+
+type helperThermal struct {
+	Level      string `json:"level"`
+	SpeedLimit int    `json:"speed_limit,omitempty"`
+}
+
+type helperCounters struct {
+	Rx uint64 `json:"rx"`
+	Tx uint64 `json:"tx"`
+}
+
+type helperPing struct {
+	PID int `json:"pid"`
+	UID int `json:"uid"`
+}
+
+// helperCollectors answer each request; swapped for fakes in tests.
+var helperCollectors = map[string]func(context.Context, *helperResponse) error{
+	"connections": func(_ context.Context, r *helperResponse) error {
+		conns, err := collectConnections()
+		r.Connections = &conns
+		return err
+	},
+	"thermal": func(ctx context.Context, r *helperResponse) error {
+		p, err := collectThermalPressure(ctx)
+		r.Thermal = &helperThermal{Level: p.level, SpeedLimit: p.speedLimit}
+		return err
+	},
+	"cgroup": func(ctx context.Context, r *helperResponse) error {
+		counters, err := collectCgroupCounters(ctx)
+		r.Cgroup = make(map[string]helperCounters, len(counters))
+		for name, c := range counters {
+			r.Cgroup[name] = helperCounters{Rx: c.rx, Tx: c.tx}
+		}
+		return err
+	},
+	"ping": func(_ context.Context, r *helperResponse) error {
+		r.Ping = &helperPing{PID: os.Getpid(), UID: os.Geteuid()}
+		return nil
+	},
+}
+
+// runHelper serves the privileged collectors on path (mo status helper),
+// normally as root so that the dashboard can run as a regular user. The
+// socket is open to its owner and one group: group when set, otherwise the
+// helper's own (root's). Other users never reach the root-collected data.
+func runHelper(w io.Writer, path, group string) error {
+	if runtime.GOOS == "windows" {
+		return errors.New("the helper needs Unix sockets and root; it is not available on Windows")
+	}
+	if os.Geteuid() != 0 {
+		fmt.Fprintln(os.Stderr, "mo status helper: not running as root; it will see no more than the dashboard does")
+	}
+	gid := -1
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return fmt.Errorf("--helper-group: %w", err)
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return fmt.Errorf("--helper-group: %w", err)
+		}
+	}
+	// A socket left behind by a helper that was killed blocks Listen.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("helper: %w", err)
+	}
+	defer ln.Close()
+	if gid >= 0 {
+		if err := os.Lchown(path, -1, gid); err != nil {
+			return fmt.Errorf("helper: %w", err)
+		}
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		return fmt.Errorf("helper: %w", err)
+	}
+	fmt.Fprintf(w, "mo status helper listening on %s\n", path)
+	return serveHelper(ln)
+}
+
+// serveHelper answers requests on each connection accepted from ln, at
+// most helperMaxConns at a time.
+func serveHelper(ln net.Listener) error {
+	slots := make(chan struct{}, helperMaxConns)
+	for {
+		slots <- struct{}{}
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer func() { <-slots }()
+			defer conn.Close()
+			scanner := bufio.NewScanner(conn)
+			enc := json.NewEncoder(conn)
+			for {
+				_ = conn.SetDeadline(time.Now().Add(helperIdleTimeout))
+				if !scanner.Scan() {
+					return
+				}
+				if enc.Encode(answerHelper(scanner.Bytes())) != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func answerHelper(line []byte) helperResponse {
+	resp := helperResponse{V: helperVersion}
+	var req helperRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = "bad request: " + err.Error()
+		return resp
+	}
+	if req.V != helperVersion {
+		resp.Error = fmt.Sprintf("unsupported protocol version %d (helper speaks %d)", req.V, helperVersion)
+		return resp
+	}
+	collect, ok := helperCollectors[req.Collect]
+	if !ok {
+		resp.Error = fmt.Sprintf("unknown collector %q", req.Collect)
+		return resp
+	}
+	return helperAnswers.get(req.Collect, collect)
+}
+
+// helperAnswerCache runs the helper's collectors one at a time and reuses
+// each answer for helperCacheTTL, so however often clients ask, root starts
+// no more than one nft or powermetrics per collector and second.
+type helperAnswerCache struct {
+	mu      sync.Mutex
+	answers map[string]helperResponse
+	at      map[string]time.Time
+}
+
+var helperAnswers = &helperAnswerCache{}
+
+func (h *helperAnswerCache) get(name string, collect func(context.Context, *helperResponse) error) helperResponse {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if at, ok := h.at[name]; ok && time.Since(at) < helperCacheTTL {
+		return h.answers[name]
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), helperCallTimeout)
+	defer cancel()
+	resp := helperResponse{V: helperVersion}
+	if err := collect(ctx, &resp); err != nil {
+		resp = helperResponse{V: helperVersion, Error: err.Error()}
+	}
+	if h.answers == nil {
+		h.answers, h.at = make(map[string]helperResponse), make(map[string]time.Time)
+	}
+	h.answers[name], h.at[name] = resp, time.Now()
+	return resp
+}
+
+// helperClient asks a running helper for one collector's data per call.
+// Calls are as rare as the throttled collectors behind them, so each dials
+// afresh rather than holding a connection.
+type helperClient struct {
+	path string
+}
+
+func (h *helperClient) call(collect string) (helperResponse, error) {
+	conn, err := net.DialTimeout("unix", h.path, helperDialTimeout)
+	if err != nil {
+		return helperResponse{}, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(helperCallTimeout))
+	if err := json.NewEncoder(conn).Encode(helperRequest{V: helperVersion, Collect: collect}); err != nil {
+		return helperResponse{}, err
+	}
+	var resp helperResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return helperResponse{}, fmt.Errorf("helper: %w", err)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("helper: %s", resp.Error)
+	}
+	return resp, nil
+}
+
+// collectConnections enumerates sockets through the helper, which sees
+// every user's, or locally.
+func (c *Collector) collectConnections() (status ConnectionStatus, err error) {
+	c.viaHelper("connections", func(r helperResponse) {
+		if r.Connections != nil {
+			status = *r.Connections
+		}
+	}, func() { status, err = collectConnections() })
+	return status, err
+}
+
+// collectThermalPressure reads powermetrics through the helper, which runs
+// it as root, or locally, where without root only pmset is available.
+func (c *Collector) collectThermalPressure(ctx context.Context) (p thermalPressure, err error) {
+	if runtime.GOOS != "darwin" {
+		return collectThermalPressure(ctx) // Nothing for the helper to add.
+	}
+	c.viaHelper("thermal", func(r helperResponse) {
+		if r.Thermal != nil {
+			p = thermalPressure{level: r.Thermal.Level, speedLimit: r.Thermal.SpeedLimit}
+		}
+	}, func() { p, err = collectThermalPressure(ctx) })
+	return p, err
+}
+
+// viaHelper runs a collector through the helper when one is configured,
+// falling back to local collection, which sees only this user's share,
+// when the helper is absent or fails. Losing and regaining the helper are
+// each logged once.
+func (c *Collector) viaHelper(collect string, remote func(helperResponse), local func()) {
+	if c.helper == nil {
+		local()
+		return
+	}
+	resp, err := c.helper.call(collect)
+	c.helperMu.Lock()
+	switch {
+	case err == nil && c.helperDown:
+		c.helperDown = false
+		c.events.add(severityInfo, categorySystem, "privileged helper answering again")
+	case err != nil && !c.helperDown:
+		c.helperDown = true
+		c.events.add(severityWarn, categorySystem, "privileged helper unavailable, showing this user's data only: "+err.Error())
+	}
+	c.helperMu.Unlock()
+	if err != nil {
+		local()
+		return
+	}
+	remote(resp)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// bucketHistory downsamples series to one point per n samples, keeping each
+// bucket's peak so a short burst still shows. Buckets end at the newest
+// sample, so only the oldest one may be partial.
+func bucketHistory(series []float64, n int) []float64 {
+	if n <= 1 || len(series) == 0 {
+		return series
+	}
+	out := make([]float64, 0, (len(series)+n-1)/n)
+	first := len(series) % n
+	if first > 0 {
+		out = append(out, peak(series[:first]))
+	}
+	for i := first; i < len(series); i += n {
+		out = append(out, peak(series[i:i+n]))
+	}
+	return out
+}
+
+func peak(series []float64) float64 {
+	m := series[0]
+	for _, v := range series[1:] {
+		m = max(m, v)
+	}
+	return m
+}
+
+// exportSnapshot returns s with its per-sample histories (CPU, memory, and
+// network in total and per interface) reduced to one point per bucket for --history-bucket. Only what is
+// written out is bucketed; the collector keeps full resolution for the view.
+// A bucket spans that many samples at the base refresh interval.
+func exportSnapshot(s MetricsSnapshot, bucket time.Duration) MetricsSnapshot {
+	n := int(math.Round(float64(bucket) / float64(refreshInterval)))
+	if n <= 1 {
+		return s
+	}
+	s.CPU.History = bucketHistory(s.CPU.History, n)
+	s.Memory.History = bucketHistory(s.Memory.History, n)
+	s.NetworkHistory.RxHistory = bucketHistory(s.NetworkHistory.RxHistory, n)
+	s.NetworkHistory.TxHistory = bucketHistory(s.NetworkHistory.TxHistory, n)
+	s.Network = slices.Clone(s.Network) // The rows are shared with the caller's snapshot.
+	for i := range s.Network {
+		s.Network[i].RxHistory = bucketHistory(s.Network[i].RxHistory, n)
+		s.Network[i].TxHistory = bucketHistory(s.Network[i].TxHistory, n)
+	}
+	s.HistoryBucket = (time.Duration(n) * refreshInterval).Seconds()
+	return s
+}
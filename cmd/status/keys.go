@@ -0,0 +1,280 @@
+//go:build !agent
+
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyBinding is one dashboard shortcut. The ? overlay is generated from
+// keymap, so the help cannot drift from what the keys actually do.
+type keyBinding struct {
+	keys   []string
+	label  string // Keys as shown in the help; defaults to the first key.
+	help   string
+	action func(m *model, msg tea.KeyMsg) tea.Cmd
+}
+
+// keymap lists the dashboard shortcuts in the order the help shows them.
+// The kiosk and event log modes handle their own keys.
+var keymap = []keyBinding{
+	{keys: []string{"?"}, help: "show or hide this help", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.showHelp = true
+		return nil
+	}},
+	{keys: []string{":"}, help: "query the snapshot, e.g. net.en0.rx > 10 or top cpu 5", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.queryOpen, m.queryInput, m.queryErr = true, "", ""
+		return nil
+	}},
+	{keys: []string{"q", "esc", "ctrl+c"}, label: "q", help: "quit", action: func(*model, tea.KeyMsg) tea.Cmd {
+		return tea.Quit
+	}},
+	{keys: []string{"r"}, help: "refresh now", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		// Sample now. Rates use the actual time since the previous sample,
+		// so the short gap does not skew them; the schedule restarts after.
+		if m.collecting {
+			return nil
+		}
+		m.tickGen++
+		m.collecting = true
+		m.forced = true
+		return m.collectCmd()
+	}},
+	{keys: []string{"f"}, help: "resume after a freeze on spike", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.frozen = nil
+		return nil
+	}},
+	{keys: []string{"e"}, help: "open the event log", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.showEvents = true
+		m.eventScroll = 0
+		return nil
+	}},
+	{keys: []string{"-"}, help: "collapse every panel to its summary", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.collapsed = toSet(panelIDs)
+		return nil
+	}},
+	{keys: []string{"+", "="}, label: "+", help: "expand every panel", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.collapsed = nil
+		return nil
+	}},
+	{keys: []string{"L"}, help: "switch to the next saved layout", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		if len(m.layouts) > 0 {
+			// After the last layout, or with none active yet, start at the first.
+			i := slices.IndexFunc(m.layouts, func(l dashboardLayout) bool { return l.name == m.layout })
+			m.applyLayout(m.layouts[(i+1)%len(m.layouts)])
+			m.savePrefs()
+		}
+		return nil
+	}},
+	{keys: []string{"g"}, help: "cycle the network graph: separate, mirrored, histogram", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.netGraph = m.display.netGraph.next()
+		return nil
+	}},
+	{keys: []string{"n"}, help: "show sparklines as numbers", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		// Swap every sparkline for the numbers behind it, and back.
+		if sparkStyle == sparkDigits {
+			sparkStyle = cmp.Or(m.graphStyle, sparkBlocks)
+		} else {
+			m.graphStyle, sparkStyle = sparkStyle, sparkDigits
+		}
+		return nil
+	}},
+	{keys: []string{"t"}, help: "show or hide the trend arrows", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		showTrends = !showTrends
+		return nil
+	}},
+	{keys: []string{"up", "down"}, label: "↑/↓", help: "select an interface", action: func(m *model, msg tea.KeyMsg) tea.Cmd {
+		step := 1
+		if msg.String() == "up" {
+			step = -1
+		}
+		m.display.selectedIface = moveSelection(selectableInterfaces(m.metrics.Network, m.display), m.display.selectedIface, step)
+		m.display.follow = nil // Steering by hand ends focus-follows-activity.
+		return nil
+	}},
+	{keys: []string{"F"}, help: "keep the busiest interface selected", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		if m.display.follow != nil {
+			m.display.follow = nil
+		} else {
+			m.display.follow = newActivityFollow()
+			names := selectableInterfaces(m.metrics.Network, m.display)
+			m.display.selectedIface = m.display.follow.pick(m.metrics.Network, names, "")
+		}
+		return nil
+	}},
+	{keys: []string{"i"}, help: "cycle interface graphs: off, own scale, shared scale", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.ifaceGraphs = m.display.ifaceGraphs.next()
+		return nil
+	}},
+	{keys: []string{"h"}, help: "hide or restore the selected interface", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		// Toggle visibility of the selected interface and persist it.
+		if name := m.display.selectedIface; name != "" {
+			if m.display.hiddenIfaces == nil {
+				m.display.hiddenIfaces = make(map[string]bool)
+			}
+			if m.display.hiddenIfaces[name] {
+				delete(m.display.hiddenIfaces, name)
+			} else {
+				m.display.hiddenIfaces[name] = true
+			}
+			m.savePrefs()
+		}
+		return nil
+	}},
+	{keys: []string{"P"}, help: "pin or unpin the selected interface at the top", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		// Pinned rows lead the list and skip the top-three cut and --min-rate.
+		if name := m.display.selectedIface; name != "" {
+			if m.display.pinned == nil {
+				m.display.pinned = make(map[string]bool)
+			}
+			if m.display.pinned[name] {
+				delete(m.display.pinned, name)
+			} else {
+				m.display.pinned[name] = true
+			}
+			m.savePrefs()
+		}
+		return nil
+	}},
+	{keys: []string{"H"}, help: "list hidden interfaces", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.showHidden = !m.display.showHidden
+		return nil
+	}},
+	{keys: []string{"c"}, help: "expand container interfaces", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.showContainers = !m.display.showContainers
+		return nil
+	}},
+	{keys: []string{"G"}, help: "group interfaces by kind", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.groupByKind = !m.display.groupByKind
+		return nil
+	}},
+	{keys: []string{"B"}, help: "fold bridge ports into their bridge", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.collapseBridges = !m.display.collapseBridges
+		return nil
+	}},
+	{keys: []string{"m"}, help: "count bytes since now instead of rates", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		// Mark now and count bytes from here; a second press clears it.
+		if m.display.mark != nil {
+			m.display.mark = nil
+		} else {
+			m.display.mark = newByteMark(time.Now(), m.metrics.Network)
+		}
+		return nil
+	}},
+	{keys: []string{"a"}, help: "show the raw byte counters instead of rates", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		// For cross-checking against ip -s link, netstat -ib and the like.
+		m.display.rawCounters = !m.display.rawCounters
+		return nil
+	}},
+	{keys: []string{"T"}, help: "switch the totals between since start and since boot", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		// Shows the totals if they were off, starting with boot totals.
+		if !m.display.showTotals {
+			m.display.showTotals, m.display.bootTotals = true, true
+			m.display.totalRxBytes, m.display.totalTxBytes = m.session.totals()
+			return nil
+		}
+		m.display.bootTotals = !m.display.bootTotals
+		return nil
+	}},
+	{keys: []string{"b"}, help: "subtract current rates as background", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		// Subtract the current rates as background; a second press clears it.
+		if m.display.tare != nil {
+			m.display.tare = nil
+		} else {
+			m.display.tare = newRateTare(m.metrics.Network, m.metrics.NetworkHistory)
+		}
+		return nil
+	}},
+	{keys: []string{"d"}, help: "cycle the disk panel order", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.diskSort = m.display.diskSort.next()
+		return nil
+	}},
+	{keys: []string{"s"}, help: "group connections by state, protocol or remote host", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.connGroup = m.display.connGroup.next()
+		return nil
+	}},
+	{keys: []string{"l"}, help: "list listening ports and their processes", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.listenersOnly = !m.display.listenersOnly
+		return nil
+	}},
+	{keys: []string{"p"}, help: "sort top processes by CPU or memory", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		m.display.procsByCPU = !m.display.procsByCPU
+		return nil
+	}},
+	{keys: []string{"k"}, help: "show or hide the cat", action: func(m *model, _ tea.KeyMsg) tea.Cmd {
+		// Toggle cat visibility and persist preference
+		m.catHidden = !m.catHidden
+		m.savePrefs()
+		return nil
+	}},
+}
+
+// lookupKey returns the binding for a key as tea.KeyMsg.String spells it.
+func lookupKey(key string) (keyBinding, bool) {
+	for _, b := range keymap {
+		for _, k := range b.keys {
+			if k == key {
+				return b, true
+			}
+		}
+	}
+	return keyBinding{}, false
+}
+
+// queryKey edits the : prompt. Enter runs the query: a top command applies
+// at once, anything else stays in the footer; an empty line clears it.
+func (m model) queryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.queryOpen = false
+	case tea.KeyBackspace:
+		if r := []rune(m.queryInput); len(r) > 0 {
+			m.queryInput = string(r[:len(r)-1])
+		}
+		m.queryErr = ""
+	case tea.KeySpace:
+		m.queryInput += " "
+	case tea.KeyRunes:
+		m.queryInput += string(msg.Runes)
+		m.queryErr = ""
+	case tea.KeyEnter:
+		if strings.TrimSpace(m.queryInput) == "" {
+			m.queryOpen, m.query = false, nil
+			m.runQuery()
+			return m, nil
+		}
+		q, err := parseQuery(m.queryInput)
+		if err != nil {
+			m.queryErr = err.Error()
+			return m, nil
+		}
+		m.queryOpen = false
+		if q.top != "" {
+			m.display.procsByCPU, m.display.procRows = q.top == "cpu", q.rows
+			return m, nil
+		}
+		m.query = &q
+		m.runQuery()
+	}
+	return m, nil
+}
+
+// helpKey handles keys while the help overlay is open: ? or esc close it,
+// q and ctrl+c still quit, anything else is ignored. Sampling carries on
+// underneath since ticks are not keys.
+func (m model) helpKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "?", "esc":
+		m.showHelp = false
+	}
+	return m, nil
+}
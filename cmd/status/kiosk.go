@@ -0,0 +1,55 @@
+//go:build !agent
+
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// kioskQuitWindow is how soon a second ctrl+c must follow the first.
+const kioskQuitWindow = 2 * time.Second
+
+// kioskCycleMsg moves kiosk focus to the next panel.
+type kioskCycleMsg struct{}
+
+func kioskCycle(every time.Duration) tea.Cmd {
+	return tea.Tick(every, func(time.Time) tea.Msg { return kioskCycleMsg{} })
+}
+
+// nextKioskFocus returns the panel after current among the cards on screen,
+// wrapping around; panels without data (latency without --ping) are skipped
+// because they are not in cards.
+func nextKioskFocus(cards []cardData, current string) string {
+	if len(cards) == 0 {
+		return ""
+	}
+	for i, c := range cards {
+		if c.id == current {
+			return cards[(i+1)%len(cards)].id
+		}
+	}
+	return cards[0].id
+}
+
+// kioskCollapsed collapses every panel except the focused one.
+func kioskCollapsed(focus string) map[string]bool {
+	collapsed := toSet(panelIDs)
+	delete(collapsed, focus)
+	return collapsed
+}
+
+// kioskKey handles a key press in kiosk mode: everything is ignored except a
+// double ctrl+c, so passers-by can't change or close a wall display.
+func (m model) kioskKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() != "ctrl+c" {
+		return m, nil
+	}
+	now := time.Now()
+	if !m.kioskQuitAt.IsZero() && now.Sub(m.kioskQuitAt) <= kioskQuitWindow {
+		return m, tea.Quit
+	}
+	m.kioskQuitAt = now
+	return m, nil
+}
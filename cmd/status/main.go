@@ -2,212 +2,125 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
-	"strings"
 	"time"
-
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 const refreshInterval = time.Second
 
-var (
-	Version   = "dev"
-	BuildTime = ""
-)
-
-type tickMsg struct{}
-type animTickMsg struct{}
-
-type metricsMsg struct {
-	data MetricsSnapshot
-	err  error
-}
-
-type model struct {
-	collector   *Collector
-	width       int
-	height      int
-	metrics     MetricsSnapshot
-	errMessage  string
-	ready       bool
-	lastUpdated time.Time
-	collecting  bool
-	animFrame   int
-	catHidden   bool // true = hidden, false = visible
-}
-
-// getConfigPath returns the path to the status preferences file.
-func getConfigPath() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ""
-	}
-	return filepath.Join(home, ".config", "mole", "status_prefs")
-}
-
-// loadCatHidden loads the cat hidden preference from config file.
-func loadCatHidden() bool {
-	path := getConfigPath()
-	if path == "" {
-		return false
-	}
-	data, err := os.ReadFile(path)
+func main() {
+	opts, err := parseOptions(os.Args[1:], os.Stderr)
 	if err != nil {
-		return false
-	}
-	return strings.TrimSpace(string(data)) == "cat_hidden=true"
-}
-
-// saveCatHidden saves the cat hidden preference to config file.
-func saveCatHidden(hidden bool) {
-	path := getConfigPath()
-	if path == "" {
-		return
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "mo status: %v\n", err)
+		os.Exit(2)
 	}
-	// Ensure directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	// Before newSource, which starts listeners and creates output files.
+	if opts.dryRun {
+		if err := runDryRun(os.Stdout, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "mo status --dry-run: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
-	value := "cat_hidden=false"
-	if hidden {
-		value = "cat_hidden=true"
-	}
-	_ = os.WriteFile(path, []byte(value+"\n"), 0644)
-}
-
-func newModel() model {
-	return model{
-		collector: NewCollector(),
-		catHidden: loadCatHidden(),
+	valuePrecision = opts.precision
+	adaptiveRateUnits = opts.rateUnits == rateUnitsAuto
+	sparkStyle = opts.sparkStyle
+	showTrends = !opts.noTrends
+	if asciiOutput = opts.ascii || !localeIsUTF8(os.Getenv); asciiOutput && sparkStyle != sparkDigits {
+		sparkStyle = sparkASCII
 	}
-}
-
-func (m model) Init() tea.Cmd {
-	return tea.Batch(tickAfter(0), animTick())
-}
-
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "esc", "ctrl+c":
-			return m, tea.Quit
-		case "k":
-			// Toggle cat visibility and persist preference
-			m.catHidden = !m.catHidden
-			saveCatHidden(m.catHidden)
-			return m, nil
-		}
-	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
-		return m, nil
-	case tickMsg:
-		if m.collecting {
-			return m, nil
+	if ran, err := runOneOff(os.Stdout, opts); ran {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "system status error: %v\n", err)
+			os.Exit(1)
 		}
-		m.collecting = true
-		return m, m.collectCmd()
-	case metricsMsg:
-		if msg.err != nil {
-			m.errMessage = msg.err.Error()
-		} else {
-			m.errMessage = ""
-		}
-		m.metrics = msg.data
-		m.lastUpdated = msg.data.CollectedAt
-		m.collecting = false
-		// Mark ready after first successful data collection.
-		if !m.ready {
-			m.ready = true
-		}
-		return m, tickAfter(refreshInterval)
-	case animTickMsg:
-		m.animFrame++
-		return m, animTickWithSpeed(m.metrics.CPU.Usage)
-	}
-	return m, nil
-}
-
-func (m model) View() string {
-	if !m.ready {
-		return "Loading..."
+		return
 	}
-
-	termWidth := m.width
-	if termWidth <= 0 {
-		termWidth = 80
+	source, err := opts.newSource()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mo status: %v\n", err)
+		os.Exit(2)
 	}
-
-	header, mole := renderHeader(m.metrics, m.errMessage, m.animFrame, termWidth, m.catHidden)
-
-	if termWidth <= 80 {
-		cardWidth := termWidth
-		if cardWidth > 2 {
-			cardWidth -= 2
+	if opts.logEvents != "" {
+		f, err := os.OpenFile(opts.logEvents, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mo status: %v\n", err)
+			os.Exit(2)
 		}
-		cards := buildCards(m.metrics, cardWidth)
-
-		var rendered []string
-		for i, c := range cards {
-			if i > 0 {
-				rendered = append(rendered, "")
-			}
-			rendered = append(rendered, renderCard(c, cardWidth, 0))
+		defer f.Close()
+		eventsOf(source).setLog(f)
+	}
+	if opts.debugNet != "" {
+		f, err := os.OpenFile(opts.debugNet, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mo status: %v\n", err)
+			os.Exit(2)
 		}
-		// Combine header, mole, and cards with consistent spacing
-		var content []string
-		content = append(content, header)
-		if mole != "" {
-			content = append(content, mole)
+		defer f.Close()
+		if c, ok := localCollector(source); ok {
+			c.netTrace = &netTrace{w: f}
 		}
-		content = append(content, lipgloss.JoinVertical(lipgloss.Left, rendered...))
-		return lipgloss.JoinVertical(lipgloss.Left, content...)
 	}
 
-	cardWidth := max(24, termWidth/2-4)
-	cards := buildCards(m.metrics, cardWidth)
-	twoCol := renderTwoColumns(cards, termWidth)
-	// Combine header, mole, and cards with consistent spacing
-	var content []string
-	content = append(content, header)
-	if mole != "" {
-		content = append(content, mole)
+	switch {
+	case opts.jsonOutput:
+		compact := opts.jsonCompact || !opts.jsonPretty && !isTerminal(os.Stdout)
+		err = runJSON(os.Stdout, source, opts.onceInterval, opts.historyBucket, compact)
+	case opts.flatOutput:
+		err = runFlat(os.Stdout, source, opts.onceInterval, opts.historyBucket)
+	case opts.spark:
+		err = runSpark(os.Stdout, source, opts.sparkIface, opts.sparkWidth, opts.sparkTime)
+	case opts.onceOutput:
+		err = runOnce(os.Stdout, source, opts.onceInterval)
+	case opts.lineOutput:
+		err = runLine(os.Stdout, source, opts.snapshotWriter(), opts.duration, opts.samples, formatLine)
+	case opts.influxLP:
+		err = runLine(os.Stdout, source, opts.snapshotWriter(), opts.duration, opts.samples, formatInflux)
+	case opts.agent:
+		err = runAgent(source, opts.snapshotWriter(), opts.duration, opts.samples)
+	case !isTerminal(os.Stdout):
+		// The alt-screen TUI needs a terminal; degrade to plain lines for pipes and CI.
+		fmt.Fprintln(os.Stderr, "mo status: stdout is not a terminal, printing plain lines. Use --json for machine-readable output.")
+		err = runLine(os.Stdout, source, opts.snapshotWriter(), opts.duration, opts.samples, formatLine)
+	default:
+		err = runDashboard(opts, source)
 	}
-	content = append(content, twoCol)
-	return lipgloss.JoinVertical(lipgloss.Left, content...)
-}
-
-func (m model) collectCmd() tea.Cmd {
-	return func() tea.Msg {
-		data, err := m.collector.Collect()
-		return metricsMsg{data: data, err: err}
+	closeSinks(source, sinkCloseWait)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "system status error: %v\n", err)
+		os.Exit(1)
 	}
 }
 
-func tickAfter(delay time.Duration) tea.Cmd {
-	return tea.Tick(delay, func(time.Time) tea.Msg { return tickMsg{} })
-}
-
-func animTick() tea.Cmd {
-	return tea.Tick(200*time.Millisecond, func(time.Time) tea.Msg { return animTickMsg{} })
-}
-
-func animTickWithSpeed(cpuUsage float64) tea.Cmd {
-	// Higher CPU = faster animation.
-	interval := max(300-int(cpuUsage*2.5), 50)
-	return tea.Tick(time.Duration(interval)*time.Millisecond, func(time.Time) tea.Msg { return animTickMsg{} })
-}
-
-func main() {
-	p := tea.NewProgram(newModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "system status error: %v\n", err)
-		os.Exit(1)
+// runOneOff runs the modes that print or serve something without sampling
+// this host, and reports whether opts asked for one. They run before
+// newSource so that they never start listeners or touch output files.
+func runOneOff(w io.Writer, opts options) (bool, error) {
+	switch {
+	case opts.showVersion:
+		fmt.Fprint(w, formatVersion())
+	case opts.exportConfig:
+		return true, exportConfig(w, opts, loadPrefs())
+	case opts.doctor:
+		if err := runDoctor(w, opts.helperClient()); err != nil {
+			fmt.Fprintf(os.Stderr, "mo status doctor: %v\n", err)
+			os.Exit(1)
+		}
+	case opts.helper:
+		return true, runHelper(w, opts.helperSocket, opts.helperGroup)
+	case opts.speedtestServe != "":
+		return true, runSpeedtestServer(w, opts.speedtestServe)
+	case opts.speedtest != "":
+		return true, runSpeedtest(w, w == os.Stdout && isTerminal(os.Stdout), opts.speedtest, opts.speedtestTime)
+	default:
+		return false, nil
 	}
+	return true, nil
 }
@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"runtime"
 	"sync"
 	"time"
 
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/host"
-	"github.com/shirou/gopsutil/v4/net"
 )
 
 // RingBuffer is a fixed-size circular buffer for float64 values.
@@ -56,144 +56,222 @@ func (rb *RingBuffer) Slice() []float64 {
 	return res
 }
 
+// snapshotSchemaVersion is reported as schema_version in JSON output. Bump it
+// whenever a field is renamed, removed or changes meaning; additions don't count.
+const snapshotSchemaVersion = 1
+
 type MetricsSnapshot struct {
-	CollectedAt    time.Time
-	Host           string
-	Platform       string
-	Uptime         string
-	Procs          uint64
-	Hardware       HardwareInfo
-	HealthScore    int    // 0-100 system health score
-	HealthScoreMsg string // Brief explanation
-
-	CPU            CPUStatus
-	GPU            []GPUStatus
-	Memory         MemoryStatus
-	Disks          []DiskStatus
-	DiskIO         DiskIOStatus
-	Network        []NetworkStatus
-	NetworkHistory NetworkHistory
-	Proxy          ProxyStatus
-	Batteries      []BatteryStatus
-	Thermal        ThermalStatus
-	Sensors        []SensorReading
-	Bluetooth      []BluetoothDevice
-	TopProcesses   []ProcessInfo
+	SchemaVersion  int          `json:"schema_version"`
+	CollectedAt    time.Time    `json:"collected_at"`
+	Host           string       `json:"host"`
+	Platform       string       `json:"platform"`
+	Uptime         string       `json:"uptime"`
+	Procs          uint64       `json:"procs"`
+	Hardware       HardwareInfo `json:"hardware"`
+	HealthScore    int          `json:"health_score"`     // 0-100 system health score
+	HealthScoreMsg string       `json:"health_score_msg"` // Brief explanation
+
+	CPU            CPUStatus         `json:"cpu"`
+	GPU            []GPUStatus       `json:"gpu"`
+	Memory         MemoryStatus      `json:"memory"`
+	Disks          []DiskStatus      `json:"disks"`
+	DiskIO         DiskIOStatus      `json:"disk_io"`
+	Network        []NetworkStatus   `json:"network"`
+	NetworkWarmup  bool              `json:"network_warmup"` // First sample; rates need a second one.
+	Connections    ConnectionStatus  `json:"connections"`
+	NetworkHistory NetworkHistory    `json:"network_history"`
+	HistoryBucket  float64           `json:"history_bucket_seconds,omitempty"` // Seconds per history point when exported with --history-bucket.
+	Proxy          ProxyStatus       `json:"proxy"`
+	Batteries      []BatteryStatus   `json:"batteries"`
+	Thermal        ThermalStatus     `json:"thermal"`
+	Sensors        []SensorReading   `json:"sensors"`
+	Bluetooth      []BluetoothDevice `json:"bluetooth"`
+	TopProcesses   []ProcessInfo     `json:"top_processes"`
+	TopMemory      []MemProcessInfo  `json:"top_memory"`
+	Latency        *LatencyStatus    `json:"latency,omitempty"`
+	PublicIP       *PublicIPStatus   `json:"public_ip,omitempty"`
+	Container      *ContainerStatus  `json:"container,omitempty"`
+	Unit           *UnitStatus       `json:"unit,omitempty"`
+	Firewall       *FirewallStatus   `json:"firewall,omitempty"`       // With --firewall.
+	TCP            *TCPStatus        `json:"tcp,omitempty"`            // Linux only.
+	IPFamilies     *IPFamilyStatus   `json:"ip_families,omitempty"`    // Linux only, with --ip-split.
+	ProcessStates  map[string]int    `json:"process_states,omitempty"` // running, sleeping, zombie, ...
+	RouteCount     int               `json:"route_count,omitempty"`    // Main IPv4 table; 0 where it cannot be read.
+	Events         []StatusEvent     `json:"-"`                        // Recent collector events, oldest first; TUI only.
+	RecentEvents   []StatusEvent     `json:"events,omitempty"`         // The last --json-events of Events, for headless consumers.
 }
 
 type HardwareInfo struct {
-	Model       string // MacBook Pro 14-inch, 2021
-	CPUModel    string // Apple M1 Pro / Intel Core i7
-	TotalRAM    string // 16GB
-	DiskSize    string // 512GB
-	OSVersion   string // macOS Sonoma 14.5
-	RefreshRate string // 120Hz / 60Hz
+	Model       string `json:"model"`        // MacBook Pro 14-inch, 2021
+	CPUModel    string `json:"cpu_model"`    // Apple M1 Pro / Intel Core i7
+	TotalRAM    string `json:"total_ram"`    // 16GB
+	DiskSize    string `json:"disk_size"`    // 512GB
+	OSVersion   string `json:"os_version"`   // macOS Sonoma 14.5
+	RefreshRate string `json:"refresh_rate"` // 120Hz / 60Hz
 }
 
 type DiskIOStatus struct {
-	ReadRate  float64 // MB/s
-	WriteRate float64 // MB/s
+	ReadRate    float64 `json:"read_rate"`    // MB/s
+	WriteRate   float64 `json:"write_rate"`   // MB/s
+	BusyPercent float64 `json:"busy_percent"` // Busiest device's share of wall time spent on I/O
+
+	// Per-device busy time; only devices whose OS reports io_time appear here.
+	Devices []DiskDeviceIO `json:"devices,omitempty"`
+}
+
+type DiskDeviceIO struct {
+	Name        string  `json:"name"`
+	BusyPercent float64 `json:"busy_percent"`
 }
 
 type ProcessInfo struct {
-	Name   string
-	CPU    float64
-	Memory float64
+	Name   string  `json:"name"`
+	CPU    float64 `json:"cpu"`
+	Memory float64 `json:"memory"`
 }
 
 type CPUStatus struct {
-	Usage            float64
-	PerCore          []float64
-	PerCoreEstimated bool
-	Load1            float64
-	Load5            float64
-	Load15           float64
-	CoreCount        int
-	LogicalCPU       int
-	PCoreCount       int // Performance cores (Apple Silicon)
-	ECoreCount       int // Efficiency cores (Apple Silicon)
+	Usage            float64   `json:"usage"`
+	PerCore          []float64 `json:"per_core"`
+	PerCoreEstimated bool      `json:"per_core_estimated"`
+	Load1            float64   `json:"load1"`
+	Load5            float64   `json:"load5"`
+	Load15           float64   `json:"load15"`
+	CoreCount        int       `json:"core_count"`
+	LogicalCPU       int       `json:"logical_cpu"`
+	PCoreCount       int       `json:"p_core_count"`      // Performance cores (Apple Silicon)
+	ECoreCount       int       `json:"e_core_count"`      // Efficiency cores (Apple Silicon)
+	History          []float64 `json:"history,omitempty"` // Recent usage, oldest first.
 }
 
 type GPUStatus struct {
-	Name        string
-	Usage       float64
-	MemoryUsed  float64
-	MemoryTotal float64
-	CoreCount   int
-	Note        string
+	Name        string  `json:"name"`
+	Usage       float64 `json:"usage"`
+	MemoryUsed  float64 `json:"memory_used"`
+	MemoryTotal float64 `json:"memory_total"`
+	CoreCount   int     `json:"core_count"`
+	Note        string  `json:"note"`
 }
 
 type MemoryStatus struct {
-	Used        uint64
-	Total       uint64
-	UsedPercent float64
-	SwapUsed    uint64
-	SwapTotal   uint64
-	Cached      uint64 // File cache that can be freed if needed
-	Pressure    string // macOS memory pressure: normal/warn/critical
+	Used        uint64    `json:"used"`
+	Total       uint64    `json:"total"`
+	UsedPercent float64   `json:"used_percent"`
+	SwapUsed    uint64    `json:"swap_used"`
+	SwapTotal   uint64    `json:"swap_total"`
+	Cached      uint64    `json:"cached"`            // File cache that can be freed if needed
+	Pressure    string    `json:"pressure"`          // macOS memory pressure: normal/warn/critical
+	History     []float64 `json:"history,omitempty"` // Recent used percent, oldest first.
 }
 
 type DiskStatus struct {
-	Mount       string
-	Device      string
-	Used        uint64
-	Total       uint64
-	UsedPercent float64
-	Fstype      string
-	External    bool
+	Mount       string  `json:"mount"`
+	Device      string  `json:"device"`
+	Used        uint64  `json:"used"`
+	Total       uint64  `json:"total"`
+	UsedPercent float64 `json:"used_percent"`
+	Fstype      string  `json:"fstype"`
+	External    bool    `json:"external"`
 }
 
 type NetworkStatus struct {
-	Name      string
-	RxRateMBs float64
-	TxRateMBs float64
-	IP        string
+	Name          string         `json:"name"`
+	RxRateMBs     float64        `json:"rx_rate_mbs"`
+	TxRateMBs     float64        `json:"tx_rate_mbs"`
+	RateUnknown   bool           `json:"rate_unknown,omitempty"` // The counters reset this sample, so no rate was measured; the rates read 0.
+	IP            string         `json:"ip"`
+	Kind          string         `json:"kind"`                   // physical, vpn, virtual, container, remote, cgroup, group
+	Bond          string         `json:"bond,omitempty"`         // Bond this interface is a member of; kept out of totals.
+	Bridge        string         `json:"bridge,omitempty"`       // Linux bridge this interface is a port of.
+	Members       []string       `json:"members,omitempty"`      // For a group row, the interfaces summed into it.
+	Untotaled     bool           `json:"untotaled,omitempty"`    // Listed in --totals-exclude: shown, but kept out of totals.
+	Renamed       string         `json:"renamed_from,omitempty"` // Previous name, on the sample a --follow-renames rename is seen.
+	Gateway       *GatewayStatus `json:"gateway,omitempty"`      // Default gateway via this interface, if any.
+	RxBytes       uint64         `json:"rx_bytes"`               // Cumulative counters as reported by the OS.
+	TxBytes       uint64         `json:"tx_bytes"`
+	RxErrors      uint64         `json:"rx_errors,omitempty"` // Cumulative receive and transmit errors.
+	TxErrors      uint64         `json:"tx_errors,omitempty"`
+	IdleSecs      float64        `json:"idle_seconds,omitempty"` // How long rx+tx has stayed below the idle rate.
+	Duplex        string         `json:"duplex,omitempty"`       // Negotiated duplex of a wired link: full or half.
+	Media         string         `json:"media,omitempty"`        // Negotiated media, e.g. 1000baseT.
+	LinkMbps      int            `json:"link_mbps,omitempty"`    // Link speed read from Media; 0 = unknown.
+	RxHistory     []float64      `json:"rx_history,omitempty"`   // This interface's recent rates, oldest first; not for containers.
+	TxHistory     []float64      `json:"tx_history,omitempty"`
+	SignalDBm     int            `json:"signal_dbm,omitempty"`     // Wi-Fi signal level, Linux only.
+	SignalHistory []float64      `json:"signal_history,omitempty"` // Recent levels in dBm, one per wifi refresh.
+}
+
+// inTotals reports whether n counts toward the aggregate rates: bond members
+// are already counted on their bond, and --totals-exclude names are not wanted.
+func (n NetworkStatus) inTotals() bool {
+	return n.Bond == "" && !n.Untotaled
 }
 
 // NetworkHistory holds the global network usage history.
 type NetworkHistory struct {
-	RxHistory []float64
-	TxHistory []float64
+	RxHistory []float64 `json:"rx_history"`
+	TxHistory []float64 `json:"tx_history"`
 }
 
-const NetworkHistorySize = 120 // Increased history size for wider graph
+const NetworkHistorySize = 120 // Default samples kept for every history graph (--history).
 
+// ProxyStatus is the proxy in effect. The top-level fields describe the
+// primary one, the entry with the highest precedence, which is what the line
+// and summary modes report.
 type ProxyStatus struct {
-	Enabled bool
-	Type    string // HTTP, HTTPS, SOCKS, PAC, WPAD, TUN
-	Host    string
+	Enabled bool          `json:"enabled"`
+	Type    string        `json:"type"` // HTTP, HTTPS, SOCKS, PAC, WPAD, TUN
+	Host    string        `json:"host"`
+	Scheme  string        `json:"scheme,omitempty"` // Traffic it applies to: http, https, socks, all, auto
+	Source  string        `json:"source,omitempty"` // env, system, tun, git, npm, curl
+	Apps    []ProxyStatus `json:"apps,omitempty"`   // Per-tool proxies (--app-proxies).
+	// Whether the proxy accepted a TCP connection (--proxy-check); nil while
+	// Checking or when not checked.
+	Reachable *bool `json:"reachable,omitempty"`
+	Checking  bool  `json:"checking,omitempty"`
+	// Every configured proxy from the same source in precedence order, the
+	// primary first; only set when they differ, e.g. HTTP and HTTPS going
+	// through different hosts.
+	Schemes []ProxyStatus `json:"schemes,omitempty"`
 }
 
 type BatteryStatus struct {
-	Percent    float64
-	Status     string
-	TimeLeft   string
-	Health     string
-	CycleCount int
-	Capacity   int // Maximum capacity percentage (e.g., 85 means 85% of original)
+	Percent    float64 `json:"percent"`
+	Status     string  `json:"status"`
+	TimeLeft   string  `json:"time_left"`
+	Health     string  `json:"health"`
+	CycleCount int     `json:"cycle_count"`
+	Capacity   int     `json:"capacity"` // Maximum capacity percentage (e.g., 85 means 85% of original)
 }
 
 type ThermalStatus struct {
-	CPUTemp      float64
-	GPUTemp      float64
-	FanSpeed     int
-	FanCount     int
-	SystemPower  float64 // System power consumption in Watts
-	AdapterPower float64 // AC adapter max power in Watts
-	BatteryPower float64 // Battery charge/discharge power in Watts (positive = discharging)
+	CPUTemp      float64 `json:"cpu_temp"`
+	GPUTemp      float64 `json:"gpu_temp"`
+	FanSpeed     int     `json:"fan_speed"`
+	FanCount     int     `json:"fan_count"`
+	SystemPower  float64 `json:"system_power"`  // System power consumption in Watts
+	AdapterPower float64 `json:"adapter_power"` // AC adapter max power in Watts
+	BatteryPower float64 `json:"battery_power"` // Battery charge/discharge power in Watts (positive = discharging)
+
+	// macOS only. Pressure is "unknown" unless running as root, where
+	// powermetrics reports it; CPUSpeedLimit comes from pmset where the
+	// hardware reports one (mostly Intel Macs).
+	ThermalPressure string `json:"thermal_pressure,omitempty"`
+	CPUSpeedLimit   int    `json:"cpu_speed_limit,omitempty"` // Percent of full speed allowed.
+	Throttled       bool   `json:"throttled,omitempty"`       // Heavy pressure or worse, or a speed limit below 100%.
 }
 
 type SensorReading struct {
-	Label string
-	Value float64
-	Unit  string
-	Note  string
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+	Note  string  `json:"note"`
 }
 
 type BluetoothDevice struct {
-	Name      string
-	Connected bool
-	Battery   string
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+	Battery   string `json:"battery"`
 }
 
 type Collector struct {
@@ -206,27 +284,213 @@ type Collector struct {
 	lastBTAt time.Time
 	lastBT   []BluetoothDevice
 
+	// Expensive collectors, refreshed per defaultCollectorIntervals.
+	conns         throttled[ConnectionStatus]
+	topProcs      throttled[[]ProcessInfo]
+	memProcs      throttled[[]MemProcessInfo]
+	procStates    throttled[map[string]int]
+	gateways      throttled[map[string]GatewayStatus]
+	routes        throttled[int]
+	wifi          throttled[map[string]int]
+	signalHistory map[string]*RingBuffer // Per wireless interface, appended on each wifi refresh.
+	links         throttled[map[string]linkMode]
+	disks         throttled[[]DiskStatus]
+	appProxyCache throttled[[]ProxyStatus]
+	pressure      throttled[thermalPressure]
+	firewall      throttled[FirewallStatus]
+	firewallCheck bool // Read the host firewall state; only with --firewall.
+	appProxies    bool // Read per-tool proxy config; only with --app-proxies.
+
+	// Latency probe (--ping), run in the background so a slow or unreachable
+	// target never holds up the refresh. pingMu guards the fields below it.
+	pingTarget  string
+	pingEvery   time.Duration
+	pingMu      sync.Mutex
+	pingLastAt  time.Time
+	pingBusy    bool
+	pingLast    LatencyStatus
+	pingHistory *RingBuffer
+	pingTCPOnly bool // ICMP failed where TCP worked; stop trying ICMP.
+	pingNote    string
+
+	// External address probe (--public-ip), backgrounded the same way.
+	// publicMu guards the fields below it.
+	publicIPURL  string
+	publicEvery  time.Duration
+	publicMu     sync.Mutex
+	publicLastAt time.Time
+	publicBusy   bool
+	publicLast   PublicIPStatus
+	publicDirect string // Last address seen with no VPN up.
+
+	// Proxy reachability (--proxy-check), through the shared background probe.
+	proxyCheck  bool
+	proxyProbe  backgroundProbe[proxyCheckResult]
+	proxyTarget string // host:port the probe result is for.
+
+	// Duplicate address checks (--arp-check), through the shared background
+	// probe; arpSeenAt is the scan last handed to watchARP.
+	arpCheck  bool
+	arpProbe  backgroundProbe[arpScan]
+	arpSeenAt time.Time
+	vpnIface  string // Set by networkRates each sample.
+
+	// Docker container watched with --container; docker is nil without it.
+	containerName   string
+	docker          *dockerClient
+	prevContainer   *dockerStats
+	containerWindow rateWindow
+	containerState  string // Last state seen, for stop/start events.
+
+	// systemd service watched with --unit; unitName is empty without it.
+	unitName   string
+	cgroupRoot string // Where the cgroup v2 hierarchy is mounted.
+	prevUnit   *unitSample
+	unitWindow rateWindow
+	unitState  string // Last state seen, for stop/start events.
+
+	// Remote interfaces polled over SNMP (--snmp); snmp is nil without it.
+	snmp      *snmpPoller
+	snmpProbe backgroundProbe[snmpPoll]
+
+	// Per-cgroup traffic from nftables counters (--cgroup-traffic); nil
+	// without it.
+	cgroups *cgroupTraffic
+
+	// Host TCP counters at the previous sample (Linux /proc/net/snmp).
+	prevTCP   tcpCounters
+	tcpWindow rateWindow
+
+	// Host-wide IPv4/IPv6 octet counters for --ip-split.
+	ipSplit        bool
+	prevIPFamily   ipFamilyCounters
+	ipFamilyWindow rateWindow
+
+	// Monotonic sample clock for every counter delta below.
+	clock monoClock
+
+	// Per-process CPU seconds by PID at the previous walk, for CPU percentages.
+	memProcsWindow rateWindow
+	prevProcCPU    map[int32]float64
+	fdHistory      map[int32][]int32 // Recent descriptor counts of the top processes.
+
 	// Fast metrics (1s).
-	prevNet      map[string]net.IOCountersStat
-	lastNetAt    time.Time
-	rxHistoryBuf *RingBuffer
-	txHistoryBuf *RingBuffer
-	lastGPUAt    time.Time
-	cachedGPU    []GPUStatus
-	prevDiskIO   disk.IOCountersStat
-	lastDiskAt   time.Time
+	prevNet       map[string]netCounter    // Keyed by ifaceKey (name + index).
+	netHistory    map[string]*ifaceHistory // Per-interface sparklines by name.
+	noiseFilters  []string                 // Prefixes of interfaces left out, defaultNoiseFilters plus --hide-interface.
+	showIfaces    []string                 // Prefixes listed even when a noise filter matches (--show-interface).
+	netCycle      uint64                   // Samples taken, for evicting vanished interfaces.
+	netRows       []NetworkStatus          // Scratch space reused by networkRates.
+	netContainers []NetworkStatus
+	netLast       []NetworkStatus // Returned again when a sample cannot yield rates.
+	netWindow     rateWindow
+	netTrace      *netTrace         // Per-sample counter trace (--debug-net); nil = off.
+	bridgePorts   map[string]string // Port to bridge, read with each sample (Linux).
+	idleRate      float64           // Combined MB/s below which an interface counts as idle (--min-rate).
+	netns         string            // Named network namespace to read counters from (--netns, Linux).
+	rxHistoryBuf  *RingBuffer
+	txHistoryBuf  *RingBuffer
+	cpuHistoryBuf *RingBuffer
+	memHistoryBuf *RingBuffer
+	lastGPUAt     time.Time
+	cachedGPU     []GPUStatus
+	prevDiskIO    disk.IOCountersStat
+	prevDiskDevs  map[string]disk.IOCountersStat
+	diskWindow    rateWindow
+	lastDiskIO    DiskIOStatus
+
+	ipStrategy ipStrategy    // How each interface's primary IPv4 is chosen.
+	cmdTimeout time.Duration // Budget per fast external command (--cmd-timeout).
+
+	// Interfaces still reported but left out of the aggregate history:
+	// totalsExcluded follows the dashboard's hidden set, totalsSkip is
+	// --totals-exclude and also marks the rows as Untotaled.
+	totalsExcluded map[string]bool
+	totalsSkip     map[string]bool
+	pinned         map[string]bool   // Interfaces always kept in the top rows; from the dashboard.
+	followRenames  bool              // Match a vanished interface to a new name by MAC (--follow-renames).
+	ifaceMACs      map[string]string // Name to MAC from the latest sample, with followRenames.
+	priming        bool              // Taking the startup baseline, which keeps no history.
+
+	// Privileged collectors go through mo status helper when it runs;
+	// helperDown tracks whether it failed last time, for the event log.
+	helper     *helperClient
+	helperMu   sync.Mutex
+	helperDown bool
+
+	showBondMembers bool // List bond members (marked, not totaled) instead of dropping them.
+	exportEvents    int  // Recent events copied into the snapshot's JSON (--json-events).
+	// Sums of interfaces matching a pattern, from the prefs file; members
+	// are dropped from the list unless showGroupMembers is set.
+	groups           []*ifaceGroup
+	showGroupMembers bool
+	diskTop          int // Volumes kept in the disk panel (--disk-top); 0 = all.
+
+	events *eventRing // Interface up/down and similar notices.
+
+	// State behind the watch* event checks in events.go.
+	cpuHotSince  time.Time
+	cpuHotRaised bool
+	throttling   bool
+	proxySeen    bool
+	lastProxy    string
+	gatewayDown  map[string]bool
+	linkFlaps    map[string]*ifaceFlaps // Recent up/down transitions by interface name.
+	lastRoutes   int
+	arpMACs      map[string]arpSighting // By neighborKey.
+	arpRaised    map[string]bool        // Conflicts already logged and still present.
+
+	// Recent combined rates per interface for --rank-window ordering.
+	rankWindow  int
+	rankHistory map[string][]float64
 }
 
 func NewCollector() *Collector {
-	return &Collector{
-		prevNet:      make(map[string]net.IOCountersStat),
-		rxHistoryBuf: NewRingBuffer(NetworkHistorySize),
-		txHistoryBuf: NewRingBuffer(NetworkHistorySize),
+	c := &Collector{
+		prevNet:      make(map[string]netCounter),
+		netHistory:   make(map[string]*ifaceHistory),
+		noiseFilters: defaultNoiseFilters(runtime.GOOS),
+		pingHistory:  NewRingBuffer(latencyHistorySize),
+		cmdTimeout:   defaultCmdTimeout,
+		clock:        newMonoClock(),
+		events:       &eventRing{},
+		diskTop:      defaultDiskTop,
 	}
+	c.setCollectorIntervals(defaultCollectorIntervals)
+	c.setHistorySize(NetworkHistorySize)
+	return c
+}
+
+// setHistorySize (re)creates the network, CPU and memory history buffers
+// with room for n samples, discarding what they held.
+func (c *Collector) setHistorySize(n int) {
+	c.rxHistoryBuf = NewRingBuffer(n)
+	c.txHistoryBuf = NewRingBuffer(n)
+	c.cpuHistoryBuf = NewRingBuffer(n)
+	c.memHistoryBuf = NewRingBuffer(n)
+}
+
+// prime takes a baseline sample and waits delay, so that the next Collect
+// already has rates. The baseline stays out of the CPU and memory histories,
+// which would otherwise gain a point only delay before the next one; the
+// network history never records a baseline.
+func (c *Collector) prime(delay time.Duration) {
+	c.priming = true
+	_, _ = c.Collect()
+	c.priming = false
+	time.Sleep(delay)
+}
+
+// rebaseline forgets the previous network and disk counters, so the next
+// Collect only sets a baseline instead of averaging over a long pause.
+func (c *Collector) rebaseline() {
+	c.netWindow.started = false
+	c.diskWindow.started = false
 }
 
 func (c *Collector) Collect() (MetricsSnapshot, error) {
 	now := time.Now()
+	tick := c.clock()
 
 	// Host info is cached by gopsutil; fetch once.
 	hostInfo, _ := host.Info()
@@ -241,6 +505,7 @@ func (c *Collector) Collect() (MetricsSnapshot, error) {
 		diskStats    []DiskStatus
 		diskIO       DiskIOStatus
 		netStats     []NetworkStatus
+		connStats    ConnectionStatus
 		proxyStats   ProxyStatus
 		batteryStats []BatteryStatus
 		thermalStats ThermalStatus
@@ -248,6 +513,19 @@ func (c *Collector) Collect() (MetricsSnapshot, error) {
 		gpuStats     []GPUStatus
 		btStats      []BluetoothDevice
 		topProcs     []ProcessInfo
+		latency      *LatencyStatus
+		publicIP     *PublicIPStatus
+		container    *ContainerStatus
+		unit         *UnitStatus
+		firewall     *FirewallStatus
+		tcp          *TCPStatus
+		ipFamilies   *IPFamilyStatus
+		procStates   map[string]int
+		gateways     map[string]GatewayStatus
+		routeCount   int
+		signals      map[string]int
+		memProcs     []MemProcessInfo
+		cgroupStats  []NetworkStatus
 	)
 
 	// Helper to launch concurrent collection.
@@ -267,18 +545,61 @@ func (c *Collector) Collect() (MetricsSnapshot, error) {
 		}()
 	}
 
+	// Fast external commands each get cmdTimeout; slow, cached ones
+	// (system_profiler, powermetrics) keep their own budgets.
+	ctx := withCmdTimeout(context.Background(), c.cmdTimeout)
+
+	// Network rates need a previous sample; flag the first cycle so the view
+	// can say so instead of looking broken.
+	netWarmup := !c.netWindow.started
+
 	// Launch independent collection tasks.
-	collect(func() (err error) { cpuStats, err = collectCPU(); return })
-	collect(func() (err error) { memStats, err = collectMemory(); return })
-	collect(func() (err error) { diskStats, err = collectDisks(); return })
-	collect(func() (err error) { diskIO = c.collectDiskIO(now); return nil })
-	collect(func() (err error) { netStats, err = c.collectNetwork(now); return })
-	collect(func() (err error) { proxyStats = collectProxy(); return nil })
-	collect(func() (err error) { batteryStats, _ = collectBatteries(); return nil })
-	collect(func() (err error) { thermalStats = collectThermal(); return nil })
+	collect(func() (err error) {
+		if cpuStats, err = collectCPU(ctx); err == nil && !c.priming {
+			c.cpuHistoryBuf.Add(cpuStats.Usage)
+		}
+		cpuStats.History = c.cpuHistoryBuf.Slice()
+		return
+	})
+	collect(func() (err error) {
+		if memStats, err = collectMemory(ctx); err == nil && !c.priming {
+			c.memHistoryBuf.Add(memStats.UsedPercent)
+		}
+		memStats.History = c.memHistoryBuf.Slice()
+		return
+	})
+	collect(func() (err error) {
+		diskStats, err = c.disks.get(now, func() ([]DiskStatus, error) { return collectDisks(c.diskTop) })
+		return
+	})
+	collect(func() (err error) { diskIO = c.collectDiskIO(tick); return nil })
+	collect(func() (err error) { netStats, err = c.collectNetwork(tick); return })
+	collect(func() (err error) { connStats, _ = c.conns.get(now, c.collectConnections); return nil })
+	if c.cgroups != nil {
+		collect(func() (err error) { cgroupStats = c.collectCgroupTraffic(ctx, tick); return nil })
+	}
+	collect(func() (err error) { tcp = c.collectTCP(tick); return nil })
+	if c.ipSplit {
+		collect(func() (err error) { ipFamilies = c.collectIPFamilies(tick); return nil })
+	}
+	collect(func() (err error) {
+		proxyStats = collectProxy(ctx)
+		if c.appProxies {
+			proxyStats.Apps, _ = c.appProxyCache.get(now, func() ([]ProxyStatus, error) { return collectAppProxies(ctx) })
+		}
+		return nil
+	})
+	collect(func() (err error) { batteryStats, _ = collectBatteries(ctx); return nil })
+	collect(func() (err error) {
+		thermalStats = collectThermal(ctx)
+		if p, err := c.pressure.get(now, func() (thermalPressure, error) { return c.collectThermalPressure(ctx) }); err == nil && p.level != "" {
+			thermalStats.ThermalPressure, thermalStats.CPUSpeedLimit, thermalStats.Throttled = p.level, p.speedLimit, p.throttled()
+		}
+		return nil
+	})
 	// Sensors disabled - CPU temp already shown in CPU card
 	// collect(func() (err error) { sensorStats, _ = collectSensors(); return nil })
-	collect(func() (err error) { gpuStats, err = c.collectGPU(now); return })
+	collect(func() (err error) { gpuStats, err = c.collectGPU(ctx, now); return })
 	collect(func() (err error) {
 		// Bluetooth is slow; cache for 30s.
 		if now.Sub(c.lastBTAt) > 30*time.Second || len(c.lastBT) == 0 {
@@ -290,11 +611,92 @@ func (c *Collector) Collect() (MetricsSnapshot, error) {
 		}
 		return nil
 	})
-	collect(func() (err error) { topProcs = collectTopProcesses(); return nil })
+	collect(func() (err error) {
+		topProcs, _ = c.topProcs.get(now, func() ([]ProcessInfo, error) { return collectTopProcesses(ctx), nil })
+		return nil
+	})
+	collect(func() (err error) {
+		procStates, _ = c.procStates.get(now, func() (map[string]int, error) { return collectProcessStates(ctx) })
+		return nil
+	})
+	collect(func() (err error) {
+		gateways, _ = c.gateways.get(now, func() (map[string]GatewayStatus, error) { return collectGateways(ctx) })
+		return nil
+	})
+	collect(func() (err error) {
+		routeCount, _ = c.routes.get(now, func() (int, error) { return collectRouteCount(ctx) })
+		return nil
+	})
+	if c.firewallCheck {
+		collect(func() (err error) {
+			fw, _ := c.firewall.get(now, func() (FirewallStatus, error) { return collectFirewall(ctx) })
+			firewall = &fw
+			return nil
+		})
+	}
+	collect(func() (err error) {
+		signals, _ = c.wifi.get(now, func() (map[string]int, error) {
+			levels, err := collectWiFiSignal()
+			if err == nil {
+				c.recordSignals(levels)
+			}
+			return levels, err
+		})
+		return nil
+	})
+	collect(func() (err error) {
+		memProcs, _ = c.memProcs.get(now, func() ([]MemProcessInfo, error) { return c.collectMemoryProcs(tick) })
+		return nil
+	})
+	if c.docker != nil {
+		collect(func() (err error) { container = c.collectContainer(tick); return nil })
+	}
+	if c.unitName != "" {
+		collect(func() (err error) { unit = c.collectUnit(ctx, tick); return nil })
+	}
+	if c.pingTarget != "" {
+		latency = c.latencySnapshot(now)
+	}
 
 	// Wait for all to complete.
 	wg.Wait()
 
+	if c.publicIPURL != "" {
+		publicIP = c.publicIPSnapshot(now, c.vpnIface)
+	}
+	if c.proxyCheck {
+		c.proxyCheckSnapshot(now, &proxyStats)
+	}
+	if c.arpCheck {
+		c.arpCheckSnapshot(ctx, now, netStats)
+	}
+
+	if c.snmp != nil {
+		netStats = append(netStats, c.snmpSnapshot(now)...)
+	}
+	netStats = append(netStats, cgroupStats...)
+
+	// Link modes need the interface list, so they run after it is known.
+	links, _ := c.links.get(now, func() (map[string]linkMode, error) { return collectLinkModes(ctx, physicalNames(netStats)) })
+	for i := range netStats {
+		if gw, ok := gateways[netStats[i].Name]; ok {
+			netStats[i].Gateway = &gw
+		}
+		if mode, ok := links[netStats[i].Name]; ok {
+			netStats[i].Duplex, netStats[i].Media = mode.duplex, mode.media
+			netStats[i].LinkMbps = mediaSpeedMbps(mode.media)
+		}
+		if dbm, ok := signals[netStats[i].Name]; ok {
+			netStats[i].SignalDBm = dbm
+			netStats[i].SignalHistory = c.signalHistory[netStats[i].Name].Slice()
+		}
+	}
+	c.watchCPU(now, cpuStats.Usage)
+	c.watchThermal(thermalStats)
+	c.watchProxy(proxyStats)
+	c.watchGateways(gateways)
+	c.watchRoutes(routeCount)
+
 	// Dependent tasks (post-collect).
 	// Cache hardware info as it's expensive and rarely changes.
 	if !c.hasStatic || now.Sub(c.lastHWAt) > 10*time.Minute {
@@ -306,7 +708,8 @@ func (c *Collector) Collect() (MetricsSnapshot, error) {
 
 	score, scoreMsg := calculateHealthScore(cpuStats, memStats, diskStats, diskIO, thermalStats)
 
-	return MetricsSnapshot{
+	snapshot := MetricsSnapshot{
+		SchemaVersion:  snapshotSchemaVersion,
 		CollectedAt:    now,
 		Host:           hostInfo.Hostname,
 		Platform:       fmt.Sprintf("%s %s", hostInfo.Platform, hostInfo.PlatformVersion),
@@ -321,17 +724,54 @@ func (c *Collector) Collect() (MetricsSnapshot, error) {
 		Disks:          diskStats,
 		DiskIO:         diskIO,
 		Network:        netStats,
+		NetworkWarmup:  netWarmup,
+		Connections:    connStats,
 		NetworkHistory: NetworkHistory{
 			RxHistory: c.rxHistoryBuf.Slice(),
 			TxHistory: c.txHistoryBuf.Slice(),
 		},
-		Proxy:        proxyStats,
-		Batteries:    batteryStats,
-		Thermal:      thermalStats,
-		Sensors:      sensorStats,
-		Bluetooth:    btStats,
-		TopProcesses: topProcs,
-	}, mergeErr
+		Proxy:         proxyStats,
+		Batteries:     batteryStats,
+		Thermal:       thermalStats,
+		Sensors:       sensorStats,
+		Bluetooth:     btStats,
+		TopProcesses:  topProcs,
+		TopMemory:     memProcs,
+		Latency:       latency,
+		PublicIP:      publicIP,
+		Container:     container,
+		Unit:          unit,
+		Firewall:      firewall,
+		TCP:           tcp,
+		IPFamilies:    ipFamilies,
+		ProcessStates: procStates,
+		RouteCount:    routeCount,
+		Events:        c.events.recent(),
+	}
+	if n := c.exportEvents; n > 0 {
+		snapshot.RecentEvents = snapshot.Events[max(len(snapshot.Events)-n, 0):]
+	}
+	sanitizeSnapshot(&snapshot)
+	return snapshot, mergeErr
+}
+
+// defaultCmdTimeout bounds each fast external command (scutil, sysctl, ps, ioreg, ...).
+const defaultCmdTimeout = 500 * time.Millisecond
+
+type cmdTimeoutKey struct{}
+
+// withCmdTimeout attaches the per-command budget to ctx for collectors that shell out.
+func withCmdTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, cmdTimeoutKey{}, d)
+}
+
+// cmdContext derives the context for one external command from the budget carried in ctx.
+func cmdContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	d, ok := ctx.Value(cmdTimeoutKey{}).(time.Duration)
+	if !ok || d <= 0 {
+		d = defaultCmdTimeout
+	}
+	return context.WithTimeout(ctx, d)
 }
 
 func runCmd(ctx context.Context, name string, args ...string) (string, error) {
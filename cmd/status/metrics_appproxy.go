@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// collectAppProxies reports proxies configured inside individual tools (git, npm, curl),
+// which can route differently from the system or environment proxy.
+// Only used with --app-proxies, since it spawns git.
+func collectAppProxies(ctx context.Context) ([]ProxyStatus, error) {
+	var proxies []ProxyStatus
+	if commandExists("git") {
+		ctx, cancel := cmdContext(ctx)
+		out, err := runCmd(ctx, "git", "config", "--global", "--get-regexp", `^https?\.proxy$`)
+		cancel()
+		if err == nil {
+			proxies = append(proxies, parseGitProxies(out)...)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if content, err := os.ReadFile(filepath.Join(home, ".npmrc")); err == nil {
+			proxies = append(proxies, parseRCProxies("npm", string(content), "proxy", "https-proxy")...)
+		}
+		if content, err := os.ReadFile(filepath.Join(home, ".curlrc")); err == nil {
+			proxies = append(proxies, parseRCProxies("curl", string(content), "proxy", "--proxy", "-x")...)
+		}
+	}
+	return proxies, nil
+}
+
+// parseGitProxies parses `git config --get-regexp` output ("http.proxy value" per line).
+func parseGitProxies(out string) []ProxyStatus {
+	var values []string
+	for line := range strings.Lines(out) {
+		if _, value, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			values = append(values, value)
+		}
+	}
+	return proxyEntries("git", values)
+}
+
+// parseRCProxies reads "key = value" or "key value" settings from an rc file,
+// skipping # and ; comments.
+func parseRCProxies(source, content string, keys ...string) []ProxyStatus {
+	var values []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			key, value, ok = strings.Cut(line, " ")
+		}
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		for _, k := range keys {
+			if key == k {
+				values = append(values, strings.Trim(strings.TrimSpace(value), `"'`))
+				break
+			}
+		}
+	}
+	return proxyEntries(source, values)
+}
+
+// proxyEntries turns raw proxy URLs into entries, collapsing duplicates such as
+// identical http and https settings.
+func proxyEntries(source string, values []string) []ProxyStatus {
+	var entries []ProxyStatus
+	seen := make(map[string]bool)
+	for _, v := range values {
+		p := proxyFromURL(v)
+		if !p.Enabled || seen[p.Type+p.Host] {
+			continue
+		}
+		seen[p.Type+p.Host] = true
+		p.Source = source
+		entries = append(entries, p)
+	}
+	return entries
+}
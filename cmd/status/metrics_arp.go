@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// arpFlapWindow is how soon an address must swing back to a MAC it had
+// before for the swing to count as a conflict. One change on its own is a
+// replaced device or a new DHCP lease; two hosts answering for the same IP
+// make the neighbor entry flip back and forth.
+const arpFlapWindow = 10 * time.Minute
+
+// arpingTimeout bounds one duplicate-address probe; arping waits -w seconds.
+const arpingTimeout = 4 * time.Second
+
+// arpScan is one --arp-check pass: the neighbor table plus the conflicts the
+// system reports or an active probe found.
+type arpScan struct {
+	at        time.Time
+	macs      map[string]string // neighborKey(ip, iface) to MAC.
+	conflicts []string          // Ready-made event text.
+}
+
+// arpTarget is one of this host's IPv4 addresses, probed for duplicates.
+type arpTarget struct {
+	iface, ip string
+}
+
+// collectARPScan reads the neighbor table and the addresses the kernel
+// flagged as duplicates: IPv6 "dadfailed" from `ip addr` on Linux, "duplicated"
+// from ifconfig on macOS. With arping on the path (Linux, needs CAP_NET_RAW)
+// it also asks whether another host answers for each of targets.
+func collectARPScan(ctx context.Context, targets []arpTarget) (arpScan, error) {
+	scan := arpScan{at: time.Now()}
+	var neighArgs, addrArgs []string
+	var parseMACs func(string) map[string]string
+	var parseDuplicates func(string) []string
+	switch runtime.GOOS {
+	case "linux":
+		neighArgs, addrArgs = []string{"ip", "neigh", "show"}, []string{"ip", "-o", "addr", "show"}
+		parseMACs, parseDuplicates = parseLinuxNeighborMACs, parseLinuxDADFailed
+	case "darwin":
+		neighArgs, addrArgs = []string{"arp", "-an"}, []string{"ifconfig"}
+		parseMACs, parseDuplicates = parseDarwinARPMACs, parseDarwinDuplicated
+	default:
+		return scan, errors.New("arp check unsupported")
+	}
+	if !commandExists(neighArgs[0]) {
+		return scan, errors.New(neighArgs[0] + " unavailable")
+	}
+
+	cmdCtx, cancel := cmdContext(ctx)
+	defer cancel()
+	out, err := runCmd(cmdCtx, neighArgs[0], neighArgs[1:]...)
+	if err != nil {
+		return scan, err
+	}
+	scan.macs = parseMACs(out)
+	if commandExists(addrArgs[0]) {
+		if out, err := runCmd(cmdCtx, addrArgs[0], addrArgs[1:]...); err == nil {
+			scan.conflicts = parseDuplicates(out)
+		}
+	}
+	if runtime.GOOS == "linux" && commandExists("arping") {
+		for _, t := range targets {
+			if arpingDuplicate(ctx, t) {
+				scan.conflicts = append(scan.conflicts, "another host answers ARP for "+t.ip+" on "+t.iface)
+			}
+		}
+	}
+	return scan, nil
+}
+
+// arpingDuplicate runs iputils duplicate address detection, which exits 1
+// when some host replies for the address. Other failures, such as missing
+// privileges (exit 2), say nothing about a conflict.
+func arpingDuplicate(ctx context.Context, t arpTarget) bool {
+	ctx, cancel := context.WithTimeout(ctx, arpingTimeout)
+	defer cancel()
+	err := exec.CommandContext(ctx, "arping", "-D", "-q", "-c", "2", "-w", "3", "-I", t.iface, t.ip).Run()
+	var exit *exec.ExitError
+	return errors.As(err, &exit) && exit.ExitCode() == 1
+}
+
+// parseLinuxNeighborMACs reads the lladdr of each `ip neigh show` entry:
+// "192.168.1.1 dev eth0 lladdr aa:bb:cc:dd:ee:ff REACHABLE". FAILED and
+// INCOMPLETE entries have none.
+func parseLinuxNeighborMACs(out string) map[string]string {
+	macs := make(map[string]string)
+	for line := range strings.Lines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[1] != "dev" || fields[3] != "lladdr" {
+			continue
+		}
+		macs[neighborKey(fields[0], fields[2])] = strings.ToLower(fields[4])
+	}
+	return macs
+}
+
+// parseDarwinARPMACs reads the MAC of each resolved `arp -an` entry:
+// "? (192.168.1.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]".
+func parseDarwinARPMACs(out string) map[string]string {
+	macs := make(map[string]string)
+	for line := range strings.Lines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[2] != "at" || fields[4] != "on" || fields[3] == "(incomplete)" {
+			continue
+		}
+		macs[neighborKey(strings.Trim(fields[1], "()"), fields[5])] = strings.ToLower(fields[3])
+	}
+	return macs
+}
+
+// parseLinuxDADFailed finds addresses in `ip -o addr show` that failed
+// duplicate address detection:
+// "2: eth0    inet6 fe80::1/64 scope link dadfailed tentative \ valid_lft forever".
+func parseLinuxDADFailed(out string) []string {
+	var conflicts []string
+	for line := range strings.Lines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.Contains(line, " dadfailed") {
+			continue
+		}
+		ip, _, _ := strings.Cut(fields[3], "/")
+		conflicts = append(conflicts, "duplicate address "+ip+" on "+fields[1])
+	}
+	return conflicts
+}
+
+// parseDarwinDuplicated finds inet6 addresses ifconfig marks "duplicated",
+// under the interface header line they follow.
+func parseDarwinDuplicated(out string) []string {
+	var conflicts []string
+	var iface string
+	for line := range strings.Lines(out) {
+		if line != "" && line[0] != ' ' && line[0] != '\t' {
+			iface, _, _ = strings.Cut(line, ":")
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "inet6" || !strings.Contains(line, " duplicated") {
+			continue
+		}
+		ip, _, _ := strings.Cut(fields[1], "%")
+		conflicts = append(conflicts, "duplicate address "+ip+" on "+iface)
+	}
+	return conflicts
+}
+
+// arpTargets lists the IPv4 address of each physical interface row.
+func arpTargets(stats []NetworkStatus) []arpTarget {
+	var targets []arpTarget
+	for _, n := range stats {
+		if n.Kind == ifaceKindPhysical && n.IP != "" && !strings.Contains(n.IP, ":") {
+			targets = append(targets, arpTarget{iface: n.Name, ip: n.IP})
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].iface < targets[j].iface })
+	return targets
+}
+
+// arpCheckSnapshot polls the ARP check in the background, since probing
+// takes seconds, and logs what each new scan turns up.
+func (c *Collector) arpCheckSnapshot(ctx context.Context, now time.Time, stats []NetworkStatus) {
+	targets := arpTargets(stats)
+	scan, ok := c.arpProbe.poll(now, func() arpScan {
+		scan, _ := collectARPScan(ctx, targets)
+		return scan
+	})
+	if !ok || !scan.at.After(c.arpSeenAt) {
+		return
+	}
+	c.arpSeenAt = scan.at
+	c.watchARP(scan)
+}
@@ -19,7 +19,7 @@ var (
 	powerCacheTTL = 30 * time.Second
 )
 
-func collectBatteries() (batts []BatteryStatus, err error) {
+func collectBatteries(ctx context.Context) (batts []BatteryStatus, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			// Swallow panics to keep UI alive.
@@ -29,7 +29,10 @@ func collectBatteries() (batts []BatteryStatus, err error) {
 
 	// macOS: pmset for real-time percentage/status.
 	if runtime.GOOS == "darwin" && commandExists("pmset") {
-		if out, err := runCmd(context.Background(), "pmset", "-g", "batt"); err == nil {
+		pmCtx, cancel := cmdContext(ctx)
+		out, err := runCmd(pmCtx, "pmset", "-g", "batt")
+		cancel()
+		if err == nil {
 			// Health/cycles/capacity from cached system_profiler.
 			health, cycles, capacity := getCachedPowerData()
 			if batts := parsePMSet(out, health, cycles, capacity); len(batts) > 0 {
@@ -169,7 +172,7 @@ func getSystemPowerOutput() string {
 	return cachedPower
 }
 
-func collectThermal() ThermalStatus {
+func collectThermal(ctx context.Context) ThermalStatus {
 	if runtime.GOOS != "darwin" {
 		return ThermalStatus{}
 	}
@@ -192,7 +195,7 @@ func collectThermal() ThermalStatus {
 	}
 
 	// Power metrics from ioreg (fast, real-time).
-	ctxPower, cancelPower := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	ctxPower, cancelPower := cmdContext(ctx)
 	defer cancelPower()
 	if out, err := runCmd(ctxPower, "ioreg", "-rn", "AppleSmartBattery"); err == nil {
 		for line := range strings.Lines(out) {
@@ -268,7 +271,7 @@ func collectThermal() ThermalStatus {
 
 	// Fallback: thermal level proxy.
 	if thermal.CPUTemp == 0 {
-		ctx2, cancel2 := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		ctx2, cancel2 := cmdContext(ctx)
 		defer cancel2()
 		out2, err := runCmd(ctx2, "sysctl", "-n", "machdep.xcpm.cpu_thermal_level")
 		if err == nil {
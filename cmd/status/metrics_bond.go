@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const sysClassNet = "/sys/class/net"
+
+// bondMembers maps each interface enslaved to a Linux bond (bonding/LACP) to
+// the bond's name. Members carry the same traffic as the bond, so counting
+// both would inflate totals. Other platforms report no bonds.
+func bondMembers() map[string]string {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	return bondMembersIn(sysClassNet)
+}
+
+// bondMembersIn reads <root>/<bond>/bonding/slaves for every bond under root.
+func bondMembersIn(root string) map[string]string {
+	paths, _ := filepath.Glob(filepath.Join(root, "*", "bonding", "slaves"))
+	var members map[string]string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		bond := filepath.Base(filepath.Dir(filepath.Dir(path)))
+		for _, member := range strings.Fields(string(data)) {
+			if members == nil {
+				members = make(map[string]string)
+			}
+			members[member] = bond
+		}
+	}
+	return members
+}
+
+// bridgePorts maps each port of a Linux bridge (br0 with eth0, tap0, ...) to
+// the bridge's name. Unlike bond members, ports stay in the totals: the
+// bridge device only carries the host's own share of their traffic.
+func bridgePorts() map[string]string {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	return bridgePortsIn(sysClassNet)
+}
+
+// bridgePortsIn lists <root>/<bridge>/brif/ for every bridge under root.
+func bridgePortsIn(root string) map[string]string {
+	paths, _ := filepath.Glob(filepath.Join(root, "*", "brif", "*"))
+	var ports map[string]string
+	for _, path := range paths {
+		if ports == nil {
+			ports = make(map[string]string)
+		}
+		ports[filepath.Base(path)] = filepath.Base(filepath.Dir(filepath.Dir(path)))
+	}
+	return ports
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// cgroupCounters is the traffic nftables counted for one cgroup.
+type cgroupCounters struct {
+	rx, tx uint64
+}
+
+// cgroupTraffic turns nftables counters on cgroup-matching rules into
+// synthetic interface rows (--cgroup-traffic), one per cgroup.
+type cgroupTraffic struct {
+	window rateWindow
+	prev   map[string]cgroupCounters
+	rx, tx map[string]*RingBuffer
+	noted  bool // The "nothing to read" event was logged.
+}
+
+func newCgroupTraffic() *cgroupTraffic {
+	return &cgroupTraffic{
+		prev: make(map[string]cgroupCounters),
+		rx:   make(map[string]*RingBuffer),
+		tx:   make(map[string]*RingBuffer),
+	}
+}
+
+// nftRuleset is the part of `nft -j list ruleset` read here.
+type nftRuleset struct {
+	Nftables []struct {
+		Chain *nftChain `json:"chain"`
+		Rule  *nftRule  `json:"rule"`
+	} `json:"nftables"`
+}
+
+type nftChain struct {
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Hook   string `json:"hook"`
+}
+
+type nftRule struct {
+	Family string            `json:"family"`
+	Table  string            `json:"table"`
+	Chain  string            `json:"chain"`
+	Expr   []json.RawMessage `json:"expr"`
+}
+
+// nftExpr holds the statements a rule is made of; only one is set per entry.
+type nftExpr struct {
+	Match *struct {
+		Op    string          `json:"op"`
+		Left  json.RawMessage `json:"left"`
+		Right json.RawMessage `json:"right"`
+	} `json:"match"`
+	Counter *struct {
+		Bytes uint64 `json:"bytes"`
+	} `json:"counter"`
+	Jump *struct {
+		Target string `json:"target"`
+	} `json:"jump"`
+	Goto *struct {
+		Target string `json:"target"`
+	} `json:"goto"`
+}
+
+// collectCgroupCounters reads the nftables ruleset. It needs root (or
+// CAP_NET_ADMIN), like nft itself.
+func collectCgroupCounters(ctx context.Context) (map[string]cgroupCounters, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("cgroup traffic is only available on Linux")
+	}
+	if !commandExists("nft") {
+		return nil, errors.New("nft unavailable")
+	}
+	ctx, cancel := cmdContext(ctx)
+	defer cancel()
+	out, err := runCmd(ctx, "nft", "-j", "list", "ruleset")
+	if err != nil {
+		return nil, err
+	}
+	return parseNftCgroupCounters([]byte(out))
+}
+
+// parseNftCgroupCounters sums the counters of rules that match a cgroup,
+// either `socket cgroupv2 level N "path"` or `meta cgroup CLASSID` (net_cls).
+// A rule counts as received traffic in chains reached from an input or
+// prerouting hook and as sent traffic from output or postrouting; forward
+// chains see other hosts' traffic and are skipped.
+func parseNftCgroupCounters(data []byte) (map[string]cgroupCounters, error) {
+	var rs nftRuleset
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("nft ruleset: %w", err)
+	}
+	type chainKey struct{ family, table, name string }
+	hooks := make(map[chainKey]string)
+	var rules []*nftRule
+	for _, item := range rs.Nftables {
+		if ch := item.Chain; ch != nil && ch.Hook != "" {
+			hooks[chainKey{ch.Family, ch.Table, ch.Name}] = ch.Hook
+		}
+		if item.Rule != nil {
+			rules = append(rules, item.Rule)
+		}
+	}
+	// Regular chains take the hook of the base chain that jumps to them;
+	// repeat until nothing changes to follow chains of jumps.
+	for changed := true; changed; {
+		changed = false
+		for _, r := range rules {
+			hook, ok := hooks[chainKey{r.Family, r.Table, r.Chain}]
+			if !ok {
+				continue
+			}
+			for _, raw := range r.Expr {
+				var e nftExpr
+				if json.Unmarshal(raw, &e) != nil {
+					continue
+				}
+				target := ""
+				if e.Jump != nil {
+					target = e.Jump.Target
+				} else if e.Goto != nil {
+					target = e.Goto.Target
+				}
+				key := chainKey{r.Family, r.Table, target}
+				if _, known := hooks[key]; target != "" && !known {
+					hooks[key], changed = hook, true
+				}
+			}
+		}
+	}
+
+	counters := make(map[string]cgroupCounters)
+	for _, r := range rules {
+		var rx bool
+		switch hooks[chainKey{r.Family, r.Table, r.Chain}] {
+		case "input", "prerouting":
+			rx = true
+		case "output", "postrouting":
+		default:
+			continue
+		}
+		var name string
+		var bytes uint64
+		var counted bool
+		for _, raw := range r.Expr {
+			var e nftExpr
+			if json.Unmarshal(raw, &e) != nil {
+				continue
+			}
+			if e.Match != nil && (e.Match.Op == "" || e.Match.Op == "==") {
+				name = cmp.Or(name, nftCgroupName(e.Match.Left, e.Match.Right))
+			}
+			if e.Counter != nil {
+				bytes, counted = e.Counter.Bytes, true
+			}
+		}
+		if name == "" || !counted {
+			continue
+		}
+		c := counters[name]
+		if rx {
+			c.rx += bytes
+		} else {
+			c.tx += bytes
+		}
+		counters[name] = c
+	}
+	return counters, nil
+}
+
+// nftCgroupName names the cgroup a match selects: the cgroup v2 path, or the
+// net_cls classid as major:minor in hex, the way tc writes it.
+func nftCgroupName(left, right json.RawMessage) string {
+	var l struct {
+		Socket *struct {
+			Key string `json:"key"`
+		} `json:"socket"`
+		Meta *struct {
+			Key string `json:"key"`
+		} `json:"meta"`
+	}
+	if json.Unmarshal(left, &l) != nil {
+		return ""
+	}
+	switch {
+	case l.Socket != nil && l.Socket.Key == "cgroupv2":
+		var path string
+		if json.Unmarshal(right, &path) == nil && path != "" {
+			return path
+		}
+	case l.Meta != nil && l.Meta.Key == "cgroup":
+		var classid uint32
+		if json.Unmarshal(right, &classid) == nil {
+			return fmt.Sprintf("%x:%x", classid>>16, classid&0xffff)
+		}
+	}
+	return ""
+}
+
+// collectCgroupTraffic returns one row per cgroup with nftables counters.
+// When there is nothing to read it logs why once and returns no rows.
+func (c *Collector) collectCgroupTraffic(ctx context.Context, tick time.Duration) []NetworkStatus {
+	t := c.cgroups
+	counters, err := c.readCgroupCounters(ctx)
+	if err == nil && len(counters) == 0 {
+		err = errors.New("no nftables counter matches a cgroup")
+	}
+	if err != nil {
+		if !t.noted {
+			t.noted = true
+			c.events.add(severityInfo, categoryNetwork, "cgroup traffic: "+err.Error())
+		}
+		return nil
+	}
+	return t.rates(tick, counters, c.rxHistoryBuf.cap)
+}
+
+// readCgroupCounters reads the nftables cgroup counters through the
+// helper, which runs as root, or locally.
+func (c *Collector) readCgroupCounters(ctx context.Context) (counters map[string]cgroupCounters, err error) {
+	c.viaHelper("cgroup", func(r helperResponse) {
+		counters = make(map[string]cgroupCounters, len(r.Cgroup))
+		for name, hc := range r.Cgroup {
+			counters[name] = cgroupCounters{rx: hc.Rx, tx: hc.Tx}
+		}
+	}, func() { counters, err = collectCgroupCounters(ctx) })
+	return counters, err
+}
+
+// rates computes per-cgroup rates against the previous sample. A cgroup
+// seen for the first time, or whose counters went backwards (the ruleset
+// was reloaded), only sets a baseline.
+func (t *cgroupTraffic) rates(tick time.Duration, counters map[string]cgroupCounters, historySize int) []NetworkStatus {
+	elapsed, ok := t.window.advance(tick)
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var rows []NetworkStatus
+	for _, name := range names {
+		cur := counters[name]
+		prev, known := t.prev[name]
+		t.prev[name] = cur
+		if !ok || !known || cur.rx < prev.rx || cur.tx < prev.tx {
+			continue
+		}
+		rx := float64(cur.rx-prev.rx) / 1024.0 / 1024.0 / elapsed
+		tx := float64(cur.tx-prev.tx) / 1024.0 / 1024.0 / elapsed
+		if t.rx[name] == nil {
+			t.rx[name], t.tx[name] = NewRingBuffer(historySize), NewRingBuffer(historySize)
+		}
+		t.rx[name].Add(rx)
+		t.tx[name].Add(tx)
+		rows = append(rows, NetworkStatus{
+			Name:      "cg:" + name,
+			RxRateMBs: rx,
+			TxRateMBs: tx,
+			Kind:      ifaceKindCgroup,
+			Untotaled: true, // Already counted on the real interfaces.
+			RxBytes:   cur.rx,
+			TxBytes:   cur.tx,
+			RxHistory: t.rx[name].Slice(),
+			TxHistory: t.tx[name].Slice(),
+		})
+	}
+	for name := range t.prev {
+		if _, ok := counters[name]; !ok {
+			delete(t.prev, name)
+			delete(t.rx, name)
+			delete(t.tx, name)
+		}
+	}
+	return rows
+}
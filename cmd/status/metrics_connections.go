@@ -0,0 +1,356 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// connRemoteTop bounds ByRemote to the hosts with the most connections.
+const connRemoteTop = 10
+
+// connIfaceTop bounds each ByIface list.
+const connIfaceTop = 8
+
+// heavyPortsTop bounds each HeavyPorts list.
+const heavyPortsTop = 3
+
+const procPortRange = "/proc/sys/net/ipv4/ip_local_port_range"
+
+type ConnectionStatus struct {
+	Total      int                    `json:"total"`
+	ByState    map[string]int         `json:"by_state,omitempty"`    // ESTABLISHED, LISTEN, TIME_WAIT, ...
+	ByProto    map[string]int         `json:"by_proto,omitempty"`    // TCP, UDP, TCP6, UDP6
+	ByRemote   map[string]int         `json:"by_remote,omitempty"`   // Remote address; the busiest connRemoteTop only.
+	Listeners  []Listener             `json:"listeners,omitempty"`   // TCP sockets in LISTEN, by port.
+	ByIface    map[string][]Conn      `json:"by_iface,omitempty"`    // Connected sockets by the interface holding their local address; connIfaceTop each.
+	HeavyPorts map[string][]PortShare `json:"heavy_ports,omitempty"` // The service ports most of each interface's connected sockets use; an estimate, see heavyPorts.
+	Ephemeral  *EphemeralStatus       `json:"ephemeral,omitempty"`   // Linux only.
+}
+
+// EphemeralStatus is how much of the local port range outgoing connections
+// hold. Once it is used up, new connections fail with EADDRNOTAVAIL; sockets
+// lingering in TIME_WAIT after short requests are the usual cause.
+type EphemeralStatus struct {
+	Low      uint32  `json:"low"`
+	High     uint32  `json:"high"`
+	InUse    int     `json:"in_use"`    // Distinct ports in the range held by connected TCP sockets.
+	TimeWait int     `json:"time_wait"` // Of those, the ports held only by TIME_WAIT sockets.
+	Percent  float64 `json:"percent"`
+}
+
+// Conn is a connected socket: one with a peer.
+type Conn struct {
+	Local   string `json:"local"`  // host:port
+	Remote  string `json:"remote"` // host:port
+	Proto   string `json:"proto"`
+	State   string `json:"state,omitempty"` // Empty for UDP.
+	PID     int32  `json:"pid,omitempty"`
+	Process string `json:"process,omitempty"`
+}
+
+// PortShare is one service port's part of an interface's connected sockets.
+// It counts sockets, not bytes: one busy download weighs as much as an idle
+// SSH session, so it only hints at what drives the traffic.
+type PortShare struct {
+	Port    uint32  `json:"port"`
+	Service string  `json:"service,omitempty"` // Well-known name, e.g. https; empty for other ports.
+	Conns   int     `json:"conns"`
+	Percent float64 `json:"percent"` // Of the interface's connected sockets.
+}
+
+// Listener is a listening TCP socket and the process that owns it.
+type Listener struct {
+	Addr    string `json:"addr"` // Local address, e.g. 0.0.0.0 or ::1.
+	Port    uint32 `json:"port"`
+	Proto   string `json:"proto"`
+	PID     int32  `json:"pid,omitempty"`     // 0 when the OS would not say (other users' sockets without root).
+	Process string `json:"process,omitempty"` // Empty when PID is unknown or the process is gone.
+}
+
+// collectConnections enumerates sockets; throttled by the collector, see throttle.go.
+func collectConnections() (ConnectionStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conns, err := net.ConnectionsWithoutUidsWithContext(ctx, "inet")
+	if err != nil {
+		return ConnectionStatus{}, err
+	}
+	status := summarizeConnections(conns)
+	processName := func(pid int32) string {
+		p, err := process.NewProcessWithContext(ctx, pid)
+		if err != nil {
+			return ""
+		}
+		name, _ := p.NameWithContext(ctx)
+		return name
+	}
+	status.Listeners = listeners(conns, processName)
+	if ifaces, err := net.InterfacesWithContext(ctx); err == nil {
+		owners := localAddrOwners(ifaces)
+		status.ByIface = ifaceConnections(conns, owners, processName)
+		status.HeavyPorts = heavyPorts(conns, owners)
+	}
+	if runtime.GOOS == "linux" {
+		if data, err := os.ReadFile(procPortRange); err == nil {
+			if low, high, err := parsePortRange(string(data)); err == nil {
+				status.Ephemeral = ephemeralUsage(conns, low, high)
+			}
+		}
+	}
+	return status, nil
+}
+
+// parsePortRange reads ip_local_port_range, two numbers separated by a tab.
+func parsePortRange(s string) (low, high uint32, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("%s: want two ports, got %q", procPortRange, s)
+	}
+	l, errLow := strconv.ParseUint(fields[0], 10, 16)
+	h, errHigh := strconv.ParseUint(fields[1], 10, 16)
+	if errLow != nil || errHigh != nil || l > h {
+		return 0, 0, fmt.Errorf("%s: bad range %q", procPortRange, s)
+	}
+	return uint32(l), uint32(h), nil
+}
+
+// ephemeralUsage counts the local ports in [low, high] that connected TCP
+// sockets hold. Listening sockets are left out: a server bound inside the
+// range takes one port, not a share of the outgoing pool.
+func ephemeralUsage(conns []net.ConnectionStat, low, high uint32) *EphemeralStatus {
+	// A port counts as TIME_WAIT only while no live socket shares it.
+	waiting := make(map[uint32]bool)
+	for _, conn := range conns {
+		port := conn.Laddr.Port
+		if conn.Type != syscall.SOCK_STREAM || conn.Status == "LISTEN" || port < low || port > high {
+			continue
+		}
+		tw := conn.Status == "TIME_WAIT"
+		if prev, seen := waiting[port]; seen {
+			tw = tw && prev
+		}
+		waiting[port] = tw
+	}
+	status := &EphemeralStatus{Low: low, High: high, InUse: len(waiting)}
+	for _, tw := range waiting {
+		if tw {
+			status.TimeWait++
+		}
+	}
+	status.Percent = float64(status.InUse) / float64(high-low+1) * 100
+	return status
+}
+
+// listeners collects the LISTEN sockets ordered by port, naming each owner
+// once through processName.
+func listeners(conns []net.ConnectionStat, processName func(int32) string) []Listener {
+	var list []Listener
+	names := make(map[int32]string)
+	for _, conn := range conns {
+		if conn.Status != "LISTEN" {
+			continue
+		}
+		l := Listener{Addr: conn.Laddr.IP, Port: conn.Laddr.Port, Proto: connProto(conn), PID: conn.Pid}
+		if l.PID > 0 {
+			name, ok := names[l.PID]
+			if !ok {
+				name = processName(l.PID)
+				names[l.PID] = name
+			}
+			l.Process = name
+		}
+		list = append(list, l)
+	}
+	slices.SortFunc(list, func(a, b Listener) int {
+		return cmp.Or(cmp.Compare(a.Port, b.Port), cmp.Compare(a.Proto, b.Proto), cmp.Compare(a.Addr, b.Addr))
+	})
+	return list
+}
+
+// localAddrOwners maps each interface address to the interface's name.
+func localAddrOwners(ifaces net.InterfaceStatList) map[string]string {
+	owners := make(map[string]string)
+	for _, iface := range ifaces {
+		for _, addr := range iface.Addrs {
+			host, _, _ := strings.Cut(addr.Addr, "/")
+			owners[host] = iface.Name
+		}
+	}
+	return owners
+}
+
+// ifaceConnections lists the connected sockets of each interface in owners,
+// an interface having as many addresses as it likes. Established
+// connections come first; each owner is named once through processName.
+func ifaceConnections(conns []net.ConnectionStat, owners map[string]string, processName func(int32) string) map[string][]Conn {
+	byIface := make(map[string][]Conn)
+	names := make(map[int32]string)
+	for _, conn := range slices.SortedFunc(slices.Values(conns), func(a, b net.ConnectionStat) int {
+		if ea, eb := a.Status == "ESTABLISHED", b.Status == "ESTABLISHED"; ea != eb {
+			if ea {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(cmp.Compare(a.Raddr.IP, b.Raddr.IP), cmp.Compare(a.Raddr.Port, b.Raddr.Port), cmp.Compare(a.Laddr.Port, b.Laddr.Port))
+	}) {
+		if ip := conn.Raddr.IP; ip == "" || ip == "0.0.0.0" || ip == "::" || ip == "*" {
+			continue
+		}
+		// Dual-stack sockets report IPv4 peers as IPv4-mapped IPv6.
+		local := strings.TrimPrefix(conn.Laddr.IP, "::ffff:")
+		iface, ok := owners[local]
+		if !ok || len(byIface[iface]) == connIfaceTop {
+			continue
+		}
+		c := Conn{
+			Local:  hostPort(local, conn.Laddr.Port),
+			Remote: hostPort(strings.TrimPrefix(conn.Raddr.IP, "::ffff:"), conn.Raddr.Port),
+			Proto:  connProto(conn),
+			PID:    conn.Pid,
+		}
+		if conn.Type == syscall.SOCK_STREAM {
+			c.State = conn.Status
+		}
+		if c.PID > 0 {
+			name, ok := names[c.PID]
+			if !ok {
+				name = processName(c.PID)
+				names[c.PID] = name
+			}
+			c.Process = name
+		}
+		byIface[iface] = append(byIface[iface], c)
+	}
+	return byIface
+}
+
+// wellKnownPorts names the service ports heavyPorts reports.
+var wellKnownPorts = map[uint32]string{
+	22: "ssh", 25: "smtp", 53: "dns", 80: "http", 110: "pop3", 123: "ntp",
+	143: "imap", 443: "https", 445: "smb", 465: "smtps", 587: "submission",
+	993: "imaps", 995: "pop3s", 1194: "openvpn", 1883: "mqtt", 3306: "mysql",
+	3389: "rdp", 5060: "sip", 5222: "xmpp", 5353: "mdns", 5432: "postgres",
+	6379: "redis", 8080: "http-alt", 8443: "https-alt", 9092: "kafka",
+	27017: "mongodb", 51820: "wireguard",
+}
+
+// heavyPorts estimates which services carry each interface's traffic from
+// the connected sockets on its addresses in owners, since no OS reports
+// bytes per socket cheaply. Each socket counts once for its service port:
+// the local port when something listens on it (a client of ours), otherwise
+// the peer's. Interfaces without connected sockets are left out.
+func heavyPorts(conns []net.ConnectionStat, owners map[string]string) map[string][]PortShare {
+	listening := make(map[uint32]bool)
+	for _, conn := range conns {
+		if conn.Status == "LISTEN" {
+			listening[conn.Laddr.Port] = true
+		}
+	}
+	counts := make(map[string]map[uint32]int)
+	totals := make(map[string]int)
+	for _, conn := range conns {
+		if ip := conn.Raddr.IP; ip == "" || ip == "0.0.0.0" || ip == "::" || ip == "*" {
+			continue
+		}
+		iface, ok := owners[strings.TrimPrefix(conn.Laddr.IP, "::ffff:")]
+		if !ok {
+			continue
+		}
+		port := conn.Raddr.Port
+		if listening[conn.Laddr.Port] {
+			port = conn.Laddr.Port
+		}
+		if counts[iface] == nil {
+			counts[iface] = make(map[uint32]int)
+		}
+		counts[iface][port]++
+		totals[iface]++
+	}
+	shares := make(map[string][]PortShare, len(counts))
+	for iface, ports := range counts {
+		list := make([]PortShare, 0, len(ports))
+		for port, n := range ports {
+			list = append(list, PortShare{Port: port, Service: wellKnownPorts[port], Conns: n, Percent: float64(n) / float64(totals[iface]) * 100})
+		}
+		slices.SortFunc(list, func(a, b PortShare) int {
+			return cmp.Or(cmp.Compare(b.Conns, a.Conns), cmp.Compare(a.Port, b.Port))
+		})
+		shares[iface] = list[:min(len(list), heavyPortsTop)]
+	}
+	return shares
+}
+
+// hostPort joins a socket address, bracketing IPv6 hosts.
+func hostPort(host string, port uint32) string {
+	if strings.Contains(host, ":") {
+		return fmt.Sprintf("[%s]:%d", host, port)
+	}
+	return fmt.Sprintf("%s:%d", host, port)
+}
+
+func summarizeConnections(conns []net.ConnectionStat) ConnectionStatus {
+	status := ConnectionStatus{
+		Total:    len(conns),
+		ByState:  make(map[string]int),
+		ByProto:  make(map[string]int),
+		ByRemote: make(map[string]int),
+	}
+	for _, conn := range conns {
+		state := conn.Status
+		if state == "" || state == "NONE" {
+			state = "UDP"
+		}
+		status.ByState[state]++
+		status.ByProto[connProto(conn)]++
+		// Listening and unconnected sockets have no peer.
+		if ip := conn.Raddr.IP; ip != "" && ip != "0.0.0.0" && ip != "::" && ip != "*" {
+			status.ByRemote[ip]++
+		}
+	}
+	status.ByRemote = topCounts(status.ByRemote, connRemoteTop)
+	return status
+}
+
+func connProto(conn net.ConnectionStat) string {
+	proto := "TCP"
+	if conn.Type == syscall.SOCK_DGRAM {
+		proto = "UDP"
+	}
+	if conn.Family == syscall.AF_INET6 {
+		proto += "6"
+	}
+	return proto
+}
+
+// sortedCounts orders a count map largest first, ties by name.
+func sortedCounts(counts map[string]int) []string {
+	return slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+}
+
+// topCounts keeps the n largest entries of counts.
+func topCounts(counts map[string]int, n int) map[string]int {
+	keys := sortedCounts(counts)
+	if len(keys) <= n {
+		return counts
+	}
+	top := make(map[string]int, n)
+	for _, k := range keys[:n] {
+		top[k] = counts[k]
+	}
+	return top
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDockerSocket = "/var/run/docker.sock"
+	dockerTimeout       = 2 * time.Second
+	containerGone       = "gone" // State once the container no longer exists.
+)
+
+// ContainerStatus is the focused view of the --container target.
+type ContainerStatus struct {
+	Name       string  `json:"name"`
+	ID         string  `json:"id,omitempty"`
+	State      string  `json:"state,omitempty"` // running, exited, paused, ... or "gone".
+	CPUPercent float64 `json:"cpu_percent"`     // Share of one CPU, like docker stats.
+	MemUsed    uint64  `json:"mem_used"`        // Excludes reclaimable page cache.
+	MemLimit   uint64  `json:"mem_limit"`
+	RxRateMBs  float64 `json:"rx_rate_mbs"`
+	TxRateMBs  float64 `json:"tx_rate_mbs"`
+	Error      string  `json:"error,omitempty"` // Docker API unreachable or refused.
+}
+
+// dockerStats is the subset of GET /containers/{id}/stats we read.
+type dockerStats struct {
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage uint64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		SystemUsage uint64 `json:"system_cpu_usage"`
+		OnlineCPUs  int    `json:"online_cpus"`
+	} `json:"cpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+}
+
+// memUsed mirrors the docker CLI: usage minus inactive page cache, which the
+// kernel reclaims before the container would hit its limit.
+func (s dockerStats) memUsed() uint64 {
+	cache := s.MemoryStats.Stats["inactive_file"] // cgroup v2
+	if v, ok := s.MemoryStats.Stats["total_inactive_file"]; ok {
+		cache = v // cgroup v1
+	}
+	if cache > s.MemoryStats.Usage {
+		return s.MemoryStats.Usage
+	}
+	return s.MemoryStats.Usage - cache
+}
+
+func (s dockerStats) netBytes() (rx, tx uint64) {
+	for _, n := range s.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	return rx, tx
+}
+
+// containerCPUPercent compares two stats samples the way docker stats does:
+// the container's share of host CPU time, scaled by the online CPU count.
+func containerCPUPercent(prev, cur dockerStats) float64 {
+	cpuDelta := float64(cur.CPUStats.CPUUsage.TotalUsage) - float64(prev.CPUStats.CPUUsage.TotalUsage)
+	sysDelta := float64(cur.CPUStats.SystemUsage) - float64(prev.CPUStats.SystemUsage)
+	if cpuDelta <= 0 || sysDelta <= 0 {
+		return 0
+	}
+	cpus := cur.CPUStats.OnlineCPUs
+	if cpus == 0 {
+		cpus = 1
+	}
+	return cpuDelta / sysDelta * float64(cpus) * 100
+}
+
+// dockerClient talks to the Docker Engine API over its unix socket.
+type dockerClient struct {
+	http *http.Client
+}
+
+// newDockerClient uses DOCKER_HOST when it names a unix socket, otherwise the
+// default socket path; Docker Desktop links that path on macOS too.
+func newDockerClient() *dockerClient {
+	socket := defaultDockerSocket
+	if host, ok := strings.CutPrefix(os.Getenv("DOCKER_HOST"), "unix://"); ok && host != "" {
+		socket = host
+	}
+	return newDockerClientAt(socket)
+}
+
+func newDockerClientAt(socket string) *dockerClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &dockerClient{http: &http.Client{Transport: transport}}
+}
+
+// errContainerNotFound is returned for a 404 from the Docker API.
+var errContainerNotFound = errors.New("no such container")
+
+// get decodes the JSON reply of an API path into v.
+func (d *dockerClient) get(ctx context.Context, path string, v any) error {
+	// The host is ignored by the dialer; it only has to be well formed.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errContainerNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("docker API: HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// collectContainer inspects the --container target and, while it runs, takes
+// a stats sample. A stopped or removed container keeps its card with the new
+// state, and an unreachable socket is reported on the card rather than failing
+// the refresh.
+func (c *Collector) collectContainer(tick time.Duration) *ContainerStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerTimeout)
+	defer cancel()
+	status := &ContainerStatus{Name: c.containerName}
+
+	var info struct {
+		ID    string `json:"Id"`
+		Name  string `json:"Name"`
+		State struct {
+			Status  string `json:"Status"`
+			Running bool   `json:"Running"`
+		} `json:"State"`
+	}
+	ref := url.PathEscape(c.containerName)
+	err := c.docker.get(ctx, "/containers/"+ref+"/json", &info)
+	if errors.Is(err, errContainerNotFound) {
+		status.State = containerGone
+	} else if err != nil {
+		status.Error = dockerError(err)
+	} else {
+		status.ID = shortContainerID(info.ID)
+		status.Name = cmp.Or(strings.TrimPrefix(info.Name, "/"), c.containerName)
+		status.State = info.State.Status
+	}
+	c.watchContainer(status.State)
+	if err != nil || !info.State.Running {
+		c.prevContainer = nil
+		c.containerWindow = rateWindow{}
+		return status
+	}
+
+	var stats dockerStats
+	if err := c.docker.get(ctx, "/containers/"+ref+"/stats?stream=false&one-shot=true", &stats); err != nil {
+		status.Error = dockerError(err)
+		return status
+	}
+	status.MemUsed = stats.memUsed()
+	status.MemLimit = stats.MemoryStats.Limit
+	elapsed, ok := c.containerWindow.advance(tick)
+	if prev := c.prevContainer; prev != nil && ok {
+		status.CPUPercent = containerCPUPercent(*prev, stats)
+		rx, tx := stats.netBytes()
+		prx, ptx := prev.netBytes()
+		if rx >= prx && tx >= ptx { // Counters reset when the container restarts.
+			status.RxRateMBs = float64(rx-prx) / 1024 / 1024 / elapsed
+			status.TxRateMBs = float64(tx-ptx) / 1024 / 1024 / elapsed
+		}
+	}
+	c.prevContainer = &stats
+	return status
+}
+
+// dockerError turns a socket failure into something actionable.
+func dockerError(err error) string {
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		return "docker socket not accessible (permission denied)"
+	case errors.Is(err, os.ErrNotExist):
+		return "docker socket not found; is Docker running?"
+	}
+	return err.Error()
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -17,7 +17,7 @@ const (
 	cpuSampleInterval = 200 * time.Millisecond
 )
 
-func collectCPU() (CPUStatus, error) {
+func collectCPU(ctx context.Context) (CPUStatus, error) {
 	counts, countsErr := cpu.Counts(false)
 	if countsErr != nil || counts == 0 {
 		counts = runtime.NumCPU()
@@ -38,7 +38,7 @@ func collectCPU() (CPUStatus, error) {
 	var totalPercent float64
 	perCoreEstimated := false
 	if err != nil || len(percents) == 0 {
-		fallbackUsage, fallbackPerCore, fallbackErr := fallbackCPUUtilization(logical)
+		fallbackUsage, fallbackPerCore, fallbackErr := fallbackCPUUtilization(ctx, logical)
 		if fallbackErr != nil {
 			if err != nil {
 				return CPUStatus{}, err
@@ -61,13 +61,13 @@ func collectCPU() (CPUStatus, error) {
 		loadAvg = *loadStats
 	}
 	if loadErr != nil || isZeroLoad(loadAvg) {
-		if fallback, err := fallbackLoadAvgFromUptime(); err == nil {
+		if fallback, err := fallbackLoadAvgFromUptime(ctx); err == nil {
 			loadAvg = fallback
 		}
 	}
 
 	// P/E core counts for Apple Silicon.
-	pCores, eCores := getCoreTopology()
+	pCores, eCores := getCoreTopology(ctx)
 
 	return CPUStatus{
 		Usage:            totalPercent,
@@ -95,7 +95,7 @@ var (
 )
 
 // getCoreTopology returns P/E core counts on Apple Silicon.
-func getCoreTopology() (pCores, eCores int) {
+func getCoreTopology(ctx context.Context) (pCores, eCores int) {
 	if runtime.GOOS != "darwin" {
 		return 0, 0
 	}
@@ -107,7 +107,7 @@ func getCoreTopology() (pCores, eCores int) {
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	ctx, cancel := cmdContext(ctx)
 	defer cancel()
 
 	out, err := runCmd(ctx, "sysctl", "-n",
@@ -150,11 +150,11 @@ func getCoreTopology() (pCores, eCores int) {
 	return pCores, eCores
 }
 
-func fallbackLoadAvgFromUptime() (load.AvgStat, error) {
+func fallbackLoadAvgFromUptime(ctx context.Context) (load.AvgStat, error) {
 	if !commandExists("uptime") {
 		return load.AvgStat{}, errors.New("uptime command unavailable")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	ctx, cancel := cmdContext(ctx)
 	defer cancel()
 
 	out, err := runCmd(ctx, "uptime")
@@ -202,7 +202,7 @@ func fallbackLoadAvgFromUptime() (load.AvgStat, error) {
 	}, nil
 }
 
-func fallbackCPUUtilization(logical int) (float64, []float64, error) {
+func fallbackCPUUtilization(ctx context.Context, logical int) (float64, []float64, error) {
 	if logical <= 0 {
 		logical = runtime.NumCPU()
 	}
@@ -210,7 +210,7 @@ func fallbackCPUUtilization(logical int) (float64, []float64, error) {
 		logical = 1
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	ctx, cancel := cmdContext(ctx)
 	defer cancel()
 
 	out, err := runCmd(ctx, "ps", "-Aceo", "pcpu")
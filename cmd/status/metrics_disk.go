@@ -12,6 +12,9 @@ import (
 	"github.com/shirou/gopsutil/v4/disk"
 )
 
+// defaultDiskTop is how many volumes the disk panel lists unless --disk-top says otherwise.
+const defaultDiskTop = 3
+
 var skipDiskMounts = map[string]bool{
 	"/System/Volumes/VM":       true,
 	"/System/Volumes/Preboot":  true,
@@ -22,7 +25,9 @@ var skipDiskMounts = map[string]bool{
 	"/dev":                     true,
 }
 
-func collectDisks() ([]DiskStatus, error) {
+// collectDisks lists mounted volumes, internal first then largest, keeping at
+// most top of them (0 = all).
+func collectDisks(top int) ([]DiskStatus, error) {
 	partitions, err := disk.Partitions(false)
 	if err != nil {
 		return nil, err
@@ -90,8 +95,8 @@ func collectDisks() ([]DiskStatus, error) {
 		return disks[i].Total > disks[j].Total
 	})
 
-	if len(disks) > 3 {
-		disks = disks[:3]
+	if top > 0 && len(disks) > top {
+		disks = disks[:top]
 	}
 
 	return disks, nil
@@ -179,7 +184,7 @@ func isExternalDisk(device string) (bool, error) {
 	return external, nil
 }
 
-func (c *Collector) collectDiskIO(now time.Time) DiskIOStatus {
+func (c *Collector) collectDiskIO(tick time.Duration) DiskIOStatus {
 	counters, err := disk.IOCounters()
 	if err != nil || len(counters) == 0 {
 		return DiskIOStatus{}
@@ -191,22 +196,20 @@ func (c *Collector) collectDiskIO(now time.Time) DiskIOStatus {
 		total.WriteBytes += v.WriteBytes
 	}
 
-	if c.lastDiskAt.IsZero() {
+	elapsed, ok := c.diskWindow.advance(tick)
+	if !ok {
 		c.prevDiskIO = total
-		c.lastDiskAt = now
-		return DiskIOStatus{}
-	}
-
-	elapsed := now.Sub(c.lastDiskAt).Seconds()
-	if elapsed <= 0 {
-		elapsed = 1
+		c.prevDiskDevs = counters
+		return c.lastDiskIO
 	}
 
 	readRate := float64(total.ReadBytes-c.prevDiskIO.ReadBytes) / 1024 / 1024 / elapsed
 	writeRate := float64(total.WriteBytes-c.prevDiskIO.WriteBytes) / 1024 / 1024 / elapsed
 
+	devices := diskBusy(c.prevDiskDevs, counters, elapsed)
+
 	c.prevDiskIO = total
-	c.lastDiskAt = now
+	c.prevDiskDevs = counters
 
 	if readRate < 0 {
 		readRate = 0
@@ -215,5 +218,32 @@ func (c *Collector) collectDiskIO(now time.Time) DiskIOStatus {
 		writeRate = 0
 	}
 
-	return DiskIOStatus{ReadRate: readRate, WriteRate: writeRate}
+	status := DiskIOStatus{ReadRate: readRate, WriteRate: writeRate, Devices: devices}
+	for _, d := range devices {
+		status.BusyPercent = max(status.BusyPercent, d.BusyPercent)
+	}
+	c.lastDiskIO = status
+	return status
+}
+
+// diskBusy derives per-device busy percentages from the io_time counter
+// (milliseconds the device had I/O in flight, Linux /proc/diskstats). Devices
+// without busy accounting, or whose counter went backwards (wrap or reset), are skipped.
+func diskBusy(prev, cur map[string]disk.IOCountersStat, elapsed float64) []DiskDeviceIO {
+	var devices []DiskDeviceIO
+	for name, c := range cur {
+		p, ok := prev[name]
+		if !ok || c.IoTime == 0 || c.IoTime < p.IoTime {
+			continue
+		}
+		busy := float64(c.IoTime-p.IoTime) / (elapsed * 1000) * 100
+		devices = append(devices, DiskDeviceIO{Name: name, BusyPercent: min(busy, 100)})
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].BusyPercent != devices[j].BusyPercent {
+			return devices[i].BusyPercent > devices[j].BusyPercent
+		}
+		return devices[i].Name < devices[j].Name
+	})
+	return devices
 }
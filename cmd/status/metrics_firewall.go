@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const socketfilterfwPath = "/usr/libexec/ApplicationFirewall/socketfilterfw"
+
+// Firewall states reported in FirewallStatus.State.
+const (
+	firewallEnabled  = "enabled"
+	firewallDisabled = "disabled"
+	firewallUnknown  = "unknown"
+)
+
+// FirewallStatus is the host firewall as the first tool that answered
+// reports it (--firewall).
+type FirewallStatus struct {
+	Tool  string `json:"tool,omitempty"` // ufw, nftables, socketfilterfw or pf.
+	State string `json:"state"`          // enabled, disabled or unknown.
+	Rules int    `json:"rules"`          // Rough count: rules, or allowed apps for socketfilterfw; -1 = unknown.
+	Note  string `json:"note,omitempty"` // Why the state is unknown, e.g. the tools need root.
+}
+
+// collectFirewall asks the platform's firewall tools in turn: ufw, then
+// nftables on Linux; the application firewall, then pf on macOS; pf on the
+// BSDs. Reading rules usually needs root, so without it the state is
+// unknown and says why. It never fails.
+func collectFirewall(ctx context.Context) (FirewallStatus, error) {
+	var readers []func(context.Context) (FirewallStatus, error)
+	switch runtime.GOOS {
+	case "linux":
+		readers = append(readers, readUfw, readNft)
+	case "darwin":
+		readers = append(readers, readSocketfilterfw, readPf)
+	case "freebsd", "openbsd", "netbsd", "dragonfly":
+		readers = append(readers, readPf)
+	default:
+		return FirewallStatus{State: firewallUnknown, Rules: -1, Note: "not supported on " + runtime.GOOS}, nil
+	}
+	var notes []string
+	for _, read := range readers {
+		status, err := read(ctx)
+		if err == nil {
+			return status, nil
+		}
+		notes = append(notes, err.Error())
+	}
+	return FirewallStatus{State: firewallUnknown, Rules: -1, Note: strings.Join(notes, "; ")}, nil
+}
+
+// firewallCmd runs one tool, telling a missing tool and a refusal apart.
+func firewallCmd(ctx context.Context, name string, args ...string) (string, error) {
+	if !commandExists(name) {
+		return "", errors.New(name + " unavailable")
+	}
+	ctx, cancel := cmdContext(ctx)
+	defer cancel()
+	out, err := runCmd(ctx, name, args...)
+	if err != nil {
+		return "", errors.New(name + " needs root")
+	}
+	return out, nil
+}
+
+func readUfw(ctx context.Context) (FirewallStatus, error) {
+	out, err := firewallCmd(ctx, "ufw", "status")
+	if err != nil {
+		return FirewallStatus{}, err
+	}
+	return parseUfwStatus(out)
+}
+
+// parseUfwStatus reads `ufw status`: a Status line, then a table of rules
+// below a dashed rule when active.
+func parseUfwStatus(out string) (FirewallStatus, error) {
+	status := FirewallStatus{Tool: "ufw"}
+	inTable := false
+	for line := range strings.Lines(out) {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Status:"):
+			switch strings.TrimSpace(strings.TrimPrefix(line, "Status:")) {
+			case "active":
+				status.State = firewallEnabled
+			case "inactive":
+				status.State = firewallDisabled
+			}
+		case strings.HasPrefix(line, "--"):
+			inTable = true
+		case inTable && line != "":
+			status.Rules++
+		}
+	}
+	if status.State == "" {
+		return FirewallStatus{}, errors.New("ufw status unreadable")
+	}
+	return status, nil
+}
+
+func readNft(ctx context.Context) (FirewallStatus, error) {
+	out, err := firewallCmd(ctx, "nft", "list", "ruleset")
+	if err != nil {
+		return FirewallStatus{}, err
+	}
+	return parseNftRuleset(out), nil
+}
+
+// parseNftRuleset counts the rules in `nft list ruleset`: every statement
+// inside a chain other than its type and policy line. The firewall counts as
+// enabled when there is a rule or a chain drops by default.
+func parseNftRuleset(out string) FirewallStatus {
+	status := FirewallStatus{Tool: "nftables", State: firewallDisabled}
+	drops := false
+	depth, chainDepth := 0, -1
+	for line := range strings.Lines(out) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasSuffix(line, "{"):
+			depth++
+			if strings.HasPrefix(line, "chain ") {
+				chainDepth = depth
+			}
+		case line == "}":
+			if depth == chainDepth {
+				chainDepth = -1
+			}
+			depth--
+		case depth == chainDepth && strings.HasPrefix(line, "type "):
+			drops = drops || strings.Contains(line, "policy drop")
+		case depth == chainDepth:
+			status.Rules++
+		}
+	}
+	if status.Rules > 0 || drops {
+		status.State = firewallEnabled
+	}
+	return status
+}
+
+func readSocketfilterfw(ctx context.Context) (FirewallStatus, error) {
+	out, err := firewallCmd(ctx, socketfilterfwPath, "--getglobalstate")
+	if err != nil {
+		return FirewallStatus{}, err
+	}
+	status := FirewallStatus{Tool: "socketfilterfw", State: parseSocketfilterfwState(out), Rules: -1}
+	if status.State == firewallUnknown {
+		return FirewallStatus{}, errors.New("socketfilterfw state unreadable")
+	}
+	if apps, err := firewallCmd(ctx, socketfilterfwPath, "--listapps"); err == nil {
+		status.Rules = parseSocketfilterfwApps(apps)
+	}
+	return status, nil
+}
+
+// parseSocketfilterfwState reads "Firewall is enabled. (State = 1)".
+func parseSocketfilterfwState(out string) string {
+	switch {
+	case strings.Contains(out, "is enabled"), strings.Contains(out, "is blocking all"):
+		return firewallEnabled
+	case strings.Contains(out, "is disabled"):
+		return firewallDisabled
+	}
+	return firewallUnknown
+}
+
+var socketfilterfwAppsRe = regexp.MustCompile(`total number of apps = (\d+)`)
+
+// parseSocketfilterfwApps reads the app count from `--listapps`, or -1.
+func parseSocketfilterfwApps(out string) int {
+	if m := socketfilterfwAppsRe.FindStringSubmatch(out); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return -1
+}
+
+func readPf(ctx context.Context) (FirewallStatus, error) {
+	out, err := firewallCmd(ctx, "pfctl", "-s", "info")
+	if err != nil {
+		return FirewallStatus{}, err
+	}
+	status := FirewallStatus{Tool: "pf", State: firewallUnknown, Rules: -1}
+	for line := range strings.Lines(out) {
+		if state, ok := strings.CutPrefix(strings.TrimSpace(line), "Status:"); ok {
+			fields := strings.Fields(state)
+			if len(fields) > 0 && fields[0] == "Enabled" {
+				status.State = firewallEnabled
+			} else if len(fields) > 0 && fields[0] == "Disabled" {
+				status.State = firewallDisabled
+			}
+		}
+	}
+	if rules, err := firewallCmd(ctx, "pfctl", "-s", "rules"); err == nil {
+		status.Rules = 0
+		for line := range strings.Lines(rules) {
+			if strings.TrimSpace(line) != "" {
+				status.Rules++
+			}
+		}
+	}
+	return status, nil
+}
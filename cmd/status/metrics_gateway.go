@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"strings"
+)
+
+// GatewayStatus is the neighbor (ARP/NDP) state of an interface's default
+// gateway: a LAN connectivity signal that needs no internet access.
+type GatewayStatus struct {
+	IP        string `json:"ip"`
+	State     string `json:"state"` // As reported: REACHABLE, STALE, FAILED, incomplete, none, ...
+	Reachable bool   `json:"reachable"`
+}
+
+// collectGateways maps interface names to their default gateway's neighbor
+// state, from `ip route`/`ip neigh` on Linux or `netstat -rn`/`arp -an` on macOS.
+func collectGateways(ctx context.Context) (map[string]GatewayStatus, error) {
+	var routeArgs, neighArgs []string
+	var parseRoutes func(string) map[string]string
+	var parseNeighbors func(string) map[string]string
+	switch runtime.GOOS {
+	case "linux":
+		routeArgs, neighArgs = []string{"ip", "route", "show", "default"}, []string{"ip", "neigh", "show"}
+		parseRoutes, parseNeighbors = parseLinuxDefaultRoutes, parseLinuxNeighbors
+	case "darwin":
+		routeArgs, neighArgs = []string{"netstat", "-rn", "-f", "inet"}, []string{"arp", "-an"}
+		parseRoutes, parseNeighbors = parseDarwinDefaultRoutes, parseDarwinARP
+	default:
+		return nil, errors.New("gateway reachability unsupported")
+	}
+	if !commandExists(routeArgs[0]) || !commandExists(neighArgs[0]) {
+		return nil, errors.New("gateway tools unavailable")
+	}
+
+	ctx, cancel := cmdContext(ctx)
+	defer cancel()
+	routes, err := runCmd(ctx, routeArgs[0], routeArgs[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	neighbors, err := runCmd(ctx, neighArgs[0], neighArgs[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return gatewayStates(parseRoutes(routes), parseNeighbors(neighbors)), nil
+}
+
+// gatewayStates joins default routes (iface -> gateway IP) with neighbor
+// states keyed by neighborKey. A gateway with no neighbor entry has never
+// answered ARP and counts as unreachable.
+func gatewayStates(routes, neighbors map[string]string) map[string]GatewayStatus {
+	states := make(map[string]GatewayStatus, len(routes))
+	for iface, ip := range routes {
+		state, ok := neighbors[neighborKey(ip, iface)]
+		if !ok {
+			state = "none"
+		}
+		states[iface] = GatewayStatus{IP: ip, State: state, Reachable: neighborReachable(state)}
+	}
+	return states
+}
+
+func neighborKey(ip, iface string) string { return ip + "%" + iface }
+
+func neighborReachable(state string) bool {
+	switch strings.ToUpper(state) {
+	case "FAILED", "INCOMPLETE", "NONE":
+		return false
+	}
+	return true
+}
+
+// parseLinuxDefaultRoutes reads `ip route show default`:
+// "default via 192.168.1.1 dev eth0 proto dhcp metric 100".
+func parseLinuxDefaultRoutes(out string) map[string]string {
+	routes := make(map[string]string)
+	for line := range strings.Lines(out) {
+		fields := strings.Fields(line)
+		var via, dev string
+		for i := 0; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "via":
+				via = fields[i+1]
+			case "dev":
+				dev = fields[i+1]
+			}
+		}
+		if via != "" && dev != "" && routes[dev] == "" {
+			routes[dev] = via
+		}
+	}
+	return routes
+}
+
+// parseLinuxNeighbors reads `ip neigh show`:
+// "192.168.1.1 dev eth0 lladdr aa:bb:cc:dd:ee:ff REACHABLE".
+func parseLinuxNeighbors(out string) map[string]string {
+	neighbors := make(map[string]string)
+	for line := range strings.Lines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[1] != "dev" {
+			continue
+		}
+		neighbors[neighborKey(fields[0], fields[2])] = fields[len(fields)-1]
+	}
+	return neighbors
+}
+
+// parseDarwinDefaultRoutes reads `netstat -rn -f inet`:
+// "default            192.168.1.1        UGScg                 en0".
+// Link-only defaults (VPN tunnels, "link#17") have no gateway to probe.
+func parseDarwinDefaultRoutes(out string) map[string]string {
+	routes := make(map[string]string)
+	for line := range strings.Lines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "default" || net.ParseIP(fields[1]) == nil {
+			continue
+		}
+		if iface := fields[3]; routes[iface] == "" {
+			routes[iface] = fields[1]
+		}
+	}
+	return routes
+}
+
+// parseDarwinARP reads `arp -an`:
+// "? (192.168.1.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]"; unresolved
+// entries show "(incomplete)" in place of the MAC.
+func parseDarwinARP(out string) map[string]string {
+	neighbors := make(map[string]string)
+	for line := range strings.Lines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[2] != "at" || fields[4] != "on" {
+			continue
+		}
+		ip := strings.Trim(fields[1], "()")
+		state := "REACHABLE"
+		if fields[3] == "(incomplete)" {
+			state = "incomplete"
+		}
+		neighbors[neighborKey(ip, fields[5])] = state
+	}
+	return neighbors
+}
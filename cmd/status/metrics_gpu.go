@@ -23,7 +23,7 @@ var (
 	gpuIdleResidencyRe   = regexp.MustCompile(`GPU idle residency:\s+([\d.]+)%`)
 )
 
-func (c *Collector) collectGPU(now time.Time) ([]GPUStatus, error) {
+func (c *Collector) collectGPU(ctx context.Context, now time.Time) ([]GPUStatus, error) {
 	if runtime.GOOS == "darwin" {
 		// Static GPU info (cached 10 min).
 		if len(c.cachedGPU) == 0 || c.lastGPUAt.IsZero() || now.Sub(c.lastGPUAt) >= macGPUInfoTTL {
@@ -46,7 +46,7 @@ func (c *Collector) collectGPU(now time.Time) ([]GPUStatus, error) {
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	ctx, cancel := cmdContext(ctx)
 	defer cancel()
 
 	if !commandExists("nvidia-smi") {
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ifaceGroupSpec is one group.<name>=<regex> prefs line.
+type ifaceGroupSpec struct {
+	name, pattern string
+}
+
+// ifaceGroup sums the interfaces whose names match re into one row of its
+// own, with its own sparkline history.
+type ifaceGroup struct {
+	name   string
+	re     *regexp.Regexp
+	rx, tx *RingBuffer
+}
+
+// parseIfaceGroup checks a group's pattern, matched anywhere in an interface
+// name unless anchored: "^wg" takes every WireGuard tunnel.
+func parseIfaceGroup(name, pattern string) (ifaceGroupSpec, error) {
+	if pattern == "" {
+		return ifaceGroupSpec{}, fmt.Errorf("group %q needs a pattern", name)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return ifaceGroupSpec{}, fmt.Errorf("group %q: %w", name, err)
+	}
+	return ifaceGroupSpec{name: name, pattern: pattern}, nil
+}
+
+func newIfaceGroups(specs []ifaceGroupSpec, historySize int) []*ifaceGroup {
+	groups := make([]*ifaceGroup, 0, len(specs))
+	for _, s := range specs {
+		groups = append(groups, &ifaceGroup{
+			name: s.name,
+			re:   regexp.MustCompile(s.pattern), // Checked when the prefs were read.
+			rx:   NewRingBuffer(historySize),
+			tx:   NewRingBuffer(historySize),
+		})
+	}
+	return groups
+}
+
+// groupRates returns one row per group with the summed rates and counters
+// of its members among lists, in prefs order. A group without members
+// present still gets a row, at zero. Bond members are skipped as their
+// traffic is already on the bond. The rows are kept out of totals, which
+// count the members themselves.
+func groupRates(groups []*ifaceGroup, lists ...[]NetworkStatus) []NetworkStatus {
+	rows := make([]NetworkStatus, 0, len(groups))
+	for _, g := range groups {
+		row := NetworkStatus{Name: g.name, Kind: ifaceKindGroup, Untotaled: true}
+		for _, list := range lists {
+			for _, n := range list {
+				if n.Bond != "" || !g.re.MatchString(n.Name) {
+					continue
+				}
+				row.Members = append(row.Members, n.Name)
+				row.RxRateMBs += n.RxRateMBs
+				row.TxRateMBs += n.TxRateMBs
+				row.RxBytes += n.RxBytes
+				row.TxBytes += n.TxBytes
+			}
+		}
+		g.rx.Add(row.RxRateMBs)
+		g.tx.Add(row.TxRateMBs)
+		row.RxHistory, row.TxHistory = g.rx.Slice(), g.tx.Slice()
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// dropGroupMembers removes the interfaces any group takes from list, in
+// place, for rows shown instead of their members (--group-members=false).
+// The removed rows are appended to dropped so totals can still count them.
+func dropGroupMembers(groups []*ifaceGroup, list, dropped []NetworkStatus) ([]NetworkStatus, []NetworkStatus) {
+	kept := list[:0]
+	for _, n := range list {
+		member := false
+		for _, g := range groups {
+			if n.Bond == "" && g.re.MatchString(n.Name) {
+				member = true
+				break
+			}
+		}
+		if member {
+			dropped = append(dropped, n)
+		} else {
+			kept = append(kept, n)
+		}
+	}
+	return kept, dropped
+}
@@ -57,54 +57,6 @@ func TestFormatUptime(t *testing.T) {
 	}
 }
 
-func TestColorizeTempThresholds(t *testing.T) {
-	tests := []struct {
-		temp     float64
-		expected string
-	}{
-		{temp: 30.0, expected: "30.0"}, // Normal - should use okStyle (green)
-		{temp: 55.9, expected: "55.9"}, // Just below warning threshold
-		{temp: 56.0, expected: "56.0"}, // Warning threshold - should use warnStyle (yellow)
-		{temp: 65.0, expected: "65.0"}, // Mid warning range
-		{temp: 75.9, expected: "75.9"}, // Just below danger threshold
-		{temp: 76.0, expected: "76.0"}, // Danger threshold - should use dangerStyle (red)
-		{temp: 90.0, expected: "90.0"}, // High temperature
-		{temp: 0.0, expected: "0.0"},   // Edge case: zero
-	}
-
-	for _, tt := range tests {
-		result := colorizeTemp(tt.temp)
-		// Check that result contains the formatted temperature value
-		if !strings.Contains(result, tt.expected) {
-			t.Errorf("colorizeTemp(%.1f) = %q, should contain %q", tt.temp, result, tt.expected)
-		}
-		// Verify output is not empty and contains the temperature
-		if result == "" {
-			t.Errorf("colorizeTemp(%.1f) returned empty string", tt.temp)
-		}
-	}
-}
-
-func TestColorizeTempStyleRanges(t *testing.T) {
-	normalTemp := colorizeTemp(40.0)
-	warningTemp := colorizeTemp(65.0)
-	dangerTemp := colorizeTemp(85.0)
-
-	if normalTemp == "" || warningTemp == "" || dangerTemp == "" {
-		t.Fatal("colorizeTemp should not return empty strings")
-	}
-
-	if !strings.Contains(normalTemp, "40.0") {
-		t.Errorf("normal temp should contain '40.0', got: %s", normalTemp)
-	}
-	if !strings.Contains(warningTemp, "65.0") {
-		t.Errorf("warning temp should contain '65.0', got: %s", warningTemp)
-	}
-	if !strings.Contains(dangerTemp, "85.0") {
-		t.Errorf("danger temp should contain '85.0', got: %s", dangerTemp)
-	}
-}
-
 func TestCalculateHealthScoreEdgeCases(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	procNetNetstat = "/proc/net/netstat"
+	procNetSNMP6   = "/proc/net/snmp6"
+)
+
+// IPFamilyStatus splits host-wide IP traffic into IPv4 and IPv6 (--ip-split).
+// Interface counters always add both families together, and the kernel only
+// keeps this split per host, loopback included. Linux only.
+type IPFamilyStatus struct {
+	V4RxMBs float64 `json:"ipv4_rx_mbs"`
+	V4TxMBs float64 `json:"ipv4_tx_mbs"`
+	V6RxMBs float64 `json:"ipv6_rx_mbs"`
+	V6TxMBs float64 `json:"ipv6_tx_mbs"`
+}
+
+// ipFamilyCounters are the cumulative octet counters the split comes from.
+type ipFamilyCounters struct {
+	v4In, v4Out, v6In, v6Out uint64
+}
+
+// readIPv6Octets pulls Ip6InOctets and Ip6OutOctets from /proc/net/snmp6,
+// which prints one "name value" pair per line.
+func readIPv6Octets(r io.Reader) (in, out uint64, err error) {
+	found := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || (fields[0] != "Ip6InOctets" && fields[0] != "Ip6OutOctets") {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s: %s %q: %w", procNetSNMP6, fields[0], fields[1], err)
+		}
+		if fields[0] == "Ip6InOctets" {
+			in = v
+		} else {
+			out = v
+		}
+		found++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if found < 2 {
+		return 0, 0, fmt.Errorf("%s: no Ip6InOctets/Ip6OutOctets", procNetSNMP6)
+	}
+	return in, out, nil
+}
+
+func readIPFamilyCounters() (ipFamilyCounters, error) {
+	f, err := os.Open(procNetNetstat)
+	if err != nil {
+		return ipFamilyCounters{}, err
+	}
+	v4, err := readProcGroup(f, procNetNetstat, "IpExt:", "InOctets", "OutOctets")
+	f.Close()
+	if err != nil {
+		return ipFamilyCounters{}, err
+	}
+	c := ipFamilyCounters{v4In: v4[0], v4Out: v4[1]}
+	// A kernel without IPv6 has no snmp6; report its half as zero.
+	if f, err := os.Open(procNetSNMP6); err == nil {
+		c.v6In, c.v6Out, err = readIPv6Octets(f)
+		f.Close()
+		if err != nil {
+			return ipFamilyCounters{}, err
+		}
+	}
+	return c, nil
+}
+
+// collectIPFamilies samples the octet counters and returns rates against the
+// previous sample; nil off Linux, while they are unreadable, and on the first
+// or a reset sample.
+func (c *Collector) collectIPFamilies(tick time.Duration) *IPFamilyStatus {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	cur, err := readIPFamilyCounters()
+	if err != nil {
+		c.ipFamilyWindow = rateWindow{}
+		return nil
+	}
+	prev := c.prevIPFamily
+	c.prevIPFamily = cur
+	return ipFamilyRates(prev, cur, &c.ipFamilyWindow, tick)
+}
+
+func ipFamilyRates(prev, cur ipFamilyCounters, window *rateWindow, tick time.Duration) *IPFamilyStatus {
+	elapsed, ok := window.advance(tick)
+	if !ok {
+		return nil
+	}
+	rate := func(prev, cur uint64) (float64, bool) {
+		if cur < prev { // 64-bit octet counters do not wrap; this is a reset.
+			return 0, false
+		}
+		return float64(cur-prev) / 1024 / 1024 / elapsed, true
+	}
+	var status IPFamilyStatus
+	var ok4in, ok4out, ok6in, ok6out bool
+	status.V4RxMBs, ok4in = rate(prev.v4In, cur.v4In)
+	status.V4TxMBs, ok4out = rate(prev.v4Out, cur.v4Out)
+	status.V6RxMBs, ok6in = rate(prev.v6In, cur.v6In)
+	status.V6TxMBs, ok6out = rate(prev.v6Out, cur.v6Out)
+	if !ok4in || !ok4out || !ok6in || !ok6out {
+		return nil
+	}
+	return &status
+}
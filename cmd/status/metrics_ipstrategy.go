@@ -0,0 +1,86 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Primary IP strategies for --primary-ip, used when an interface has several IPv4 addresses.
+const (
+	ipStrategyFirst        = "first"          // First non-loopback IPv4, as reported by the OS.
+	ipStrategyDefaultRoute = "default-route"  // The address the default route would use.
+	ipStrategySubnetPrefix = "prefer-subnet=" // First address inside the given CIDR.
+)
+
+type ipStrategy struct {
+	name   string
+	subnet *net.IPNet // Only for prefer-subnet.
+}
+
+func parseIPStrategy(value string) (ipStrategy, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "" || value == ipStrategyFirst:
+		return ipStrategy{name: ipStrategyFirst}, nil
+	case value == ipStrategyDefaultRoute:
+		return ipStrategy{name: ipStrategyDefaultRoute}, nil
+	case strings.HasPrefix(value, ipStrategySubnetPrefix):
+		_, subnet, err := net.ParseCIDR(strings.TrimPrefix(value, ipStrategySubnetPrefix))
+		if err != nil {
+			return ipStrategy{}, fmt.Errorf("invalid subnet in %q: %w", value, err)
+		}
+		return ipStrategy{name: ipStrategySubnetPrefix, subnet: subnet}, nil
+	}
+	return ipStrategy{}, fmt.Errorf("unknown primary IP strategy %q (want first, default-route or prefer-subnet=CIDR)", value)
+}
+
+func (s ipStrategy) String() string {
+	if s.name == ipStrategySubnetPrefix && s.subnet != nil {
+		return ipStrategySubnetPrefix + s.subnet.String()
+	}
+	return cmp.Or(s.name, ipStrategyFirst)
+}
+
+// pick chooses one of an interface's IPv4 addresses, falling back to the first
+// when the strategy has no match on this interface.
+func (s ipStrategy) pick(addrs []string, routeIP string) string {
+	if len(addrs) == 0 {
+		return ""
+	}
+	for _, addr := range addrs {
+		switch s.name {
+		case ipStrategyDefaultRoute:
+			if addr == routeIP {
+				return addr
+			}
+		case ipStrategySubnetPrefix:
+			if ip := net.ParseIP(addr); ip != nil && s.subnet.Contains(ip) {
+				return addr
+			}
+		}
+	}
+	return addrs[0]
+}
+
+// isRoutableIPv6 reports whether addr is an IPv6 address worth showing:
+// link-local fe80:: and loopback ::1 say nothing about reachability.
+func isRoutableIPv6(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.To4() == nil && ip.IsGlobalUnicast()
+}
+
+// defaultRouteIP returns the local address the kernel would pick for outbound
+// traffic. Connecting a UDP socket only consults the routing table; nothing is sent.
+func defaultRouteIP() string {
+	conn, err := net.Dial("udp4", "192.0.2.1:9") // TEST-NET-1, never routed beyond the default gateway.
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
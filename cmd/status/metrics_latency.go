@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const (
+	latencyHistorySize = 60
+	latencyTimeout     = 2 * time.Second
+	latencyDefaultPort = "443"
+)
+
+const (
+	latencyICMP = "icmp"
+	latencyTCP  = "tcp"
+)
+
+type LatencyStatus struct {
+	Target    string    `json:"target"`
+	Method    string    `json:"method"` // icmp or tcp
+	CurrentMs float64   `json:"current_ms"`
+	MinMs     float64   `json:"min_ms"`
+	AvgMs     float64   `json:"avg_ms"`
+	MaxMs     float64   `json:"max_ms"`
+	History   []float64 `json:"history_ms"`
+	Note      string    `json:"note,omitempty"`
+	Error     string    `json:"error,omitempty"` // Last probe failure.
+}
+
+var pingTimeRe = regexp.MustCompile(`time[=<]\s*([0-9.]+)\s*ms`)
+
+// latencySnapshot returns the latest probe result and starts a new probe in the
+// background once pingEvery has elapsed and no probe is in flight.
+func (c *Collector) latencySnapshot(now time.Time) *LatencyStatus {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	if !c.pingBusy && (c.pingLastAt.IsZero() || now.Sub(c.pingLastAt) >= c.pingEvery) {
+		c.pingBusy = true
+		c.pingLastAt = now
+		go c.probeLatency()
+	}
+	status := c.pingLast
+	if status.Target == "" {
+		status = LatencyStatus{Target: c.pingTarget, Method: latencyICMP}
+	}
+	return &status
+}
+
+// probeLatency measures the --ping target once. It prefers ICMP through the system
+// ping binary and switches to TCP connect timing for good once ICMP fails but TCP
+// works, which is the usual outcome when ICMP needs privileges or is filtered.
+func (c *Collector) probeLatency() {
+	c.pingMu.Lock()
+	tcpOnly := c.pingTCPOnly
+	c.pingMu.Unlock()
+
+	var rtt time.Duration
+	err := errors.New("no probe")
+	if !tcpOnly {
+		rtt, err = icmpRTT(c.pingTarget)
+	}
+	if err != nil {
+		if tcpRTT, tcpErr := tcpConnectRTT(c.pingTarget); tcpErr == nil {
+			rtt, err, tcpOnly = tcpRTT, nil, true
+		}
+	}
+
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	c.pingBusy = false
+	if tcpOnly && !c.pingTCPOnly {
+		c.pingTCPOnly = true
+		c.pingNote = "ICMP unavailable, timing TCP connect"
+	}
+
+	status := LatencyStatus{Target: c.pingTarget, Method: latencyICMP, Note: c.pingNote}
+	if c.pingTCPOnly {
+		status.Method = latencyTCP
+	}
+	if err == nil {
+		status.CurrentMs = float64(rtt.Microseconds()) / 1000
+		c.pingHistory.Add(status.CurrentMs)
+	} else {
+		status.Error = err.Error()
+	}
+	status.History = c.pingHistory.Slice()
+	status.MinMs, status.AvgMs, status.MaxMs = latencySpread(status.History)
+	c.pingLast = status
+}
+
+func icmpRTT(target string) (time.Duration, error) {
+	host := target
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		host = h
+	}
+	countFlag := "-c"
+	if runtime.GOOS == "windows" {
+		countFlag = "-n"
+	}
+	if !commandExists("ping") {
+		return 0, errors.New("ping not found")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), latencyTimeout)
+	defer cancel()
+	out, err := runCmd(ctx, "ping", countFlag, "1", host)
+	if err != nil {
+		return 0, fmt.Errorf("ping %s: %w", host, err)
+	}
+	return parsePingRTT(out)
+}
+
+// parsePingRTT extracts the round trip from ping output ("time=12.3 ms", or "time<1ms" on Windows).
+func parsePingRTT(out string) (time.Duration, error) {
+	m := pingTimeRe.FindStringSubmatch(out)
+	if m == nil {
+		return 0, errors.New("no reply")
+	}
+	ms, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+func tcpConnectRTT(target string) (time.Duration, error) {
+	addr := target
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		addr = net.JoinHostPort(target, latencyDefaultPort)
+	}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, latencyTimeout)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	_ = conn.Close()
+	return rtt, nil
+}
+
+func latencySpread(history []float64) (minMs, avgMs, maxMs float64) {
+	if len(history) == 0 {
+		return 0, 0, 0
+	}
+	minMs, maxMs = history[0], history[0]
+	var sum float64
+	for _, v := range history {
+		minMs = min(minMs, v)
+		maxMs = max(maxMs, v)
+		sum += v
+	}
+	return minMs, sum / float64(len(history)), maxMs
+}
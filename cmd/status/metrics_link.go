@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// linkMode is the negotiated duplex and media of a wired interface.
+type linkMode struct {
+	duplex string // "full" or "half"; empty when unknown.
+	media  string // e.g. "1000baseT" on macOS, "1000Mb/s Twisted Pair" on Linux.
+}
+
+// collectLinkModes asks `ethtool` (Linux) or `ifconfig` (macOS) for the
+// negotiated mode of each named interface. Interfaces that report nothing
+// useful, such as Wi-Fi or a down port, are left out.
+func collectLinkModes(ctx context.Context, names []string) (map[string]linkMode, error) {
+	var tool string
+	var parse func(string) linkMode
+	switch runtime.GOOS {
+	case "linux":
+		tool, parse = "ethtool", parseEthtool
+	case "darwin":
+		tool, parse = "ifconfig", parseIfconfigMedia
+	default:
+		return nil, errors.New("link mode unsupported")
+	}
+	if !commandExists(tool) {
+		return nil, errors.New(tool + " unavailable")
+	}
+	modes := make(map[string]linkMode, len(names))
+	for _, name := range names {
+		cmdCtx, cancel := cmdContext(ctx)
+		out, err := runCmd(cmdCtx, tool, name)
+		cancel()
+		if err != nil {
+			continue
+		}
+		if mode := parse(out); mode != (linkMode{}) {
+			modes[name] = mode
+		}
+	}
+	return modes, nil
+}
+
+// parseEthtool reads the Speed, Duplex and Port lines of `ethtool <iface>`.
+func parseEthtool(out string) linkMode {
+	var mode linkMode
+	var speed, port string
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, "Unknown") || value == "Other" {
+			continue
+		}
+		switch key {
+		case "Speed":
+			speed = value
+		case "Duplex":
+			mode.duplex = strings.ToLower(value)
+		case "Port":
+			port = value
+		}
+	}
+	mode.media = strings.TrimSpace(speed + " " + port)
+	return mode
+}
+
+// parseIfconfigMedia reads the negotiated part of the macOS media line,
+// "media: autoselect (1000baseT <full-duplex,flow-control>)".
+func parseIfconfigMedia(out string) linkMode {
+	for _, line := range strings.Split(out, "\n") {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), "media:")
+		if !ok {
+			continue
+		}
+		_, active, ok := strings.Cut(rest, "(")
+		if !ok {
+			return linkMode{} // Not negotiated, or Wi-Fi's bare "autoselect".
+		}
+		active = strings.TrimSuffix(strings.TrimSpace(active), ")")
+		media, opts, _ := strings.Cut(active, "<")
+		mode := linkMode{media: strings.TrimSpace(media)}
+		for _, opt := range strings.Split(strings.TrimSuffix(opts, ">"), ",") {
+			switch strings.TrimSpace(opt) {
+			case "full-duplex":
+				mode.duplex = "full"
+			case "half-duplex":
+				mode.duplex = "half"
+			}
+		}
+		if mode.media == "none" {
+			return linkMode{}
+		}
+		return mode
+	}
+	return linkMode{}
+}
+
+// mediaSpeedMbps reads the link speed from a media string: ethtool's
+// "1000Mb/s Twisted Pair" or ifconfig's "1000baseT" and "10GbaseT".
+// It returns 0 when there is no number to read.
+func mediaSpeedMbps(media string) int {
+	field, _, _ := strings.Cut(media, " ")
+	field = strings.ToLower(field)
+	unit := 1
+	num := field
+	for _, suffix := range []struct {
+		s    string
+		mbps int
+	}{{"mb/s", 1}, {"gbase", 1000}, {"mbase", 1}, {"base", 1}} {
+		if i := strings.Index(field, suffix.s); i > 0 {
+			num, unit = field[:i], suffix.mbps
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return int(n * float64(unit))
+}
+
+// physicalNames lists the wired-capable interfaces of a sample.
+func physicalNames(netStats []NetworkStatus) []string {
+	var names []string
+	for _, n := range netStats {
+		if n.Kind == ifaceKindPhysical {
+			names = append(names, n.Name)
+		}
+	}
+	return names
+}
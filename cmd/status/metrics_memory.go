@@ -5,24 +5,23 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/shirou/gopsutil/v4/mem"
 )
 
-func collectMemory() (MemoryStatus, error) {
+func collectMemory(ctx context.Context) (MemoryStatus, error) {
 	vm, err := mem.VirtualMemory()
 	if err != nil {
 		return MemoryStatus{}, err
 	}
 
 	swap, _ := mem.SwapMemory()
-	pressure := getMemoryPressure()
+	pressure := getMemoryPressure(ctx)
 
 	// On macOS, vm.Cached is 0, so we calculate from file-backed pages.
 	cached := vm.Cached
 	if runtime.GOOS == "darwin" && cached == 0 {
-		cached = getFileBackedMemory()
+		cached = getFileBackedMemory(ctx)
 	}
 
 	return MemoryStatus{
@@ -36,8 +35,8 @@ func collectMemory() (MemoryStatus, error) {
 	}, nil
 }
 
-func getFileBackedMemory() uint64 {
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+func getFileBackedMemory(ctx context.Context) uint64 {
+	ctx, cancel := cmdContext(ctx)
 	defer cancel()
 	out, err := runCmd(ctx, "vm_stat")
 	if err != nil {
@@ -75,11 +74,11 @@ func getFileBackedMemory() uint64 {
 	return 0
 }
 
-func getMemoryPressure() string {
+func getMemoryPressure(ctx context.Context) string {
 	if runtime.GOOS != "darwin" {
 		return ""
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	ctx, cancel := cmdContext(ctx)
 	defer cancel()
 	out, err := runCmd(ctx, "memory_pressure")
 	if err != nil {
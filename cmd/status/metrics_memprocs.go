@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// memProcsTop is the number of rows in the top-memory panel.
+const memProcsTop = 5
+
+// MemProcessInfo is one row of the top-memory panel.
+type MemProcessInfo struct {
+	PID  int32   `json:"pid"`
+	Name string  `json:"name"`
+	RSS  uint64  `json:"rss"`
+	CPU  float64 `json:"cpu"` // Percent of one core since the previous refresh.
+
+	NumFDs    int32  `json:"num_fds"`              // Open file descriptors; -1 when not permitted.
+	FDLimit   uint64 `json:"fd_limit,omitempty"`   // Soft RLIMIT_NOFILE; 0 when unknown (macOS).
+	FDGrowing bool   `json:"fd_growing,omitempty"` // Rising every walk and past half the limit.
+}
+
+// fdTrendSamples is how many consecutive walks a descriptor count must rise
+// across before it is flagged as a likely leak.
+const fdTrendSamples = 4
+
+// collectMemoryProcs returns the heaviest processes by RSS and by CPU.
+// Walking every process is expensive, so the collector throttles it.
+func (c *Collector) collectMemoryProcs(tick time.Duration) ([]MemProcessInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed, _ := c.memProcsWindow.advance(tick)
+	cpuTimes := make(map[int32]float64, len(procs))
+	handles := make(map[int32]*process.Process, len(procs))
+	samples := make([]MemProcessInfo, 0, len(procs))
+	for _, p := range procs {
+		mem, err := p.MemoryInfoWithContext(ctx)
+		if err != nil || mem == nil {
+			continue // Exited, or owned by another user.
+		}
+		s := MemProcessInfo{PID: p.Pid, RSS: mem.RSS}
+		if t, err := p.TimesWithContext(ctx); err == nil {
+			total := t.User + t.System
+			cpuTimes[p.Pid] = total
+			if prev, ok := c.prevProcCPU[p.Pid]; ok && elapsed > 0 && total >= prev {
+				s.CPU = (total - prev) / elapsed * 100
+			}
+		}
+		handles[p.Pid] = p
+		samples = append(samples, s)
+	}
+
+	// Names and descriptors are only resolved for the rows that make the cut.
+	top := topProcessSamples(samples, memProcsTop)
+	fdHistory := make(map[int32][]int32, len(top))
+	for i := range top {
+		p := handles[top[i].PID]
+		if name, err := p.NameWithContext(ctx); err == nil {
+			top[i].Name = name
+		}
+		top[i].NumFDs = -1
+		if n, err := p.NumFDsWithContext(ctx); err == nil {
+			top[i].NumFDs = n
+			top[i].FDLimit = fdSoftLimit(ctx, p)
+			history := append(c.fdHistory[p.Pid], n)
+			if len(history) > fdTrendSamples {
+				history = history[len(history)-fdTrendSamples:]
+			}
+			fdHistory[p.Pid] = history
+			top[i].FDGrowing = fdGrowing(history, top[i].FDLimit)
+		}
+	}
+	c.fdHistory = fdHistory
+
+	c.prevProcCPU = cpuTimes
+	return top, nil
+}
+
+// fdSoftLimit returns the process's soft open-files limit, or 0 when the
+// platform does not expose it (gopsutil has no Rlimit on macOS).
+func fdSoftLimit(ctx context.Context, p *process.Process) uint64 {
+	limits, err := p.RlimitWithContext(ctx)
+	if err != nil {
+		return 0
+	}
+	for _, l := range limits {
+		if l.Resource == process.RLIMIT_NOFILE {
+			return l.Soft
+		}
+	}
+	return 0
+}
+
+// fdGrowing reports whether the counts rose on every one of the last
+// fdTrendSamples walks and the latest is past half of limit.
+func fdGrowing(history []int32, limit uint64) bool {
+	if limit == 0 || len(history) < fdTrendSamples {
+		return false
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i] <= history[i-1] {
+			return false
+		}
+	}
+	return uint64(history[len(history)-1]) >= limit/2
+}
+
+// topProcessSamples keeps the top n samples by RSS plus the top n by CPU, so the
+// view can re-sort either way without another enumeration. Result is RSS-ordered.
+func topProcessSamples(samples []MemProcessInfo, n int) []MemProcessInfo {
+	byCPU := slices.Clone(samples)
+	slices.SortStableFunc(byCPU, func(a, b MemProcessInfo) int { return compareDesc(a.CPU, b.CPU) })
+	byRSS := slices.Clone(samples)
+	slices.SortStableFunc(byRSS, func(a, b MemProcessInfo) int { return compareDesc(a.RSS, b.RSS) })
+
+	keep := make(map[int32]bool, 2*n)
+	for i := 0; i < n && i < len(byRSS); i++ {
+		keep[byRSS[i].PID] = true
+	}
+	for i := 0; i < n && i < len(byCPU); i++ {
+		keep[byCPU[i].PID] = true
+	}
+	var top []MemProcessInfo
+	for _, s := range byRSS {
+		if keep[s.PID] {
+			top = append(top, s)
+		}
+	}
+	return top
+}
+
+func compareDesc[T float64 | uint64](a, b T) int {
+	switch {
+	case a > b:
+		return -1
+	case a < b:
+		return 1
+	}
+	return 0
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"net/url"
 	"os"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,125 +15,548 @@ import (
 	"github.com/shirou/gopsutil/v4/net"
 )
 
-func (c *Collector) collectNetwork(now time.Time) ([]NetworkStatus, error) {
-	stats, err := net.IOCounters(true)
+// netEvictCycles is how many samples an interface may be missing before its
+// baseline counters are dropped. It keeps prevNet bounded on hosts where
+// container veths come and go by the hundred.
+const netEvictCycles = 3
+
+// netHistoryKeep is how long an interface's sparkline history outlives the
+// interface, so one that drops out briefly (a USB adapter replugged, Wi-Fi
+// roaming) picks its graph back up instead of starting over.
+const netHistoryKeep = 60 * time.Second
+
+// defaultIdleRateMBs is the combined rx+tx below which an interface counts as
+// idle when --min-rate is not set (about 1 KB/s).
+const defaultIdleRateMBs = 0.001
+
+// Host lookups behind collectNetwork, swapped for fakes in tests.
+var (
+	netIOCounters    = net.IOCounters
+	hostInterfaceIPs = getInterfaceIPs
+	hostBondMembers  = bondMembers
+	hostBridgePorts  = bridgePorts
+	hostMACs         = interfaceMACs
+)
+
+// netCounter is an interface's last counters and the sample it was seen in.
+type netCounter struct {
+	stat net.IOCountersStat
+	seen uint64
+	idle float64 // Seconds spent below the idle rate; traffic or a counter reset clears it.
+	mac  string  // Hardware address, tracked only with --follow-renames.
+}
+
+// ifaceHistory is one interface's recent rates for its sparklines. It is
+// keyed by name rather than ifaceKey, as a replugged adapter comes back
+// with a new index, and kept for netHistoryKeep after the interface was
+// last seen.
+type ifaceHistory struct {
+	rx, tx *RingBuffer
+	seen   time.Duration // Tick of the last rate added.
+}
+
+func (c *Collector) collectNetwork(tick time.Duration) ([]NetworkStatus, error) {
+	if c.netns != "" {
+		// Addresses and bonds are looked up in the host namespace, so a
+		// --netns run reports counters only.
+		stats, err := netnsIOCounters(c.netns)
+		if err != nil {
+			return nil, err
+		}
+		return c.networkRates(stats, nil, nil, nil, tick), nil
+	}
+	stats, err := netIOCounters(true)
 	if err != nil {
 		return nil, err
 	}
 
 	// Map interface IPs.
-	ifAddrs := getInterfaceIPs()
+	ifAddrs, ifIndexes := hostInterfaceIPs(c.ipStrategy)
+	if c.followRenames {
+		c.ifaceMACs = hostMACs()
+	}
+	c.bridgePorts = hostBridgePorts()
+	return c.networkRates(stats, ifAddrs, ifIndexes, hostBondMembers(), tick), nil
+}
 
-	if c.lastNetAt.IsZero() {
-		c.lastNetAt = now
+// networkRates turns one sample of per-interface counters into rates against
+// the previous sample. It is split from collectNetwork so the per-sample cost
+// can be measured without touching the host.
+func (c *Collector) networkRates(stats []net.IOCountersStat, ifAddrs map[string]string, ifIndexes map[string]int, bonds map[string]string, tick time.Duration) []NetworkStatus {
+	c.netCycle++
+	elapsed, ok := c.netWindow.advance(tick)
+	defer c.netTrace.flush(time.Now(), tick)
+	if !ok {
 		for _, s := range stats {
-			c.prevNet[s.Name] = s
+			key := ifaceKey(s.Name, ifIndexes[s.Name])
+			p := c.prevNet[key]
+			c.prevNet[key] = netCounter{stat: s, seen: c.netCycle, idle: p.idle, mac: c.ifaceMACs[s.Name]}
+			c.netTrace.add(netTraceEntry{Iface: s.Name, Note: "baseline", PrevRx: p.stat.BytesRecv, CurRx: s.BytesRecv, PrevTx: p.stat.BytesSent, CurTx: s.BytesSent})
 		}
-		return nil, nil
+		return slices.Clone(c.netLast) // Nil on the first sample.
 	}
 
-	elapsed := now.Sub(c.lastNetAt).Seconds()
-	if elapsed <= 0 {
-		elapsed = 1
+	// Candidates go into a scratch slice reused across samples; only the rows
+	// that survive ranking are copied into the returned slice.
+	rows, containers := c.netRows[:0], c.netContainers[:0]
+	c.vpnIface = ""
+	var present map[string]bool
+	if c.followRenames {
+		present = make(map[string]bool, len(stats))
+		for _, s := range stats {
+			present[s.Name] = true
+		}
 	}
-
-	var result []NetworkStatus
 	for _, cur := range stats {
-		if isNoiseInterface(cur.Name) {
+		key := ifaceKey(cur.Name, ifIndexes[cur.Name])
+		prev, known := c.prevNet[key]
+		var renamedFrom string
+		if !known && c.followRenames {
+			// A rename keeps the device and its counters, so pick up where
+			// the old name left off instead of starting a new baseline.
+			if src, p, ok := c.renameSource(cur.Name, present); ok {
+				delete(c.prevNet, src)
+				prev, known, renamedFrom = p, true, p.stat.Name
+				if h, ok := c.netHistory[renamedFrom]; ok {
+					c.netHistory[cur.Name] = h
+					delete(c.netHistory, renamedFrom)
+				}
+				c.events.add(severityInfo, categoryNetwork, "interface renamed: "+renamedFrom+" → "+cur.Name)
+			}
+		}
+		counter := netCounter{stat: cur, seen: c.netCycle, mac: c.ifaceMACs[cur.Name]}
+		kind := classifyInterface(cur.Name)
+		// macOS always has a few utun devices with link-local addresses only;
+		// one holding a routable address is a connected VPN. Checked before the noise filter,
+		// which hides utun.
+		if kind == ifaceKindVPN && c.vpnIface == "" && ifAddrs[key] != "" {
+			c.vpnIface = cur.Name
+		}
+		if c.isNoise(cur.Name) {
 			continue
 		}
-		prev, ok := c.prevNet[cur.Name]
-		if !ok {
+		if bonds[cur.Name] != "" && !c.showBondMembers {
+			continue // Its traffic already shows on the bond.
+		}
+		if !known {
+			c.prevNet[key] = counter
+			c.netTrace.add(netTraceEntry{Iface: cur.Name, Note: "new", Elapsed: elapsed, CurRx: cur.BytesRecv, CurTx: cur.BytesSent})
+			// New since the last sample (USB NIC, VPN): baseline it now so
+			// its rate shows from the next sample. Containers churn too
+			// much to be worth an event.
+			if kind != ifaceKindContainer {
+				c.watchLink(tick, cur.Name, true)
+			}
 			continue
 		}
-		rx := float64(cur.BytesRecv-prev.BytesRecv) / 1024.0 / 1024.0 / elapsed
-		tx := float64(cur.BytesSent-prev.BytesSent) / 1024.0 / 1024.0 / elapsed
-		if rx < 0 {
-			rx = 0
+		// Counters going backwards mean the interface was brought down and up
+		// (or, rarely, wrapped): this interval's rate is unknown, so the row
+		// carries none and the sample becomes the new baseline. The check
+		// comes first, as the unsigned difference would be huge.
+		reset := cur.BytesRecv < prev.stat.BytesRecv || cur.BytesSent < prev.stat.BytesSent
+		var rx, tx float64
+		if !reset {
+			rx = float64(cur.BytesRecv-prev.stat.BytesRecv) / 1024.0 / 1024.0 / elapsed
+			tx = float64(cur.BytesSent-prev.stat.BytesSent) / 1024.0 / 1024.0 / elapsed
+		}
+		entry := netTraceEntry{Iface: cur.Name, Elapsed: elapsed, PrevRx: prev.stat.BytesRecv, CurRx: cur.BytesRecv,
+			PrevTx: prev.stat.BytesSent, CurTx: cur.BytesSent, RxMBs: rx, TxMBs: tx}
+		if reset {
+			entry.Note = "reset"
 		}
-		if tx < 0 {
-			tx = 0
+		c.netTrace.add(entry)
+		if !reset && rx+tx < cmp.Or(c.idleRate, defaultIdleRateMBs) {
+			counter.idle = prev.idle + elapsed
+		}
+		c.prevNet[key] = counter
+		status := NetworkStatus{
+			Name:        cur.Name,
+			RxRateMBs:   rx,
+			TxRateMBs:   tx,
+			RateUnknown: reset,
+			IP:          ifAddrs[key],
+			Kind:        kind,
+			Bond:        bonds[cur.Name],
+			Bridge:      c.bridgePorts[cur.Name],
+			Untotaled:   c.totalsSkip[cur.Name],
+			Renamed:     renamedFrom,
+			RxBytes:     cur.BytesRecv,
+			TxBytes:     cur.BytesSent,
+			RxErrors:    cur.Errin,
+			TxErrors:    cur.Errout,
+			IdleSecs:    counter.idle,
+		}
+		if kind == ifaceKindContainer {
+			containers = append(containers, status)
+			continue
+		}
+		h := c.netHistory[cur.Name]
+		if h == nil {
+			h = &ifaceHistory{rx: NewRingBuffer(c.rxHistoryBuf.cap), tx: NewRingBuffer(c.rxHistoryBuf.cap)}
+			c.netHistory[cur.Name] = h
+		}
+		if !reset {
+			h.rx.Add(rx)
+			h.tx.Add(tx)
+		}
+		h.seen = tick
+		rows = append(rows, status)
+	}
+	// Groups sum every member, including those that will not make the cut.
+	var groups, members []NetworkStatus
+	if len(c.groups) > 0 {
+		groups = groupRates(c.groups, rows, containers)
+		if !c.showGroupMembers {
+			rows, members = dropGroupMembers(c.groups, rows, nil)
+			containers, members = dropGroupMembers(c.groups, containers, members)
 		}
-		result = append(result, NetworkStatus{
-			Name:      cur.Name,
-			RxRateMBs: rx,
-			TxRateMBs: tx,
-			IP:        ifAddrs[cur.Name],
-		})
 	}
+	c.netRows, c.netContainers = rows, containers
 
-	c.lastNetAt = now
-	for _, s := range stats {
-		c.prevNet[s.Name] = s
+	for key, p := range c.prevNet {
+		if c.netCycle-p.seen < netEvictCycles {
+			continue
+		}
+		delete(c.prevNet, key)
+		if !c.isNoise(p.stat.Name) && classifyInterface(p.stat.Name) != ifaceKindContainer {
+			c.watchLink(tick, p.stat.Name, false)
+		}
+	}
+	for name, h := range c.netHistory {
+		if tick-h.seen > netHistoryKeep {
+			delete(c.netHistory, name)
+		}
+	}
+	c.settleLinks(tick)
+
+	c.rankInterfaces(rows)
+	// Pinned interfaces lead and always make the cut, even past three.
+	top := rows[:min(len(rows), max(3, pinFirst(rows, c.pinned)))]
+	// The ports of a bridge that made the cut come along, so the view can
+	// show which of them drive its traffic.
+	if len(c.bridgePorts) > 0 {
+		listed := make(map[string]bool, len(top))
+		for _, r := range top {
+			listed[r.Name] = true
+		}
+		kept := len(top)
+		for _, r := range rows[len(top):] {
+			if r.Bridge != "" && listed[r.Bridge] {
+				rows[kept] = r
+				kept++
+			}
+		}
+		top = rows[:kept]
+	}
+	// Groups lead, outside the top rows. Container interfaces are kept in
+	// full after the top entries so the view can collapse them into one
+	// summary row while totals still include them.
+	sortByThroughput(containers)
+	result := make([]NetworkStatus, 0, len(groups)+len(top)+len(containers))
+	result = append(result, groups...)
+	result = append(result, top...)
+	result = append(result, containers...)
+	// Copy out histories only for the rows that made the cut.
+	for i := range top {
+		r := &result[len(groups)+i]
+		h := c.netHistory[r.Name]
+		r.RxHistory, r.TxHistory = h.rx.Slice(), h.tx.Slice()
+	}
+
+	// Members shown only through their group still count.
+	var totalRx, totalTx float64
+	var unknown bool
+	for _, list := range [][]NetworkStatus{result, members} {
+		for _, r := range list {
+			if c.totalsExcluded[r.Name] || !r.inTotals() {
+				continue
+			}
+			unknown = unknown || r.RateUnknown
+			totalRx += r.RxRateMBs
+			totalTx += r.TxRateMBs
+		}
+	}
+
+	// Update history using the global/aggregated stats. A total missing an
+	// interface whose counters reset would draw a dip that never happened.
+	if !unknown {
+		c.rxHistoryBuf.Add(totalRx)
+		c.txHistoryBuf.Add(totalTx)
 	}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].RxRateMBs+result[i].TxRateMBs > result[j].RxRateMBs+result[j].TxRateMBs
+	c.netLast = result
+	return result
+}
+
+// renameSource finds the counter last seen under another name with the same
+// MAC as name, provided that name is gone from the current sample: VLANs and
+// bond members share their parent's MAC while it is still present. An
+// ambiguous match, or no MAC at all, finds nothing.
+func (c *Collector) renameSource(name string, present map[string]bool) (string, netCounter, bool) {
+	mac := c.ifaceMACs[name]
+	if mac == "" || mac == "00:00:00:00:00:00" {
+		return "", netCounter{}, false
+	}
+	var found string
+	for key, p := range c.prevNet {
+		if p.mac != mac || present[p.stat.Name] {
+			continue
+		}
+		if found != "" {
+			return "", netCounter{}, false
+		}
+		found = key
+	}
+	if found == "" {
+		return "", netCounter{}, false
+	}
+	return found, c.prevNet[found], true
+}
+
+// interfaceMACs maps interface names to their hardware addresses.
+func interfaceMACs() map[string]string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	macs := make(map[string]string, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.HardwareAddr != "" {
+			macs[iface.Name] = strings.ToLower(iface.HardwareAddr)
+		}
+	}
+	return macs
+}
+
+// pinFirst moves the pinned interfaces to the front of list, keeping the
+// order within each part, and returns how many there are.
+func pinFirst(list []NetworkStatus, pinned map[string]bool) int {
+	if len(pinned) == 0 {
+		return 0
+	}
+	slices.SortStableFunc(list, func(a, b NetworkStatus) int {
+		switch pa, pb := pinned[a.Name], pinned[b.Name]; {
+		case pa == pb:
+			return 0
+		case pa:
+			return -1
+		}
+		return 1
 	})
-	if len(result) > 3 {
-		result = result[:3]
+	n := 0
+	for n < len(list) && pinned[list[n].Name] {
+		n++
 	}
+	return n
+}
 
-	var totalRx, totalTx float64
-	for _, r := range result {
-		totalRx += r.RxRateMBs
-		totalTx += r.TxRateMBs
+// rankInterfaces orders list busiest first. With a rank window above one
+// sample it ranks by each interface's mean rate over that window, so a
+// one-tick blip does not reshuffle the top rows.
+func (c *Collector) rankInterfaces(list []NetworkStatus) {
+	if c.rankWindow <= 1 {
+		sortByThroughput(list)
+		return
+	}
+	if c.rankHistory == nil {
+		c.rankHistory = make(map[string][]float64)
+	}
+	scores := make(map[string]float64, len(list))
+	seen := make(map[string]bool, len(list))
+	for _, n := range list {
+		recent := append(c.rankHistory[n.Name], n.RxRateMBs+n.TxRateMBs)
+		if len(recent) > c.rankWindow {
+			recent = recent[len(recent)-c.rankWindow:]
+		}
+		c.rankHistory[n.Name] = recent
+		var sum float64
+		for _, v := range recent {
+			sum += v
+		}
+		scores[n.Name] = sum / float64(len(recent))
+		seen[n.Name] = true
+	}
+	// Forget interfaces that went away so a returning one starts fresh.
+	for name := range c.rankHistory {
+		if !seen[name] {
+			delete(c.rankHistory, name)
+		}
 	}
+	sort.SliceStable(list, func(i, j int) bool { return scores[list[i].Name] > scores[list[j].Name] })
+}
 
-	// Update history using the global/aggregated stats
-	c.rxHistoryBuf.Add(totalRx)
-	c.txHistoryBuf.Add(totalTx)
+func sortByThroughput(list []NetworkStatus) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].RxRateMBs+list[i].TxRateMBs > list[j].RxRateMBs+list[j].TxRateMBs
+	})
+}
 
-	return result, nil
+// ifaceKey identifies an interface by name plus kernel index. An interface that is
+// destroyed and recreated under the same name (VPN tunnels, USB NICs) gets a new
+// index, so its counters are never diffed against the old device's.
+//
+// Interfaces in other network namespaces are not visible to this process at all:
+// gopsutil reads the current namespace's /proc/net/dev and only reports names, so
+// same-named devices elsewhere never reach us and the index only disambiguates
+// within what we can see.
+func ifaceKey(name string, index int) string {
+	if index <= 0 {
+		return name
+	}
+	return name + "#" + strconv.Itoa(index)
 }
 
-func getInterfaceIPs() map[string]string {
-	result := make(map[string]string)
+// getInterfaceIPs returns the primary IPv4 (or, failing that, IPv6) per interface keyed by ifaceKey,
+// chosen by strategy, along with each interface name's kernel index.
+func getInterfaceIPs(strategy ipStrategy) (map[string]string, map[string]int) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
-		return result
+		return map[string]string{}, map[string]int{}
 	}
+	routeIP := ""
+	if strategy.name == ipStrategyDefaultRoute {
+		routeIP = defaultRouteIP()
+	}
+	return interfaceIPs(ifaces, strategy, routeIP)
+}
+
+func interfaceIPs(ifaces net.InterfaceStatList, strategy ipStrategy, routeIP string) (map[string]string, map[string]int) {
+	ips := make(map[string]string)
+	indexes := make(map[string]int)
 	for _, iface := range ifaces {
+		indexes[iface.Name] = iface.Index
+		var candidates, v6 []string
 		for _, addr := range iface.Addrs {
-			// IPv4 only.
+			host := strings.Split(addr.Addr, "/")[0]
 			if strings.Contains(addr.Addr, ".") && !strings.HasPrefix(addr.Addr, "127.") {
-				ip := strings.Split(addr.Addr, "/")[0]
-				result[iface.Name] = ip
-				break
+				candidates = append(candidates, host)
+			} else if isRoutableIPv6(host) {
+				v6 = append(v6, host)
 			}
 		}
+		// IPv4 is preferred; IPv6-only interfaces show their IPv6 address
+		// rather than none.
+		ip := strategy.pick(candidates, routeIP)
+		if ip == "" {
+			ip = strategy.pick(v6, routeIP)
+		}
+		if ip != "" {
+			ips[ifaceKey(iface.Name, iface.Index)] = ip
+		}
 	}
-	return result
+	return ips, indexes
 }
 
-func isNoiseInterface(name string) bool {
+// isNoise reports whether the network card leaves name out: it matches
+// one of noiseFilters and none of showIfaces (--show-interface), which win.
+func (c *Collector) isNoise(name string) bool {
+	return !hasIfacePrefix(name, c.showIfaces) && hasIfacePrefix(name, c.noiseFilters)
+}
+
+// isNoiseInterfaceOn reports whether name is an internal interface on goos
+// by the default filters.
+func isNoiseInterfaceOn(name, goos string) bool {
+	return hasIfacePrefix(name, defaultNoiseFilters(goos))
+}
+
+// defaultNoiseFilters lists the prefixes of internal interfaces on goos. The
+// ap/anpi prefixes are macOS-specific; on Linux they collide with real
+// devices such as an ap0 SoftAP.
+func defaultNoiseFilters(goos string) []string {
+	filters := []string{"lo", "awdl", "utun", "llw", "bridge", "gif", "stf", "xhc"}
+	if goos == "darwin" {
+		filters = append(filters, "anpi", "ap")
+	}
+	return filters
+}
+
+// hasIfacePrefix reports whether name starts with one of prefixes,
+// ignoring case.
+func hasIfacePrefix(name string, prefixes []string) bool {
 	lower := strings.ToLower(name)
-	noiseList := []string{"lo", "awdl", "utun", "llw", "bridge", "gif", "stf", "xhc", "anpi", "ap"}
-	for _, prefix := range noiseList {
-		if strings.HasPrefix(lower, prefix) {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(lower, strings.ToLower(prefix)) {
 			return true
 		}
 	}
 	return false
 }
 
-func collectProxy() ProxyStatus {
+// Interface kinds reported in NetworkStatus.Kind.
+const (
+	ifaceKindPhysical  = "physical"
+	ifaceKindVPN       = "vpn"
+	ifaceKindVirtual   = "virtual"
+	ifaceKindContainer = "container"
+	ifaceKindRemote    = "remote" // Polled from another device with --snmp.
+	ifaceKindCgroup    = "cgroup" // nftables counters per cgroup, with --cgroup-traffic.
+	ifaceKindGroup     = "group"  // The sum of a group.<name>= prefs line's interfaces.
+)
+
+var (
+	// Docker, Kubernetes CNI plugins, LXC and Podman networking.
+	containerIfacePrefixes = []string{"veth", "br-", "docker", "cni", "flannel", "cali", "vxlan", "weave", "cilium", "lxc", "podman", "kube-"}
+	vpnIfacePrefixes       = []string{"utun", "tun", "wg", "ppp", "ipsec", "tailscale", "zt"}
+	virtualIfacePrefixes   = []string{"bridge", "vmnet", "vboxnet", "virbr", "tap", "dummy", "vnic", "gif", "stf"}
+)
+
+// classifyInterface guesses the interface kind from its name.
+func classifyInterface(name string) string {
+	lower := strings.ToLower(name)
+	hasAnyPrefix := func(prefixes []string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(lower, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case hasAnyPrefix(containerIfacePrefixes):
+		return ifaceKindContainer
+	case hasAnyPrefix(vpnIfacePrefixes):
+		return ifaceKindVPN
+	case hasAnyPrefix(virtualIfacePrefixes):
+		return ifaceKindVirtual
+	default:
+		return ifaceKindPhysical
+	}
+}
+
+func collectProxy(ctx context.Context) ProxyStatus {
 	if proxy := collectProxyFromEnv(os.Getenv); proxy.Enabled {
+		proxy.setSource("env")
 		return proxy
 	}
 
-	// macOS: check system proxy via scutil.
-	if runtime.GOOS == "darwin" {
-		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
-		defer cancel()
-		out, err := runCmd(ctx, "scutil", "--proxy")
+	// The system proxy: scutil on macOS, GNOME's settings on Linux desktops,
+	// the Internet Settings registry key on Windows.
+	var system func(string) ProxyStatus
+	var name string
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		system, name, args = collectProxyFromScutilOutput, "scutil", []string{"--proxy"}
+	case "linux":
+		system, name, args = collectProxyFromGsettingsOutput, "gsettings", []string{"list-recursively", "org.gnome.system.proxy"}
+	case "windows":
+		system, name, args = collectProxyFromRegOutput, "reg", []string{"query", internetSettingsKey}
+	}
+	if system != nil && commandExists(name) {
+		cmdCtx, cancel := cmdContext(ctx)
+		out, err := runCmd(cmdCtx, name, args...)
+		cancel()
 		if err == nil {
-			if proxy := collectProxyFromScutilOutput(out); proxy.Enabled {
+			if proxy := system(out); proxy.Enabled {
+				proxy.setSource("system")
 				return proxy
 			}
 		}
+	}
 
+	if runtime.GOOS == "darwin" {
 		if proxy := collectProxyFromTunInterfaces(); proxy.Enabled {
+			proxy.Source = "tun"
 			return proxy
 		}
 	}
@@ -141,60 +566,88 @@ func collectProxy() ProxyStatus {
 
 func collectProxyFromEnv(getenv func(string) string) ProxyStatus {
 	// Include ALL_PROXY for users running proxy tools that only export a single variable.
-	envKeys := []string{
-		"https_proxy", "HTTPS_PROXY",
-		"http_proxy", "HTTP_PROXY",
-		"all_proxy", "ALL_PROXY",
+	envKeys := []struct{ scheme, lower, upper string }{
+		{"https", "https_proxy", "HTTPS_PROXY"},
+		{"http", "http_proxy", "HTTP_PROXY"},
+		{"all", "all_proxy", "ALL_PROXY"},
 	}
+	var found []ProxyStatus
 	for _, key := range envKeys {
-		val := strings.TrimSpace(getenv(key))
+		val := strings.TrimSpace(getenv(key.lower))
+		if val == "" {
+			val = strings.TrimSpace(getenv(key.upper))
+		}
 		if val == "" {
 			continue
 		}
+		proxy := proxyFromURL(val)
+		proxy.Scheme = key.scheme
+		found = append(found, proxy)
+	}
+	return primaryProxy(found)
+}
 
-		proxyType := "HTTP"
-		lower := strings.ToLower(val)
-		if strings.HasPrefix(lower, "socks") {
-			proxyType = "SOCKS"
-		}
+func (p *ProxyStatus) setSource(source string) {
+	p.Source = source
+	for i := range p.Schemes {
+		p.Schemes[i].Source = source
+	}
+}
 
-		host := parseProxyHost(val)
-		if host == "" {
-			host = val
+// primaryProxy returns the first of found, in precedence order, carrying the
+// whole list in Schemes when the entries do not all point at the same proxy.
+func primaryProxy(found []ProxyStatus) ProxyStatus {
+	if len(found) == 0 {
+		return ProxyStatus{Enabled: false}
+	}
+	primary := found[0]
+	for _, p := range found[1:] {
+		if p.Type != primary.Type || p.Host != primary.Host {
+			primary.Schemes = found
+			break
 		}
-		return ProxyStatus{Enabled: true, Type: proxyType, Host: host}
 	}
-
-	return ProxyStatus{Enabled: false}
+	return primary
 }
 
-func collectProxyFromScutilOutput(out string) ProxyStatus {
-	if out == "" {
+// proxyFromURL builds a proxy entry from a proxy URL or bare host:port.
+func proxyFromURL(val string) ProxyStatus {
+	val = strings.TrimSpace(val)
+	if val == "" {
 		return ProxyStatus{Enabled: false}
 	}
+	proxyType := "HTTP"
+	lower := strings.ToLower(val)
+	if strings.HasPrefix(lower, "socks") {
+		proxyType = "SOCKS"
+	}
 
-	if scutilProxyEnabled(out, "SOCKSEnable") {
-		host := joinHostPort(scutilProxyValue(out, "SOCKSProxy"), scutilProxyValue(out, "SOCKSPort"))
-		if host == "" {
-			host = "System Proxy"
-		}
-		return ProxyStatus{Enabled: true, Type: "SOCKS", Host: host}
+	host := parseProxyHost(val)
+	if host == "" {
+		host = val
 	}
+	return ProxyStatus{Enabled: true, Type: proxyType, Host: host}
+}
 
-	if scutilProxyEnabled(out, "HTTPSEnable") {
-		host := joinHostPort(scutilProxyValue(out, "HTTPSProxy"), scutilProxyValue(out, "HTTPSPort"))
-		if host == "" {
-			host = "System Proxy"
-		}
-		return ProxyStatus{Enabled: true, Type: "HTTPS", Host: host}
+func collectProxyFromScutilOutput(out string) ProxyStatus {
+	if out == "" {
+		return ProxyStatus{Enabled: false}
 	}
 
-	if scutilProxyEnabled(out, "HTTPEnable") {
-		host := joinHostPort(scutilProxyValue(out, "HTTPProxy"), scutilProxyValue(out, "HTTPPort"))
+	var found []ProxyStatus
+	for _, kind := range []struct{ key, typ string }{
+		{"SOCKS", "SOCKS"},
+		{"HTTPS", "HTTPS"},
+		{"HTTP", "HTTP"},
+	} {
+		if !scutilProxyEnabled(out, kind.key+"Enable") {
+			continue
+		}
+		host := joinHostPort(scutilProxyValue(out, kind.key+"Proxy"), scutilProxyValue(out, kind.key+"Port"))
 		if host == "" {
 			host = "System Proxy"
 		}
-		return ProxyStatus{Enabled: true, Type: "HTTP", Host: host}
+		found = append(found, ProxyStatus{Enabled: true, Type: kind.typ, Host: host, Scheme: strings.ToLower(kind.key)})
 	}
 
 	if scutilProxyEnabled(out, "ProxyAutoConfigEnable") {
@@ -203,18 +656,106 @@ func collectProxyFromScutilOutput(out string) ProxyStatus {
 		if host == "" {
 			host = "PAC"
 		}
-		return ProxyStatus{Enabled: true, Type: "PAC", Host: host}
+		found = append(found, ProxyStatus{Enabled: true, Type: "PAC", Host: host, Scheme: "auto"})
 	}
 
 	if scutilProxyEnabled(out, "ProxyAutoDiscoveryEnable") {
-		return ProxyStatus{Enabled: true, Type: "WPAD", Host: "Auto Discovery"}
+		found = append(found, ProxyStatus{Enabled: true, Type: "WPAD", Host: "Auto Discovery", Scheme: "auto"})
 	}
 
-	return ProxyStatus{Enabled: false}
+	return primaryProxy(found)
+}
+
+// collectProxyFromGsettingsOutput reads `gsettings list-recursively
+// org.gnome.system.proxy`, one "schema key value" line per setting with
+// strings in single quotes. Mode manual lists the per-scheme hosts (a port
+// of 0 is unset); auto is a PAC URL, or WPAD when the URL is empty.
+func collectProxyFromGsettingsOutput(out string) ProxyStatus {
+	settings := make(map[string]string)
+	for line := range strings.Lines(out) {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		schema := strings.TrimPrefix(strings.TrimPrefix(fields[0], "org.gnome.system.proxy"), ".")
+		settings[strings.TrimPrefix(schema+"."+fields[1], ".")] = strings.Trim(fields[2], "'")
+	}
+
+	var found []ProxyStatus
+	switch settings["mode"] {
+	case "manual":
+		for _, kind := range []struct{ key, typ string }{
+			{"socks", "SOCKS"},
+			{"https", "HTTPS"},
+			{"http", "HTTP"},
+		} {
+			host, port := settings[kind.key+".host"], settings[kind.key+".port"]
+			if host == "" {
+				continue
+			}
+			if port == "0" {
+				port = ""
+			}
+			found = append(found, ProxyStatus{Enabled: true, Type: kind.typ, Host: joinHostPort(host, port), Scheme: kind.key})
+		}
+	case "auto":
+		if host := parseProxyHost(settings["autoconfig-url"]); host != "" {
+			found = append(found, ProxyStatus{Enabled: true, Type: "PAC", Host: host, Scheme: "auto"})
+		} else {
+			found = append(found, ProxyStatus{Enabled: true, Type: "WPAD", Host: "Auto Discovery", Scheme: "auto"})
+		}
+	}
+	return primaryProxy(found)
+}
+
+const internetSettingsKey = `HKCU\Software\Microsoft\Windows\CurrentVersion\Internet Settings`
+
+// collectProxyFromRegOutput reads `reg query` of the Internet Settings key,
+// one "name type value" line per value. ProxyServer holds one host:port for
+// every scheme, or per-scheme entries such as "http=h:80;https=h:443";
+// AutoConfigURL is a PAC script used whether or not ProxyEnable is set.
+func collectProxyFromRegOutput(out string) ProxyStatus {
+	values := make(map[string]string)
+	for line := range strings.Lines(out) {
+		if fields := strings.Fields(line); len(fields) >= 3 && strings.HasPrefix(fields[1], "REG_") {
+			values[fields[0]] = strings.Join(fields[2:], " ")
+		}
+	}
+
+	var found []ProxyStatus
+	if enable, _ := strconv.ParseUint(strings.TrimPrefix(values["ProxyEnable"], "0x"), 16, 32); enable != 0 && values["ProxyServer"] != "" {
+		server := values["ProxyServer"]
+		if !strings.Contains(server, "=") {
+			found = append(found, ProxyStatus{Enabled: true, Type: "HTTP", Host: parseProxyHost(server), Scheme: "all"})
+		}
+		entries := make(map[string]string)
+		for entry := range strings.SplitSeq(server, ";") {
+			if scheme, host, ok := strings.Cut(strings.TrimSpace(entry), "="); ok {
+				entries[strings.ToLower(scheme)] = host
+			}
+		}
+		for _, kind := range []struct{ key, typ string }{
+			{"socks", "SOCKS"},
+			{"https", "HTTPS"},
+			{"http", "HTTP"},
+		} {
+			if host := parseProxyHost(entries[kind.key]); host != "" {
+				found = append(found, ProxyStatus{Enabled: true, Type: kind.typ, Host: host, Scheme: kind.key})
+			}
+		}
+	}
+	if pac := values["AutoConfigURL"]; pac != "" {
+		host := parseProxyHost(pac)
+		if host == "" {
+			host = "PAC"
+		}
+		found = append(found, ProxyStatus{Enabled: true, Type: "PAC", Host: host, Scheme: "auto"})
+	}
+	return primaryProxy(found)
 }
 
 func collectProxyFromTunInterfaces() ProxyStatus {
-	stats, err := net.IOCounters(true)
+	stats, err := netIOCounters(true)
 	if err != nil {
 		return ProxyStatus{Enabled: false}
 	}
@@ -1,6 +1,21 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
 
 func TestCollectProxyFromEnvSupportsAllProxy(t *testing.T) {
 	env := map[string]string{
@@ -58,3 +73,971 @@ func TestCollectProxyFromScutilOutputHTTPHostPort(t *testing.T) {
 		t.Fatalf("unexpected host: %s", got.Host)
 	}
 }
+
+func TestCollectProxyFromGsettingsOutput(t *testing.T) {
+	out := `org.gnome.system.proxy autoconfig-url ''
+org.gnome.system.proxy ignore-hosts ['localhost', '127.0.0.0/8', '::1']
+org.gnome.system.proxy mode 'manual'
+org.gnome.system.proxy use-same-proxy true
+org.gnome.system.proxy.ftp host ''
+org.gnome.system.proxy.ftp port 0
+org.gnome.system.proxy.http enabled false
+org.gnome.system.proxy.http host 'proxy.corp'
+org.gnome.system.proxy.http port 3128
+org.gnome.system.proxy.https host 'proxy.corp'
+org.gnome.system.proxy.https port 3128
+org.gnome.system.proxy.socks host 'socks.corp'
+org.gnome.system.proxy.socks port 0
+`
+	got := collectProxyFromGsettingsOutput(out)
+	var schemes []string
+	for _, p := range got.Schemes {
+		schemes = append(schemes, p.Scheme+" "+p.Type+" "+p.Host)
+	}
+	want := []string{"socks SOCKS socks.corp", "https HTTPS proxy.corp:3128", "http HTTP proxy.corp:3128"}
+	if !got.Enabled || got.Type != "SOCKS" || !slices.Equal(schemes, want) {
+		t.Fatalf("gsettings proxies = %+v %q, want %q", got, schemes, want)
+	}
+
+	for mode, want := range map[string]string{
+		"'none'": "",
+		"'auto'": "WPAD Auto Discovery",
+	} {
+		got := collectProxyFromGsettingsOutput(strings.Replace(out, "'manual'", mode, 1))
+		if text := strings.TrimSpace(got.Type + " " + got.Host); got.Enabled != (want != "") || text != want {
+			t.Errorf("gsettings mode %s = %+v, want %q", mode, got, want)
+		}
+	}
+	pac := strings.Replace(strings.Replace(out, "'manual'", "'auto'", 1), "autoconfig-url ''", "autoconfig-url 'http://wpad.corp/proxy.pac'", 1)
+	if got := collectProxyFromGsettingsOutput(pac); got.Type != "PAC" || got.Host != "wpad.corp" {
+		t.Errorf("gsettings PAC = %+v", got)
+	}
+}
+
+func TestCollectProxyFromRegOutput(t *testing.T) {
+	reg := func(values ...string) string {
+		return "\r\nHKEY_CURRENT_USER\\Software\\Microsoft\\Windows\\CurrentVersion\\Internet Settings\r\n" +
+			"    DisableCachingOfSSLPages    REG_DWORD    0x0\r\n" + strings.Join(values, "\r\n") + "\r\n"
+	}
+	tests := []struct {
+		name, out, want string
+	}{
+		{"one server", reg("    ProxyEnable    REG_DWORD    0x1", "    ProxyServer    REG_SZ    10.0.0.1:8080"), "all HTTP 10.0.0.1:8080"},
+		{"per scheme", reg("    ProxyEnable    REG_DWORD    0x1", "    ProxyServer    REG_SZ    http=10.0.0.1:80;https=10.0.0.2:443"), "https HTTPS 10.0.0.2:443"},
+		{"disabled", reg("    ProxyEnable    REG_DWORD    0x0", "    ProxyServer    REG_SZ    10.0.0.1:8080"), ""},
+		{"pac", reg("    ProxyEnable    REG_DWORD    0x0", "    AutoConfigURL    REG_SZ    http://10.0.0.3/proxy.pac"), "auto PAC 10.0.0.3"},
+	}
+	for _, tt := range tests {
+		got := collectProxyFromRegOutput(tt.out)
+		if text := strings.TrimSpace(got.Scheme + " " + got.Type + " " + got.Host); got.Enabled != (tt.want != "") || text != tt.want {
+			t.Errorf("%s: collectProxyFromRegOutput() = %+v, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollectorNoiseFilters(t *testing.T) {
+	opts, err := parseOptions([]string{"--show-interface", "UTUN3,bridge0", "--hide-interface", "ifb"}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseOptions() error = %v", err)
+	}
+	c := opts.newCollector()
+	for name, want := range map[string]bool{
+		"utun3":   false, // Shown: an explicit include beats the utun default.
+		"utun4":   true,
+		"bridge0": false,
+		"bridge1": true,
+		"IFB0":    true, // Added, matched regardless of case.
+		"lo":      true,
+		"eth0":    false,
+	} {
+		if got := c.isNoise(name); got != want {
+			t.Errorf("isNoise(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestClassifyInterface(t *testing.T) {
+	tests := map[string]string{
+		"en0":        ifaceKindPhysical,
+		"eth0":       ifaceKindPhysical,
+		"veth3a1b2c": ifaceKindContainer,
+		"br-9f1c2d":  ifaceKindContainer,
+		"docker0":    ifaceKindContainer,
+		"cni0":       ifaceKindContainer,
+		"cali12ab":   ifaceKindContainer,
+		"utun3":      ifaceKindVPN,
+		"wg0":        ifaceKindVPN,
+		"bridge0":    ifaceKindVirtual,
+		"vboxnet0":   ifaceKindVirtual,
+	}
+	for name, want := range tests {
+		if got := classifyInterface(name); got != want {
+			t.Errorf("classifyInterface(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestInterfaceIPsKeyedByIndex(t *testing.T) {
+	ifaces := net.InterfaceStatList{
+		{Index: 4, Name: "eth0", Addrs: net.InterfaceAddrList{{Addr: "10.0.0.5/24"}}},
+		{Index: 9, Name: "wg0", Addrs: net.InterfaceAddrList{{Addr: "127.0.0.2/8"}, {Addr: "192.168.7.1/32"}}},
+		{Name: "odd0", Addrs: net.InterfaceAddrList{{Addr: "172.16.0.1/16"}}},
+	}
+
+	ips, indexes := interfaceIPs(ifaces, ipStrategy{}, "")
+	if ips["eth0#4"] != "10.0.0.5" {
+		t.Errorf("eth0 ip = %q, want 10.0.0.5", ips["eth0#4"])
+	}
+	if ips["wg0#9"] != "192.168.7.1" {
+		t.Errorf("wg0 ip = %q, want loopback skipped", ips["wg0#9"])
+	}
+	// Without an index the bare name is the key.
+	if ips["odd0"] != "172.16.0.1" {
+		t.Errorf("odd0 ip = %q", ips["odd0"])
+	}
+	if indexes["wg0"] != 9 {
+		t.Errorf("wg0 index = %d, want 9", indexes["wg0"])
+	}
+	if ifaceKey("eth0", 4) == ifaceKey("eth0", 7) {
+		t.Errorf("same name with different index must not share a key")
+	}
+}
+
+func TestInterfaceIPsFallsBackToIPv6(t *testing.T) {
+	ifaces := net.InterfaceStatList{
+		{Index: 2, Name: "eth0", Addrs: net.InterfaceAddrList{{Addr: "fe80::1/64"}, {Addr: "2001:db8::42/64"}}},
+		{Index: 3, Name: "eth1", Addrs: net.InterfaceAddrList{{Addr: "2001:db8::7/64"}, {Addr: "10.0.0.7/24"}}},
+		{Index: 4, Name: "utun0", Addrs: net.InterfaceAddrList{{Addr: "fe80::abcd/64"}}},
+	}
+	ips, _ := interfaceIPs(ifaces, ipStrategy{}, "")
+	if ips["eth0#2"] != "2001:db8::42" {
+		t.Errorf("IPv6-only eth0 ip = %q, want 2001:db8::42", ips["eth0#2"])
+	}
+	if ips["eth1#3"] != "10.0.0.7" {
+		t.Errorf("dual-stack eth1 ip = %q, want IPv4 preferred", ips["eth1#3"])
+	}
+	if ip, ok := ips["utun0#4"]; ok {
+		t.Errorf("link-local only utun0 ip = %q, want none", ip)
+	}
+}
+
+func TestParseAppProxies(t *testing.T) {
+	git := parseGitProxies("http.proxy http://127.0.0.1:7890\nhttps.proxy http://127.0.0.1:7890\n")
+	if len(git) != 1 || git[0].Source != "git" || git[0].Host != "127.0.0.1:7890" {
+		t.Fatalf("parseGitProxies() = %+v, want one deduplicated git entry", git)
+	}
+
+	npmrc := `; comment
+registry=https://registry.npmjs.org/
+proxy=http://proxy.corp:3128
+https-proxy = "socks5://10.0.0.1:1080"
+`
+	npm := parseRCProxies("npm", npmrc, "proxy", "https-proxy")
+	if len(npm) != 2 {
+		t.Fatalf("parseRCProxies() = %+v, want 2 entries", npm)
+	}
+	if npm[0].Host != "proxy.corp:3128" || npm[0].Type != "HTTP" {
+		t.Errorf("npm proxy = %+v", npm[0])
+	}
+	if npm[1].Host != "10.0.0.1:1080" || npm[1].Type != "SOCKS" || npm[1].Source != "npm" {
+		t.Errorf("npm https-proxy = %+v", npm[1])
+	}
+
+	curl := parseRCProxies("curl", "# curlrc\n-x 192.168.1.1:8080\n", "proxy", "--proxy", "-x")
+	if len(curl) != 1 || curl[0].Host != "192.168.1.1:8080" {
+		t.Fatalf("curlrc proxies = %+v", curl)
+	}
+}
+
+func TestParsePingRTT(t *testing.T) {
+	tests := []struct {
+		out  string
+		want time.Duration
+	}{
+		{"64 bytes from 1.1.1.1: icmp_seq=1 ttl=57 time=12.3 ms", 12300 * time.Microsecond},
+		{"Reply from 1.1.1.1: bytes=32 time<1ms TTL=57", time.Millisecond},
+		{"Reply from 1.1.1.1: bytes=32 time=8ms TTL=57", 8 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		got, err := parsePingRTT(tt.out)
+		if err != nil || got != tt.want {
+			t.Errorf("parsePingRTT(%q) = %v, %v; want %v", tt.out, got, err, tt.want)
+		}
+	}
+	if _, err := parsePingRTT("Request timeout for icmp_seq 0"); err == nil {
+		t.Errorf("parsePingRTT() expected error without a reply")
+	}
+}
+
+func TestPrimaryIPStrategies(t *testing.T) {
+	ifaces := net.InterfaceStatList{
+		{Index: 2, Name: "en0", Addrs: net.InterfaceAddrList{{Addr: "192.168.1.20/24"}, {Addr: "10.8.0.3/16"}}},
+	}
+
+	tests := []struct {
+		strategy string
+		routeIP  string
+		want     string
+	}{
+		{"first", "", "192.168.1.20"},
+		{"default-route", "10.8.0.3", "10.8.0.3"},
+		{"default-route", "172.16.0.9", "192.168.1.20"}, // Route uses another interface.
+		{"prefer-subnet=10.0.0.0/8", "", "10.8.0.3"},
+		{"prefer-subnet=172.16.0.0/12", "", "192.168.1.20"},
+	}
+	for _, tt := range tests {
+		strategy, err := parseIPStrategy(tt.strategy)
+		if err != nil {
+			t.Fatalf("parseIPStrategy(%q) error = %v", tt.strategy, err)
+		}
+		ips, _ := interfaceIPs(ifaces, strategy, tt.routeIP)
+		if got := ips["en0#2"]; got != tt.want {
+			t.Errorf("%s (route %s): ip = %q, want %q", tt.strategy, tt.routeIP, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"last", "prefer-subnet=10.0.0.0"} {
+		if _, err := parseIPStrategy(bad); err == nil {
+			t.Errorf("parseIPStrategy(%q) expected error", bad)
+		}
+	}
+}
+
+func TestBondMembersIn(t *testing.T) {
+	root := t.TempDir()
+	for bond, slaves := range map[string]string{"bond0": "eth0 eth1\n", "bond1": ""} {
+		dir := filepath.Join(root, bond, "bonding")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "slaves"), []byte(slaves), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "eth0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got := bondMembersIn(root)
+	want := map[string]string{"eth0": "bond0", "eth1": "bond0"}
+	if !maps.Equal(got, want) {
+		t.Fatalf("bondMembersIn() = %v, want %v", got, want)
+	}
+}
+
+func TestGatewayStates(t *testing.T) {
+	linuxRoutes := "default via 192.168.1.1 dev eth0 proto dhcp metric 100\ndefault via 10.0.0.1 dev wlan0 metric 600\ndefault dev wg0 scope link\n"
+	linuxNeigh := "192.168.1.1 dev eth0 lladdr aa:bb:cc:dd:ee:ff REACHABLE\n10.0.0.1 dev wlan0 FAILED\nfe80::1 dev eth0 lladdr aa:bb:cc:dd:ee:01 router STALE\n"
+	got := gatewayStates(parseLinuxDefaultRoutes(linuxRoutes), parseLinuxNeighbors(linuxNeigh))
+	want := map[string]GatewayStatus{
+		"eth0":  {IP: "192.168.1.1", State: "REACHABLE", Reachable: true},
+		"wlan0": {IP: "10.0.0.1", State: "FAILED", Reachable: false},
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("linux gatewayStates() = %v, want %v", got, want)
+	}
+
+	darwinRoutes := `Routing tables
+
+Internet:
+Destination        Gateway            Flags               Netif Expire
+default            192.168.1.1        UGScg                 en0
+default            link#17            UCSIg             utun3
+default            172.20.10.1        UGScIg                en1
+127                127.0.0.1          UCS                   lo0
+`
+	darwinARP := "? (192.168.1.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]\n? (192.168.1.9) at (incomplete) on en0 ifscope [ethernet]\n"
+	got = gatewayStates(parseDarwinDefaultRoutes(darwinRoutes), parseDarwinARP(darwinARP))
+	want = map[string]GatewayStatus{
+		"en0": {IP: "192.168.1.1", State: "REACHABLE", Reachable: true},
+		"en1": {IP: "172.20.10.1", State: "none", Reachable: false},
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("darwin gatewayStates() = %v, want %v", got, want)
+	}
+}
+
+func TestRankInterfacesWindow(t *testing.T) {
+	c := &Collector{rankWindow: 3}
+	sample := func(en0, en1 float64) []string {
+		list := []NetworkStatus{{Name: "en1", RxRateMBs: en1}, {Name: "en0", RxRateMBs: en0}}
+		c.rankInterfaces(list)
+		return []string{list[0].Name, list[1].Name}
+	}
+	sample(5, 1)
+	sample(5, 1)
+	// en1 spikes for a single tick; the windowed mean keeps en0 on top.
+	if got := sample(5, 9); got[0] != "en0" {
+		t.Fatalf("blip reordered interfaces: %v", got)
+	}
+	// Sustained traffic does take over once it dominates the window.
+	sample(5, 9)
+	if got := sample(5, 9); got[0] != "en1" {
+		t.Fatalf("sustained traffic should rank first: %v", got)
+	}
+
+	// Window 1 is the plain instantaneous order.
+	c = &Collector{rankWindow: 1}
+	if got := sample(1, 2); got[0] != "en1" {
+		t.Fatalf("rankWindow 1 should sort by current rate: %v", got)
+	}
+}
+
+func TestEventWatchersAndLog(t *testing.T) {
+	var log bytes.Buffer
+	c := &Collector{events: &eventRing{}}
+	c.events.setLog(&log)
+
+	start := time.Now()
+	c.watchCPU(start, 97)
+	c.watchCPU(start.Add(20*time.Second), 99)
+	c.watchCPU(start.Add(31*time.Second), 98)
+	c.watchCPU(start.Add(40*time.Second), 98) // Already raised.
+	c.watchProxy(ProxyStatus{})               // First sample is the baseline.
+	c.watchProxy(ProxyStatus{Enabled: true, Type: "HTTP", Host: "127.0.0.1:7890"})
+	c.watchGateways(map[string]GatewayStatus{"en0": {IP: "192.168.1.1", Reachable: false}})
+	c.watchGateways(map[string]GatewayStatus{"en0": {IP: "192.168.1.1", Reachable: true}})
+
+	want := []StatusEvent{
+		{Severity: severityWarn, Category: categorySystem, Message: "CPU above 95% for 30s"},
+		{Severity: severityInfo, Category: categoryProxy, Message: "proxy enabled: HTTP 127.0.0.1:7890"},
+		{Severity: severityError, Category: categoryNetwork, Message: "gateway 192.168.1.1 on en0 unreachable"},
+		{Severity: severityInfo, Category: categoryNetwork, Message: "gateway 192.168.1.1 on en0 reachable again"},
+	}
+	got := c.events.recent()
+	if len(got) != len(want) {
+		t.Fatalf("recorded %d events, want %d: %+v", len(got), len(want), got)
+	}
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	for i, w := range want {
+		if got[i].Severity != w.Severity || got[i].Category != w.Category || got[i].Message != w.Message {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], w)
+		}
+		var logged StatusEvent
+		if err := json.Unmarshal([]byte(lines[i]), &logged); err != nil || logged.Message != w.Message || logged.Severity != w.Severity {
+			t.Errorf("log line %d = %q (%v)", i, lines[i], err)
+		}
+	}
+}
+
+func TestLinkFlapping(t *testing.T) {
+	c := NewCollector()
+	for i, up := range []bool{false, true, false, true, false, true} {
+		c.watchLink(time.Duration(i)*5*time.Second, "en5", up)
+	}
+	c.settleLinks(60 * time.Second) // 35s after the last transition: still flapping.
+	c.settleLinks(85 * time.Second)
+	c.watchLink(90*time.Second, "en5", false)
+	var got []string
+	for _, ev := range c.events.recent() {
+		got = append(got, ev.Message)
+	}
+	want := []string{
+		"interface down: en5", "interface up: en5", "interface down: en5",
+		"en5 flapping (4 transitions in 60s)",
+		"en5 stopped flapping after 6 transitions",
+		"interface down: en5",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+}
+
+// syntheticCounters returns n interface counters whose byte counts grow with
+// step, so consecutive samples produce non-zero rates.
+func syntheticCounters(n int, step uint64) []net.IOCountersStat {
+	stats := make([]net.IOCountersStat, n)
+	for i := range stats {
+		name := fmt.Sprintf("veth%d", i)
+		if i%5 == 0 {
+			name = fmt.Sprintf("eth%d", i)
+		}
+		stats[i] = net.IOCountersStat{Name: name, BytesRecv: step * uint64(i+1), BytesSent: step * uint64(i)}
+	}
+	return stats
+}
+
+func TestNetworkRatesEvictsVanishedInterfaces(t *testing.T) {
+	c := NewCollector()
+	c.networkRates(syntheticCounters(10, 1), nil, nil, nil, time.Second)
+	c.networkRates(syntheticCounters(10, 2), nil, nil, nil, 2*time.Second)
+
+	// Only two interfaces remain; the rest stay baselined for a short grace
+	// period, then are dropped.
+	for i := range netEvictCycles {
+		tick := time.Duration(3+i) * time.Second
+		c.networkRates(syntheticCounters(2, uint64(3+i)), nil, nil, nil, tick)
+		if i < netEvictCycles-1 && len(c.prevNet) != 10 {
+			t.Fatalf("cycle %d: prevNet has %d entries, want 10 during grace period", i, len(c.prevNet))
+		}
+	}
+	if len(c.prevNet) != 2 {
+		t.Fatalf("prevNet has %d entries after eviction, want 2", len(c.prevNet))
+	}
+
+	got := c.networkRates(syntheticCounters(2, 10), nil, nil, nil, 10*time.Second)
+	if &c.netRows[0] == &got[0] {
+		t.Fatalf("networkRates must not return its scratch slice")
+	}
+}
+
+func TestNetworkRatesKeepsHistoryThroughDropout(t *testing.T) {
+	c := NewCollector()
+	sample := func(sec int, names ...string) []NetworkStatus {
+		stats := make([]net.IOCountersStat, len(names))
+		for i, name := range names {
+			stats[i] = net.IOCountersStat{Name: name, BytesRecv: uint64(sec) << 20, BytesSent: uint64(sec) << 19}
+		}
+		return c.networkRates(stats, nil, nil, nil, time.Duration(sec)*time.Second)
+	}
+	history := func(rows []NetworkStatus, name string) []float64 {
+		for _, r := range rows {
+			if r.Name == name {
+				return r.RxHistory
+			}
+		}
+		return nil
+	}
+	for sec := 1; sec <= 3; sec++ {
+		sample(sec, "en0", "en5")
+	}
+	// en5, a USB adapter, is unplugged long enough to lose its counters.
+	for sec := 4; sec <= 20; sec++ {
+		sample(sec, "en0")
+	}
+	sample(21, "en0", "en5") // A new baseline.
+	if got := history(sample(22, "en0", "en5"), "en5"); len(got) != 3 {
+		t.Fatalf("en5 history after replugging = %v, want the 2 earlier points and the new one", got)
+	}
+	if got := history(sample(23, "en0"), "en0"); len(got) != 22 {
+		t.Fatalf("en0 history = %d points, want 22", len(got))
+	}
+	sample(22+int(netHistoryKeep/time.Second), "en0")
+	if _, ok := c.netHistory["en5"]; !ok {
+		t.Fatalf("en5 history evicted before netHistoryKeep")
+	}
+	sample(23+int(netHistoryKeep/time.Second), "en0")
+	if _, ok := c.netHistory["en5"]; ok {
+		t.Fatalf("en5 history kept past netHistoryKeep")
+	}
+}
+
+func TestNetworkRatesCounterReset(t *testing.T) {
+	const mb = 1 << 20
+	c := NewCollector()
+	sample := func(sec int, eth0 uint64) NetworkStatus {
+		stats := []net.IOCountersStat{{Name: "eth0", BytesRecv: eth0, BytesSent: eth0 / 2}, {Name: "eth1"}}
+		for _, r := range c.networkRates(stats, nil, nil, nil, time.Duration(sec)*time.Second) {
+			if r.Name == "eth0" {
+				return r
+			}
+		}
+		t.Fatalf("sample %d: no eth0 row", sec)
+		return NetworkStatus{}
+	}
+	c.networkRates([]net.IOCountersStat{{Name: "eth0", BytesRecv: 8 * mb, BytesSent: 4 * mb}, {Name: "eth1"}}, nil, nil, nil, time.Second)
+	if got := sample(2, 10*mb); got.RxRateMBs != 2 || got.TxRateMBs != 1 {
+		t.Fatalf("rates = %v/%v, want 2/1", got.RxRateMBs, got.TxRateMBs)
+	}
+	if h := c.rxHistoryBuf.Slice(); !slices.Equal(h, []float64{2}) {
+		t.Fatalf("aggregate history %v, want [2]", h)
+	}
+	// wlan0-style restart: the counters start over near zero.
+	got := sample(3, 1000)
+	if !got.RateUnknown || got.RxRateMBs != 0 || got.TxRateMBs != 0 || len(got.RxHistory) != 1 {
+		t.Fatalf("reset sample = %+v, want no rate and no new point", got)
+	}
+	if h := c.rxHistoryBuf.Slice(); !slices.Equal(h, []float64{2}) {
+		t.Fatalf("aggregate history %v across the reset, want no dip recorded", h)
+	}
+	if got := sample(4, 1000+mb); got.RxRateMBs != 1 || !slices.Equal(got.RxHistory, []float64{2, 1}) {
+		t.Fatalf("after reset = %v history %v, want 1 measured from the new baseline", got.RxRateMBs, got.RxHistory)
+	}
+	if h := c.rxHistoryBuf.Slice(); !slices.Equal(h, []float64{2, 1}) {
+		t.Fatalf("aggregate history %v after the reset, want [2 1]", h)
+	}
+	// A tiny delta is traffic, not a reset.
+	if got := sample(5, 1001+mb); got.RxRateMBs <= 0 || got.RxRateMBs > 0.001 || len(got.RxHistory) != 3 {
+		t.Fatalf("tiny delta = %v history %v", got.RxRateMBs, got.RxHistory)
+	}
+}
+
+func BenchmarkNetworkRates500(b *testing.B) {
+	c := NewCollector()
+	samples := [2][]net.IOCountersStat{syntheticCounters(500, 1), syntheticCounters(500, 2)}
+	c.networkRates(samples[0], nil, nil, nil, time.Second)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		c.networkRates(samples[(i+1)%2], nil, nil, nil, time.Duration(i+2)*time.Second)
+	}
+}
+
+// fakeNetwork makes collectNetwork read samples, one per call, instead of the
+// host. Addresses and bonds are empty so results don't depend on the machine.
+func fakeNetwork(t *testing.T, samples ...[]net.IOCountersStat) {
+	t.Helper()
+	counters, addrs, bonds, bridges := netIOCounters, hostInterfaceIPs, hostBondMembers, hostBridgePorts
+	t.Cleanup(func() {
+		netIOCounters, hostInterfaceIPs, hostBondMembers, hostBridgePorts = counters, addrs, bonds, bridges
+	})
+
+	netIOCounters = func(bool) ([]net.IOCountersStat, error) {
+		if len(samples) == 0 {
+			return nil, fmt.Errorf("no more fake samples")
+		}
+		next := samples[0]
+		samples = samples[1:]
+		return next, nil
+	}
+	hostInterfaceIPs = func(ipStrategy) (map[string]string, map[string]int) { return nil, nil }
+	hostBondMembers = func() map[string]string { return nil }
+	hostBridgePorts = func() map[string]string { return nil }
+}
+
+func TestCollectNetwork(t *testing.T) {
+	const mb = 1 << 20
+	sample := func(rx ...uint64) []net.IOCountersStat {
+		stats := make([]net.IOCountersStat, len(rx))
+		for i, r := range rx {
+			stats[i] = net.IOCountersStat{Name: fmt.Sprintf("eth%d", i), BytesRecv: r, BytesSent: r / 2}
+		}
+		return stats
+	}
+	fakeNetwork(t,
+		sample(0, 0, 0, 0, 0),
+		sample(1*mb, 4*mb, 2*mb, 8*mb, 0),
+	)
+
+	c := NewCollector()
+	if got, err := c.collectNetwork(0); err != nil || got != nil {
+		t.Fatalf("first sample = %v, %v; want nil until there is a baseline", got, err)
+	}
+	got, err := c.collectNetwork(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Busiest first, cut to the top three; rates are per second over the 2s gap.
+	var names []string
+	for _, n := range got {
+		names = append(names, n.Name)
+	}
+	if !slices.Equal(names, []string{"eth3", "eth1", "eth2"}) {
+		t.Fatalf("interfaces = %v, want [eth3 eth1 eth2]", names)
+	}
+	if got[0].RxRateMBs != 4 || got[0].TxRateMBs != 2 || got[0].RxBytes != 8*mb {
+		t.Fatalf("eth3 = %+v, want 4 MB/s down, 2 MB/s up", got[0])
+	}
+	// The aggregate history counts only the interfaces returned.
+	if history := c.rxHistoryBuf.Slice(); len(history) != 1 || history[0] != 4+2+1 {
+		t.Fatalf("rx history = %v, want [7]", history)
+	}
+
+	if _, err := c.collectNetwork(3 * time.Second); err == nil {
+		t.Fatal("a failing counter read should surface its error")
+	}
+}
+
+func TestCollectNetworkSameInstant(t *testing.T) {
+	const mb = 1 << 20
+	sample := func(rx uint64) []net.IOCountersStat {
+		return []net.IOCountersStat{{Name: "eth0", BytesRecv: rx}}
+	}
+	fakeNetwork(t, sample(0), sample(1*mb), sample(6*mb), sample(7*mb))
+
+	c := NewCollector()
+	c.collectNetwork(0)
+	c.collectNetwork(time.Second)
+	// The third sample lands on the same tick: dividing its 5 MB by a made-up
+	// second would read 5 MB/s, so the previous rate stays instead.
+	got, _ := c.collectNetwork(time.Second)
+	if len(got) != 1 || got[0].RxRateMBs != 1 {
+		t.Fatalf("same-instant sample = %+v, want the previous 1 MB/s", got)
+	}
+	// It still becomes the baseline for the next real gap.
+	got, _ = c.collectNetwork(2 * time.Second)
+	if len(got) != 1 || got[0].RxRateMBs != 1 {
+		t.Fatalf("sample after the stall = %+v, want 1 MB/s", got)
+	}
+	if history := c.rxHistoryBuf.Slice(); !slices.Equal(history, []float64{1, 1}) {
+		t.Fatalf("rx history = %v, want the stalled cycle left out", history)
+	}
+}
+
+func TestSummarizeConnections(t *testing.T) {
+	conn := func(status string, family, typ uint32, raddr string) net.ConnectionStat {
+		return net.ConnectionStat{Status: status, Family: family, Type: typ, Raddr: net.Addr{IP: raddr}}
+	}
+	var conns []net.ConnectionStat
+	for i := range connRemoteTop + 2 {
+		conns = append(conns, conn("ESTABLISHED", syscall.AF_INET, syscall.SOCK_STREAM, fmt.Sprintf("10.0.0.%d", i)))
+	}
+	conns = append(conns,
+		conn("ESTABLISHED", syscall.AF_INET, syscall.SOCK_STREAM, "10.0.0.1"),
+		conn("LISTEN", syscall.AF_INET6, syscall.SOCK_STREAM, "::"),
+		conn("NONE", syscall.AF_INET, syscall.SOCK_DGRAM, ""),
+	)
+
+	got := summarizeConnections(conns)
+	if got.Total != len(conns) || got.ByState["ESTABLISHED"] != connRemoteTop+3 || got.ByState["UDP"] != 1 {
+		t.Fatalf("ByState = %v (total %d)", got.ByState, got.Total)
+	}
+	if want := map[string]int{"TCP": connRemoteTop + 3, "TCP6": 1, "UDP": 1}; !maps.Equal(got.ByProto, want) {
+		t.Fatalf("ByProto = %v, want %v", got.ByProto, want)
+	}
+	// Only the busiest remotes are kept, and listeners are not remotes.
+	if len(got.ByRemote) != connRemoteTop || got.ByRemote["10.0.0.1"] != 2 || got.ByRemote["::"] != 0 {
+		t.Fatalf("ByRemote = %v", got.ByRemote)
+	}
+}
+
+func TestParseProcNetDev(t *testing.T) {
+	const dev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  veth0: 2048 20 1 2 3 0 0 0 4096 40 4 5 6 0 0 0
+`
+	stats, err := parseProcNetDev(strings.NewReader(dev))
+	if err != nil {
+		t.Fatalf("parseProcNetDev() error = %v", err)
+	}
+	want := []net.IOCountersStat{
+		{Name: "lo", BytesRecv: 1000, PacketsRecv: 10, BytesSent: 1000, PacketsSent: 10},
+		{Name: "veth0", BytesRecv: 2048, PacketsRecv: 20, Errin: 1, Dropin: 2, Fifoin: 3, BytesSent: 4096, PacketsSent: 40, Errout: 4, Dropout: 5, Fifoout: 6},
+	}
+	if !slices.Equal(stats, want) {
+		t.Fatalf("parseProcNetDev() = %+v, want %+v", stats, want)
+	}
+	if _, err := parseProcNetDev(strings.NewReader("eth0: 1 2 3\n")); err == nil {
+		t.Fatal("parseProcNetDev() accepted a short line")
+	}
+}
+
+func TestParseLinkModes(t *testing.T) {
+	const ethtool = `Settings for eth0:
+	Supported ports: [ TP ]
+	Speed: 100Mb/s
+	Duplex: Half
+	Port: Twisted Pair
+	Link detected: yes
+`
+	if got, want := parseEthtool(ethtool), (linkMode{duplex: "half", media: "100Mb/s Twisted Pair"}); got != want {
+		t.Errorf("parseEthtool() = %+v, want %+v", got, want)
+	}
+	if got := parseEthtool("Settings for eth1:\n\tSpeed: Unknown!\n\tDuplex: Unknown! (255)\n\tPort: Other\n"); got != (linkMode{}) {
+		t.Errorf("parseEthtool(down link) = %+v, want nothing", got)
+	}
+
+	const ifconfig = `en7: flags=8863<UP,BROADCAST,SMART,RUNNING,SIMPLEX,MULTICAST> mtu 1500
+	ether 00:11:22:33:44:55
+	media: autoselect (1000baseT <full-duplex,flow-control>)
+	status: active
+`
+	if got, want := parseIfconfigMedia(ifconfig), (linkMode{duplex: "full", media: "1000baseT"}); got != want {
+		t.Errorf("parseIfconfigMedia() = %+v, want %+v", got, want)
+	}
+	for _, line := range []string{"\tmedia: autoselect\n", "\tmedia: autoselect (none)\n"} {
+		if got := parseIfconfigMedia(line); got != (linkMode{}) {
+			t.Errorf("parseIfconfigMedia(%q) = %+v, want nothing", line, got)
+		}
+	}
+
+	for media, want := range map[string]int{
+		"100Mb/s Twisted Pair": 100,
+		"1000baseT":            1000,
+		"2500Base-T":           2500,
+		"10GbaseT":             10000,
+		"autoselect":           0,
+		"":                     0,
+	} {
+		if got := mediaSpeedMbps(media); got != want {
+			t.Errorf("mediaSpeedMbps(%q) = %d, want %d", media, got, want)
+		}
+	}
+}
+
+func TestProxyDialTarget(t *testing.T) {
+	tests := []struct {
+		proxy ProxyStatus
+		want  string
+	}{
+		{ProxyStatus{Enabled: true, Type: "HTTP", Host: "127.0.0.1:7890"}, "127.0.0.1:7890"},
+		{ProxyStatus{Enabled: true, Type: "SOCKS", Host: "proxy.lan"}, "proxy.lan:1080"},
+		{ProxyStatus{Enabled: true, Type: "HTTPS", Host: "http://[::1]/"}, "[::1]:443"},
+		{ProxyStatus{Enabled: true, Type: "PAC", Host: "http://wpad/proxy.pac"}, ""},
+		{ProxyStatus{Enabled: true, Type: "TUN", Host: "utun4"}, ""},
+		{ProxyStatus{Type: "HTTP", Host: "127.0.0.1:7890"}, ""},
+	}
+	for _, tt := range tests {
+		if got := proxyDialTarget(tt.proxy); got != tt.want {
+			t.Errorf("proxyDialTarget(%+v) = %q, want %q", tt.proxy, got, tt.want)
+		}
+	}
+}
+
+func TestNetworkRatesPerInterfaceHistory(t *testing.T) {
+	c := NewCollector()
+	eth := func(rx uint64) []net.IOCountersStat {
+		return []net.IOCountersStat{{Name: "eth0", BytesRecv: rx}, {Name: "eth1"}}
+	}
+	c.networkRates(eth(0), nil, nil, nil, time.Second)
+	c.networkRates(eth(1<<20), nil, nil, nil, 2*time.Second)
+	got := c.networkRates(eth(3<<20), nil, nil, nil, 3*time.Second)
+	for _, n := range got {
+		want := []float64{0, 0}
+		if n.Name == "eth0" {
+			want = []float64{1, 2}
+		}
+		if !slices.Equal(n.RxHistory, want) || len(n.TxHistory) != 2 {
+			t.Errorf("%s history = %v / %v, want rx %v", n.Name, n.RxHistory, n.TxHistory, want)
+		}
+	}
+}
+
+func TestNetworkRatesGroups(t *testing.T) {
+	sample := func(mb uint64) []net.IOCountersStat {
+		return []net.IOCountersStat{{Name: "eth0", BytesRecv: 8 * mb}, {Name: "wg0", BytesRecv: mb}, {Name: "wg1", BytesRecv: 2 * mb},
+			{Name: "wg2", BytesSent: 3 * mb}, {Name: "wg3"}}
+	}
+	for _, members := range []bool{true, false} {
+		c := NewCollector()
+		c.groups = newIfaceGroups([]ifaceGroupSpec{{"VPN total", "^wg"}, {"none", "^ppp"}}, 10)
+		c.showGroupMembers = members
+		c.networkRates(sample(0), nil, nil, nil, time.Second)
+		got := c.networkRates(sample(1<<20), nil, nil, nil, 2*time.Second)
+		vpn, none := got[0], got[1]
+		// wg2 and wg3 miss the top rows but still count.
+		if vpn.Name != "VPN total" || vpn.Kind != ifaceKindGroup || vpn.RxRateMBs != 3 || vpn.TxRateMBs != 3 ||
+			!slices.Equal(vpn.Members, []string{"wg0", "wg1", "wg2", "wg3"}) || !slices.Equal(vpn.RxHistory, []float64{3}) {
+			t.Errorf("members=%t: group row = %+v", members, vpn)
+		}
+		if none.Name != "none" || none.RxRateMBs+none.TxRateMBs != 0 || none.Members != nil {
+			t.Errorf("members=%t: empty group row = %+v", members, none)
+		}
+		var names []string
+		for _, n := range got[2:] {
+			names = append(names, n.Name)
+		}
+		want := []string{"eth0", "wg2", "wg1"}
+		if !members {
+			want = []string{"eth0"}
+		}
+		if !slices.Equal(names, want) {
+			t.Errorf("members=%t: rows = %v, want %v", members, names, want)
+		}
+		// Totals never count a group on top of its members, and count
+		// every member the group row stands in for.
+		wantTotal := []float64{10} // The rows listed.
+		if !members {
+			wantTotal = []float64{11}
+		}
+		if total := c.rxHistoryBuf.Slice(); !slices.Equal(total, wantTotal) {
+			t.Errorf("members=%t: total rx history = %v, want %v", members, total, wantTotal)
+		}
+	}
+}
+
+func TestRouteCount(t *testing.T) {
+	linux := "default via 192.168.1.1 dev eth0 proto dhcp metric 100\n10.8.0.0/24 dev tun0 scope link\n192.168.1.0/24 dev eth0 proto kernel scope link src 192.168.1.20\n\n"
+	if got := countLinuxRoutes(linux); got != 3 {
+		t.Errorf("countLinuxRoutes() = %d, want 3", got)
+	}
+	proc := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+		"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"
+	if got := countProcRoutes(proc); got != 2 {
+		t.Errorf("countProcRoutes() = %d, want 2", got)
+	}
+	if got := countProcRoutes(""); got != 0 {
+		t.Errorf("countProcRoutes(empty) = %d, want 0", got)
+	}
+	darwin := `Routing tables
+
+Internet:
+Destination        Gateway            Flags               Netif Expire
+default            192.168.1.1        UGScg                 en0
+127                127.0.0.1          UCS                   lo0
+192.168.1          link#6             UCS                   en0      !
+`
+	if got := countDarwinRoutes(darwin); got != 3 {
+		t.Errorf("countDarwinRoutes() = %d, want 3", got)
+	}
+
+	c := &Collector{events: &eventRing{}}
+	for _, n := range []int{12, 14, 0, 48, 46} {
+		c.watchRoutes(n)
+	}
+	got := c.events.recent()
+	if len(got) != 1 || got[0].Message != "routes 14 → 48" {
+		t.Fatalf("watchRoutes events = %+v, want one for 14 → 48", got)
+	}
+}
+
+func TestFollowRenames(t *testing.T) {
+	c := NewCollector()
+	c.followRenames = true
+	mb := uint64(1024 * 1024)
+	sample := func(name string, step uint64, tick time.Duration) []NetworkStatus {
+		c.ifaceMACs = map[string]string{name: "aa:bb:cc:dd:ee:ff", "eth0.100": "aa:bb:cc:dd:ee:ff"}
+		return c.networkRates([]net.IOCountersStat{{Name: name, BytesRecv: step * mb, BytesSent: step * mb}}, nil, nil, nil, tick)
+	}
+	sample("eth0", 1, time.Second)
+	sample("eth0", 2, 2*time.Second)
+	rows := sample("enp3s0", 3, 3*time.Second)
+	if len(rows) != 1 || rows[0].Name != "enp3s0" || rows[0].Renamed != "eth0" || rows[0].RxRateMBs != 1 {
+		t.Fatalf("rows = %+v, want enp3s0 renamed from eth0 with its rate carried over", rows)
+	}
+	if got := rows[0].RxHistory; !slices.Equal(got, []float64{1, 1}) {
+		t.Fatalf("history after rename = %v, want both samples kept", got)
+	}
+	if _, ok := c.prevNet["eth0"]; ok {
+		t.Fatalf("old name should be dropped once its counter moved")
+	}
+	events := c.events.recent()
+	if last := events[len(events)-1].Message; last != "interface renamed: eth0 → enp3s0" {
+		t.Fatalf("last event = %q, want the rename", last)
+	}
+
+	s := newSessionStats(time.Unix(0, 0))
+	s.add(MetricsSnapshot{CollectedAt: time.Unix(1, 0), Network: []NetworkStatus{{Name: "eth0", RxRateMBs: 1}}})
+	s.add(MetricsSnapshot{CollectedAt: time.Unix(2, 0), Network: []NetworkStatus{{Name: "eth0", RxRateMBs: 1}}})
+	s.add(MetricsSnapshot{CollectedAt: time.Unix(3, 0), Network: []NetworkStatus{{Name: "enp3s0", Renamed: "eth0", RxRateMBs: 1}}})
+	if len(s.ifaces) != 1 || s.ifaces["enp3s0"] == nil || s.ifaces["enp3s0"].rx != 2*float64(mb) {
+		t.Fatalf("session totals = %v, want eth0's bytes carried to enp3s0", s.ifaces)
+	}
+}
+
+func TestARPCheck(t *testing.T) {
+	neigh := "192.168.1.1 dev eth0 lladdr AA:BB:CC:00:00:01 REACHABLE\n192.168.1.9 dev eth0  FAILED\n"
+	if got := parseLinuxNeighborMACs(neigh); !maps.Equal(got, map[string]string{"192.168.1.1%eth0": "aa:bb:cc:00:00:01"}) {
+		t.Errorf("parseLinuxNeighborMACs() = %v", got)
+	}
+	arp := "? (192.168.1.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]\n? (192.168.1.7) at (incomplete) on en0 ifscope [ethernet]\n"
+	if got := parseDarwinARPMACs(arp); !maps.Equal(got, map[string]string{"192.168.1.1%en0": "0:11:22:33:44:55"}) {
+		t.Errorf("parseDarwinARPMACs() = %v", got)
+	}
+	addrs := "2: eth0    inet 192.168.1.20/24 brd 192.168.1.255 scope global eth0\\       valid_lft forever\n" +
+		"2: eth0    inet6 fe80::1/64 scope link dadfailed tentative \\       valid_lft forever preferred_lft forever\n"
+	if got := parseLinuxDADFailed(addrs); !slices.Equal(got, []string{"duplicate address fe80::1 on eth0"}) {
+		t.Errorf("parseLinuxDADFailed() = %v", got)
+	}
+	ifconfig := "en0: flags=8863<UP,BROADCAST> mtu 1500\n\tinet6 fe80::1%en0 prefixlen 64 duplicated scopeid 0x4\n\tinet 192.168.1.20 netmask 0xffffff00\n"
+	if got := parseDarwinDuplicated(ifconfig); !slices.Equal(got, []string{"duplicate address fe80::1 on en0"}) {
+		t.Errorf("parseDarwinDuplicated() = %v", got)
+	}
+	stats := []NetworkStatus{{Name: "wlan0", IP: "10.0.0.5", Kind: ifaceKindPhysical}, {Name: "tun0", IP: "10.8.0.2", Kind: ifaceKindVPN}, {Name: "eth0", Kind: ifaceKindPhysical}}
+	if got := arpTargets(stats); !slices.Equal(got, []arpTarget{{iface: "wlan0", ip: "10.0.0.5"}}) {
+		t.Errorf("arpTargets() = %v", got)
+	}
+
+	c := &Collector{events: &eventRing{}}
+	start := time.Now()
+	scan := func(after time.Duration, mac string, conflicts ...string) {
+		c.watchARP(arpScan{at: start.Add(after), macs: map[string]string{"192.168.1.5%eth0": mac}, conflicts: conflicts})
+	}
+	scan(0, "aa")
+	scan(time.Minute, "bb") // A replaced device: not a conflict on its own.
+	if got := c.events.recent(); len(got) != 0 {
+		t.Fatalf("events after one MAC change = %+v, want none", got)
+	}
+	scan(2*time.Minute, "aa", "duplicate address fe80::1 on eth0")
+	scan(3*time.Minute, "bb", "duplicate address fe80::1 on eth0")
+	got := c.events.recent()
+	if len(got) != 2 || got[0].Message != "duplicate address fe80::1 on eth0" || got[1].Message != "IP conflict: 192.168.1.5 on eth0 answered by bb and aa" {
+		t.Fatalf("events = %+v, want the DAD failure and one flap, each logged once", got)
+	}
+	scan(30*time.Minute, "bb")
+	scan(31*time.Minute, "bb", "duplicate address fe80::1 on eth0")
+	if got := c.events.recent(); len(got) != 3 {
+		t.Errorf("events = %+v, want a cleared conflict logged again when it returns", got)
+	}
+}
+
+func TestCgroupTraffic(t *testing.T) {
+	ruleset := func(webOut, webIn uint64) []byte {
+		return []byte(fmt.Sprintf(`{"nftables": [
+  {"metainfo": {"version": "1.0.9", "json_schema_version": 1}},
+  {"chain": {"family": "inet", "table": "acct", "name": "out", "handle": 1, "type": "filter", "hook": "output", "prio": 0, "policy": "accept"}},
+  {"chain": {"family": "inet", "table": "acct", "name": "in", "handle": 2, "type": "filter", "hook": "input", "prio": 0, "policy": "accept"}},
+  {"chain": {"family": "inet", "table": "acct", "name": "tenants", "handle": 3}},
+  {"chain": {"family": "inet", "table": "acct", "name": "fwd", "handle": 4, "type": "filter", "hook": "forward", "prio": 0, "policy": "accept"}},
+  {"rule": {"family": "inet", "table": "acct", "chain": "out", "handle": 5, "expr": [{"jump": {"target": "tenants"}}]}},
+  {"rule": {"family": "inet", "table": "acct", "chain": "tenants", "handle": 6, "expr": [
+    {"match": {"op": "==", "left": {"socket": {"key": "cgroupv2", "level": 2}}, "right": "system.slice/web.service"}},
+    {"counter": {"packets": 10, "bytes": %d}}]}},
+  {"rule": {"family": "inet", "table": "acct", "chain": "in", "handle": 7, "expr": [
+    {"match": {"op": "==", "left": {"socket": {"key": "cgroupv2", "level": 2}}, "right": "system.slice/web.service"}},
+    {"counter": {"packets": 10, "bytes": %d}}]}},
+  {"rule": {"family": "inet", "table": "acct", "chain": "out", "handle": 8, "expr": [
+    {"match": {"op": "==", "left": {"meta": {"key": "cgroup"}}, "right": 1048577}},
+    {"counter": {"packets": 1, "bytes": 4096}}]}},
+  {"rule": {"family": "inet", "table": "acct", "chain": "fwd", "handle": 9, "expr": [
+    {"match": {"op": "==", "left": {"meta": {"key": "cgroup"}}, "right": 1048578}},
+    {"counter": {"packets": 1, "bytes": 4096}}]}},
+  {"rule": {"family": "inet", "table": "acct", "chain": "in", "handle": 10, "expr": [{"counter": {"packets": 1, "bytes": 1}}]}}
+]}`, webOut, webIn))
+	}
+	counters, err := parseNftCgroupCounters(ruleset(1<<20, 2<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]cgroupCounters{"system.slice/web.service": {rx: 2 << 20, tx: 1 << 20}, "10:1": {tx: 4096}}
+	if !maps.Equal(counters, want) {
+		t.Errorf("counters = %+v, want %+v", counters, want)
+	}
+	if _, err := parseNftCgroupCounters([]byte("not json")); err == nil {
+		t.Error("bad JSON should fail")
+	}
+
+	tr := newCgroupTraffic()
+	tr.rates(0, counters, 10)
+	next, _ := parseNftCgroupCounters(ruleset(3<<20, 2<<20))
+	rows := tr.rates(2*time.Second, next, 10)
+	if len(rows) != 2 || rows[1].Name != "cg:system.slice/web.service" || rows[1].TxRateMBs != 1 || rows[1].RxRateMBs != 0 {
+		t.Fatalf("rows = %+v, want web.service sending 1 MB/s", rows)
+	}
+	if r := rows[1]; r.Kind != ifaceKindCgroup || r.inTotals() || len(r.TxHistory) != 1 {
+		t.Errorf("row = %+v, want a cgroup row left out of totals with history", r)
+	}
+	// A reloaded ruleset starts its counters over.
+	if rows := tr.rates(3*time.Second, counters, 10); len(rows) != 1 || rows[0].Name != "cg:10:1" {
+		t.Errorf("rows after a reload = %+v, want only the unchanged cgroup", rows)
+	}
+}
+
+func TestNetworkDebugTrace(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewCollector()
+	c.netTrace = &netTrace{w: &buf}
+	mb := uint64(1024 * 1024)
+	c.networkRates([]net.IOCountersStat{{Name: "eth0", BytesRecv: mb, BytesSent: mb}}, nil, nil, nil, time.Second)
+	c.networkRates([]net.IOCountersStat{{Name: "eth0", BytesRecv: 5 * mb, BytesSent: 2 * mb}}, nil, nil, nil, 3*time.Second)
+	c.networkRates([]net.IOCountersStat{{Name: "eth0", BytesRecv: mb, BytesSent: 3 * mb}, {Name: "eth1", BytesRecv: 7}}, nil, nil, nil, 4*time.Second)
+
+	var got []netTraceEntry
+	for line := range strings.Lines(buf.String()) {
+		var e netTraceEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("trace line %q: %v", line, err)
+		}
+		got = append(got, e)
+	}
+	if len(got) != 4 {
+		t.Fatalf("trace = %+v, want four entries", got)
+	}
+	if e := got[0]; e.Note != "baseline" || e.CurRx != mb || e.Tick != 1 {
+		t.Errorf("first sample = %+v, want a baseline at tick 1", e)
+	}
+	if e := got[1]; e.Note != "" || e.Elapsed != 2 || e.PrevRx != mb || e.CurRx != 5*mb || e.RxMBs != 2 || e.TxMBs != 0.5 {
+		t.Errorf("second sample = %+v, want 4 MB over 2s", e)
+	}
+	if got[2].Note != "reset" || got[3].Iface != "eth1" || got[3].Note != "new" || got[3].At.IsZero() {
+		t.Errorf("third sample = %+v, want eth0 reset and eth1 new", got[2:])
+	}
+
+	c.netTrace = nil
+	c.networkRates([]net.IOCountersStat{{Name: "eth0", BytesRecv: 2 * mb}}, nil, nil, nil, 5*time.Second)
+	if len(strings.Split(strings.TrimSpace(buf.String()), "\n")) != 4 {
+		t.Error("no trace should be written without --debug-net")
+	}
+}
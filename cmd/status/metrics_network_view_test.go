@@ -0,0 +1,926 @@
+//go:build !agent
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	stdnet "net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+func TestCollectProxyPerScheme(t *testing.T) {
+	env := map[string]string{
+		"HTTPS_PROXY": "http://10.0.0.2:3128",
+		"http_proxy":  "http://10.0.0.1:3128",
+		"HTTP_PROXY":  "http://ignored:1",
+	}
+	got := collectProxyFromEnv(func(key string) string { return env[key] })
+	// HTTPS wins precedence; both stay listed with their scheme.
+	if got.Scheme != "https" || got.Host != "10.0.0.2:3128" || len(got.Schemes) != 2 || got.Schemes[1].Host != "10.0.0.1:3128" {
+		t.Fatalf("env proxies = %+v", got)
+	}
+	card := renderNetworkCard([]NetworkStatus{{Name: "en0"}}, NetworkHistory{}, got, false, 60, viewState{})
+	text := stripANSI(strings.Join(card.lines, "\n"))
+	if !strings.Contains(text, "Proxy  https  HTTP 10.0.0.2:3128") || !strings.Contains(text, "Proxy  http   HTTP 10.0.0.1:3128") {
+		t.Fatalf("network card should list each scheme:\n%s", text)
+	}
+
+	// The same proxy for every scheme reads as one.
+	env = map[string]string{"https_proxy": "http://10.0.0.1:3128", "http_proxy": "http://10.0.0.1:3128"}
+	if got := collectProxyFromEnv(func(key string) string { return env[key] }); got.Schemes != nil {
+		t.Fatalf("identical proxies should not be split: %+v", got.Schemes)
+	}
+
+	out := `
+<dictionary> {
+  HTTPEnable : 1
+  HTTPProxy : 10.0.0.1
+  HTTPPort : 8080
+  HTTPSEnable : 1
+  HTTPSProxy : 10.0.0.2
+  HTTPSPort : 8443
+  ProxyAutoConfigEnable : 1
+  ProxyAutoConfigURLString : http://10.0.0.3/proxy.pac
+}`
+	got = collectProxyFromScutilOutput(out)
+	var schemes []string
+	for _, p := range got.Schemes {
+		schemes = append(schemes, p.Scheme+" "+p.Type+" "+p.Host)
+	}
+	want := []string{"https HTTPS 10.0.0.2:8443", "http HTTP 10.0.0.1:8080", "auto PAC 10.0.0.3"}
+	if got.Type != "HTTPS" || !slices.Equal(schemes, want) {
+		t.Fatalf("scutil proxies = %s %q, want HTTPS %q", got.Type, schemes, want)
+	}
+}
+
+func TestNetworkRowsCollapsesContainers(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "en0", RxRateMBs: 1, Kind: ifaceKindPhysical},
+		{Name: "veth1", RxRateMBs: 0.5, Kind: ifaceKindContainer},
+		{Name: "veth2", TxRateMBs: 0.25, Kind: ifaceKindContainer},
+	}
+
+	collapsed := networkRows(stats, viewState{})
+	if len(collapsed) != 2 {
+		t.Fatalf("networkRows() collapsed = %d lines, want 2: %q", len(collapsed), collapsed)
+	}
+	summary := stripANSI(collapsed[1])
+	if !strings.Contains(summary, "Containers (2)") || !strings.Contains(summary, "0.50 MB/s") {
+		t.Fatalf("networkRows() summary = %q", summary)
+	}
+
+	expanded := networkRows(stats, viewState{showContainers: true})
+	if len(expanded) != 4 {
+		t.Fatalf("networkRows() expanded = %d lines, want 4: %q", len(expanded), expanded)
+	}
+
+	if rows := networkRows(stats[:1], viewState{}); rows != nil {
+		t.Fatalf("networkRows() single interface = %q, want nil", rows)
+	}
+}
+
+func TestNetworkRowsHiddenInterfaces(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "en0", RxRateMBs: 2, Kind: ifaceKindPhysical},
+		{Name: "en5", RxRateMBs: 1, Kind: ifaceKindPhysical},
+		{Name: "en7", RxRateMBs: 1, Kind: ifaceKindPhysical},
+	}
+	state := viewState{hiddenIfaces: map[string]bool{"en5": true}}
+
+	rows := networkRows(stats, state)
+	if len(rows) != 3 || !strings.Contains(stripANSI(rows[2]), "1 hidden") {
+		t.Fatalf("networkRows() with hidden = %q", rows)
+	}
+	if got := selectableInterfaces(stats, state); len(got) != 2 || got[1] != "en7" {
+		t.Fatalf("selectableInterfaces() = %v, want [en0 en7]", got)
+	}
+
+	state.showHidden = true
+	if got := selectableInterfaces(stats, state); len(got) != 3 {
+		t.Fatalf("selectableInterfaces() showing hidden = %v", got)
+	}
+}
+
+func TestHiddenInterfacesStillCountTowardTotals(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "en0", RxRateMBs: 2, Kind: ifaceKindPhysical},
+		{Name: "en5", RxRateMBs: 1, Kind: ifaceKindPhysical},
+	}
+	state := viewState{hiddenIfaces: map[string]bool{"en5": true}}
+
+	card := renderNetworkCard(stats, NetworkHistory{}, ProxyStatus{}, false, 60, state)
+	if !strings.Contains(stripANSI(card.lines[0]), "3.0 MB/s") {
+		t.Fatalf("hidden interface should count toward totals, got %q", stripANSI(card.lines[0]))
+	}
+
+	state.excludeHidden = true
+	card = renderNetworkCard(stats, NetworkHistory{}, ProxyStatus{}, false, 60, state)
+	if !strings.Contains(stripANSI(card.lines[0]), "2.0 MB/s") {
+		t.Fatalf("--exclude-hidden should drop hidden interface from totals, got %q", stripANSI(card.lines[0]))
+	}
+}
+
+func TestMoveSelection(t *testing.T) {
+	names := []string{"en0", "en5", "en7"}
+	if got := moveSelection(names, "", 1); got != "en0" {
+		t.Errorf("moveSelection() from nothing = %q, want en0", got)
+	}
+	if got := moveSelection(names, "en0", 1); got != "en5" {
+		t.Errorf("moveSelection() down = %q, want en5", got)
+	}
+	if got := moveSelection(names, "en7", 1); got != "en7" {
+		t.Errorf("moveSelection() past end = %q, want en7", got)
+	}
+	if got := moveSelection(names, "gone0", -1); got != "en0" {
+		t.Errorf("moveSelection() from vanished = %q, want en0", got)
+	}
+	if got := moveSelection(nil, "en0", 1); got != "" {
+		t.Errorf("moveSelection() empty = %q, want empty", got)
+	}
+}
+
+func TestNetworkRowsMinRate(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "en0", RxRateMBs: 2, Kind: ifaceKindPhysical},
+		{Name: "en5", RxRateMBs: 0.001, Kind: ifaceKindPhysical},
+		{Name: "en7", TxRateMBs: 0.5, Kind: ifaceKindPhysical},
+	}
+	state := viewState{minRate: 0.01}
+
+	rows := networkRows(stats, state)
+	if len(rows) != 2 || strings.Contains(stripANSI(strings.Join(rows, "\n")), "en5") {
+		t.Fatalf("networkRows() with --min-rate = %q", rows)
+	}
+
+	state.minRate = 10
+	rows = networkRows(stats, state)
+	if len(rows) != 1 || stripANSI(rows[0]) != "No active traffic" {
+		t.Fatalf("networkRows() all idle = %q", rows)
+	}
+	// Idle interfaces still feed the totals.
+	card := renderNetworkCard(stats, NetworkHistory{}, ProxyStatus{}, false, 60, state)
+	if !strings.Contains(stripANSI(card.lines[0]), "2.0 MB/s") {
+		t.Fatalf("idle filter should not change totals, got %q", stripANSI(card.lines[0]))
+	}
+}
+
+func TestNetworkRowsColumns(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "eth0", IP: "10.0.0.2", RxRateMBs: 11.92, TxRateMBs: 0.5, Kind: ifaceKindPhysical, LinkMbps: 1000, RxErrors: 3},
+		{Name: "wg0", RxRateMBs: 0.1, Kind: ifaceKindVPN},
+	}
+	state := viewState{netColumns: []string{"name", "ip", "rx", "util", "errors", "kind"}}
+	rows := networkRows(stats, state)
+	if len(rows) != 2 {
+		t.Fatalf("networkRows() = %q", rows)
+	}
+	want := []string{
+		"eth0   10.0.0.2 ↓   12 MB/s 10% err 3/0 physical",
+		"wg0    —        ↓ 0.10 MB/s   — err 0/0 vpn",
+	}
+	for i, row := range rows {
+		if got := stripANSI(row); got != want[i] {
+			t.Errorf("row %d = %q, want %q", i, got, want[i])
+		}
+	}
+
+	// The default set keeps the classic row.
+	classic := networkRows(stats, viewState{})
+	state.netColumns = defaultNetColumns
+	if got := networkRows(stats, state); !slices.Equal(got, classic) {
+		t.Errorf("default columns = %q, want %q", got, classic)
+	}
+}
+
+func TestNetworkCardWarmup(t *testing.T) {
+	card := renderNetworkCard(nil, NetworkHistory{}, ProxyStatus{}, true, 60, viewState{})
+	if got := stripANSI(strings.Join(card.lines, "\n")); !strings.Contains(got, "Warming up") {
+		t.Errorf("warmup card = %q, want warming up notice", got)
+	}
+
+	card = renderNetworkCard(nil, NetworkHistory{}, ProxyStatus{}, false, 60, viewState{})
+	if got := stripANSI(strings.Join(card.lines, "\n")); strings.Contains(got, "Warming up") {
+		t.Errorf("card after warmup = %q, want no warming up notice", got)
+	}
+}
+
+func TestIfaceColorsStable(t *testing.T) {
+	a := ifaceColors([]string{"en0", "en1", "utun3"})
+	b := ifaceColors([]string{"utun3", "en0", "en1"})
+	for name, idx := range a {
+		if b[name] != idx {
+			t.Errorf("%s color changed with row order: %d vs %d", name, idx, b[name])
+		}
+	}
+
+	names := []string{"en0", "en1", "en2", "en3", "en4", "en5", "en6", "en7"}
+	seen := make(map[int]string)
+	for name, idx := range ifaceColors(names) {
+		if other, ok := seen[idx]; ok {
+			t.Errorf("%s and %s share color %d", name, other, idx)
+		}
+		seen[idx] = name
+	}
+}
+
+func TestNetworkCardBondMembersNotTotaled(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "bond0", RxRateMBs: 2, TxRateMBs: 1},
+		{Name: "eth0", RxRateMBs: 1, TxRateMBs: 0.5, Bond: "bond0"},
+		{Name: "eth1", RxRateMBs: 1, TxRateMBs: 0.5, Bond: "bond0"},
+	}
+	card := renderNetworkCard(stats, NetworkHistory{}, ProxyStatus{}, false, 60, viewState{})
+	text := stripANSI(strings.Join(card.lines, "\n"))
+	if !strings.Contains(text, "Down") || !strings.Contains(card.summary, "↓ 2.0 MB/s") {
+		t.Fatalf("summary = %q, want bond-only total", card.summary)
+	}
+	if !strings.Contains(text, "in bond0") {
+		t.Fatalf("member rows should name their bond:\n%s", text)
+	}
+}
+
+func TestTotalsExclude(t *testing.T) {
+	c := NewCollector()
+	c.totalsSkip = toSet([]string{"br0"})
+	mb := uint64(1024 * 1024)
+	counters := func(step uint64) []net.IOCountersStat {
+		return []net.IOCountersStat{
+			{Name: "eth0", BytesRecv: step * mb, BytesSent: step * mb},
+			{Name: "br0", BytesRecv: 10 * step * mb, BytesSent: 10 * step * mb},
+		}
+	}
+	c.networkRates(counters(1), nil, nil, nil, time.Second)
+	rows := c.networkRates(counters(2), nil, nil, nil, 2*time.Second)
+	if len(rows) != 2 || rows[0].Name != "br0" || !rows[0].Untotaled || rows[1].Untotaled {
+		t.Fatalf("rows = %+v, want br0 listed first and marked untotaled", rows)
+	}
+	if got := c.rxHistoryBuf.Slice(); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("aggregate rx history = %v, want [1] without br0", got)
+	}
+
+	card := renderNetworkCard(rows, NetworkHistory{}, ProxyStatus{}, false, 60, viewState{})
+	if !strings.Contains(card.summary, "↓ 1.0 MB/s") {
+		t.Fatalf("summary = %q, want the eth0-only total", card.summary)
+	}
+	if text := stripANSI(strings.Join(card.lines, "\n")); !strings.Contains(text, "br0") {
+		t.Fatalf("excluded interface should still be listed:\n%s", text)
+	}
+}
+
+func TestEventRing(t *testing.T) {
+	r := &eventRing{}
+	for i := range eventRingSize + 5 {
+		r.add(severityInfo, categoryNetwork, fmt.Sprintf("interface up: en%d", i))
+	}
+	got := r.recent()
+	if len(got) != eventRingSize || got[0].Message != "interface up: en5" {
+		t.Fatalf("recent() kept %d events starting at %q", len(got), got[0].Message)
+	}
+	got[0].Message = "changed"
+	if r.recent()[0].Message == "changed" {
+		t.Fatalf("recent() must return a copy")
+	}
+
+	m := model{metrics: MetricsSnapshot{Events: []StatusEvent{{At: time.Now(), Message: "interface up: en5"}}}}
+	if !strings.Contains(stripANSI(m.footer()), "interface up: en5") {
+		t.Fatalf("footer should show the latest event: %q", stripANSI(m.footer()))
+	}
+}
+
+func TestEventLogPanel(t *testing.T) {
+	m := model{ready: true, width: 100, height: 9, events: &eventRing{}}
+	for i := range 10 {
+		m.events.add(severityInfo, categoryNetwork, fmt.Sprintf("interface up: en%d", i))
+	}
+	press := func(key tea.KeyMsg) {
+		next, _ := m.Update(key)
+		m = next.(model)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	view := stripANSI(m.View())
+	if !strings.Contains(view, "Event log") || !strings.Contains(view, "en9") || strings.Contains(view, "en2") {
+		t.Fatalf("event log should show the newest events:\n%s", view)
+	}
+	for range 20 {
+		press(tea.KeyMsg{Type: tea.KeyUp})
+	}
+	if m.eventScroll != 5 || !strings.Contains(stripANSI(m.View()), "en0") {
+		t.Fatalf("scroll = %d, want 5 (top of the log)", m.eventScroll)
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.showEvents {
+		t.Fatalf("esc should close the event log")
+	}
+}
+
+func TestNetworkRowsGroupedByKind(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "wg0", Kind: ifaceKindVPN, RxRateMBs: 4},
+		{Name: "en0", Kind: ifaceKindPhysical, RxRateMBs: 3},
+		{Name: "vmnet1", Kind: ifaceKindVirtual, RxRateMBs: 2},
+		{Name: "en1", RxRateMBs: 1}, // No kind from an older agent: classified by name.
+	}
+	var got []string
+	for _, line := range networkRows(stats, viewState{groupByKind: true}) {
+		got = append(got, strings.Fields(stripANSI(line))[0])
+	}
+	want := []string{"Physical", "en0", "en1", "VPN", "wg0", "Virtual", "vmn…"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("grouped rows = %v, want %v", got, want)
+	}
+	if line := stripANSI(networkRows(stats, viewState{groupByKind: true})[0]); !strings.Contains(line, formatRate(4)) {
+		t.Fatalf("physical subtotal = %q, want en0+en1 = %s", line, formatRate(4))
+	}
+}
+
+func TestTrafficShare(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "en0", RxRateMBs: 8, TxRateMBs: 0.5},
+		{Name: "en1", RxRateMBs: 1, TxRateMBs: 0.5},
+	}
+	if got := trafficShare(stats, stats[0]); got != "85%" {
+		t.Fatalf("trafficShare(en0) = %q, want 85%%", got)
+	}
+	idle := []NetworkStatus{{Name: "en0"}, {Name: "en1"}}
+	if got := trafficShare(idle, idle[0]); got != "—" {
+		t.Fatalf("trafficShare() with no traffic = %q, want —", got)
+	}
+
+	card := renderNetworkCard(stats, NetworkHistory{}, ProxyStatus{}, false, 60, viewState{selectedIface: "en1"})
+	text := stripANSI(strings.Join(card.lines, "\n"))
+	if !strings.Contains(text, "15% of total") || strings.Contains(text, "85% of total") {
+		t.Fatalf("only the selected row should show its share:\n%s", text)
+	}
+}
+
+func TestPinnedInterfaces(t *testing.T) {
+	c := NewCollector()
+	c.pinned = toSet([]string{"eth0"}) // The quietest of the synthetic interfaces.
+	c.networkRates(syntheticCounters(10, 1), nil, nil, nil, time.Second)
+	rows := c.networkRates(syntheticCounters(10, 2), nil, nil, nil, 2*time.Second)
+	var names []string
+	for _, r := range rows {
+		if r.Kind != ifaceKindContainer {
+			names = append(names, r.Name)
+		}
+	}
+	if !slices.Equal(names, []string{"eth0", "eth5"}) {
+		t.Fatalf("rows = %v, want eth0 pinned ahead of the busier eth5", names)
+	}
+
+	state := viewState{pinned: c.pinned, minRate: 100}
+	regular, _, _, idle := splitInterfaces([]NetworkStatus{{Name: "eth1", RxRateMBs: 200}, {Name: "eth0"}, {Name: "eth2"}}, state)
+	if len(regular) != 2 || regular[0].Name != "eth0" || idle != 1 {
+		t.Fatalf("splitInterfaces() = %+v (idle %d), want pinned eth0 first and kept past --min-rate", regular, idle)
+	}
+}
+
+func TestPublicIPProbe(t *testing.T) {
+	var reply atomic.Value
+	reply.Store("203.0.113.7\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, reply.Load())
+	}))
+	defer srv.Close()
+
+	c := &Collector{publicIPURL: srv.URL, publicEvery: time.Hour}
+	snapshot := func(vpn string) PublicIPStatus {
+		c.publicLastAt = time.Now() // Keep publicIPSnapshot from probing in the background.
+		return *c.publicIPSnapshot(time.Now(), vpn)
+	}
+
+	c.probePublicIP("")
+	if got := snapshot(""); got.IP != "203.0.113.7" || got.Leak {
+		t.Fatalf("direct probe = %+v", got)
+	}
+	// Same address with a VPN up: traffic is bypassing the tunnel.
+	c.probePublicIP("wg0")
+	if got := snapshot("wg0"); !got.Leak || !strings.Contains(stripANSI(publicIPLine(got)), "while wg0 is up") {
+		t.Fatalf("leak not flagged: %+v", got)
+	}
+	reply.Store("198.51.100.2")
+	c.probePublicIP("wg0")
+	if got := snapshot("wg0"); got.Leak || stripANSI(publicIPLine(got)) != "Public 198.51.100.2 · via wg0" {
+		t.Fatalf("tunnelled probe = %+v", got)
+	}
+
+	reply.Store("<html>rate limited</html>")
+	c.probePublicIP("")
+	if got := snapshot(""); got.IP != "" || got.Error == "" || stripANSI(publicIPLine(got)) != "Public unknown" {
+		t.Fatalf("bad reply should read as unknown: %+v", got)
+	}
+	srv.Close()
+	c.probePublicIP("")
+	if got := snapshot(""); got.IP != "" || got.Error == "" {
+		t.Fatalf("offline probe should read as unknown: %+v", got)
+	}
+}
+
+func TestTCPRetransmits(t *testing.T) {
+	const snmp = `Ip: Forwarding DefaultTTL InReceives
+Ip: 1 64 123
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 100 20 3 4 5 9000 %d %d 0 7 0
+Udp: InDatagrams NoPorts
+Udp: 1 2
+`
+	read := func(out, retrans uint64) tcpCounters {
+		t.Helper()
+		c, err := readTCPCounters(strings.NewReader(fmt.Sprintf(snmp, out, retrans)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	if got := read(8000, 12); got != (tcpCounters{outSegs: 8000, retransSegs: 12}) {
+		t.Fatalf("readTCPCounters = %+v", got)
+	}
+	if _, err := readTCPCounters(strings.NewReader("Ip: Forwarding\nIp: 1\n")); err == nil {
+		t.Error("missing Tcp: section should fail")
+	}
+
+	var w rateWindow
+	if got := tcpRates(tcpCounters{}, read(8000, 12), &w, 0); got != nil {
+		t.Fatalf("first sample should have no rate, got %+v", got)
+	}
+	got := tcpRates(read(8000, 12), read(10000, 32), &w, 2*time.Second)
+	if got == nil || got.RetransRate != 10 || got.RetransPercent != 1 {
+		t.Fatalf("tcpRates = %+v, want 10/s and 1%%", got)
+	}
+	if !strings.Contains(stripANSI(tcpLine(*got)), "Retrans 10/s · 1.0% of sent") {
+		t.Errorf("tcpLine = %q", stripANSI(tcpLine(*got)))
+	}
+	// A 32-bit counter wrapping past zero still yields the small delta.
+	got = tcpRates(read(1<<32-100, 1<<32-5), read(100, 5), &w, 3*time.Second)
+	if got == nil || got.RetransRate != 10 {
+		t.Fatalf("wrapped tcpRates = %+v", got)
+	}
+	// A 64-bit counter going backwards is a reset: skip the sample.
+	if got := tcpRates(read(1<<40, 1<<40), read(10, 1), &w, 4*time.Second); got != nil {
+		t.Fatalf("reset should yield no rate, got %+v", got)
+	}
+}
+
+func TestCollectNetworkIdleTimer(t *testing.T) {
+	const mb = 1 << 20
+	sample := func(rx uint64) []net.IOCountersStat {
+		return []net.IOCountersStat{{Name: "eth0", BytesRecv: rx}}
+	}
+	// Quiet for two 10s gaps, a burst, quiet again, then a counter reset.
+	fakeNetwork(t, sample(5*mb), sample(5*mb), sample(5*mb), sample(50*mb), sample(50*mb), sample(0))
+
+	c := NewCollector()
+	var idle []float64
+	for i := range 6 {
+		got, _ := c.collectNetwork(time.Duration(i*10) * time.Second)
+		if len(got) == 1 {
+			idle = append(idle, got[0].IdleSecs)
+		}
+	}
+	if want := []float64{10, 20, 0, 10, 0}; !slices.Equal(idle, want) {
+		t.Fatalf("idle seconds = %v, want %v", idle, want)
+	}
+
+	if got := idleSuffix(NetworkStatus{IdleSecs: 150}); got != " idle 2m" {
+		t.Fatalf("idleSuffix(150s) = %q", got)
+	}
+	if got := idleSuffix(NetworkStatus{IdleSecs: 10}); got != "" {
+		t.Fatalf("idleSuffix(10s) = %q, want nothing for a short lull", got)
+	}
+}
+
+func TestTrafficDirection(t *testing.T) {
+	for _, tc := range []struct {
+		rx, tx float64
+		want   string
+	}{
+		{0, 0, "· idle"},
+		{0.005, 0.001, "· idle"}, // Both below the floor.
+		{5, 0.1, "↓ down"},
+		{2, 1, "↓ down"}, // Exactly the ratio.
+		{0.2, 3, "↑ up"},
+		{1.5, 1, "⇅ both"},
+	} {
+		if got := trafficDirection(tc.rx, tc.tx, asymMinRate, 2); got != tc.want {
+			t.Errorf("trafficDirection(%v, %v) = %q, want %q", tc.rx, tc.tx, got, tc.want)
+		}
+	}
+
+	// With --dir-totals the session's bytes decide, not the current rates.
+	stats := []NetworkStatus{
+		{Name: "en0", RxRateMBs: 4, Kind: ifaceKindPhysical},
+		{Name: "en5", Kind: ifaceKindPhysical},
+	}
+	state := viewState{netColumns: []string{"name", "dir"}, dirRatio: 2}
+	if rows := networkRows(stats, state); len(rows) != 2 || stripANSI(rows[0]) != "en0    ↓ down" || stripANSI(rows[1]) != "en5    · idle" {
+		t.Fatalf("rows by rate = %q", rows)
+	}
+	state.dirTotals = true
+	state.sessionBytes = map[string][2]uint64{"en0": {100 << 20, 900 << 20}, "en5": {1 << 20, 1 << 20}}
+	if rows := networkRows(stats, state); len(rows) != 2 || stripANSI(rows[0]) != "en0    ↑ up" || stripANSI(rows[1]) != "en5    ⇅ both" {
+		t.Fatalf("rows by session totals = %q", rows)
+	}
+}
+
+func TestAsymmetrySuffix(t *testing.T) {
+	series := func(n int, v float64) []float64 { return slices.Repeat([]float64{v}, n) }
+	tests := []struct {
+		name   string
+		rx, tx []float64
+		want   string
+	}{
+		{"sending with nothing back", series(5, 0), series(5, 2), " one-way ↑ only"},
+		{"receiving with acks", series(5, 4.5), series(5, 0.1), " one-way ↓ 45:1"},
+		{"balanced", series(5, 1), series(5, 1), ""},
+		{"one balanced sample breaks it", append(series(4, 0), 1), series(5, 1), ""},
+		{"too quiet to matter", series(5, 0), series(5, 0.001), ""},
+		{"not enough samples", series(3, 0), series(3, 2), ""},
+	}
+	for _, tt := range tests {
+		n := NetworkStatus{Name: "eth0", RxHistory: tt.rx, TxHistory: tt.tx}
+		if got := asymmetrySuffix(n, 20, 4); got != tt.want {
+			t.Errorf("%s: asymmetrySuffix() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := asymmetrySuffix(NetworkStatus{RxHistory: series(5, 0), TxHistory: series(5, 2)}, 0, 4); got != "" {
+		t.Errorf("ratio 0 should turn the check off, got %q", got)
+	}
+
+	row := stripANSI(strings.Join(networkRows([]NetworkStatus{{Name: "eth0", TxRateMBs: 2, RxHistory: series(5, 0), TxHistory: series(5, 2)}, {Name: "wlan0"}},
+		viewState{asymRatio: 20, asymWindow: 4}), "\n"))
+	if !strings.Contains(row, "one-way ↑ only") {
+		t.Errorf("row should carry the asymmetry mark:\n%s", row)
+	}
+}
+
+func TestIfaceConnections(t *testing.T) {
+	conn := func(status string, typ uint32, laddr, raddr string, pid int32) net.ConnectionStat {
+		return net.ConnectionStat{Status: status, Type: typ, Laddr: net.Addr{IP: laddr, Port: 50000}, Raddr: net.Addr{IP: raddr, Port: 443}, Pid: pid}
+	}
+	conns := []net.ConnectionStat{
+		conn("TIME_WAIT", syscall.SOCK_STREAM, "10.0.0.2", "1.1.1.1", 0),
+		conn("ESTABLISHED", syscall.SOCK_STREAM, "::ffff:10.0.0.2", "::ffff:9.9.9.9", 42),
+		conn("ESTABLISHED", syscall.SOCK_STREAM, "2001:db8::2", "2606:4700::1", 42),
+		conn("LISTEN", syscall.SOCK_STREAM, "10.0.0.2", "", 42),
+		conn("ESTABLISHED", syscall.SOCK_STREAM, "192.168.9.9", "1.1.1.1", 0), // No interface has it.
+		conn("NONE", syscall.SOCK_DGRAM, "10.8.0.5", "10.8.0.1", 0),
+	}
+	ifaces := net.InterfaceStatList{
+		{Name: "en0", Addrs: net.InterfaceAddrList{{Addr: "10.0.0.2/24"}, {Addr: "2001:db8::2/64"}}},
+		{Name: "utun3", Addrs: net.InterfaceAddrList{{Addr: "10.8.0.5/32"}}},
+	}
+	lookups := 0
+	got := ifaceConnections(conns, localAddrOwners(ifaces), func(int32) string { lookups++; return "curl" })
+	want := map[string][]Conn{
+		"en0": {
+			{Local: "[2001:db8::2]:50000", Remote: "[2606:4700::1]:443", Proto: "TCP", State: "ESTABLISHED", PID: 42, Process: "curl"},
+			{Local: "10.0.0.2:50000", Remote: "9.9.9.9:443", Proto: "TCP", State: "ESTABLISHED", PID: 42, Process: "curl"},
+			{Local: "10.0.0.2:50000", Remote: "1.1.1.1:443", Proto: "TCP", State: "TIME_WAIT"},
+		},
+		"utun3": {{Local: "10.8.0.5:50000", Remote: "10.8.0.1:443", Proto: "UDP"}},
+	}
+	if !reflect.DeepEqual(got, want) || lookups != 1 {
+		t.Fatalf("ifaceConnections() = %+v (%d lookups), want %+v", got, lookups, want)
+	}
+
+	lines := ifaceConnLines("en0", got)
+	if len(lines) != 3 || stripANSI(lines[2]) != "      TCP  :50000 → 1.1.1.1:443 TIME_WAIT" {
+		t.Fatalf("ifaceConnLines() = %q", lines)
+	}
+	if lines := ifaceConnLines("wg0", got); len(lines) != 1 || !strings.Contains(stripANSI(lines[0]), "no connections") {
+		t.Fatalf("ifaceConnLines(wg0) = %q", lines)
+	}
+	if lines := ifaceConnLines("en0", nil); lines != nil {
+		t.Fatalf("ifaceConnLines() before the first sample = %q", lines)
+	}
+}
+
+func TestHeavyPorts(t *testing.T) {
+	conn := func(status, laddr string, lport uint32, raddr string, rport uint32) net.ConnectionStat {
+		return net.ConnectionStat{Status: status, Type: syscall.SOCK_STREAM, Laddr: net.Addr{IP: laddr, Port: lport}, Raddr: net.Addr{IP: raddr, Port: rport}}
+	}
+	conns := []net.ConnectionStat{
+		conn("LISTEN", "0.0.0.0", 22, "", 0),
+		conn("ESTABLISHED", "10.0.0.2", 22, "10.0.0.9", 51000), // Someone's SSH session to us.
+		conn("ESTABLISHED", "10.0.0.2", 50001, "1.1.1.1", 443),
+		conn("ESTABLISHED", "::ffff:10.0.0.2", 50002, "::ffff:9.9.9.9", 443),
+		conn("TIME_WAIT", "10.0.0.2", 50003, "1.1.1.1", 443),
+		conn("ESTABLISHED", "10.8.0.5", 50004, "10.8.0.1", 8000),
+		conn("ESTABLISHED", "10.8.0.5", 50005, "10.8.0.1", 8001),
+		conn("ESTABLISHED", "192.168.9.9", 50006, "1.1.1.1", 443), // No interface has it.
+	}
+	ifaces := net.InterfaceStatList{
+		{Name: "en0", Addrs: net.InterfaceAddrList{{Addr: "10.0.0.2/24"}}},
+		{Name: "utun3", Addrs: net.InterfaceAddrList{{Addr: "10.8.0.5/32"}}},
+		{Name: "wg0", Addrs: net.InterfaceAddrList{{Addr: "10.9.0.1/24"}}},
+	}
+	got := heavyPorts(conns, localAddrOwners(ifaces))
+	want := map[string][]PortShare{
+		"en0":   {{Port: 443, Service: "https", Conns: 3, Percent: 75}, {Port: 22, Service: "ssh", Conns: 1, Percent: 25}},
+		"utun3": {{Port: 8000, Conns: 1, Percent: 50}, {Port: 8001, Conns: 1, Percent: 50}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("heavyPorts() = %+v, want %+v", got, want)
+	}
+
+	for _, tt := range []struct {
+		iface, want string
+	}{
+		{"en0", "mostly :443 (https), 75% of connections (estimate)"},
+		{"utun3", "mixed: :8000 50%, :8001 50% of connections (estimate)"},
+		{"wg0", ""},
+	} {
+		if hint := heavyPortsHint(got[tt.iface]); hint != tt.want {
+			t.Errorf("heavyPortsHint(%s) = %q, want %q", tt.iface, hint, tt.want)
+		}
+	}
+}
+
+func TestProxyCheckInBackground(t *testing.T) {
+	dial := proxyDial
+	t.Cleanup(func() { proxyDial = dial })
+	release := make(chan struct{})
+	var dialed []string
+	var mu sync.Mutex
+	proxyDial = func(_, addr string, _ time.Duration) (stdnet.Conn, error) {
+		<-release
+		mu.Lock()
+		dialed = append(dialed, addr)
+		mu.Unlock()
+		if addr == "127.0.0.1:7890" {
+			client, server := stdnet.Pipe()
+			server.Close()
+			return client, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	c := &Collector{proxyCheck: true}
+	c.proxyProbe.every = time.Hour
+	check := func(host string) ProxyStatus {
+		p := ProxyStatus{Enabled: true, Type: "HTTP", Host: host}
+		c.proxyCheckSnapshot(time.Now(), &p)
+		return p
+	}
+	settle := func(host string) ProxyStatus {
+		for range 200 {
+			if p := check(host); !p.Checking {
+				return p
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("proxy check for %s never finished", host)
+		return ProxyStatus{}
+	}
+
+	// The refresh must not wait on a slow dial.
+	if p := check("10.0.0.9"); !p.Checking || p.Reachable != nil {
+		t.Fatalf("first snapshot = %+v, want checking", p)
+	}
+	close(release)
+	if p := settle("10.0.0.9"); p.Reachable == nil || *p.Reachable {
+		t.Fatalf("refused proxy = %+v, want unreachable", p)
+	}
+	if got := stripANSI(proxyCheckText(check("10.0.0.9"))); got != " unreachable" {
+		t.Fatalf("proxyCheckText() = %q", got)
+	}
+	// A different proxy is probed afresh instead of inheriting the verdict.
+	if p := settle("127.0.0.1:7890"); p.Reachable == nil || !*p.Reachable {
+		t.Fatalf("listening proxy = %+v, want reachable", p)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"10.0.0.9:80", "127.0.0.1:7890"}; !slices.Equal(dialed, want) {
+		t.Fatalf("dialed %q, want %q", dialed, want)
+	}
+}
+
+func TestIPFamilySplit(t *testing.T) {
+	const netstat = `TcpExt: SyncookiesSent SyncookiesRecv
+TcpExt: 0 0
+IpExt: InNoRoutes InMcastPkts InOctets OutOctets InMcastOctets
+IpExt: 0 0 %d %d 0
+`
+	const snmp6 = "Ip6InReceives                   \t12\nIp6InOctets                     \t%d\nIp6OutOctets                    \t%d\n"
+	const mb = 1 << 20
+	read := func(v4In, v4Out, v6In, v6Out uint64) ipFamilyCounters {
+		v4, err := readProcGroup(strings.NewReader(fmt.Sprintf(netstat, v4In, v4Out)), procNetNetstat, "IpExt:", "InOctets", "OutOctets")
+		if err != nil {
+			t.Fatal(err)
+		}
+		in, out, err := readIPv6Octets(strings.NewReader(fmt.Sprintf(snmp6, v6In, v6Out)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ipFamilyCounters{v4In: v4[0], v4Out: v4[1], v6In: in, v6Out: out}
+	}
+
+	var window rateWindow
+	first := read(0, 0, 0, 0)
+	if got := ipFamilyRates(ipFamilyCounters{}, first, &window, 0); got != nil {
+		t.Fatalf("first sample = %+v, want nil", got)
+	}
+	second := read(8*mb, 2*mb, 4*mb, 1*mb)
+	got := ipFamilyRates(first, second, &window, 2*time.Second)
+	if want := (IPFamilyStatus{V4RxMBs: 4, V4TxMBs: 1, V6RxMBs: 2, V6TxMBs: 0.5}); got == nil || *got != want {
+		t.Fatalf("rates = %+v, want %+v", got, want)
+	}
+	if line := stripANSI(ipFamilyLine(*got)); line != "IPv4 ↓ 4.0 MB/s ↑ 1.0 MB/s · IPv6 ↓ 2.0 MB/s ↑ 0.50 MB/s" {
+		t.Errorf("ipFamilyLine() = %q", line)
+	}
+	if got := ipFamilyRates(second, read(0, 0, 0, 0), &window, 3*time.Second); got != nil {
+		t.Errorf("reset counters = %+v, want nil", got)
+	}
+	if _, _, err := readIPv6Octets(strings.NewReader("Ip6InReceives 1\n")); err == nil {
+		t.Error("snmp6 without octet counters should fail")
+	}
+
+	rows := stripANSI(strings.Join(networkRows([]NetworkStatus{{Name: "en0", RxRateMBs: 1}, {Name: "en1"}}, viewState{selectedIface: "en0"}), "\n"))
+	if !strings.Contains(rows, "IPv4+IPv6") {
+		t.Errorf("selected row should note that counters cover both families:\n%s", rows)
+	}
+}
+
+func TestConnectionListeners(t *testing.T) {
+	listen := func(ip string, port uint32, family uint32, pid int32) net.ConnectionStat {
+		return net.ConnectionStat{Status: "LISTEN", Family: family, Type: syscall.SOCK_STREAM, Laddr: net.Addr{IP: ip, Port: port}, Pid: pid}
+	}
+	conns := []net.ConnectionStat{
+		listen("0.0.0.0", 8080, syscall.AF_INET, 42),
+		listen("::", 8080, syscall.AF_INET6, 42),
+		listen("127.0.0.1", 22, syscall.AF_INET, 0), // Another user's socket, no PID without root.
+		{Status: "ESTABLISHED", Laddr: net.Addr{IP: "10.0.0.1", Port: 51000}, Pid: 42},
+	}
+	lookups := 0
+	got := listeners(conns, func(pid int32) string {
+		lookups++
+		return "nginx"
+	})
+	want := []Listener{
+		{Addr: "127.0.0.1", Port: 22, Proto: "TCP"},
+		{Addr: "0.0.0.0", Port: 8080, Proto: "TCP", PID: 42, Process: "nginx"},
+		{Addr: "::", Port: 8080, Proto: "TCP6", PID: 42, Process: "nginx"},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("listeners() = %+v, want %+v", got, want)
+	}
+	if lookups != 1 {
+		t.Errorf("process looked up %d times, want once per PID", lookups)
+	}
+
+	card := renderListenersCard(ConnectionStatus{Total: 4, Listeners: got})
+	rows := stripANSI(strings.Join(card.lines, "\n"))
+	for _, line := range []string{"127.0.0.1:22     TCP  —", ":8080            TCP  nginx (42)", ":8080            TCP6 nginx (42)"} {
+		if !strings.Contains(rows, line) {
+			t.Errorf("listeners card missing %q:\n%s", line, rows)
+		}
+	}
+
+	m := model{}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	if !next.(model).display.listenersOnly {
+		t.Error("l should switch the connections panel to listening ports")
+	}
+}
+
+func TestEphemeralUsage(t *testing.T) {
+	low, high, err := parsePortRange("32768\t60999\n")
+	if err != nil || low != 32768 || high != 60999 {
+		t.Fatalf("parsePortRange() = %d, %d, %v", low, high, err)
+	}
+	if _, _, err := parsePortRange("60999 32768"); err == nil {
+		t.Error("parsePortRange should reject an inverted range")
+	}
+
+	tcp := func(port uint32, state string) net.ConnectionStat {
+		return net.ConnectionStat{Type: syscall.SOCK_STREAM, Status: state, Laddr: net.Addr{IP: "10.0.0.2", Port: port}}
+	}
+	conns := []net.ConnectionStat{
+		tcp(40000, "ESTABLISHED"),
+		tcp(40000, "TIME_WAIT"), // Same port to another peer: one port, still live.
+		tcp(40001, "TIME_WAIT"),
+		tcp(40002, "TIME_WAIT"),
+		tcp(40010, "LISTEN"),    // A server in the range is not outgoing use.
+		tcp(443, "ESTABLISHED"), // Below the range.
+		{Type: syscall.SOCK_DGRAM, Laddr: net.Addr{Port: 40020}},
+	}
+	got := ephemeralUsage(conns, 40000, 40009)
+	want := &EphemeralStatus{Low: 40000, High: 40009, InUse: 3, TimeWait: 2, Percent: 30}
+	if *got != *want {
+		t.Fatalf("ephemeralUsage() = %+v, want %+v", *got, *want)
+	}
+
+	m := model{display: viewState{ephemeralAlert: 25}}
+	m.metrics.Connections.Ephemeral = got
+	if alert := stripANSI(renderAlerts(m.metrics, 0, m.display.ephemeralAlert)); alert != "⚠ ephemeral ports 30% used, 2 in TIME_WAIT" {
+		t.Errorf("renderAlerts() = %q", alert)
+	}
+	m.checkAlerts()
+	if !m.ephemeralAlerted {
+		t.Fatal("expected the ephemeral port alert to be raised")
+	}
+	m.metrics.Connections.Ephemeral = &EphemeralStatus{Low: 40000, High: 40009, InUse: 1, Percent: 10}
+	m.checkAlerts()
+	if m.ephemeralAlerted {
+		t.Error("alert should clear below the threshold")
+	}
+	if line := stripANSI(ephemeralLine(got, 80)); line != "30.0% of ephemeral ports 40000–40009 · 2 TIME_WAIT" {
+		t.Errorf("ephemeralLine() = %q", line)
+	}
+}
+
+func TestWiFiSignal(t *testing.T) {
+	wireless := `Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE
+ face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22
+ wlan0: 0000   54.  -56.  -256        0      0      0      0    103        0
+ wlan1: 0000    0     0     0         0      0      0      0      0        0
+`
+	got := parseProcWireless(wireless)
+	if want := map[string]int{"wlan0": -56}; !maps.Equal(got, want) {
+		t.Fatalf("parseProcWireless() = %v, want %v", got, want)
+	}
+
+	c := &Collector{}
+	c.recordSignals(map[string]int{"wlan0": -56, "wlan1": -70})
+	c.recordSignals(map[string]int{"wlan0": -80})
+	if _, ok := c.signalHistory["wlan1"]; ok {
+		t.Fatalf("history of an interface with no reading should be dropped")
+	}
+	history := c.signalHistory["wlan0"].Slice()
+	if !slices.Equal(history, []float64{-56, -80}) {
+		t.Fatalf("wlan0 history = %v, want [-56 -80]", history)
+	}
+
+	line := stripANSI(signalLine(NetworkStatus{Name: "wlan0", SignalDBm: -80, SignalHistory: history}))
+	if !strings.HasSuffix(line, "signal -80 dBm") {
+		t.Fatalf("signalLine() = %q, want the current level", line)
+	}
+	if signalLine(NetworkStatus{Name: "eth0"}) != "" {
+		t.Fatalf("wired interfaces should get no signal line")
+	}
+}
+
+func TestBridgePorts(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"br0/brif/eth0", "br0/brif/tap0", "br0/brif/tap1", "virbr0/brif", "eth0"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ports := bridgePortsIn(root)
+	if want := map[string]string{"eth0": "br0", "tap0": "br0", "tap1": "br0"}; !maps.Equal(ports, want) {
+		t.Fatalf("bridgePortsIn() = %v, want %v", ports, want)
+	}
+
+	// tap1 is the quietest of five rows but still comes along with br0.
+	c := NewCollector()
+	c.bridgePorts = ports
+	mb := uint64(1024 * 1024)
+	counters := func(step uint64) []net.IOCountersStat {
+		return []net.IOCountersStat{
+			{Name: "br0", BytesRecv: 10 * step * mb},
+			{Name: "eth0", BytesRecv: 9 * step * mb},
+			{Name: "wlan0", BytesRecv: 8 * step * mb},
+			{Name: "eth1", BytesRecv: 7 * step * mb},
+			{Name: "tap1", BytesRecv: step},
+		}
+	}
+	c.networkRates(counters(1), nil, nil, nil, time.Second)
+	rows := c.networkRates(counters(2), nil, nil, nil, 2*time.Second)
+	var names []string
+	for _, r := range rows {
+		names = append(names, r.Name+":"+r.Bridge)
+	}
+	if want := []string{"br0:", "eth0:br0", "wlan0:", "tap1:br0"}; !slices.Equal(names, want) {
+		t.Fatalf("rows = %v, want the top three plus the bridge's other port", names)
+	}
+
+	got := stripANSI(strings.Join(networkRows(rows, viewState{}), "\n"))
+	if !strings.Contains(got, "br0   ") || !strings.Contains(got, "\n├ eth0") || !strings.Contains(got, "\n└ tap1") || strings.Index(got, "tap1") > strings.Index(got, "wlan0") {
+		t.Errorf("rows should nest the ports under br0:\n%s", got)
+	}
+	got = stripANSI(strings.Join(networkRows(rows, viewState{collapseBridges: true}), "\n"))
+	if strings.Contains(got, "tap1") || !strings.Contains(got, "+2 ports") {
+		t.Errorf("collapsed rows should fold the ports into br0:\n%s", got)
+	}
+	got = stripANSI(strings.Join(networkRows(rows, viewState{groupByKind: true}), "\n"))
+	if !strings.Contains(got, "in br0") || strings.Contains(got, "└") {
+		t.Errorf("grouped rows should list ports flat, naming the bridge:\n%s", got)
+	}
+}
@@ -5,14 +5,13 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
-	"time"
 )
 
-func collectTopProcesses() []ProcessInfo {
+func collectTopProcesses(ctx context.Context) []ProcessInfo {
 	if runtime.GOOS != "darwin" {
 		return nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := cmdContext(ctx)
 	defer cancel()
 
 	// Use ps to get top processes by CPU.
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+)
+
+// Process states reported in MetricsSnapshot.ProcessStates.
+const (
+	procStateRunning  = "running"
+	procStateSleeping = "sleeping"
+	procStateDiskWait = "disk-wait"
+	procStateStopped  = "stopped"
+	procStateZombie   = "zombie"
+	procStateIdle     = "idle"
+)
+
+// collectProcessStates counts processes per state with a single ps call.
+// gopsutil's per-process Status spawns ps once per PID on macOS, which is far
+// too slow to run over every process.
+func collectProcessStates(ctx context.Context) (map[string]int, error) {
+	if runtime.GOOS == "windows" || !commandExists("ps") {
+		return nil, errors.New("process states unavailable")
+	}
+	ctx, cancel := cmdContext(ctx)
+	defer cancel()
+	out, err := runCmd(ctx, "ps", "-axo", "stat=")
+	if err != nil {
+		return nil, err
+	}
+	return parseProcessStates(out), nil
+}
+
+// parseProcessStates maps the first letter of each ps STAT field to a state.
+func parseProcessStates(out string) map[string]int {
+	states := make(map[string]int)
+	for line := range strings.Lines(out) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'R':
+			states[procStateRunning]++
+		case 'S':
+			states[procStateSleeping]++
+		case 'D', 'U':
+			states[procStateDiskWait]++
+		case 'T', 't':
+			states[procStateStopped]++
+		case 'Z':
+			states[procStateZombie]++
+		case 'I':
+			states[procStateIdle]++
+		}
+	}
+	return states
+}
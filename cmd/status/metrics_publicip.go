@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPublicIPURL = "https://api.ipify.org"
+	publicIPTimeout    = 5 * time.Second
+	publicIPMaxBody    = 256 // An address plus whitespace; anything longer is not one.
+)
+
+// PublicIPStatus is the external address reported by the --public-ip endpoint.
+type PublicIPStatus struct {
+	IP       string `json:"ip"` // Empty while unknown (offline, endpoint down).
+	Endpoint string `json:"endpoint"`
+	VPN      string `json:"vpn,omitempty"`   // VPN interface with an IPv4 address, if any.
+	Leak     bool   `json:"leak,omitempty"`  // VPN is up but the address matches the one seen without it.
+	Error    string `json:"error,omitempty"` // Last probe failure.
+}
+
+// publicIPSnapshot returns the latest probe result and starts a new probe in
+// the background once publicEvery has elapsed, like latencySnapshot.
+func (c *Collector) publicIPSnapshot(now time.Time, vpn string) *PublicIPStatus {
+	c.publicMu.Lock()
+	defer c.publicMu.Unlock()
+	if !c.publicBusy && (c.publicLastAt.IsZero() || now.Sub(c.publicLastAt) >= c.publicEvery) {
+		c.publicBusy = true
+		c.publicLastAt = now
+		go c.probePublicIP(vpn)
+	}
+	status := c.publicLast
+	status.Endpoint = c.publicIPURL
+	status.VPN = vpn
+	status.Leak = vpn != "" && status.IP != "" && status.IP == c.publicDirect
+	return &status
+}
+
+// probePublicIP queries the endpoint once. The address seen while no VPN is up
+// is remembered so a later match with a VPN up can be flagged as a leak.
+func (c *Collector) probePublicIP(vpn string) {
+	ctx, cancel := context.WithTimeout(context.Background(), publicIPTimeout)
+	defer cancel()
+	ip, err := fetchPublicIP(ctx, c.publicIPURL)
+
+	c.publicMu.Lock()
+	defer c.publicMu.Unlock()
+	c.publicBusy = false
+	if err != nil {
+		c.publicLast = PublicIPStatus{Error: err.Error()}
+		return
+	}
+	c.publicLast = PublicIPStatus{IP: ip}
+	if vpn == "" {
+		c.publicDirect = ip
+	}
+}
+
+// fetchPublicIP expects the endpoint to answer with the bare address as text,
+// which most "what is my IP" services do.
+func fetchPublicIP(ctx context.Context, endpoint string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, publicIPMaxBody))
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(body))
+	ip := net.ParseIP(text)
+	if ip == nil {
+		return "", fmt.Errorf("endpoint returned %q, not an IP address", text)
+	}
+	return ip.String(), nil
+}
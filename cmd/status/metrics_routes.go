@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+)
+
+const procNetRoute = "/proc/net/route"
+
+// routeJump is how far the route count must move between two samples to be
+// logged; connecting a VPN typically pushes or pulls a batch of routes.
+const routeJump = 5
+
+// collectRouteCount counts the routes in the main IPv4 table, from
+// `ip route show table main` on Linux (or /proc/net/route without iproute2)
+// and `netstat -rn -f inet` on macOS.
+func collectRouteCount(ctx context.Context) (int, error) {
+	switch runtime.GOOS {
+	case "linux":
+		if !commandExists("ip") {
+			data, err := os.ReadFile(procNetRoute)
+			if err != nil {
+				return 0, err
+			}
+			return countProcRoutes(string(data)), nil
+		}
+		ctx, cancel := cmdContext(ctx)
+		defer cancel()
+		out, err := runCmd(ctx, "ip", "route", "show", "table", "main")
+		if err != nil {
+			return 0, err
+		}
+		return countLinuxRoutes(out), nil
+	case "darwin":
+		if !commandExists("netstat") {
+			return 0, errors.New("netstat unavailable")
+		}
+		ctx, cancel := cmdContext(ctx)
+		defer cancel()
+		out, err := runCmd(ctx, "netstat", "-rn", "-f", "inet")
+		if err != nil {
+			return 0, err
+		}
+		return countDarwinRoutes(out), nil
+	}
+	return 0, errors.New("route count unsupported")
+}
+
+// countLinuxRoutes counts `ip route` output, one route per line.
+func countLinuxRoutes(out string) int {
+	n := 0
+	for line := range strings.Lines(out) {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// countProcRoutes counts /proc/net/route entries below its header line.
+func countProcRoutes(data string) int {
+	return max(countLinuxRoutes(data)-1, 0)
+}
+
+// countDarwinRoutes counts the rows under the "Destination" header of
+// `netstat -rn -f inet`, skipping the "Routing tables"/"Internet:" preamble.
+func countDarwinRoutes(out string) int {
+	n, inTable := 0, false
+	for line := range strings.Lines(out) {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "Destination":
+			inTable = true
+		case inTable:
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IF-MIB columns read for each --snmp-iface index. The 64-bit ifHC*
+// counters are preferred; agents without ifXTable only have the 32-bit ones,
+// which wrap in under a minute at gigabit speeds but counterDelta unwraps one
+// wrap per poll.
+const (
+	oidIfName        = "1.3.6.1.2.1.31.1.1.1.1"
+	oidIfHCInOctets  = "1.3.6.1.2.1.31.1.1.1.6"
+	oidIfHCOutOctets = "1.3.6.1.2.1.31.1.1.1.10"
+	oidIfInOctets    = "1.3.6.1.2.1.2.2.1.10"
+	oidIfOutOctets   = "1.3.6.1.2.1.2.2.1.16"
+)
+
+// snmpCounters is one interface's octet counters from one poll.
+type snmpCounters struct {
+	name   string
+	rx, tx uint64
+	hc     bool // 64-bit ifHC* counters rather than ifIn/OutOctets.
+}
+
+// snmpPoll is one background poll of the agent, stamped on the collector's
+// monotonic clock when the response arrived.
+type snmpPoll struct {
+	at       time.Duration
+	counters map[int]snmpCounters
+	err      error
+}
+
+// snmpPoller turns successive polls of one agent into interface rows, much as
+// networkRates does for local counters.
+type snmpPoller struct {
+	client  *snmpClient
+	host    string // As given to --snmp, for row names and events.
+	indexes []int  // ifIndex values from --snmp-iface.
+
+	window  rateWindow
+	seen    time.Duration // Poll the rows were last computed from.
+	prev    map[int]snmpCounters
+	rx, tx  map[int]*RingBuffer
+	rows    []NetworkStatus
+	failing bool
+}
+
+func newSNMPPoller(client *snmpClient, host string, indexes []int) *snmpPoller {
+	return &snmpPoller{
+		client:  client,
+		host:    host,
+		indexes: indexes,
+		prev:    make(map[int]snmpCounters),
+		rx:      make(map[int]*RingBuffer),
+		tx:      make(map[int]*RingBuffer),
+	}
+}
+
+// fetch reads every configured interface in one GET.
+func (p *snmpPoller) fetch() (map[int]snmpCounters, error) {
+	var oids []string
+	for _, idx := range p.indexes {
+		suffix := "." + strconv.Itoa(idx)
+		oids = append(oids, oidIfName+suffix, oidIfHCInOctets+suffix, oidIfHCOutOctets+suffix, oidIfInOctets+suffix, oidIfOutOctets+suffix)
+	}
+	values, err := p.client.get(oids)
+	if err != nil {
+		return nil, err
+	}
+	counters := make(map[int]snmpCounters, len(p.indexes))
+	for _, idx := range p.indexes {
+		suffix := "." + strconv.Itoa(idx)
+		c := snmpCounters{name: values[oidIfName+suffix].text}
+		if in, out := values[oidIfHCInOctets+suffix], values[oidIfHCOutOctets+suffix]; in.found && out.found {
+			c.rx, c.tx, c.hc = in.num, out.num, true
+		} else if in, out := values[oidIfInOctets+suffix], values[oidIfOutOctets+suffix]; in.found && out.found {
+			c.rx, c.tx = in.num, out.num
+		} else {
+			return nil, fmt.Errorf("snmp %s: no interface with index %d", p.host, idx)
+		}
+		counters[idx] = c
+	}
+	return counters, nil
+}
+
+// snmpSnapshot returns the remote interface rows, polling the agent in the
+// background so a slow or silent one never holds up the refresh. Rows are
+// recomputed only when a new poll has landed; a failed poll drops them until
+// the agent answers again.
+func (c *Collector) snmpSnapshot(now time.Time) []NetworkStatus {
+	p := c.snmp
+	res, ok := c.snmpProbe.poll(now, func() snmpPoll {
+		counters, err := p.fetch()
+		return snmpPoll{at: c.clock(), counters: counters, err: err}
+	})
+	if !ok || res.at == p.seen {
+		return p.rows
+	}
+	p.seen = res.at
+	if res.err != nil {
+		if !p.failing {
+			c.events.add(severityWarn, categoryNetwork, res.err.Error())
+		}
+		p.failing, p.rows = true, nil
+		return nil
+	}
+	if p.failing {
+		c.events.add(severityInfo, categoryNetwork, "snmp "+p.host+" answering again")
+		p.failing = false
+	}
+	p.rows = p.rates(res, c.rxHistoryBuf.cap)
+	return p.rows
+}
+
+// rates computes per-interface rates against the previous poll. The first
+// poll, and an interface whose counters reset (an agent reboot) or switched
+// width, only set a baseline.
+func (p *snmpPoller) rates(res snmpPoll, historySize int) []NetworkStatus {
+	elapsed, ok := p.window.advance(res.at)
+	var rows []NetworkStatus
+	for _, idx := range p.indexes {
+		cur := res.counters[idx]
+		prev, known := p.prev[idx]
+		p.prev[idx] = cur
+		if !ok || !known || prev.hc != cur.hc {
+			continue
+		}
+		rxDelta, rxOK := counterDelta(prev.rx, cur.rx)
+		txDelta, txOK := counterDelta(prev.tx, cur.tx)
+		// A 64-bit counter never wraps in practice; going backwards is a reset.
+		if !rxOK || !txOK || cur.hc && (cur.rx < prev.rx || cur.tx < prev.tx) {
+			continue
+		}
+		rx := float64(rxDelta) / 1024.0 / 1024.0 / elapsed
+		tx := float64(txDelta) / 1024.0 / 1024.0 / elapsed
+		if p.rx[idx] == nil {
+			p.rx[idx], p.tx[idx] = NewRingBuffer(historySize), NewRingBuffer(historySize)
+		}
+		p.rx[idx].Add(rx)
+		p.tx[idx].Add(tx)
+		name := cur.name
+		if name == "" {
+			name = "if" + strconv.Itoa(idx)
+		}
+		rows = append(rows, NetworkStatus{
+			Name:      name + "@" + p.host,
+			RxRateMBs: rx,
+			TxRateMBs: tx,
+			IP:        p.host,
+			Kind:      ifaceKindRemote,
+			Untotaled: true, // Another device's traffic, not this host's.
+			RxBytes:   cur.rx,
+			TxBytes:   cur.tx,
+			RxHistory: p.rx[idx].Slice(),
+			TxHistory: p.tx[idx].Slice(),
+		})
+	}
+	return rows
+}
+
+// snmpAddr adds the standard port to a --snmp host given without one.
+func snmpAddr(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), snmpDefaultPort)
+}
+
+// parseIfIndexes reads --snmp-iface, e.g. "2,3".
+func parseIfIndexes(value string) ([]int, error) {
+	var indexes []int
+	for _, item := range splitList(value) {
+		idx, err := strconv.Atoi(item)
+		if err != nil || idx < 1 {
+			return nil, fmt.Errorf("--snmp-iface %q: want positive ifIndex numbers", item)
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, nil
+}
+
+func formatIfIndexes(indexes []int) string {
+	parts := make([]string, len(indexes))
+	for i, idx := range indexes {
+		parts[i] = strconv.Itoa(idx)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const procNetSNMP = "/proc/net/snmp"
+
+// TCPStatus reports host-wide TCP retransmissions, a sign of a lossy path
+// that interface drop counters miss. Linux only.
+type TCPStatus struct {
+	RetransRate    float64 `json:"retrans_rate"`    // Segments retransmitted per second.
+	RetransPercent float64 `json:"retrans_percent"` // Share of segments sent that were retransmits.
+}
+
+// tcpCounters are the cumulative Tcp: counters the rates come from.
+type tcpCounters struct {
+	outSegs, retransSegs uint64
+}
+
+// readTCPCounters pulls OutSegs and RetransSegs from the Tcp: group of
+// /proc/net/snmp.
+func readTCPCounters(r io.Reader) (tcpCounters, error) {
+	values, err := readProcGroup(r, procNetSNMP, "Tcp:", "OutSegs", "RetransSegs")
+	if err != nil {
+		return tcpCounters{}, err
+	}
+	return tcpCounters{outSegs: values[0], retransSegs: values[1]}, nil
+}
+
+// readProcGroup reads the named counters from the header/value line pair
+// that /proc/net/snmp and /proc/net/netstat print for each group ("Tcp:",
+// "IpExt:"), in the order asked for.
+func readProcGroup(r io.Reader, file, group string, names ...string) ([]uint64, error) {
+	var header []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != group {
+			continue
+		}
+		if header == nil {
+			header = fields
+			continue
+		}
+		values := make([]uint64, len(names))
+		for i, name := range names {
+			col := slices.Index(header, name)
+			if col < 0 || col >= len(fields) {
+				return nil, fmt.Errorf("%s: no %s %s", file, group, name)
+			}
+			v, err := strconv.ParseUint(fields[col], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s %q: %w", file, name, fields[col], err)
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%s: no %s section", file, group)
+}
+
+// counterDelta is cur-prev for a kernel counter that may be an unsigned long
+// of either width. A 32-bit counter that wrapped is unwrapped; any other drop
+// is a reset (namespace change, reboot under a frozen clock) and yields ok=false.
+func counterDelta(prev, cur uint64) (delta uint64, ok bool) {
+	if cur >= prev {
+		return cur - prev, true
+	}
+	if prev <= 1<<32-1 && cur <= 1<<32-1 {
+		return cur + (1<<32 - prev), true
+	}
+	return 0, false
+}
+
+// collectTCP samples /proc/net/snmp and returns rates against the previous
+// sample; nil off Linux, while the file is unreadable, and on the first or a
+// reset sample.
+func (c *Collector) collectTCP(tick time.Duration) *TCPStatus {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	f, err := os.Open(procNetSNMP)
+	if err != nil {
+		c.tcpWindow = rateWindow{}
+		return nil
+	}
+	cur, err := readTCPCounters(f)
+	f.Close()
+	if err != nil {
+		c.tcpWindow = rateWindow{}
+		return nil
+	}
+	prev := c.prevTCP
+	c.prevTCP = cur
+	return tcpRates(prev, cur, &c.tcpWindow, tick)
+}
+
+func tcpRates(prev, cur tcpCounters, window *rateWindow, tick time.Duration) *TCPStatus {
+	elapsed, ok := window.advance(tick)
+	if !ok {
+		return nil
+	}
+	retrans, okRetrans := counterDelta(prev.retransSegs, cur.retransSegs)
+	out, okOut := counterDelta(prev.outSegs, cur.outSegs)
+	if !okRetrans || !okOut {
+		return nil
+	}
+	status := &TCPStatus{RetransRate: float64(retrans) / elapsed}
+	if out > 0 {
+		status.RetransPercent = min(float64(retrans)/float64(out)*100, 100)
+	}
+	return status
+}
@@ -1,8 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
 )
 
 func TestNewRingBuffer(t *testing.T) {
@@ -179,3 +193,523 @@ func TestRingBuffer_NegativeAndZeroValues(t *testing.T) {
 		t.Errorf("Slice() with negative/zero values = %v, want %v", got, want)
 	}
 }
+
+func TestDiskBusy(t *testing.T) {
+	prev := map[string]disk.IOCountersStat{
+		"sda":   {IoTime: 1000},
+		"sdb":   {IoTime: 5000},
+		"disk0": {},               // No busy accounting (macOS).
+		"nvme0": {IoTime: 900000}, // Counter reset below.
+	}
+	cur := map[string]disk.IOCountersStat{
+		"sda":   {IoTime: 1500},
+		"sdb":   {IoTime: 9000},
+		"disk0": {},
+		"nvme0": {IoTime: 20},
+		"sdc":   {IoTime: 100}, // New device, no baseline yet.
+	}
+
+	got := diskBusy(prev, cur, 2)
+	if len(got) != 2 {
+		t.Fatalf("diskBusy() = %+v, want sda and sdb only", got)
+	}
+	if got[0].Name != "sdb" || got[0].BusyPercent != 100 {
+		t.Errorf("busiest = %+v, want sdb capped at 100%%", got[0])
+	}
+	if got[1].Name != "sda" || got[1].BusyPercent != 25 {
+		t.Errorf("second = %+v, want sda at 25%%", got[1])
+	}
+}
+
+func TestTopProcessSamples(t *testing.T) {
+	samples := []MemProcessInfo{
+		{PID: 1, RSS: 100, CPU: 0},
+		{PID: 2, RSS: 900, CPU: 1},
+		{PID: 3, RSS: 50, CPU: 80},
+		{PID: 4, RSS: 500, CPU: 2},
+		{PID: 5, RSS: 10, CPU: 0},
+	}
+
+	top := topProcessSamples(samples, 2)
+	var pids []int32
+	for _, s := range top {
+		pids = append(pids, s.PID)
+	}
+	// Top two by RSS (2, 4) plus top two by CPU (3, 4), ordered by RSS.
+	want := []int32{2, 4, 3}
+	if !slices.Equal(pids, want) {
+		t.Fatalf("topProcessSamples() pids = %v, want %v", pids, want)
+	}
+}
+
+func TestThrottledReusesCachedValue(t *testing.T) {
+	calls := 0
+	th := throttled[int]{every: 5 * time.Second}
+	fetch := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	start := time.Now()
+	for _, offset := range []time.Duration{0, time.Second, 4 * time.Second} {
+		if v, _ := th.get(start.Add(offset), fetch); v != 1 {
+			t.Fatalf("get(+%v) = %d, want cached 1", offset, v)
+		}
+	}
+	if v, _ := th.get(start.Add(5*time.Second), fetch); v != 2 {
+		t.Fatalf("get after interval = %d, want refreshed 2", v)
+	}
+
+	// A failed fetch keeps the previous value.
+	failing := func() (int, error) { return 0, errors.New("boom") }
+	if v, err := th.get(start.Add(10*time.Second), failing); err == nil || v != 2 {
+		t.Fatalf("get with failing fetch = %d, %v; want 2 and an error", v, err)
+	}
+}
+
+func TestCmdContextUsesConfiguredTimeout(t *testing.T) {
+	ctx, cancel := cmdContext(withCmdTimeout(context.Background(), 2*time.Second))
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) <= defaultCmdTimeout {
+		t.Fatalf("cmdContext() deadline in %v, want about 2s", time.Until(deadline))
+	}
+
+	ctx, cancel = cmdContext(context.Background())
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > defaultCmdTimeout {
+		t.Fatalf("cmdContext() without a budget should fall back to %v", defaultCmdTimeout)
+	}
+}
+
+func TestParseProcessStates(t *testing.T) {
+	out := "Ss\nR+\nS\nZ\nZ+\nD\nT\nI<\n\n"
+	got := parseProcessStates(out)
+	want := map[string]int{
+		procStateSleeping: 2,
+		procStateRunning:  1,
+		procStateZombie:   2,
+		procStateDiskWait: 1,
+		procStateStopped:  1,
+		procStateIdle:     1,
+	}
+	for state, n := range want {
+		if got[state] != n {
+			t.Errorf("parseProcessStates()[%s] = %d, want %d", state, got[state], n)
+		}
+	}
+}
+
+func TestRateWindowIgnoresWallClockSteps(t *testing.T) {
+	// The wall clock is stepped back an hour (an NTP correction) between two
+	// samples taken two seconds apart. Wall times carry no monotonic reading
+	// once serialized, so subtracting them would give -3598s.
+	wall := []time.Time{
+		time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 1, 11, 0, 2, 0, time.UTC),
+	}
+	if d := wall[1].Sub(wall[0]); d >= 0 {
+		t.Fatalf("test setup: wall delta = %v, want negative", d)
+	}
+	ticks := []time.Duration{5 * time.Second, 7 * time.Second}
+	i := 0
+	c := &Collector{clock: func() time.Duration { return ticks[i] }}
+
+	if _, ok := c.netWindow.advance(c.clock()); ok {
+		t.Fatalf("first sample should only set the baseline")
+	}
+	i++
+	elapsed, ok := c.netWindow.advance(c.clock())
+	if !ok || elapsed != 2 {
+		t.Fatalf("advance() = %v, %v; want 2s, true", elapsed, ok)
+	}
+
+	// A clock that fails to advance yields no window rather than a made-up one,
+	// and the next real gap is measured from the re-baselined sample.
+	if elapsed, ok := c.netWindow.advance(c.clock()); ok {
+		t.Fatalf("advance() on a stalled clock = %v, true; want no rate", elapsed)
+	}
+	if elapsed, ok := c.netWindow.advance(c.clock() + time.Second); !ok || elapsed != 1 {
+		t.Fatalf("advance() after a stall = %v, %v; want 1s, true", elapsed, ok)
+	}
+}
+
+func TestRateWindowSnapsToNominal(t *testing.T) {
+	w := rateWindow{nominal: time.Second}
+	w.advance(0)
+	tests := []struct {
+		gap  time.Duration
+		want float64
+	}{
+		{1040 * time.Millisecond, 1},   // Jitter: snapped.
+		{930 * time.Millisecond, 1},    // Early tick: snapped.
+		{300 * time.Millisecond, 0.3},  // Forced refresh: measured.
+		{30 * time.Second, 30},         // Sleep gap: measured.
+		{1200 * time.Millisecond, 1.2}, // Past the tolerance: measured.
+	}
+	tick := time.Duration(0)
+	for _, tt := range tests {
+		tick += tt.gap
+		if got, _ := w.advance(tick); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("advance(+%v) = %v, want %v", tt.gap, got, tt.want)
+		}
+	}
+}
+
+func TestMonoClockAdvances(t *testing.T) {
+	clock := newMonoClock()
+	a := clock()
+	time.Sleep(time.Millisecond)
+	if b := clock(); b <= a {
+		t.Fatalf("monoClock went from %v to %v", a, b)
+	}
+}
+
+func TestFDGrowing(t *testing.T) {
+	tests := []struct {
+		history []int32
+		limit   uint64
+		want    bool
+	}{
+		{[]int32{600, 700, 800, 900}, 1024, true},
+		{[]int32{600, 700, 700, 900}, 1024, false}, // Plateau breaks the trend.
+		{[]int32{10, 20, 30, 40}, 1024, false},     // Rising but far from the limit.
+		{[]int32{700, 800, 900}, 1024, false},      // Not enough walks yet.
+		{[]int32{600, 700, 800, 900}, 0, false},    // Limit unknown.
+	}
+	for _, tt := range tests {
+		if got := fdGrowing(tt.history, tt.limit); got != tt.want {
+			t.Errorf("fdGrowing(%v, %d) = %v, want %v", tt.history, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestCollectContainer(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mo") // t.TempDir can exceed the unix socket path limit.
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+
+	var state atomic.Value
+	state.Store("running")
+	var sample atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case state.Load() == containerGone:
+			http.NotFound(w, r)
+		case r.URL.Path == "/containers/web/json":
+			fmt.Fprintf(w, `{"Id":"0123456789abcdef","Name":"/web","State":{"Status":%q,"Running":%v}}`, state.Load(), state.Load() == "running")
+		case r.URL.Path == "/containers/web/stats":
+			n := sample.Add(1)
+			fmt.Fprintf(w, `{"cpu_stats":{"cpu_usage":{"total_usage":%d},"system_cpu_usage":%d,"online_cpus":4},
+				"memory_stats":{"usage":%d,"limit":%d,"stats":{"inactive_file":%d}},
+				"networks":{"eth0":{"rx_bytes":%d,"tx_bytes":%d}}}`,
+				n*1e8, n*4e9, 300<<20, 2<<30, 44<<20, n<<20, n<<19)
+		default:
+			http.Error(w, "unexpected "+r.URL.Path, http.StatusBadRequest)
+		}
+	}))
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	c := &Collector{containerName: "web", docker: newDockerClientAt(socket), events: &eventRing{}}
+	first := c.collectContainer(0)
+	if first.Error != "" || first.State != "running" || first.ID != "0123456789ab" || first.CPUPercent != 0 {
+		t.Fatalf("first sample = %+v", first)
+	}
+	got := c.collectContainer(time.Second)
+	// 1e8 of 4e9 host nanoseconds on 4 CPUs is 10% of one CPU.
+	want := ContainerStatus{Name: "web", ID: "0123456789ab", State: "running", CPUPercent: 10,
+		MemUsed: 256 << 20, MemLimit: 2 << 30, RxRateMBs: 1, TxRateMBs: 0.5}
+	if *got != want {
+		t.Fatalf("second sample = %+v, want %+v", *got, want)
+	}
+
+	state.Store("exited")
+	if got := c.collectContainer(2 * time.Second); got.State != "exited" || c.prevContainer != nil {
+		t.Fatalf("stopped container = %+v", got)
+	}
+	state.Store(containerGone)
+	if got := c.collectContainer(3 * time.Second); got.State != containerGone || got.Error != "" {
+		t.Fatalf("removed container = %+v", got)
+	}
+	var msgs []string
+	for _, e := range c.events.recent() {
+		msgs = append(msgs, e.Message)
+	}
+	if want := []string{"container web exited", "container web removed"}; !slices.Equal(msgs, want) {
+		t.Errorf("events = %q, want %q", msgs, want)
+	}
+
+	missing := &Collector{containerName: "web", docker: newDockerClientAt(filepath.Join(dir, "none.sock")), events: &eventRing{}}
+	if got := missing.collectContainer(0); !strings.Contains(got.Error, "socket not found") {
+		t.Errorf("missing socket error = %q", got.Error)
+	}
+}
+
+func TestCollectUnit(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "system.slice", "web.service")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(usageUsec int) {
+		for name, data := range map[string]string{
+			"cpu.stat":       fmt.Sprintf("usage_usec %d\nuser_usec 0\n", usageUsec),
+			"memory.current": "314572800\n",
+			"memory.stat":    "anon 1\ninactive_file 46137344\n",
+			"memory.max":     "1073741824\n",
+			"pids.current":   "7\n",
+		} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	props := "LoadState=loaded\nActiveState=active\nSubState=running\nControlGroup=/system.slice/web.service\n"
+	real := unitProps
+	t.Cleanup(func() { unitProps = real })
+	unitProps = func(context.Context, string) (map[string]string, error) { return parseSystemctlShow(props), nil }
+
+	c := &Collector{unitName: "web.service", cgroupRoot: root, events: &eventRing{}}
+	write(1_000_000)
+	c.collectUnit(context.Background(), 0)
+	write(1_250_000)
+	got := c.collectUnit(context.Background(), time.Second)
+	want := UnitStatus{Name: "web.service", State: "active/running", CGroup: "/system.slice/web.service", Tasks: 7,
+		CPUPercent: 25, MemUsed: 256 << 20, MemLimit: 1 << 30}
+	if *got != want {
+		t.Fatalf("collectUnit() = %+v, want %+v", *got, want)
+	}
+
+	props = "LoadState=loaded\nActiveState=inactive\nSubState=dead\nControlGroup=\n"
+	if got := c.collectUnit(context.Background(), 2*time.Second); got.State != "inactive/dead" || c.prevUnit != nil {
+		t.Errorf("stopped unit = %+v, prev %v", got, c.prevUnit)
+	}
+	props = "LoadState=not-found\nActiveState=inactive\nSubState=dead\n"
+	if got := c.collectUnit(context.Background(), 3*time.Second); got.State != unitGone || got.Error != "" {
+		t.Errorf("missing unit = %+v", got)
+	}
+	var messages []string
+	for _, ev := range c.events.recent() {
+		messages = append(messages, ev.Message)
+	}
+	if want := []string{"unit web.service inactive/dead", "unit web.service not found"}; !slices.Equal(messages, want) {
+		t.Errorf("events = %q, want %q", messages, want)
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	a := adaptiveInterval{min: time.Second, max: 5 * time.Second}
+	idle := MetricsSnapshot{CPU: CPUStatus{Usage: 3}, Network: []NetworkStatus{{RxRateMBs: 0.01}}}
+	busy := MetricsSnapshot{CPU: CPUStatus{Usage: 3}, Network: []NetworkStatus{{RxRateMBs: 2}}}
+
+	var got []time.Duration
+	cur := a.min
+	for _, s := range []MetricsSnapshot{idle, idle, idle, idle, busy, idle} {
+		cur = a.next(cur, s)
+		got = append(got, cur)
+	}
+	// Doubles while idle up to max, snaps back to min on activity.
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, time.Second, 2 * time.Second}
+	if !slices.Equal(got, want) {
+		t.Fatalf("intervals = %v, want %v", got, want)
+	}
+	if sampleIdle(MetricsSnapshot{CPU: CPUStatus{Usage: 40}}) {
+		t.Error("a busy CPU is not idle")
+	}
+	if sampleIdle(MetricsSnapshot{DiskIO: DiskIOStatus{WriteRate: 20}}) {
+		t.Error("disk writes are not idle")
+	}
+}
+
+func TestIdleDimmer(t *testing.T) {
+	start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	idle := MetricsSnapshot{CPU: CPUStatus{Usage: 2}}
+	busy := MetricsSnapshot{CPU: CPUStatus{Usage: 2}, Network: []NetworkStatus{{Name: "en0", RxRateMBs: 3}}}
+	d := &idleDimmer{after: time.Minute}
+	steps := []struct {
+		at      time.Duration
+		s       MetricsSnapshot
+		changed bool
+		dimmed  bool
+	}{
+		{0, idle, false, false},
+		{59 * time.Second, idle, false, false},
+		{time.Minute, idle, true, true},
+		{2 * time.Minute, MetricsSnapshot{NetworkWarmup: true, CPU: CPUStatus{Usage: 90}}, false, true}, // No rates to judge.
+		{3 * time.Minute, busy, true, false},
+		{4 * time.Minute, idle, false, false},
+		{5 * time.Minute, idle, true, true},
+	}
+	for i, step := range steps {
+		if changed := d.observe(start.Add(step.at), step.s); changed != step.changed || d.dimmed() != step.dimmed {
+			t.Fatalf("step %d: changed %t dimmed %t, want %t %t", i, changed, d.dimmed(), step.changed, step.dimmed)
+		}
+	}
+	d.wake(start.Add(5*time.Minute + time.Second))
+	if d.observe(start.Add(6*time.Minute), idle) || d.dimmed() {
+		t.Errorf("a key press should restart the idle stretch")
+	}
+	var off *idleDimmer
+	if off.observe(start, idle) || off.dimmed() {
+		t.Errorf("a nil dimmer should never dim")
+	}
+}
+
+func TestParseFirewall(t *testing.T) {
+	ufw := `Status: active
+
+To                         Action      From
+--                         ------      ----
+22/tcp                     ALLOW       Anywhere
+80,443/tcp                 ALLOW       Anywhere
+22/tcp (v6)                ALLOW       Anywhere (v6)
+`
+	if got, err := parseUfwStatus(ufw); err != nil || got != (FirewallStatus{Tool: "ufw", State: firewallEnabled, Rules: 3}) {
+		t.Errorf("parseUfwStatus(active) = %+v, %v", got, err)
+	}
+	if got, err := parseUfwStatus("Status: inactive\n"); err != nil || got.State != firewallDisabled || got.Rules != 0 {
+		t.Errorf("parseUfwStatus(inactive) = %+v, %v", got, err)
+	}
+	if _, err := parseUfwStatus("ERROR: You need to be root to run this script\n"); err == nil {
+		t.Errorf("parseUfwStatus(not root) should fail")
+	}
+
+	nft := `table inet filter {
+	set blocked {
+		type ipv4_addr
+		elements = { 10.0.0.1 }
+	}
+	chain input {
+		type filter hook input priority filter; policy drop;
+		ct state established,related accept
+		iif "lo" accept
+		tcp dport 22 accept
+	}
+	chain output {
+		type filter hook output priority filter; policy accept;
+	}
+}
+`
+	if got := parseNftRuleset(nft); got != (FirewallStatus{Tool: "nftables", State: firewallEnabled, Rules: 3}) {
+		t.Errorf("parseNftRuleset() = %+v", got)
+	}
+	if got := parseNftRuleset(""); got.State != firewallDisabled || got.Rules != 0 {
+		t.Errorf("parseNftRuleset(empty) = %+v", got)
+	}
+
+	for out, want := range map[string]string{
+		"Firewall is enabled. (State = 1)\n":                             firewallEnabled,
+		"Firewall is blocking all non-essential incoming connections.\n": firewallEnabled,
+		"Firewall is disabled. (State = 0)\n":                            firewallDisabled,
+		"":                                                               firewallUnknown,
+	} {
+		if got := parseSocketfilterfwState(out); got != want {
+			t.Errorf("parseSocketfilterfwState(%q) = %q, want %q", out, got, want)
+		}
+	}
+	if got := parseSocketfilterfwApps("ALF: total number of apps = 7 \n\n1 : /Applications/Zoom.app\n"); got != 7 {
+		t.Errorf("parseSocketfilterfwApps() = %d, want 7", got)
+	}
+}
+
+func TestCadenceWatch(t *testing.T) {
+	w := &cadenceWatch{margin: 0.25}
+	at := time.Unix(1700000000, 0)
+	step := func(gap time.Duration) bool {
+		at = at.Add(gap)
+		return w.observe(at, time.Second)
+	}
+	step(0) // The first sample only starts the clock.
+	// One slow cycle, and a run cut short, are not falling behind.
+	for _, gap := range []time.Duration{3 * time.Second, time.Second, 1400 * time.Millisecond, 1400 * time.Millisecond, time.Second} {
+		if step(gap) || w.String() != "" {
+			t.Fatalf("after a %s gap: behind with %q", gap, w.String())
+		}
+	}
+	for i := range cadenceBehindCycles {
+		if changed := step(1400 * time.Millisecond); changed != (i == cadenceBehindCycles-1) {
+			t.Fatalf("late sample %d: changed = %v", i+1, changed)
+		}
+	}
+	if got, want := w.String(), "sampling behind (actual 1.4s vs target 1s)"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+	if !step(1100*time.Millisecond) || w.String() != "" {
+		t.Fatalf("a gap within the margin should catch up, got %q", w.String())
+	}
+
+	w.reset()
+	if w.observe(at.Add(time.Hour), time.Second) {
+		t.Fatal("a reset watch should not count the pause as a late sample")
+	}
+	off := &cadenceWatch{}
+	for range 10 {
+		at = at.Add(5 * time.Second)
+		off.observe(at, time.Second)
+	}
+	if off.String() != "" {
+		t.Fatalf("--behind-margin 0 still warned: %q", off.String())
+	}
+}
+
+func TestCollectorPrime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("samples the host")
+	}
+	c := NewCollector()
+	c.prime(50 * time.Millisecond)
+	snap, _ := c.Collect()
+	if snap.NetworkWarmup {
+		t.Error("the sample after prime should already have rates")
+	}
+	// Only the real sample lands in the histories.
+	if len(snap.CPU.History) != 1 || len(snap.Memory.History) != 1 || len(snap.NetworkHistory.RxHistory) != 1 {
+		t.Errorf("prime left history behind: cpu %v, mem %v, rx %v", snap.CPU.History, snap.Memory.History, snap.NetworkHistory.RxHistory)
+	}
+}
+
+func TestThermalPressure(t *testing.T) {
+	powermetrics := "*** Sampled system activity ***\n\n**** Thermal pressure ****\n\nCurrent pressure level: Heavy\n"
+	if got := parsePowermetricsThermal(powermetrics); got != thermalHeavy {
+		t.Errorf("powermetrics level = %q, want heavy", got)
+	}
+	if got := parsePmsetSpeedLimit("CPU_Scheduler_Limit \t= 100\nCPU_Available_CPUs \t= 8\nCPU_Speed_Limit \t= 70\n"); got != 70 {
+		t.Errorf("pmset speed limit = %d, want 70", got)
+	}
+	if got := parsePmsetSpeedLimit("Note: No thermal warning level has been recorded\nNote: No CPU power status has been recorded\n"); got != 0 {
+		t.Errorf("pmset without a limit = %d, want 0", got)
+	}
+	for _, tc := range []struct {
+		p    thermalPressure
+		want bool
+	}{
+		{thermalPressure{level: thermalModerate}, false},
+		{thermalPressure{level: thermalTrapping}, true},
+		{thermalPressure{level: thermalUnknown, speedLimit: 100}, false},
+		{thermalPressure{level: thermalUnknown, speedLimit: 80}, true},
+	} {
+		if got := tc.p.throttled(); got != tc.want {
+			t.Errorf("%+v throttled = %v, want %v", tc.p, got, tc.want)
+		}
+	}
+
+	c := &Collector{events: &eventRing{}}
+	c.watchThermal(ThermalStatus{ThermalPressure: thermalNominal})
+	c.watchThermal(ThermalStatus{ThermalPressure: thermalHeavy, Throttled: true})
+	c.watchThermal(ThermalStatus{ThermalPressure: thermalHeavy, Throttled: true})
+	c.watchThermal(ThermalStatus{ThermalPressure: thermalNominal})
+	got := c.events.recent()
+	if len(got) != 2 || got[0].Message != "CPU thermally throttled, pressure heavy" || got[0].Severity != severityWarn {
+		t.Errorf("events = %+v, want throttling start and end", got)
+	}
+
+}
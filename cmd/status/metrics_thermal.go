@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Thermal pressure levels as powermetrics names them, lowercased.
+const (
+	thermalNominal  = "nominal"
+	thermalModerate = "moderate"
+	thermalHeavy    = "heavy"
+	thermalTrapping = "trapping"
+	thermalSleeping = "sleeping"
+	thermalUnknown  = "unknown"
+)
+
+// thermalPressure is one reading of how hard macOS is holding the CPU back.
+type thermalPressure struct {
+	level      string // One of the thermal* levels.
+	speedLimit int    // CPU_Speed_Limit percent from pmset; 0 = not reported.
+}
+
+// throttled reports whether the reading means the CPU is being slowed:
+// heavy pressure or worse, or a speed limit below 100%.
+func (p thermalPressure) throttled() bool {
+	switch p.level {
+	case thermalHeavy, thermalTrapping, thermalSleeping:
+		return true
+	}
+	return p.speedLimit > 0 && p.speedLimit < 100
+}
+
+// collectThermalPressure reads the thermal pressure level from powermetrics
+// when running as root, and otherwise falls back to `pmset -g therm`, whose
+// CPU speed limit Intel Macs report but Apple Silicon mostly leaves out;
+// the level is then "unknown". macOS only.
+func collectThermalPressure(ctx context.Context) (thermalPressure, error) {
+	if runtime.GOOS != "darwin" {
+		return thermalPressure{}, errors.New("thermal pressure unsupported")
+	}
+	if os.Geteuid() == 0 && commandExists("powermetrics") {
+		ctx, cancel := context.WithTimeout(ctx, powermetricsTimeout)
+		defer cancel()
+		if out, err := runCmd(ctx, "powermetrics", "--samplers", "thermal", "-i", "200", "-n", "1"); err == nil {
+			if level := parsePowermetricsThermal(out); level != "" {
+				return thermalPressure{level: level}, nil
+			}
+		}
+	}
+	p := thermalPressure{level: thermalUnknown}
+	if !commandExists("pmset") {
+		return p, nil
+	}
+	ctx, cancel := cmdContext(ctx)
+	defer cancel()
+	out, err := runCmd(ctx, "pmset", "-g", "therm")
+	if err != nil {
+		return p, nil
+	}
+	p.speedLimit = parsePmsetSpeedLimit(out)
+	return p, nil
+}
+
+// parsePowermetricsThermal reads "Current pressure level: Nominal".
+func parsePowermetricsThermal(out string) string {
+	for line := range strings.Lines(out) {
+		if _, level, ok := strings.Cut(line, "Current pressure level:"); ok {
+			return strings.ToLower(strings.TrimSpace(level))
+		}
+	}
+	return ""
+}
+
+// parsePmsetSpeedLimit reads "CPU_Speed_Limit 	= 80" from `pmset -g therm`;
+// Macs that have recorded no limit print a "Note: No CPU power status" line
+// instead, which leaves it 0.
+func parsePmsetSpeedLimit(out string) int {
+	for line := range strings.Lines(out) {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "CPU_Speed_Limit" {
+			continue
+		}
+		if limit, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && limit > 0 {
+			return limit
+		}
+	}
+	return 0
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCgroupRoot = "/sys/fs/cgroup"
+	unitGone          = "not-found" // systemd's LoadState for a unit it does not know.
+)
+
+// UnitStatus is the focused view of the --unit systemd service: the totals
+// of every process in its cgroup.
+type UnitStatus struct {
+	Name       string  `json:"name"`
+	State      string  `json:"state,omitempty"`  // ActiveState/SubState, e.g. active/running, or not-found.
+	CGroup     string  `json:"cgroup,omitempty"` // ControlGroup, e.g. /system.slice/nginx.service.
+	Tasks      uint64  `json:"tasks,omitempty"`
+	CPUPercent float64 `json:"cpu_percent"` // Share of one CPU, like --container.
+	MemUsed    uint64  `json:"mem_used"`    // Excludes reclaimable page cache.
+	MemLimit   uint64  `json:"mem_limit"`   // MemoryMax; 0 = unlimited.
+	RxRateMBs  float64 `json:"rx_rate_mbs"`
+	TxRateMBs  float64 `json:"tx_rate_mbs"`
+	NetCounted bool    `json:"net_counted"`     // An nftables counter matches the cgroup; without one the rates are unknown.
+	Error      string  `json:"error,omitempty"` // systemctl or the cgroup could not be read.
+}
+
+// running reports whether the unit has processes to measure.
+func (u UnitStatus) running() bool {
+	return strings.HasPrefix(u.State, "active/") || strings.HasPrefix(u.State, "reloading/")
+}
+
+// unitSample is what one --unit sample read, for rates against the next.
+type unitSample struct {
+	cpuUsec uint64
+	net     cgroupCounters
+	counted bool
+}
+
+// parseSystemctlShow reads `systemctl show -p A -p B` output, one
+// key=value per line.
+func parseSystemctlShow(out string) map[string]string {
+	props := make(map[string]string)
+	for line := range strings.Lines(out) {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// unitProps asks systemd for the unit's state and cgroup; swapped for a
+// fake in tests.
+var unitProps = readUnitProps
+
+func readUnitProps(ctx context.Context, unit string) (map[string]string, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("--unit needs Linux with systemd")
+	}
+	if !commandExists("systemctl") {
+		return nil, errors.New("systemctl unavailable; is this a systemd host?")
+	}
+	ctx, cancel := cmdContext(ctx)
+	defer cancel()
+	out, err := runCmd(ctx, "systemctl", "show", "-p", "LoadState", "-p", "ActiveState", "-p", "SubState", "-p", "ControlGroup", "--", unit)
+	if err != nil {
+		return nil, err
+	}
+	return parseSystemctlShow(out), nil
+}
+
+// readUnitCgroup reads the unit's CPU time, memory and task count from its
+// cgroup v2 directory.
+func readUnitCgroup(dir string, status *UnitStatus) (cpuUsec uint64, err error) {
+	read := func(name string) (string, error) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		return strings.TrimSpace(string(data)), err
+	}
+	stat, err := read("cpu.stat")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, errors.New("no cgroup v2 cpu.stat for the unit; cgroup v1 hosts are not supported")
+		}
+		return 0, err
+	}
+	cpuUsec = cgroupStatField(stat, "usage_usec")
+	if current, err := read("memory.current"); err == nil {
+		used, _ := strconv.ParseUint(current, 10, 64)
+		if memStat, err := read("memory.stat"); err == nil {
+			used -= min(used, cgroupStatField(memStat, "inactive_file"))
+		}
+		status.MemUsed = used
+	}
+	if limit, err := read("memory.max"); err == nil && limit != "max" {
+		status.MemLimit, _ = strconv.ParseUint(limit, 10, 64)
+	}
+	if tasks, err := read("pids.current"); err == nil {
+		status.Tasks, _ = strconv.ParseUint(tasks, 10, 64)
+	}
+	return cpuUsec, nil
+}
+
+// cgroupStatField returns one "key value" line's value from a cgroup stat
+// file such as cpu.stat, or 0.
+func cgroupStatField(stat, key string) uint64 {
+	for line := range strings.Lines(stat) {
+		if name, value, ok := strings.Cut(strings.TrimSpace(line), " "); ok && name == key {
+			v, _ := strconv.ParseUint(value, 10, 64)
+			return v
+		}
+	}
+	return 0
+}
+
+// collectUnit resolves the --unit service's cgroup through systemctl and
+// samples it. The kernel keeps no per-cgroup traffic counters, so the rates
+// come from an nftables rule matching the cgroup (socket cgroupv2), as with
+// --cgroup-traffic, when there is one. A unit that is missing or stopped
+// keeps its card with that state, and failures are reported on the card
+// rather than failing the refresh.
+func (c *Collector) collectUnit(ctx context.Context, tick time.Duration) *UnitStatus {
+	status := &UnitStatus{Name: c.unitName}
+	props, err := unitProps(ctx, c.unitName)
+	switch {
+	case err != nil:
+		status.Error = err.Error()
+	case props["LoadState"] == unitGone:
+		status.State = unitGone
+	default:
+		status.State = props["ActiveState"] + "/" + props["SubState"]
+		status.CGroup = props["ControlGroup"]
+	}
+	c.watchUnit(status.State)
+	if !status.running() || status.CGroup == "" {
+		c.prevUnit = nil
+		c.unitWindow = rateWindow{}
+		return status
+	}
+
+	var cur unitSample
+	cur.cpuUsec, err = readUnitCgroup(filepath.Join(c.cgroupRoot, status.CGroup), status)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	if counters, err := c.readCgroupCounters(ctx); err == nil {
+		cur.net, cur.counted = counters[strings.Trim(status.CGroup, "/")]
+	}
+	status.NetCounted = cur.counted
+	elapsed, ok := c.unitWindow.advance(tick)
+	if prev := c.prevUnit; prev != nil && ok {
+		if cur.cpuUsec >= prev.cpuUsec {
+			status.CPUPercent = float64(cur.cpuUsec-prev.cpuUsec) / 1e6 / elapsed * 100
+		}
+		// The counters restart when the ruleset is reloaded.
+		if cur.counted && prev.counted && cur.net.rx >= prev.net.rx && cur.net.tx >= prev.net.tx {
+			status.RxRateMBs = float64(cur.net.rx-prev.net.rx) / 1024 / 1024 / elapsed
+			status.TxRateMBs = float64(cur.net.tx-prev.net.tx) / 1024 / 1024 / elapsed
+		}
+	}
+	c.prevUnit = &cur
+	return status
+}
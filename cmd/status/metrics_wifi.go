@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	procNetWireless   = "/proc/net/wireless"
+	signalHistorySize = 60 // Readings kept per wireless interface; 5 minutes at the default 5s.
+)
+
+// collectWiFiSignal reads each wireless interface's signal level in dBm from
+// /proc/net/wireless. Linux only: macOS no longer ships a tool that reports
+// it without root.
+func collectWiFiSignal() (map[string]int, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("wifi signal unsupported")
+	}
+	data, err := os.ReadFile(procNetWireless)
+	if err != nil {
+		return nil, err
+	}
+	return parseProcWireless(string(data)), nil
+}
+
+// parseProcWireless reads the rows below the two header lines:
+// "wlan0: 0000   54.  -56.  -256        0      0      0      0    103        0".
+// The level column is in dBm on every current driver; the trailing dot marks
+// a value updated since the last read.
+func parseProcWireless(data string) map[string]int {
+	levels := make(map[string]int)
+	for line := range strings.Lines(data) {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 3 {
+			continue
+		}
+		level, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "."), 64)
+		if err != nil || level >= 0 {
+			continue // Header, or a driver reporting a relative 0-100 level.
+		}
+		levels[strings.TrimSpace(name)] = int(level)
+	}
+	return levels
+}
+
+// recordSignals appends fresh readings to the per-interface histories and
+// drops the history of an interface that no longer reports one.
+func (c *Collector) recordSignals(levels map[string]int) {
+	if c.signalHistory == nil {
+		c.signalHistory = make(map[string]*RingBuffer)
+	}
+	for name := range c.signalHistory {
+		if _, ok := levels[name]; !ok {
+			delete(c.signalHistory, name)
+		}
+	}
+	for name, dbm := range levels {
+		h := c.signalHistory[name]
+		if h == nil {
+			h = NewRingBuffer(signalHistorySize)
+			c.signalHistory[name] = h
+		}
+		h.Add(float64(dbm))
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// netTraceEntry is one line of the --debug-net trace: the inputs and output
+// of one interface's rate in one sample, for chasing counter resets and
+// timing bugs reported from the field.
+type netTraceEntry struct {
+	At      time.Time `json:"at"`
+	Tick    float64   `json:"tick"` // Monotonic clock, seconds since the collector started.
+	Iface   string    `json:"iface"`
+	Note    string    `json:"note,omitempty"` // baseline, new or reset; empty for a normal rate.
+	Elapsed float64   `json:"elapsed"`        // Seconds since the previous sample.
+	PrevRx  uint64    `json:"prev_rx"`
+	CurRx   uint64    `json:"cur_rx"`
+	PrevTx  uint64    `json:"prev_tx"`
+	CurTx   uint64    `json:"cur_tx"`
+	RxMBs   float64   `json:"rx_mbs"`
+	TxMBs   float64   `json:"tx_mbs"`
+}
+
+// netTrace buffers one sample's entries and writes them as JSON lines.
+// A nil *netTrace, as without --debug-net, ignores every call.
+type netTrace struct {
+	w       io.Writer
+	entries []netTraceEntry
+}
+
+func (t *netTrace) add(e netTraceEntry) {
+	if t != nil {
+		t.entries = append(t.entries, e)
+	}
+}
+
+// flush writes the buffered entries stamped with at and tick. Write errors
+// are dropped: the trace must never stop the collector.
+func (t *netTrace) flush(at time.Time, tick time.Duration) {
+	if t == nil || len(t.entries) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range t.entries {
+		e.At, e.Tick = at, tick.Seconds()
+		_ = enc.Encode(e)
+	}
+	_, _ = t.w.Write(buf.Bytes()) // One write per sample keeps lines whole.
+	t.entries = t.entries[:0]
+}
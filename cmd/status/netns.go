@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// netnsDir is where `ip netns add` bind-mounts named network namespaces.
+const netnsDir = "/var/run/netns"
+
+// parseProcNetDev reads the per-interface counters of /proc/net/dev. It is
+// used for --netns, where the file has to be read from inside the namespace.
+func parseProcNetDev(r io.Reader) ([]net.IOCountersStat, error) {
+	var stats []net.IOCountersStat
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.Contains(name, "|") {
+			continue // The two header lines.
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("/proc/net/dev: short line for %s", strings.TrimSpace(name))
+		}
+		var v [16]uint64
+		for i := range v {
+			n, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("/proc/net/dev: %s: %w", strings.TrimSpace(name), err)
+			}
+			v[i] = n
+		}
+		stats = append(stats, net.IOCountersStat{
+			Name:        strings.TrimSpace(name),
+			BytesRecv:   v[0],
+			PacketsRecv: v[1],
+			Errin:       v[2],
+			Dropin:      v[3],
+			Fifoin:      v[4],
+			BytesSent:   v[8],
+			PacketsSent: v[9],
+			Errout:      v[10],
+			Dropout:     v[11],
+			Fifoout:     v[12],
+		})
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/shirou/gopsutil/v4/net"
+	"golang.org/x/sys/unix"
+)
+
+// netnsIOCounters reads interface counters from inside the named network
+// namespace. setns applies to the calling thread only, so the goroutine is
+// pinned to its thread while it is switched over and reads through
+// /proc/thread-self (plain /proc/self/net follows the main thread). If
+// switching back fails the thread is left locked, which makes the runtime
+// discard it instead of reusing it in the wrong namespace.
+func netnsIOCounters(name string) ([]net.IOCountersStat, error) {
+	target, err := os.Open(filepath.Join(netnsDir, name))
+	if err != nil {
+		return nil, fmt.Errorf("--netns %s: %w", name, err)
+	}
+	defer target.Close()
+
+	runtime.LockOSThread()
+	origin, err := os.Open("/proc/thread-self/ns/net")
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("--netns: %w", err)
+	}
+	defer origin.Close()
+
+	if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		if errors.Is(err, unix.EPERM) {
+			return nil, fmt.Errorf("--netns %s: entering a namespace needs root (CAP_SYS_ADMIN)", name)
+		}
+		return nil, fmt.Errorf("--netns %s: %w", name, err)
+	}
+	stats, readErr := readThreadNetDev()
+	if err := unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET); err != nil {
+		return nil, fmt.Errorf("--netns %s: restoring the host namespace: %w", name, err)
+	}
+	runtime.UnlockOSThread()
+	if readErr != nil {
+		return nil, fmt.Errorf("--netns %s: %w", name, readErr)
+	}
+	return stats, nil
+}
+
+func readThreadNetDev() ([]net.IOCountersStat, error) {
+	f, err := os.Open("/proc/thread-self/net/dev")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseProcNetDev(f)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+func netnsIOCounters(string) ([]net.IOCountersStat, error) {
+	return nil, errors.New("--netns is only supported on Linux")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// notify shows a desktop notification (--notify): osascript on macOS, notify-send on Linux.
+func notify(ctx context.Context, title, body string) error {
+	ctx, cancel := cmdContext(ctx)
+	defer cancel()
+	switch {
+	case runtime.GOOS == "darwin":
+		script := `display notification "` + appleScriptEscape(body) + `" with title "` + appleScriptEscape(title) + `"`
+		_, err := runCmd(ctx, "osascript", "-e", script)
+		return err
+	case commandExists("notify-send"):
+		_, err := runCmd(ctx, "notify-send", title, body)
+		return err
+	}
+	return errors.New("no desktop notifier available")
+}
+
+// quietHours is a daily local-time window, such as 22:00-08:00, during which
+// alerts stay in the footer but no desktop notification is sent.
+type quietHours struct {
+	start, end int // Minutes after local midnight.
+	set        bool
+}
+
+// parseQuietHours parses "HH:MM-HH:MM". The window may cross midnight.
+func parseQuietHours(value string) (quietHours, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(value), "-")
+	if !ok {
+		return quietHours{}, fmt.Errorf("invalid quiet hours %q (want HH:MM-HH:MM)", value)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return quietHours{}, fmt.Errorf("invalid quiet hours %q: %w", value, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return quietHours{}, fmt.Errorf("invalid quiet hours %q: %w", value, err)
+	}
+	if start == end {
+		return quietHours{}, fmt.Errorf("invalid quiet hours %q: start and end must differ", value)
+	}
+	return quietHours{start: start, end: end, set: true}, nil
+}
+
+// parseClock returns the minutes after midnight for "HH:MM".
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", strings.TrimSpace(s))
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether t's wall clock, in t's own location, falls inside
+// the window. The end minute is exclusive.
+func (q quietHours) contains(t time.Time) bool {
+	if !q.set {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return now >= q.start && now < q.end
+	}
+	return now >= q.start || now < q.end // Crosses midnight.
+}
+
+func (q quietHours) String() string {
+	if !q.set {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", q.start/60, q.start%60, q.end/60, q.end%60)
+}
+
+func appleScriptEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,830 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// options holds the command-line settings for mo status.
+type options struct {
+	showVersion        bool          // Print build metadata and exit.
+	doctor             bool          // Check which collectors work here and exit.
+	agent              bool          // Run headless, feeding only --listen, --statsd and snapshot files.
+	jsonOutput         bool          // Print one JSON snapshot and exit.
+	jsonCompact        bool          // Print --json on one line; without it or jsonPretty, only off a terminal.
+	jsonPretty         bool          // Indent --json even off a terminal.
+	flatOutput         bool          // Print one snapshot as key=value lines and exit.
+	onceOutput         bool          // Print one --line summary and exit.
+	spark              bool          // Print rx/tx sparklines on one line and exit (`mo status spark`).
+	sparkIface         string        // The interface spark draws; empty = the totals.
+	sparkWidth         int           // Points per spark sparkline.
+	sparkTime          time.Duration // How long spark samples when the source has no history yet.
+	onceInterval       time.Duration // Gap between the two samples --json, --flat and --once take.
+	lineOutput         bool          // Print plain summary lines instead of the TUI.
+	influxLP           bool          // Print InfluxDB line protocol per sample instead of the TUI.
+	statsdAddr         string        // Also send each sample to this StatsD host:port over UDP.
+	listenAddr         string        // Serve the latest sample over HTTP on this address.
+	netns              string        // Read interface counters inside this named network namespace (Linux).
+	proxyCheck         bool          // Probe whether the proxy accepts connections, in the background.
+	firewall           bool          // Show the host firewall state.
+	arpCheck           bool          // Watch for duplicate IPs, probing with arping where available.
+	ipSplit            bool          // Also report host-wide IPv4 and IPv6 rates (Linux).
+	corsOrigin         string        // Access-Control-Allow-Origin for --listen; empty = no CORS.
+	metricNaming       promNaming    // Prefix and static labels for the /metrics endpoint.
+	precision          int           // Decimal places for rates and percentages; -1 keeps the defaults.
+	rateUnits          string        // rateUnitsAuto or rateUnitsMB.
+	excludeHidden      bool          // Interfaces hidden in the UI also drop out of the totals.
+	totalsExclude      []string      // Interfaces listed as usual but never added to the totals.
+	showIfaces         []string      // Interface prefixes listed even though the noise filter takes them.
+	hideIfaces         []string      // Interface prefixes added to the noise filter.
+	followRenames      bool          // Carry an interface's history across a rename with the same MAC.
+	minRate            float64       // Hide interface rows below this combined MB/s.
+	summaryFields      []string
+	netColumns         []string                 // Interface table columns, in order.
+	dirRatio           float64                  // One direction leads once it is this many times the other.
+	dirTotals          bool                     // Judge direction on the session totals.
+	sparkStyle         string                   // Sparkline glyph set: blocks, braille, ascii or digits.
+	noTrends           bool                     // Start with the trend arrows hidden.
+	ascii              bool                     // Draw with ASCII only, whatever the locale.
+	appProxies         bool                     // Also report proxies set in git, npm and curl config.
+	intervals          map[string]time.Duration // Per-collector refresh overrides.
+	pingTarget         string                   // Host to measure latency to; empty disables.
+	primaryIP          ipStrategy               // How an interface's displayed IPv4 is chosen.
+	cmdTimeout         time.Duration            // Budget for each fast external command.
+	duration           time.Duration            // Exit after this long; 0 = until quit.
+	samples            int                      // Exit after this many samples with rates; 0 = until quit.
+	warmup             time.Duration            // Gap of the dashboard's startup baseline sample; 0 = none.
+	noSummary          bool                     // Skip the session recap printed when the TUI exits.
+	pauseUnfocused     bool                     // Stop sampling while the terminal reports it lost focus.
+	speedtest          string                   // Measure throughput to this endpoint and exit.
+	speedtestTime      time.Duration            // Length of each direction of the speed test.
+	speedtestServe     string                   // Answer speed tests on this address instead.
+	helper             bool                     // Run as the privileged helper (mo status helper).
+	helperSocket       string                   // Unix socket of the helper, served or used.
+	helperGroup        string                   // Group allowed to use the helper's socket; empty = the helper's own.
+	sourceURL          string                   // Render a remote Mole JSON snapshot instead of this host.
+	zombieThreshold    int                      // Alert when zombie processes exceed this; 0 disables.
+	ephemeralThreshold float64                  // Alert when this percent of the ephemeral port range is in use; 0 disables.
+	notify             bool                     // Send alerts as desktop notifications.
+	quietHours         quietHours               // Hold back notifications in this window; unset = use prefs.
+	showTotals         bool                     // Show cumulative bytes moved in the network card.
+	aligned            bool                     // Right-align interface rates in fixed-width columns.
+	rateWidth          int                      // Width of the aligned columns; 0 = fit the widest expected value.
+	bondMembers        bool                     // List bonded member interfaces alongside their bond.
+	jsonEvents         int                      // Recent events to include in JSON output; 0 = none.
+	groupMembers       bool                     // List the interfaces an interface group sums alongside it.
+	diskSort           diskSort                 // Initial disk panel order.
+	connGroup          connGroup                // Initial connections panel grouping.
+	ifaceGraphs        ifaceGraphs              // Initial per-interface sparklines.
+	diskTop            int                      // Volumes listed in the disk panel; 0 = all.
+	rankWindow         int                      // Samples averaged when ranking the busiest interfaces.
+	asymRatio          float64                  // Flag interfaces whose one direction carries this many times the other; 0 = off.
+	asymWindow         int                      // Samples the skew must hold for.
+	trigger            spikeTrigger             // Freeze the dashboard when a sample crosses these.
+	kiosk              bool                     // Read-only wall display that cycles panel focus.
+	kioskCycle         time.Duration            // Time each panel stays focused in kiosk mode.
+	exportConfig       bool                     // Print the effective settings as YAML and exit.
+	dryRun             bool                     // Validate the configuration and exit.
+	historySize        int                      // Samples kept for the network, CPU and memory graphs.
+	publicIP           bool                     // Probe the external address (--public-ip).
+	publicIPURL        string                   // Endpoint answering with the caller's IP as text.
+	logEvents          string                   // Append every event to this JSON-lines file.
+	debugNet           string                   // Append a per-interface, per-sample rate trace to this file.
+	record             string                   // Write every snapshot, after a settings header, to this JSON-lines file.
+	logCSV             string                   // Append every sample's headline metrics to this CSV file.
+	historyCSV         string                   // Write every sample's interface rates to this CSV file.
+	steadyRates        bool                     // Divide by the refresh interval when the measured gap is close to it.
+	container          string                   // Docker container name or ID to watch (--container).
+	unit               string                   // systemd unit to watch (--unit).
+	adaptive           bool                     // Stretch the refresh interval while idle.
+	adaptiveMin        time.Duration            // Interval under load with --adaptive.
+	adaptiveMax        time.Duration            // Longest idle interval with --adaptive.
+	behindMargin       float64                  // Percent over the interval samples may run before the footer warns; 0 = off.
+	dimAfter           time.Duration            // Dim the dashboard after this long idle; 0 = never.
+	dimLevel           float64                  // Share of their brightness colors keep while dimmed.
+	thresholds         rateThresholds           // Interface rate colors; prefs entries override them per interface.
+
+	// Remote interfaces polled over SNMP.
+	snmpHost      string        // Agent host[:port]; empty disables.
+	snmpCommunity string        // v2c community.
+	snmpIfaces    []int         // ifIndex values to poll.
+	snmpV3        string        // USM credentials, user=..,auth=..,priv=..; replaces the community.
+	snmpTimeout   time.Duration // Per attempt; one retry follows a timeout.
+	cgroupTraffic bool          // List per-cgroup nftables counters as interfaces.
+
+	// Periodic JSON snapshot files.
+	snapshotEvery  time.Duration
+	historyBucket  time.Duration // Export histories at one point per this long; 0 = every sample.
+	snapshotDir    string
+	snapshotKeep   int
+	snapshotMaxAge time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		precision:          -1,
+		rateUnits:          rateUnitsAuto,
+		groupMembers:       true,
+		onceInterval:       oneShotDelay,
+		sparkWidth:         20,
+		sparkTime:          5 * time.Second,
+		snapshotDir:        ".",
+		snapshotKeep:       100,
+		summaryFields:      summaryFields,
+		netColumns:         defaultNetColumns,
+		dirRatio:           2,
+		sparkStyle:         sparkBlocks,
+		primaryIP:          ipStrategy{name: ipStrategyFirst},
+		cmdTimeout:         defaultCmdTimeout,
+		zombieThreshold:    5,
+		warmup:             200 * time.Millisecond,
+		ephemeralThreshold: 80,
+		metricNaming:       promNaming{prefix: defaultMetricPrefix},
+		speedtestTime:      10 * time.Second,
+		helperSocket:       defaultHelperSocket,
+		diskTop:            defaultDiskTop,
+		rankWindow:         1,
+		asymRatio:          20,
+		asymWindow:         30,
+		kioskCycle:         defaultKioskCycle,
+		historySize:        NetworkHistorySize,
+		publicIPURL:        defaultPublicIPURL,
+		adaptiveMin:        refreshInterval,
+		behindMargin:       25,
+		dimLevel:           0.4,
+		adaptiveMax:        10 * time.Second,
+		snmpCommunity:      "public",
+		snmpTimeout:        defaultSNMPTimeout,
+	}
+}
+
+// parseOptions parses command-line flags on top of the defaults.
+func parseOptions(args []string, output io.Writer) (options, error) {
+	opts := defaultOptions()
+	fs := newFlagSet(&opts, output)
+	// `mo status agent --listen :9100` takes its flags after the subcommand,
+	// as do `mo status helper --helper-group staff` and
+	// `mo status spark --iface en0`.
+	if len(args) > 0 && (args[0] == "agent" || args[0] == "helper" || args[0] == "spark") {
+		opts.agent, opts.helper, opts.spark = args[0] == "agent", args[0] == "helper", args[0] == "spark"
+		args = args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	// `mo status version` mirrors --version; `mo status doctor` runs the self-test.
+	switch {
+	case fs.NArg() == 1 && fs.Arg(0) == "version":
+		opts.showVersion = true
+	case fs.NArg() == 1 && fs.Arg(0) == "doctor":
+		opts.doctor = true
+	case fs.NArg() == 1 && fs.Arg(0) == "agent":
+		opts.agent = true
+	case fs.NArg() == 1 && fs.Arg(0) == "helper":
+		opts.helper = true
+	case fs.NArg() == 1 && fs.Arg(0) == "spark":
+		opts.spark = true
+	case fs.NArg() > 0:
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if n := countTrue(opts.jsonOutput, opts.flatOutput, opts.onceOutput, opts.lineOutput, opts.influxLP, opts.agent, opts.spark); n > 1 {
+		return opts, fmt.Errorf("--json, --flat, --once, --line, --influx-lp, agent and spark cannot be combined")
+	}
+	if opts.sparkWidth < 1 || opts.sparkTime <= 0 {
+		return opts, fmt.Errorf("--width and --spark-time must be positive")
+	}
+	if opts.speedtest != "" && opts.speedtestServe != "" {
+		return opts, fmt.Errorf("--speedtest and --speedtest-serve cannot be combined")
+	}
+	if opts.speedtestTime <= 0 || opts.speedtestTime > speedtestMaxTime {
+		return opts, fmt.Errorf("--speedtest-time must be positive and at most %s", speedtestMaxTime)
+	}
+	if opts.jsonEvents < 0 || opts.jsonEvents > eventRingSize {
+		return opts, fmt.Errorf("--json-events must be between 0 and %d", eventRingSize)
+	}
+	if opts.onceInterval <= 0 {
+		return opts, fmt.Errorf("--once-interval must be positive")
+	}
+	if opts.jsonCompact && opts.jsonPretty {
+		return opts, fmt.Errorf("--json-compact and --json-pretty cannot be combined")
+	}
+	if opts.agent && opts.listenAddr == "" && opts.statsdAddr == "" && opts.snapshotEvery <= 0 && opts.logCSV == "" && opts.historyCSV == "" && opts.record == "" {
+		return opts, fmt.Errorf("agent needs somewhere to send samples: --listen, --statsd, --log-csv, --history-csv, --record or --snapshot-every")
+	}
+	if err := checkMetricPrefix(opts.metricNaming.prefix); err != nil {
+		return opts, err
+	}
+	if opts.precision < -1 || opts.precision > 3 {
+		return opts, fmt.Errorf("--precision must be between 0 and 3, got %d", opts.precision)
+	}
+	if opts.rateUnits != rateUnitsAuto && opts.rateUnits != rateUnitsMB {
+		return opts, fmt.Errorf("--rate-units must be auto or mb, got %q", opts.rateUnits)
+	}
+	if _, ok := sparkGlyphs[opts.sparkStyle]; !ok {
+		return opts, fmt.Errorf("unknown --sparkline-style %q (want blocks, braille, ascii or digits)", opts.sparkStyle)
+	}
+	if opts.trigger.cpu < 0 || opts.trigger.rate < 0 {
+		return opts, fmt.Errorf("--freeze-cpu and --freeze-rate must not be negative")
+	}
+	if opts.kioskCycle <= 0 {
+		return opts, fmt.Errorf("--kiosk-cycle must be positive")
+	}
+	if u, err := url.Parse(opts.publicIPURL); opts.publicIP && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		return opts, fmt.Errorf("invalid --public-ip-url %q: want http(s)://host/path", opts.publicIPURL)
+	}
+	if opts.netns != "" && runtime.GOOS != "linux" {
+		return opts, fmt.Errorf("--netns is only supported on Linux")
+	}
+	if opts.netns != "" && opts.sourceURL != "" {
+		return opts, fmt.Errorf("--netns cannot be combined with --source-url")
+	}
+	if opts.debugNet != "" && opts.sourceURL != "" {
+		return opts, fmt.Errorf("--debug-net traces the local collector and cannot be combined with --source-url")
+	}
+	if opts.dirRatio <= 1 {
+		return opts, fmt.Errorf("--dir-ratio must be above 1")
+	}
+	if opts.behindMargin < 0 {
+		return opts, fmt.Errorf("--behind-margin must not be negative")
+	}
+	if opts.dimAfter < 0 {
+		return opts, fmt.Errorf("--dim-after must not be negative")
+	}
+	if opts.dimLevel <= 0 || opts.dimLevel >= 1 {
+		return opts, fmt.Errorf("--dim-level must be between 0 and 1")
+	}
+	if opts.adaptive && (opts.adaptiveMin <= 0 || opts.adaptiveMax < opts.adaptiveMin) {
+		return opts, fmt.Errorf("--adaptive-min must be positive and no larger than --adaptive-max")
+	}
+	if opts.adaptive && opts.steadyRates {
+		return opts, fmt.Errorf("--steady-rates assumes a fixed interval and cannot be combined with --adaptive")
+	}
+	if t := opts.thresholds; t.warnRx < 0 || t.critRx < 0 || t.warnTx < 0 || t.critTx < 0 {
+		return opts, fmt.Errorf("--warn-rx, --crit-rx, --warn-tx and --crit-tx must not be negative")
+	}
+	if t := opts.thresholds; t.rxCeiling < 0 || t.txCeiling < 0 {
+		return opts, fmt.Errorf("--rx-ceiling and --tx-ceiling must not be negative")
+	}
+	if err := opts.thresholds.check(); err != nil {
+		return opts, fmt.Errorf("invalid rate thresholds: %w", err)
+	}
+	if opts.snmpHost != "" && len(opts.snmpIfaces) == 0 {
+		return opts, fmt.Errorf("--snmp needs --snmp-iface with the ifIndex values to poll")
+	}
+	if opts.snmpV3 != "" {
+		if _, err := parseSNMPv3(opts.snmpV3); err != nil {
+			return opts, err
+		}
+	}
+	if opts.snmpTimeout <= 0 {
+		return opts, fmt.Errorf("--snmp-timeout must be positive")
+	}
+	if opts.historySize < 2 {
+		return opts, fmt.Errorf("--history must be at least 2")
+	}
+	if opts.rankWindow < 1 {
+		return opts, fmt.Errorf("--rank-window must be at least 1")
+	}
+	if opts.asymRatio != 0 && opts.asymRatio <= 1 {
+		return opts, fmt.Errorf("--asym-ratio must be above 1, or 0 to turn it off")
+	}
+	if opts.asymWindow < 2 {
+		return opts, fmt.Errorf("--asym-window must be at least 2")
+	}
+	opts.asymWindow = min(opts.asymWindow, opts.historySize) // The graphs keep no more than that.
+	if opts.diskTop < 0 {
+		return opts, fmt.Errorf("--disk-top must not be negative")
+	}
+	if opts.zombieThreshold < 0 {
+		return opts, fmt.Errorf("--zombie-threshold must not be negative")
+	}
+	if opts.ephemeralThreshold < 0 || opts.ephemeralThreshold > 100 {
+		return opts, fmt.Errorf("--ephemeral-threshold must be a percentage between 0 and 100")
+	}
+	if opts.duration < 0 {
+		return opts, fmt.Errorf("--duration must not be negative")
+	}
+	if opts.warmup < 0 || opts.warmup >= refreshInterval {
+		return opts, fmt.Errorf("--warmup must be at least 0 and below %s", refreshInterval)
+	}
+	if opts.samples < 0 {
+		return opts, fmt.Errorf("--samples must not be negative")
+	}
+	if opts.cmdTimeout <= 0 {
+		return opts, fmt.Errorf("--cmd-timeout must be positive")
+	}
+	if opts.minRate < 0 {
+		return opts, fmt.Errorf("--min-rate must not be negative")
+	}
+	if opts.rateWidth < 0 {
+		return opts, fmt.Errorf("--rate-width must not be negative")
+	}
+	opts.aligned = opts.aligned || opts.rateWidth > 0
+	if opts.historyBucket < 0 {
+		return opts, fmt.Errorf("--history-bucket must not be negative")
+	}
+	if opts.snapshotEvery < 0 || opts.snapshotMaxAge < 0 || opts.snapshotKeep < 0 {
+		return opts, fmt.Errorf("snapshot interval, age and count must not be negative")
+	}
+	if opts.snapshotEvery > 0 && opts.snapshotDir == "" {
+		return opts, fmt.Errorf("--snapshot-dir must not be empty")
+	}
+	return opts, nil
+}
+
+// settingFlag is a flag.Value for settings parsed by a helper. Unlike fs.Func
+// it reports the current value, so --help defaults and --export-config show it.
+type settingFlag struct {
+	get func() string
+	set func(string) error
+}
+
+func (f settingFlag) String() string {
+	if f.get == nil {
+		return ""
+	}
+	return f.get()
+}
+
+func (f settingFlag) Set(value string) error { return f.set(value) }
+
+// newFlagSet binds every option to opts, using its current values as defaults.
+func newFlagSet(opts *options, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	// Errors are reported by the caller; only usage goes to output.
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {
+		fmt.Fprintln(output, "Usage: mo status [options] [version|doctor|agent|helper]")
+		fmt.Fprintln(output)
+		listed := flag.NewFlagSet("status", flag.ContinueOnError)
+		fs.VisitAll(func(f *flag.Flag) {
+			if !slices.Contains(hiddenFlags, f.Name) {
+				listed.Var(f.Value, f.Name, f.Usage)
+				listed.Lookup(f.Name).DefValue = f.DefValue
+			}
+		})
+		listed.SetOutput(output)
+		listed.PrintDefaults()
+	}
+	fs.BoolVar(&opts.showVersion, "version", opts.showVersion, "print version, commit, build date and Go version, then exit")
+	fs.BoolVar(&opts.jsonOutput, "json", opts.jsonOutput, "print a single JSON snapshot and exit")
+	fs.BoolVar(&opts.jsonCompact, "json-compact", opts.jsonCompact, "print --json on one line (the default when stdout is not a terminal)")
+	fs.BoolVar(&opts.jsonPretty, "json-pretty", opts.jsonPretty, "indent --json even when stdout is not a terminal")
+	fs.BoolVar(&opts.flatOutput, "flat", opts.flatOutput, "print a single snapshot as sorted key=value lines (network.en0.rx_rate_mbs=1.5) and exit")
+	fs.BoolVar(&opts.onceOutput, "once", opts.onceOutput, "print a single plain summary line, as --line does, and exit")
+	fs.DurationVar(&opts.onceInterval, "once-interval", opts.onceInterval, "measure --json, --flat and --once rates over this long: longer is steadier, shorter answers sooner")
+	fs.StringVar(&opts.sparkIface, "iface", opts.sparkIface, "interface mo status spark draws (default the totals)")
+	fs.IntVar(&opts.sparkWidth, "width", opts.sparkWidth, "points per mo status spark sparkline")
+	fs.DurationVar(&opts.sparkTime, "spark-time", opts.sparkTime, "how long mo status spark samples when the source has no history yet: shorter answers sooner with noisier points")
+	fs.BoolVar(&opts.lineOutput, "line", opts.lineOutput, "print one plain summary line per second instead of the TUI")
+	fs.BoolVar(&opts.influxLP, "influx-lp", opts.influxLP, "print InfluxDB line protocol for each sample instead of the TUI")
+	fs.StringVar(&opts.listenAddr, "listen", opts.listenAddr, "serve the latest sample on this address, e.g. :9100 (/api/snapshot, /api/history/network, /metrics)")
+	fs.StringVar(&opts.corsOrigin, "cors-origin", opts.corsOrigin, `allow browser pages from this origin (or "*") to call the --listen API`)
+	fs.StringVar(&opts.metricNaming.prefix, "metric-prefix", opts.metricNaming.prefix, "start every /metrics name with this instead of mole_, e.g. myhost_")
+	fs.Var(settingFlag{func() string { return formatMetricLabels(opts.metricNaming.labels) }, func(value string) error {
+		labels, err := parseMetricLabels(opts.metricNaming.labels, value)
+		opts.metricNaming.labels = labels
+		return err
+	}}, "metric-label", "static label added to every /metrics series, e.g. dc=us-east (repeatable or comma-separated)")
+	fs.StringVar(&opts.statsdAddr, "statsd", opts.statsdAddr, "also send each sample as StatsD gauges to host:port over UDP, e.g. localhost:8125")
+	fs.IntVar(&opts.precision, "precision", opts.precision, "decimal places for rates and percentages, 0-3 (-1 = default)")
+	fs.StringVar(&opts.rateUnits, "rate-units", opts.rateUnits, "network rate units: auto (B/s to GB/s, whichever fits) or mb (always MB/s)")
+
+	fs.BoolVar(&opts.excludeHidden, "exclude-hidden", opts.excludeHidden, "leave interfaces hidden with h out of the network totals")
+	fs.Var(settingFlag{func() string { return strings.Join(opts.totalsExclude, ",") }, func(value string) error {
+		opts.totalsExclude = splitList(value)
+		return nil
+	}}, "totals-exclude", "comma-separated interfaces to list but leave out of the network totals, e.g. br0,virbr0")
+	fs.Var(settingFlag{func() string { return strings.Join(opts.showIfaces, ",") }, func(value string) error {
+		opts.showIfaces = splitList(value)
+		return nil
+	}}, "show-interface", "comma-separated interface name prefixes to list even though they look internal, e.g. utun3,bridge0")
+	fs.Var(settingFlag{func() string { return strings.Join(opts.hideIfaces, ",") }, func(value string) error {
+		opts.hideIfaces = splitList(value)
+		return nil
+	}}, "hide-interface", "comma-separated interface name prefixes to leave out like loopback, e.g. vnet,ifb")
+	fs.BoolVar(&opts.followRenames, "follow-renames", opts.followRenames, "keep an interface's history and totals when it is renamed (e.g. eth0 to enp3s0) but keeps its MAC address")
+	fs.BoolVar(&opts.showTotals, "totals", opts.showTotals, "show bytes received and sent since start in the network card")
+	fs.BoolVar(&opts.aligned, "aligned", opts.aligned, "right-align interface rates in fixed-width columns, sized to the busiest recent rate")
+	fs.IntVar(&opts.rateWidth, "rate-width", opts.rateWidth, "width of the aligned rate columns in characters (0 = fit the widest expected rate); implies --aligned")
+	fs.Var(settingFlag{func() string { return strings.Join(opts.netColumns, ",") }, func(value string) error {
+		cols, err := parseNetColumns(value)
+		opts.netColumns = cols
+		return err
+	}}, "net-columns", "comma-separated interface table columns, in order: "+strings.Join(netColumnNames, ","))
+	fs.Float64Var(&opts.dirRatio, "dir-ratio", opts.dirRatio, "the dir column calls an interface mostly down or up once one direction is this many times the other, otherwise both")
+	fs.BoolVar(&opts.dirTotals, "dir-totals", opts.dirTotals, "judge the dir column on bytes moved this session instead of the current rates")
+	fs.IntVar(&opts.jsonEvents, "json-events", opts.jsonEvents, fmt.Sprintf("include the last N events (time, severity, category, message) in --json, --listen, --record and snapshot output, at most %d; 0 leaves them out", eventRingSize))
+	fs.BoolVar(&opts.groupMembers, "group-members", opts.groupMembers, "keep listing the interfaces a group.<name>= line in the prefs file sums; false shows the group row instead of them")
+	fs.BoolVar(&opts.bondMembers, "bond-members", opts.bondMembers, "also list interfaces enslaved to a Linux bond (marked, left out of totals)")
+	fs.Var(settingFlag{func() string { return opts.diskSort.String() }, func(value string) error {
+		by, err := parseDiskSort(value)
+		opts.diskSort = by
+		return err
+	}}, "disk-sort", "disk panel order: size, free (least free first) or mount")
+	fs.Var(settingFlag{func() string { return opts.connGroup.String() }, func(value string) error {
+		by, err := parseConnGroup(value)
+		opts.connGroup = by
+		return err
+	}}, "conn-group", "connections panel grouping: state, proto or remote (top talkers)")
+	fs.Var(settingFlag{func() string { return opts.ifaceGraphs.String() }, func(value string) error {
+		g, err := parseIfaceGraphs(value)
+		opts.ifaceGraphs = g
+		return err
+	}}, "iface-graphs", "a sparkline under each interface row: off, own (each scaled to its own peak) or shared (one scale)")
+	fs.BoolVar(&opts.ipSplit, "ip-split", opts.ipSplit, "also show host-wide IPv4 and IPv6 rates separately (Linux; interface counters combine both)")
+	fs.StringVar(&opts.netns, "netns", opts.netns, "read interface counters inside this named network namespace from /var/run/netns (Linux, needs root)")
+	fs.IntVar(&opts.diskTop, "disk-top", opts.diskTop, "list at most this many volumes in the disk panel (0 = all)")
+	fs.IntVar(&opts.rankWindow, "rank-window", opts.rankWindow, "rank the busiest interfaces by their mean rate over this many samples (1 = current sample)")
+	fs.Float64Var(&opts.asymRatio, "asym-ratio", opts.asymRatio, "mark interfaces whose traffic one way is at least this many times the other, a hint of asymmetric routing (0 = off)")
+	fs.IntVar(&opts.asymWindow, "asym-window", opts.asymWindow, "samples in a row the --asym-ratio skew must hold for")
+	fs.Float64Var(&opts.trigger.cpu, "freeze-cpu", opts.trigger.cpu, "freeze the dashboard when CPU usage reaches this percent (0 = off; f resumes)")
+	fs.Float64Var(&opts.trigger.rate, "freeze-rate", opts.trigger.rate, "freeze the dashboard when any interface reaches this MB/s either way (0 = off; f resumes)")
+	fs.BoolVar(&opts.kiosk, "kiosk", opts.kiosk, "read-only wall display: ignore keys, rotate panel focus, exit only on ctrl+c twice")
+	fs.DurationVar(&opts.kioskCycle, "kiosk-cycle", opts.kioskCycle, "how long each panel stays focused with --kiosk")
+	fs.IntVar(&opts.historySize, "history", opts.historySize, "samples kept for the network, CPU and memory graphs")
+	fs.BoolVar(&opts.publicIP, "public-ip", opts.publicIP, "show the external IP as seen by --public-ip-url, to spot VPN leaks")
+	fs.StringVar(&opts.publicIPURL, "public-ip-url", opts.publicIPURL, "endpoint that answers with your IP as plain text")
+	fs.BoolVar(&opts.steadyRates, "steady-rates", opts.steadyRates, "treat sample gaps within 10% of the refresh interval as exactly one interval, smoothing rate jitter")
+	fs.StringVar(&opts.unit, "unit", opts.unit, "also watch this systemd service's CPU, memory and tasks from its cgroup (Linux, cgroup v2); network too when an nftables rule counts its cgroup")
+	fs.StringVar(&opts.container, "container", opts.container, "also watch this Docker container's CPU, memory and network (name or ID; reads /var/run/docker.sock or DOCKER_HOST)")
+	fs.Float64Var(&opts.thresholds.warnRx, "warn-rx", opts.thresholds.warnRx, "color an interface's download rate yellow from this many MB/s (0 = off)")
+	fs.Float64Var(&opts.thresholds.critRx, "crit-rx", opts.thresholds.critRx, "color an interface's download rate red from this many MB/s (0 = off)")
+	fs.Float64Var(&opts.thresholds.warnTx, "warn-tx", opts.thresholds.warnTx, "color an interface's upload rate yellow from this many MB/s (0 = off)")
+	fs.Float64Var(&opts.thresholds.critTx, "crit-tx", opts.thresholds.critTx, "color an interface's upload rate red from this many MB/s (0 = off)")
+	fs.Float64Var(&opts.thresholds.rxCeiling, "rx-ceiling", opts.thresholds.rxCeiling, "draw download sparklines on a fixed scale of this many MB/s, marking clipped points (0 = scale to the largest point)")
+	fs.Float64Var(&opts.thresholds.txCeiling, "tx-ceiling", opts.thresholds.txCeiling, "draw upload sparklines on a fixed scale of this many MB/s, marking clipped points (0 = scale to the largest point)")
+	fs.BoolVar(&opts.adaptive, "adaptive", opts.adaptive, "refresh the dashboard less often while the machine is idle, to save battery")
+	fs.DurationVar(&opts.adaptiveMin, "adaptive-min", opts.adaptiveMin, "refresh interval under load with --adaptive")
+	fs.DurationVar(&opts.adaptiveMax, "adaptive-max", opts.adaptiveMax, "longest refresh interval while idle with --adaptive")
+	fs.DurationVar(&opts.dimAfter, "dim-after", opts.dimAfter, "dim the dashboard after this long without traffic, CPU or disk activity, e.g. 5m for a wall display; any activity or key brightens it (0 = never)")
+	fs.Float64Var(&opts.dimLevel, "dim-level", opts.dimLevel, "brightness colors keep while dimmed, between 0 and 1")
+	fs.Float64Var(&opts.behindMargin, "behind-margin", opts.behindMargin, "warn when samples keep arriving this many percent later than the refresh interval, a sign of a slow collector (0 = off)")
+	fs.StringVar(&opts.snmpHost, "snmp", opts.snmpHost, "also poll interface counters from this SNMP agent, host[:port], e.g. a router (needs --snmp-iface)")
+	fs.StringVar(&opts.snmpCommunity, "snmp-community", opts.snmpCommunity, "SNMPv2c community for --snmp")
+	fs.Var(settingFlag{func() string { return formatIfIndexes(opts.snmpIfaces) }, func(value string) error {
+		indexes, err := parseIfIndexes(value)
+		opts.snmpIfaces = indexes
+		return err
+	}}, "snmp-iface", "comma-separated ifIndex values to poll with --snmp, e.g. 2,3")
+	fs.StringVar(&opts.snmpV3, "snmp-v3", opts.snmpV3, "use SNMPv3 instead of a community: user=NAME[,auth=md5|sha:PASS[,priv=aes:PASS]]")
+	fs.BoolVar(&opts.cgroupTraffic, "cgroup-traffic", opts.cgroupTraffic, "list traffic counted by nftables rules that match a cgroup (socket cgroupv2 or meta cgroup) as extra interfaces; Linux, needs root")
+	fs.DurationVar(&opts.snmpTimeout, "snmp-timeout", opts.snmpTimeout, "wait this long for each SNMP response before retrying once")
+	fs.StringVar(&opts.debugNet, "debug-net", opts.debugNet, "append the raw counters, elapsed time and resulting rate of every interface in every sample to this JSON-lines file")
+	fs.StringVar(&opts.record, "record", opts.record, "record the session, every snapshot plus the settings in effect, to this JSON-lines file for a bug report or replay")
+	fs.StringVar(&opts.historyCSV, "history-csv", opts.historyCSV, "write every interface's rates to this CSV file, one row per interface per sample (time,iface,rx_mbs,tx_mbs); replaces the file")
+	fs.StringVar(&opts.logCSV, "log-csv", opts.logCSV, "append each sample's headline metrics to this CSV file, one row per metric (time,host,measurement,iface,kind,target,field,value)")
+	fs.StringVar(&opts.logEvents, "log-events", opts.logEvents, "append every event (interface up/down, proxy changes, alerts) to this JSON-lines file")
+	fs.Float64Var(&opts.minRate, "min-rate", opts.minRate, "hide interfaces whose rx+tx is below this MB/s (still counted in totals)")
+	fs.Var(settingFlag{func() string { return formatSummaryFields(opts.summaryFields) }, func(value string) error {
+		fields, err := parseSummaryFields(value)
+		opts.summaryFields = fields
+		return err
+	}}, "summary", "comma-separated summary line fields: "+strings.Join(summaryFields, ",")+` ("none" hides it)`)
+	fs.StringVar(&opts.sparkStyle, "sparkline-style", opts.sparkStyle, "sparkline glyphs: blocks, braille, ascii (for consoles with gappy block fonts) or digits (latest values as numbers)")
+	fs.BoolVar(&opts.ascii, "ascii", opts.ascii, "draw the dashboard with ASCII characters only (automatic when the locale is not UTF-8)")
+	fs.BoolVar(&opts.noTrends, "no-trends", opts.noTrends, "start without the ▲/▼/▬ trend arrows (t toggles them)")
+	fs.BoolVar(&opts.appProxies, "app-proxies", opts.appProxies, "also detect proxies configured in git, npm and curl (runs git config)")
+	fs.BoolVar(&opts.firewall, "firewall", opts.firewall, "show whether the host firewall (ufw or nftables, the macOS application firewall, pf) is on and roughly how many rules it has; most tools need root")
+	fs.BoolVar(&opts.proxyCheck, "proxy-check", opts.proxyCheck, "check in the background that the proxy accepts TCP connections")
+	fs.BoolVar(&opts.arpCheck, "arp-check", opts.arpCheck, "log duplicate IP conflicts: neighbor entries flipping between MACs, addresses the kernel flags, and arping probes of this host's addresses (Linux)")
+	fs.StringVar(&opts.pingTarget, "ping", opts.pingTarget, "measure latency to this host (ICMP, or TCP connect to :443 or host:port)")
+	fs.Var(settingFlag{func() string { return opts.primaryIP.String() }, func(value string) error {
+		strategy, err := parseIPStrategy(value)
+		opts.primaryIP = strategy
+		return err
+	}}, "primary-ip", "IPv4 shown for multi-address interfaces: first, default-route or prefer-subnet=CIDR")
+	fs.DurationVar(&opts.cmdTimeout, "cmd-timeout", opts.cmdTimeout, "time limit for each helper command such as scutil, sysctl, ps or nvidia-smi")
+	fs.DurationVar(&opts.duration, "duration", opts.duration, "exit after this long, e.g. 10m (0 = run until quit)")
+	fs.IntVar(&opts.samples, "samples", opts.samples, "exit after this many samples; the first, which only sets the rate baseline, is not counted (0 = no limit)")
+	fs.DurationVar(&opts.warmup, "warmup", opts.warmup, "take the dashboard's first sample this long before the first frame so it opens with real rates (0 = rates from the second refresh)")
+	fs.BoolVar(&opts.pauseUnfocused, "pause-unfocused", opts.pauseUnfocused, "stop sampling while the terminal window is unfocused, needs a terminal that reports focus")
+	fs.StringVar(&opts.speedtest, "speedtest", opts.speedtest, "measure download and upload throughput to a --speedtest-serve host:port, or download from an http(s) URL, then exit")
+	fs.DurationVar(&opts.speedtestTime, "speedtest-time", opts.speedtestTime, "how long --speedtest runs each direction (at most 1m)")
+	fs.StringVar(&opts.speedtestServe, "speedtest-serve", opts.speedtestServe, "answer --speedtest clients on this address, e.g. :5201")
+	fs.StringVar(&opts.helperSocket, "helper-socket", opts.helperSocket, "Unix socket of mo status helper, which collects connections and root-only probes for a dashboard not running as root")
+	fs.StringVar(&opts.helperGroup, "helper-group", opts.helperGroup, "with mo status helper, let this group use the socket (default: the helper's own group, root's)")
+	fs.BoolVar(&opts.noSummary, "no-summary", opts.noSummary, "do not print the session summary when the dashboard exits")
+	fs.StringVar(&opts.sourceURL, "source-url", opts.sourceURL, "poll a remote Mole JSON snapshot, e.g. http://agent:9100/api/snapshot, instead of collecting locally")
+	fs.IntVar(&opts.zombieThreshold, "zombie-threshold", opts.zombieThreshold, "alert when more than this many zombie processes exist (0 = off)")
+	fs.Float64Var(&opts.ephemeralThreshold, "ephemeral-threshold", opts.ephemeralThreshold, "alert when this percent of the ephemeral port range is in use, Linux only (0 = off)")
+	fs.BoolVar(&opts.notify, "notify", opts.notify, "also send alerts as desktop notifications (osascript or notify-send)")
+	fs.Var(settingFlag{func() string { return opts.quietHours.String() }, func(value string) error {
+		q, err := parseQuietHours(value)
+		opts.quietHours = q
+		return err
+	}}, "quiet-hours", "local-time window with no desktop notifications, e.g. 22:00-08:00 (default: quiet_hours in status_prefs)")
+	fs.Var(settingFlag{func() string { return formatCollectorIntervals(opts.intervals) }, func(value string) error {
+		intervals, err := parseCollectorIntervals(value)
+		opts.intervals = intervals
+		return err
+	}}, "collector-interval", "refresh overrides for slow collectors, e.g. connections=10s,disks=1m ("+strings.Join(collectorNames(), ", ")+")")
+	fs.DurationVar(&opts.snapshotEvery, "snapshot-every", opts.snapshotEvery, "write a JSON snapshot file at this interval, e.g. 5m (0 = off)")
+	fs.DurationVar(&opts.historyBucket, "history-bucket", opts.historyBucket, "export histories (--json, --flat, --snapshot-every, --listen) at one point per bucket, the peak of its samples, e.g. 10s (0 = every sample)")
+	fs.StringVar(&opts.snapshotDir, "snapshot-dir", opts.snapshotDir, "directory for --snapshot-every files")
+	fs.IntVar(&opts.snapshotKeep, "snapshot-keep", opts.snapshotKeep, "keep at most this many snapshot files (0 = unlimited)")
+	fs.DurationVar(&opts.snapshotMaxAge, "snapshot-max-age", opts.snapshotMaxAge, "delete snapshot files older than this (0 = never)")
+	fs.BoolVar(&opts.dryRun, "dry-run", opts.dryRun, "check the configuration (flags, prefs file, interfaces, addresses and paths) and exit, non-zero on any problem")
+	fs.BoolVar(&opts.exportConfig, "export-config", opts.exportConfig, "print the effective settings (defaults, prefs file and flags merged) as YAML and exit")
+	return fs
+}
+
+// helperClient returns the helper to ask for privileged collectors, or nil
+// when running as root already or when no helper is listening. An explicit
+// --helper-socket is always tried, so its absence shows in the event log.
+func (o options) helperClient() *helperClient {
+	if o.helperSocket == "" || os.Geteuid() == 0 || runtime.GOOS == "windows" {
+		return nil
+	}
+	if _, err := os.Stat(o.helperSocket); err != nil && o.helperSocket == defaultHelperSocket {
+		return nil
+	}
+	return &helperClient{path: o.helperSocket}
+}
+
+// newCollector returns a collector configured from the options.
+func (o options) newCollector() *Collector {
+	c := NewCollector()
+	c.appProxies = o.appProxies
+	c.setCollectorIntervals(o.intervals)
+	c.pingTarget = o.pingTarget
+	c.ipStrategy = o.primaryIP
+	c.cmdTimeout = o.cmdTimeout
+	c.showBondMembers = o.bondMembers
+	c.exportEvents = o.jsonEvents
+	c.groups = newIfaceGroups(loadPrefs().groups, o.historySize)
+	c.showGroupMembers = o.groupMembers
+	c.helper = o.helperClient()
+	c.diskTop = o.diskTop
+	c.rankWindow = o.rankWindow
+	if len(o.totalsExclude) > 0 {
+		c.totalsSkip = toSet(o.totalsExclude)
+	}
+	c.noiseFilters = append(c.noiseFilters, o.hideIfaces...)
+	c.showIfaces = o.showIfaces
+	c.idleRate = o.minRate
+	c.netns = o.netns
+	c.followRenames = o.followRenames
+	c.proxyCheck = o.proxyCheck
+	c.firewallCheck = o.firewall
+	c.arpCheck = o.arpCheck
+	c.ipSplit = o.ipSplit
+	c.setHistorySize(o.historySize)
+	if o.steadyRates {
+		c.netWindow.nominal = refreshInterval
+		c.diskWindow.nominal = refreshInterval
+	}
+	if o.publicIP {
+		c.publicIPURL = o.publicIPURL
+	}
+	if o.unit != "" {
+		c.unitName = o.unit
+		c.cgroupRoot = defaultCgroupRoot
+	}
+	if o.container != "" {
+		c.containerName = o.container
+		c.docker = newDockerClient()
+	}
+	if o.snmpHost != "" {
+		client := &snmpClient{addr: snmpAddr(o.snmpHost), community: o.snmpCommunity, timeout: o.snmpTimeout, retries: 1}
+		if o.snmpV3 != "" {
+			client.v3, _ = parseSNMPv3(o.snmpV3) // Checked by parseOptions.
+		}
+		c.snmp = newSNMPPoller(client, o.snmpHost, o.snmpIfaces)
+	}
+	if o.cgroupTraffic {
+		c.cgroups = newCgroupTraffic()
+	}
+	return c
+}
+
+// newSource returns the remote source for --source-url, or a local collector,
+// wrapped to feed --statsd, --listen, --record and --log-csv when set.
+func (o options) newSource() (snapshotSource, error) {
+	var src snapshotSource
+	if o.sourceURL != "" {
+		remote, err := newRemoteSource(o.sourceURL)
+		if err != nil {
+			return nil, err
+		}
+		src = remote
+	} else {
+		if o.netns != "" {
+			// Fail at start on a missing namespace or missing privileges
+			// rather than on every refresh.
+			if _, err := netnsIOCounters(o.netns); err != nil {
+				return nil, err
+			}
+		}
+		src = o.newCollector()
+	}
+	if o.statsdAddr == "" && o.listenAddr == "" && o.record == "" && o.logCSV == "" && o.historyCSV == "" {
+		return src, nil
+	}
+	sinks := &sinkFanout{events: eventsOf(src)}
+	if o.record != "" {
+		var config strings.Builder
+		if err := exportConfig(&config, o, loadPrefs()); err != nil {
+			return nil, err
+		}
+		f, err := os.Create(o.record)
+		if err != nil {
+			return nil, fmt.Errorf("--record: %w", err)
+		}
+		rec, err := newSessionRecorder(f, config.String(), time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("--record: %w", err)
+		}
+		rec.events = eventsOf(src)
+		sinks.add("--record", func(s MetricsSnapshot) error { rec.record(s); return nil })
+	}
+	if o.logCSV != "" {
+		f, err := os.OpenFile(o.logCSV, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("--log-csv: %w", err)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("--log-csv: %w", err)
+		}
+		header := fi.Size() == 0
+		sinks.add("--log-csv", func(s MetricsSnapshot) error {
+			err := writeCSV(f, s, header)
+			header = header && err != nil
+			return err
+		})
+	}
+	if o.historyCSV != "" {
+		f, err := os.Create(o.historyCSV)
+		if err != nil {
+			return nil, fmt.Errorf("--history-csv: %w", err)
+		}
+		header := true
+		sinks.add("--history-csv", func(s MetricsSnapshot) error {
+			err := writeHistoryCSV(f, s, header)
+			header = header && err != nil
+			return err
+		})
+	}
+	if o.statsdAddr != "" {
+		statsd, err := newStatsdSink(o.statsdAddr)
+		if err != nil {
+			return nil, err
+		}
+		sinks.add("--statsd", func(s MetricsSnapshot) error { statsd.send(s); return nil })
+	}
+	if o.listenAddr != "" {
+		api, err := newAPIServer(o.listenAddr, o.corsOrigin, o.historyBucket, o.metricNaming)
+		if err != nil {
+			return nil, err
+		}
+		sinks.add("--listen", func(s MetricsSnapshot) error { api.record(s); return nil })
+	}
+	return sinkSource{snapshotSource: src, sinks: sinks}, nil
+}
+
+func countTrue(flags ...bool) int {
+	n := 0
+	for _, f := range flags {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
+// snapshotWriter returns the configured periodic snapshot writer, or nil when disabled.
+func (o options) snapshotWriter() *snapshotWriter {
+	if o.snapshotEvery <= 0 {
+		return nil
+	}
+	w := newSnapshotWriter(o.snapshotDir, o.snapshotEvery, o.snapshotKeep, o.snapshotMaxAge)
+	w.bucket = o.historyBucket
+	return w
+}
+
+func formatSummaryFields(fields []string) string {
+	if len(fields) == 0 {
+		return "none"
+	}
+	return strings.Join(fields, ",")
+}
+
+func parseSummaryFields(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "none" {
+		return nil, nil
+	}
+	fields := splitList(value)
+	for _, f := range fields {
+		if !slices.Contains(summaryFields, f) {
+			return nil, fmt.Errorf("unknown summary field %q", f)
+		}
+	}
+	return fields, nil
+}
+
+func parseNetColumns(value string) ([]string, error) {
+	cols := splitList(value)
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("--net-columns needs at least one column")
+	}
+	for i, c := range cols {
+		if !slices.Contains(netColumnNames, c) {
+			return nil, fmt.Errorf("unknown --net-columns column %q (want %s)", c, strings.Join(netColumnNames, ", "))
+		}
+		if slices.Contains(cols[:i], c) {
+			return nil, fmt.Errorf("--net-columns lists %q twice", c)
+		}
+	}
+	return cols, nil
+}
+
+// exportSkipFlags are left out of --export-config: they pick a one-off mode
+// rather than configure the dashboard.
+var exportSkipFlags = []string{"version", "json", "flat", "once", "line", "influx-lp", "iface", "width", "spark-time", "export-config", "debug-net", "speedtest", "speedtest-serve", "record", "dry-run"}
+
+// hiddenFlags work but are left out of --help: they are for chasing bugs
+// with a maintainer, not everyday use.
+var hiddenFlags = []string{"debug-net"}
+
+// exportConfig prints the effective settings as YAML keyed by flag name, so
+// they can be read back or turned into flags. Values come from defaults, the
+// prefs file and the command line, in increasing precedence.
+func exportConfig(w io.Writer, opts options, prefs statusPrefs) error {
+	if !opts.quietHours.set {
+		opts.quietHours, _ = parseQuietHours(prefs.quietHours)
+	}
+	var b strings.Builder
+	b.WriteString("# mo status effective settings\n")
+	if opts.adaptive {
+		fmt.Fprintf(&b, "# refresh interval: adaptive, %s to %s\n", opts.adaptiveMin, opts.adaptiveMax)
+	} else {
+		fmt.Fprintf(&b, "# refresh interval: %s (fixed)\n", refreshInterval)
+	}
+	newFlagSet(&opts, io.Discard).VisitAll(func(f *flag.Flag) {
+		if !slices.Contains(exportSkipFlags, f.Name) {
+			fmt.Fprintf(&b, "%s: %s\n", f.Name, yamlScalar(f.Value.String()))
+		}
+	})
+	b.WriteString("prefs:\n")
+	fmt.Fprintf(&b, "  cat_hidden: %t\n", prefs.catHidden)
+	hidden := make([]string, len(prefs.hiddenIfaces))
+	for i, name := range prefs.hiddenIfaces {
+		hidden[i] = yamlScalar(name)
+	}
+	fmt.Fprintf(&b, "  hidden_ifaces: [%s]\n", strings.Join(hidden, ", "))
+	pinned := make([]string, len(prefs.pinnedIfaces))
+	for i, name := range prefs.pinnedIfaces {
+		pinned[i] = yamlScalar(name)
+	}
+	fmt.Fprintf(&b, "  pinned_ifaces: [%s]\n", strings.Join(pinned, ", "))
+	var thresholds []string
+	for _, iface := range slices.Sorted(maps.Keys(prefs.thresholds)) {
+		var levels []string
+		for level := range strings.SplitSeq(prefs.thresholds[iface].String(), ",") {
+			if key, value, ok := strings.Cut(level, "="); ok {
+				levels = append(levels, key+": "+value)
+			}
+		}
+		thresholds = append(thresholds, yamlScalar(iface)+": {"+strings.Join(levels, ", ")+"}")
+	}
+	fmt.Fprintf(&b, "  thresholds: {%s}\n", strings.Join(thresholds, ", "))
+	layouts := make([]string, len(prefs.layouts))
+	for i, l := range prefs.layouts {
+		layouts[i] = yamlScalar(l.name) + ": " + yamlScalar(l.spec)
+	}
+	fmt.Fprintf(&b, "  layouts: {%s}\n", strings.Join(layouts, ", "))
+	groups := make([]string, len(prefs.groups))
+	for i, g := range prefs.groups {
+		groups[i] = yamlScalar(g.name) + ": " + yamlScalar(g.pattern)
+	}
+	fmt.Fprintf(&b, "  groups: {%s}\n", strings.Join(groups, ", "))
+	fmt.Fprintf(&b, "  layout: %s\n", yamlScalar(prefs.layout))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yamlScalar leaves plain words, numbers and durations bare and quotes the
+// rest. Colons are always quoted: YAML 1.1 reads 22:00 as a base-60 number.
+func yamlScalar(s string) string {
+	plain := s != ""
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._/+=,-", r)) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return s
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOptionsDefaults(t *testing.T) {
+	opts, err := parseOptions(nil, io.Discard)
+	if err != nil {
+		t.Fatalf("parseOptions() error = %v", err)
+	}
+	if opts.precision != -1 {
+		t.Errorf("default precision = %d, want -1", opts.precision)
+	}
+}
+
+func TestParseOptionsPrecision(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{[]string{"--precision", "0"}, 0, false},
+		{[]string{"--precision=3"}, 3, false},
+		{[]string{"--precision", "4"}, 0, true},
+		{[]string{"--precision", "-2"}, 0, true},
+		{[]string{"extra"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		opts, err := parseOptions(tt.args, io.Discard)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseOptions(%v) expected error", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseOptions(%v) error = %v", tt.args, err)
+			continue
+		}
+		if opts.precision != tt.want {
+			t.Errorf("parseOptions(%v).precision = %d, want %d", tt.args, opts.precision, tt.want)
+		}
+	}
+}
+
+func TestParseOptionsSummaryFields(t *testing.T) {
+	opts, err := parseOptions([]string{"--summary", "cpu, mem"}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseOptions() error = %v", err)
+	}
+	if len(opts.summaryFields) != 2 || opts.summaryFields[1] != "mem" {
+		t.Fatalf("summaryFields = %v, want [cpu mem]", opts.summaryFields)
+	}
+
+	opts, err = parseOptions([]string{"--summary", "none"}, io.Discard)
+	if err != nil || opts.summaryFields != nil {
+		t.Fatalf("--summary none = %v, %v; want nil fields", opts.summaryFields, err)
+	}
+
+	if _, err := parseOptions([]string{"--summary", "cpu,bogus"}, io.Discard); err == nil {
+		t.Fatalf("expected error for unknown summary field")
+	}
+}
+
+func TestParseOptionsNetColumns(t *testing.T) {
+	opts, err := parseOptions(nil, io.Discard)
+	if err != nil || !slices.Equal(opts.netColumns, defaultNetColumns) {
+		t.Fatalf("default netColumns = %v, %v; want %v", opts.netColumns, err, defaultNetColumns)
+	}
+	opts, err = parseOptions([]string{"--net-columns", "name, util,rx"}, io.Discard)
+	if err != nil || !slices.Equal(opts.netColumns, []string{"name", "util", "rx"}) {
+		t.Fatalf("--net-columns = %v, %v; want [name util rx]", opts.netColumns, err)
+	}
+	for _, bad := range []string{"", "name,speed", "rx,rx"} {
+		if _, err := parseOptions([]string{"--net-columns", bad}, io.Discard); err == nil {
+			t.Errorf("--net-columns %q: expected an error", bad)
+		}
+	}
+}
+
+func TestParseCollectorIntervals(t *testing.T) {
+	got, err := parseCollectorIntervals("connections=10s, disks=1m")
+	if err != nil {
+		t.Fatalf("parseCollectorIntervals() error = %v", err)
+	}
+	if got[collectorConnections] != 10*time.Second || got[collectorDisks] != time.Minute {
+		t.Fatalf("parseCollectorIntervals() = %v", got)
+	}
+
+	for _, bad := range []string{"bogus=1s", "connections", "disks=soon", "disks=-1s"} {
+		if _, err := parseCollectorIntervals(bad); err == nil {
+			t.Errorf("parseCollectorIntervals(%q) expected error", bad)
+		}
+	}
+}
+
+func TestParseOptionsVersion(t *testing.T) {
+	for _, args := range [][]string{{"--version"}, {"version"}} {
+		opts, err := parseOptions(args, io.Discard)
+		if err != nil {
+			t.Fatalf("parseOptions(%v) error = %v", args, err)
+		}
+		if !opts.showVersion {
+			t.Errorf("parseOptions(%v) showVersion = false", args)
+		}
+	}
+	if !strings.HasPrefix(formatVersion(), "mo status "+Version+"\n") {
+		t.Errorf("formatVersion() = %q, want version on the first line", formatVersion())
+	}
+}
+
+func TestParseOptionsAgent(t *testing.T) {
+	for _, args := range [][]string{{"agent", "--listen", ":9100"}, {"--listen", ":9100", "agent"}} {
+		opts, err := parseOptions(args, io.Discard)
+		if err != nil || !opts.agent || opts.listenAddr != ":9100" {
+			t.Errorf("parseOptions(%v) = agent %v, listen %q, %v", args, opts.agent, opts.listenAddr, err)
+		}
+	}
+	for _, args := range [][]string{{"agent"}, {"agent", "--listen", ":9100", "--json"}} {
+		if _, err := parseOptions(args, io.Discard); err == nil {
+			t.Errorf("parseOptions(%v) expected error", args)
+		}
+	}
+}
+
+func TestParseOptionsDoctor(t *testing.T) {
+	opts, err := parseOptions([]string{"doctor"}, io.Discard)
+	if err != nil || !opts.doctor {
+		t.Fatalf("parseOptions(doctor) = %+v, %v", opts, err)
+	}
+	if _, err := parseOptions([]string{"doctor", "extra"}, io.Discard); err == nil {
+		t.Fatalf("parseOptions(doctor extra) expected error")
+	}
+
+	out := stripANSI(formatDoctor([]doctorCheck{
+		{name: "CPU", result: checkPass, detail: "usage readable"},
+		{name: "nvidia-smi", result: checkWarn, detail: "not found; no NVIDIA GPU"},
+		{name: "Network", result: checkFail, detail: "no interface counters"},
+	}))
+	for _, want := range []string{"[pass] CPU", "[warn] nvidia-smi", "[fail] Network          no interface counters"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("formatDoctor() missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestParseOptionsHistory(t *testing.T) {
+	opts, err := parseOptions([]string{"--history", "300"}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseOptions() error = %v", err)
+	}
+	c := opts.newCollector()
+	if c.cpuHistoryBuf.cap != 300 || c.rxHistoryBuf.cap != 300 {
+		t.Errorf("history buffers = %d/%d, want 300", c.cpuHistoryBuf.cap, c.rxHistoryBuf.cap)
+	}
+	if _, err := parseOptions([]string{"--history", "1"}, io.Discard); err == nil {
+		t.Error("parseOptions(--history 1) expected error")
+	}
+}
+
+func TestExportConfig(t *testing.T) {
+	opts, err := parseOptions([]string{"--history", "300", "--disk-sort", "free", "--collector-interval", "disks=1m"}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseOptions() error = %v", err)
+	}
+	var b strings.Builder
+	if err := exportConfig(&b, opts, statusPrefs{hiddenIfaces: []string{"bridge0"}, quietHours: "22:00-08:00"}); err != nil {
+		t.Fatalf("exportConfig() error = %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"history: 300\n",
+		"disk-sort: free\n",
+		"zombie-threshold: 5\n", // Defaults are included.
+		"collector-interval: app-proxies=30s,arp=30s,connections=5s,disks=1m0s,",
+		`quiet-hours: "22:00-08:00"` + "\n", // Merged from prefs, quoted for YAML 1.1.
+		"  hidden_ifaces: [bridge0]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("exportConfig() missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "export-config:") || strings.Contains(out, "json:") {
+		t.Errorf("exportConfig() should skip one-off mode flags:\n%s", out)
+	}
+}
+
+func TestParseOptionsAdaptive(t *testing.T) {
+	opts, err := parseOptions([]string{"--adaptive", "--adaptive-max", "30s"}, io.Discard)
+	if err != nil || !opts.adaptive || opts.adaptiveMin != refreshInterval || opts.adaptiveMax != 30*time.Second {
+		t.Fatalf("parseOptions(--adaptive) = %+v, %v", opts, err)
+	}
+	for _, args := range [][]string{
+		{"--adaptive", "--adaptive-min", "5s", "--adaptive-max", "2s"},
+		{"--adaptive", "--adaptive-min", "0s"},
+		{"--adaptive", "--steady-rates"},
+	} {
+		if _, err := parseOptions(args, io.Discard); err == nil {
+			t.Errorf("parseOptions(%v) expected error", args)
+		}
+	}
+}
+
+func TestParseOptionsOnceInterval(t *testing.T) {
+	opts, err := parseOptions([]string{"--json", "--once-interval", "250ms"}, io.Discard)
+	if err != nil || opts.onceInterval != 250*time.Millisecond {
+		t.Fatalf("parseOptions(--once-interval 250ms) = %v, %v", opts.onceInterval, err)
+	}
+	for _, gap := range []string{"0s", "-1s"} {
+		if _, err := parseOptions([]string{"--once-interval", gap}, io.Discard); err == nil {
+			t.Errorf("parseOptions(--once-interval %s) expected error", gap)
+		}
+	}
+}
+
+func TestParseOptionsSpeedtestTime(t *testing.T) {
+	if opts, err := parseOptions([]string{"--speedtest-time", "30s"}, io.Discard); err != nil || opts.speedtestTime != 30*time.Second {
+		t.Fatalf("parseOptions(--speedtest-time 30s) = %v, %v", opts.speedtestTime, err)
+	}
+	for _, d := range []string{"0s", "2m"} {
+		if _, err := parseOptions([]string{"--speedtest-time", d}, io.Discard); err == nil {
+			t.Errorf("parseOptions(--speedtest-time %s) expected error", d)
+		}
+	}
+}
+
+func TestHiddenFlags(t *testing.T) {
+	var help strings.Builder
+	if _, err := parseOptions([]string{"--help"}, &help); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("--help error = %v", err)
+	}
+	if strings.Contains(help.String(), "debug-net") || !strings.Contains(help.String(), "-log-events string") {
+		t.Errorf("help should list the flags except --debug-net:\n%s", help.String())
+	}
+	if opts, err := parseOptions([]string{"--debug-net", "/tmp/trace.jsonl"}, io.Discard); err != nil || opts.debugNet != "/tmp/trace.jsonl" {
+		t.Errorf("--debug-net = %+v, %v, want it accepted", opts.debugNet, err)
+	}
+}
+
+func TestDryRunCheck(t *testing.T) {
+	dir := t.TempDir()
+	ifaces := []string{"lo", "eth0"}
+	opts := defaultOptions()
+	opts.listenAddr = "127.0.0.1:9100"
+	opts.logCSV = filepath.Join(dir, "rates.csv")
+	opts.totalsExclude = []string{"eth0"}
+	if problems, warnings := dryRunCheck(opts, "hidden_ifaces=lo\nthresholds.eth0=warn_rx=50,crit_rx=100\n", ifaces); len(problems)+len(warnings) > 0 {
+		t.Fatalf("clean config: problems %q, warnings %q", problems, warnings)
+	}
+
+	opts.listenAddr = "localhost"
+	opts.logCSV = filepath.Join(dir, "missing", "rates.csv")
+	opts.totalsExclude = []string{"wg0"}
+	prefs := "# comment\ncat_hidden=maybe\nnonsense\ncolour=red\nthresholds.eth0=fast\nlayout=compact\npinned_ifaces=tun0\n"
+	problems, warnings := dryRunCheck(opts, prefs, ifaces)
+	for _, want := range []string{"--listen", "--log-csv", "line 2: cat_hidden", "line 3", `line 4: unknown key "colour"`, "line 5: thresholds.eth0", "layout=compact", `"wg0"`} {
+		if !slices.ContainsFunc(problems, func(p string) bool { return strings.Contains(p, want) }) {
+			t.Errorf("no problem mentions %q in %q", want, problems)
+		}
+	}
+	if len(problems) != 8 {
+		t.Errorf("problems = %q, want 8", problems)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `"tun0"`) {
+		t.Errorf("warnings = %q, want tun0 only", warnings)
+	}
+	// Without a local interface list nothing is checked against it.
+	if problems, _ := dryRunCheck(defaultOptions(), "", nil); len(problems) != 0 {
+		t.Errorf("defaults: problems %q", problems)
+	}
+}
+
+func TestRunOneOffBeforeSource(t *testing.T) {
+	record := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(record, []byte("kept\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	opts, err := parseOptions([]string{"--version", "--record", record}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ran, err := runOneOff(&out, opts); !ran || err != nil || out.Len() == 0 {
+		t.Fatalf("runOneOff(--version) = %v, %v, output %q", ran, err, out.String())
+	}
+	if data, _ := os.ReadFile(record); string(data) != "kept\n" {
+		t.Errorf("--record file = %q, want it untouched", data)
+	}
+	opts, err = parseOptions([]string{"--export-config", "--record", record, "--listen", "127.0.0.1:1"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if ran, err := runOneOff(&out, opts); !ran || err != nil || !strings.Contains(out.String(), `listen: "127.0.0.1:1"`) {
+		t.Fatalf("runOneOff(--export-config) = %v, %v, output:\n%s", ran, err, out.String())
+	}
+	if data, _ := os.ReadFile(record); string(data) != "kept\n" {
+		t.Errorf("--record file = %q after --export-config, want it untouched", data)
+	}
+	if ran, _ := runOneOff(io.Discard, defaultOptions()); ran {
+		t.Errorf("runOneOff(defaults) ran, want the dashboard left to main")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// oneShotDelay separates the two samples a one-shot run takes so that
+// delta-based rates (network, disk I/O) are populated; --once-interval
+// changes it.
+const oneShotDelay = time.Second
+
+// isTerminal reports whether f is attached to a character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// collectOnce takes two samples gap apart and returns the second. The rates
+// average over gap: a longer one smooths out bursts at the cost of a slower
+// answer.
+func collectOnce(c snapshotSource, gap time.Duration) (MetricsSnapshot, error) {
+	if _, err := c.Collect(); err != nil {
+		return MetricsSnapshot{}, err
+	}
+	time.Sleep(gap)
+	return c.Collect()
+}
+
+// runJSON prints a single snapshot as JSON, indented or on one line.
+func runJSON(w io.Writer, collector snapshotSource, gap, bucket time.Duration, compact bool) error {
+	snapshot, err := collectOnce(collector, gap)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, exportSnapshot(snapshot, bucket), compact)
+}
+
+// writeJSON encodes snapshot indented for reading, or compact as a single
+// line for piping. Both keep the struct's field order and sort map keys.
+func writeJSON(w io.Writer, snapshot MetricsSnapshot, compact bool) error {
+	enc := json.NewEncoder(w)
+	if !compact {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(snapshot)
+}
+
+// runFlat prints a single snapshot as sorted key=value lines for --flat.
+func runFlat(w io.Writer, collector snapshotSource, gap, bucket time.Duration) error {
+	snapshot, err := collectOnce(collector, gap)
+	if err != nil {
+		return err
+	}
+	snapshot = exportSnapshot(snapshot, bucket)
+	lines, err := formatFlat(snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// runOnce prints a single --line summary for --once.
+func runOnce(w io.Writer, collector snapshotSource, gap time.Duration) error {
+	snapshot, err := collectOnce(collector, gap)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, formatLine(snapshot))
+	return err
+}
+
+// formatFlat flattens every field of the JSON snapshot into dotted keys, so
+// the namespace always matches --json: network.en0.rx_rate_mbs=1.5. List
+// entries are keyed by their name when each has a distinct one, otherwise by
+// position; dots inside a name become underscores so cut -d. still works.
+func formatFlat(s MetricsSnapshot) ([]string, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber() // Keep counters exact instead of rounding through float64.
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	var lines []string
+	flattenJSON("", tree, &lines)
+	sort.Strings(lines)
+	return lines, nil
+}
+
+func flattenJSON(prefix string, v any, lines *[]string) {
+	join := func(key string) string {
+		key = strings.ReplaceAll(key, ".", "_")
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for key, child := range v {
+			flattenJSON(join(key), child, lines)
+		}
+	case []any:
+		keys := flatListKeys(v)
+		for i, child := range v {
+			flattenJSON(join(keys[i]), child, lines)
+		}
+	case nil:
+		// Absent values print nothing, like omitted JSON fields.
+	default:
+		value := fmt.Sprint(v)
+		value = strings.NewReplacer("\n", " ", "\r", " ").Replace(value)
+		*lines = append(*lines, prefix+"="+value)
+	}
+}
+
+// flatListKeys names list entries by their "name" field when every entry has
+// a distinct one, and by index otherwise.
+func flatListKeys(list []any) []string {
+	keys := make([]string, len(list))
+	seen := make(map[string]bool, len(list))
+	for i, item := range list {
+		obj, _ := item.(map[string]any)
+		name, _ := obj["name"].(string)
+		if name == "" || seen[name] {
+			for j := range keys {
+				keys[j] = strconv.Itoa(j)
+			}
+			return keys
+		}
+		seen[name] = true
+		keys[i] = name
+	}
+	return keys
+}
+
+// runLine prints format's rendering of each refresh (formatLine for --line,
+// formatInflux for --influx-lp) until interrupted, until duration has passed,
+// or until samples lines are out, whichever comes first; zero leaves either
+// unlimited. The first sample only sets the rate baseline, so it is neither
+// printed nor counted. It never touches terminal modes, so it is safe for
+// pipes and CI logs.
+func runLine(w io.Writer, collector snapshotSource, snapshots *snapshotWriter, duration time.Duration, samples int, format func(MetricsSnapshot) string) error {
+	var deadline time.Time
+	if duration > 0 {
+		deadline = time.Now().Add(duration)
+	}
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for printed := -1; ; printed++ {
+		snapshot, err := collector.Collect()
+		if werr := snapshots.maybeWrite(snapshot); werr != nil && err == nil {
+			err = werr
+		}
+		// The first sample has no rate baseline yet; skip it.
+		if printed >= 0 {
+			if _, werr := fmt.Fprintln(w, format(snapshot)); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mo status: %v\n", err)
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return nil
+		}
+		if samples > 0 && printed+1 >= samples {
+			return nil
+		}
+		<-ticker.C
+	}
+}
+
+// runAgent samples like runLine but prints nothing: the samples only reach
+// the sinks newSource set up (--listen, --statsd) and the snapshot files.
+// Collection errors still go to stderr.
+func runAgent(collector snapshotSource, snapshots *snapshotWriter, duration time.Duration, samples int) error {
+	return runLine(io.Discard, collector, snapshots, duration, samples, func(MetricsSnapshot) string { return "" })
+}
+
+// formatLine renders the headline metrics of a snapshot on one line.
+func formatLine(s MetricsSnapshot) string {
+	var rx, tx float64
+	for _, n := range s.Network {
+		if !n.inTotals() {
+			continue
+		}
+		rx += n.RxRateMBs
+		tx += n.TxRateMBs
+	}
+	parts := []string{
+		s.CollectedAt.Format("15:04:05"),
+		fmt.Sprintf("health %d", s.HealthScore),
+		"cpu " + strings.TrimSpace(formatPercent(s.CPU.Usage)),
+		"mem " + strings.TrimSpace(formatPercent(s.Memory.UsedPercent)),
+		"down " + formatRate(rx),
+		"up " + formatRate(tx),
+		"read " + formatIORate(s.DiskIO.ReadRate),
+		"write " + formatIORate(s.DiskIO.WriteRate),
+	}
+	if s.Proxy.Enabled {
+		parts = append(parts, "proxy "+s.Proxy.Type)
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatLine(t *testing.T) {
+	s := MetricsSnapshot{
+		CollectedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local),
+		HealthScore: 92,
+		CPU:         CPUStatus{Usage: 12.34},
+		Memory:      MemoryStatus{UsedPercent: 45.6},
+		Network: []NetworkStatus{
+			{Name: "en0", RxRateMBs: 0.5, TxRateMBs: 0.02},
+			{Name: "en5", RxRateMBs: 0.25},
+		},
+		DiskIO: DiskIOStatus{ReadRate: 1.5},
+		Proxy:  ProxyStatus{Enabled: true, Type: "SOCKS"},
+	}
+
+	want := "03:04:05  health 92  cpu 12.3%  mem 45.6%  down 0.75 MB/s  up 0.02 MB/s  read 1.5 MB/s  write 0.0 MB/s  proxy SOCKS"
+	if got := formatLine(s); got != want {
+		t.Fatalf("formatLine() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	snap := MetricsSnapshot{CollectedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local), Network: []NetworkStatus{{Name: "en0", RxRateMBs: 2}}}
+	var out strings.Builder
+	if err := runOnce(&out, fixedSource{snap}, time.Millisecond); err != nil {
+		t.Fatalf("runOnce() error = %v", err)
+	}
+	if got := out.String(); got != formatLine(snap)+"\n" || strings.Count(got, "\n") != 1 {
+		t.Errorf("runOnce() = %q, want one --line summary", got)
+	}
+	if _, err := parseOptions([]string{"--once", "--json"}, io.Discard); err == nil {
+		t.Error("parseOptions(--once --json) expected error")
+	}
+}
+
+func TestFormatFlat(t *testing.T) {
+	s := MetricsSnapshot{
+		Host:    "box",
+		CPU:     CPUStatus{Usage: 12.5},
+		Network: []NetworkStatus{{Name: "en0", RxRateMBs: 1.5, RxBytes: 1 << 62}, {Name: "eth0.100"}},
+		Disks:   []DiskStatus{{Mount: "/"}, {Mount: "/data"}},
+	}
+	lines, err := formatFlat(s)
+	if err != nil {
+		t.Fatalf("formatFlat() error = %v", err)
+	}
+	for _, want := range []string{
+		"host=box",
+		"cpu.usage=12.5",
+		"network.en0.rx_rate_mbs=1.5",
+		"network.en0.rx_bytes=4611686018427387904",
+		"network.eth0_100.tx_rate_mbs=0",
+		"disks.1.mount=/data",
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("formatFlat() missing %q in %q", want, lines)
+		}
+	}
+	if !slices.IsSorted(lines) {
+		t.Errorf("formatFlat() lines are not sorted")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	s := MetricsSnapshot{
+		SchemaVersion: snapshotSchemaVersion,
+		CollectedAt:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Network:       []NetworkStatus{{Name: "en0", RxRateMBs: 1.5}},
+	}
+	var pretty, compact bytes.Buffer
+	if err := writeJSON(&pretty, s, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSON(&compact, s, true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(pretty.String(), "\n  \"schema_version\"") {
+		t.Errorf("pretty JSON should be indented:\n%s", pretty.String())
+	}
+	if strings.Count(compact.String(), "\n") != 1 || !strings.HasPrefix(compact.String(), `{"schema_version":`) {
+		t.Errorf("compact JSON should be one line:\n%s", compact.String())
+	}
+	// Same fields in the same order: only the whitespace differs.
+	var squeezed bytes.Buffer
+	if err := json.Compact(&squeezed, pretty.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if squeezed.String()+"\n" != compact.String() {
+		t.Errorf("pretty and compact JSON differ beyond whitespace:\n%s\n%s", squeezed.String(), compact.String())
+	}
+}
+
+func TestSpeedtest(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go serveSpeedtest(ln)
+
+	for _, direction := range []string{"download", "upload"} {
+		var progressed bool
+		r, err := speedtestTCP(ln.Addr().String(), direction, 200*time.Millisecond, func(int64) { progressed = true })
+		if err != nil || r.bytes == 0 || r.rate() <= 0 || !progressed {
+			t.Fatalf("%s: result %+v, err %v, progress %v", direction, r, err, progressed)
+		}
+	}
+
+	// With every slot taken the server refuses instead of queueing.
+	for range speedtestMaxConns {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, speedtestHello+"upload\n")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := speedtestTCP(ln.Addr().String(), "download", 200*time.Millisecond, func(int64) {}); err == nil {
+		t.Errorf("a busy server should refuse the test")
+	}
+
+	body := bytes.Repeat([]byte("x"), 3<<20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.Write(body) }))
+	defer srv.Close()
+	var out strings.Builder
+	if err := runSpeedtest(&out, false, srv.URL, 2*time.Second); err != nil {
+		t.Fatalf("runSpeedtest(http) error = %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "download ") || !strings.Contains(got, "(3.0 MB in ") || !strings.Contains(got, "upload   skipped") {
+		t.Errorf("runSpeedtest(http) output:\n%s", got)
+	}
+
+	if _, err := speedtestTCP(srv.Listener.Addr().String(), "download", 200*time.Millisecond, func(int64) {}); err == nil {
+		t.Errorf("a server that does not speak the protocol should fail the test")
+	}
+}
+
+func TestRunSpark(t *testing.T) {
+	snap := MetricsSnapshot{
+		Network:        []NetworkStatus{{Name: "en0", RxHistory: []float64{0, 2, 4, 8}, TxHistory: []float64{0, 0, 1, 2}}},
+		NetworkHistory: NetworkHistory{RxHistory: []float64{8}, TxHistory: []float64{2}},
+	}
+	var out strings.Builder
+	// The source's history covers the width, so it answers without waiting.
+	if err := runSpark(&out, fixedSource{snap}, "en0", 4, time.Minute); err != nil {
+		t.Fatalf("runSpark() error = %v", err)
+	}
+	if got := out.String(); got != "↓▁▂▄█ ↑▁▁▁▂\n" {
+		t.Errorf("runSpark(en0) = %q", got)
+	}
+	if got := plainSparkLine(snap.NetworkHistory.RxHistory, snap.NetworkHistory.TxHistory, 3); got != "↓▁▁█ ↑▁▁▂" {
+		t.Errorf("plainSparkLine(totals) = %q, want the short history padded", got)
+	}
+	if err := runSpark(io.Discard, fixedSource{snap}, "wg0", 4, time.Minute); err == nil || !strings.Contains(err.Error(), "have en0") {
+		t.Errorf("runSpark(wg0) error = %v, want the interfaces listed", err)
+	}
+}
+
+func stripANSI(s string) string {
+	var result strings.Builder
+	i := 0
+	for i < len(s) {
+		if i < len(s)-1 && s[i] == '\x1b' && s[i+1] == '[' {
+			i += 2
+			for i < len(s) && (s[i] < 'A' || s[i] > 'Z') && (s[i] < 'a' || s[i] > 'z') {
+				i++
+			}
+			if i < len(s) {
+				i++
+			}
+		} else {
+			result.WriteByte(s[i])
+			i++
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// statusPrefs are UI choices persisted across runs.
+type statusPrefs struct {
+	catHidden    bool
+	hiddenIfaces []string
+	pinnedIfaces []string
+	quietHours   string                    // Raw quiet_hours value; --quiet-hours overrides it.
+	thresholds   map[string]rateThresholds // Per-interface rate colors, from thresholds.<iface>= lines.
+	layouts      []dashboardLayout         // From layout.<name>= lines, in file order.
+	layout       string                    // Name of the layout last switched to with L.
+	groups       []ifaceGroupSpec          // From group.<name>= lines, in file order.
+}
+
+// getConfigPath returns the path to the status preferences file.
+func getConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "mole", "status_prefs")
+}
+
+// loadPrefs reads the key=value preferences file; missing keys keep defaults.
+func loadPrefs() statusPrefs {
+	path := getConfigPath()
+	if path == "" {
+		return statusPrefs{}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return statusPrefs{}
+	}
+	return parsePrefs(string(data))
+}
+
+func parsePrefs(data string) statusPrefs {
+	var prefs statusPrefs
+	for line := range strings.Lines(data) {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		key = strings.TrimSpace(key)
+		if iface, ok := strings.CutPrefix(key, "thresholds."); ok && iface != "" {
+			// An invalid entry is skipped like any other unknown value.
+			if t, err := parseRateThresholds(value); err == nil {
+				if prefs.thresholds == nil {
+					prefs.thresholds = make(map[string]rateThresholds)
+				}
+				prefs.thresholds[iface] = t
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(key, "layout."); ok && name != "" {
+			if l, err := parseLayout(name, value); err == nil {
+				prefs.layouts = append(prefs.layouts, l)
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(key, "group."); ok && name != "" {
+			if g, err := parseIfaceGroup(name, value); err == nil {
+				prefs.groups = append(prefs.groups, g)
+			}
+			continue
+		}
+		switch key {
+		case "cat_hidden":
+			prefs.catHidden = value == "true"
+		case "hidden_ifaces":
+			prefs.hiddenIfaces = splitList(value)
+		case "pinned_ifaces":
+			prefs.pinnedIfaces = splitList(value)
+		case "quiet_hours":
+			prefs.quietHours = value
+		case "layout":
+			prefs.layout = value
+		}
+	}
+	return prefs
+}
+
+func formatPrefs(prefs statusPrefs) string {
+	var b strings.Builder
+	if prefs.catHidden {
+		b.WriteString("cat_hidden=true\n")
+	} else {
+		b.WriteString("cat_hidden=false\n")
+	}
+	if len(prefs.hiddenIfaces) > 0 {
+		b.WriteString("hidden_ifaces=" + strings.Join(prefs.hiddenIfaces, ",") + "\n")
+	}
+	if len(prefs.pinnedIfaces) > 0 {
+		b.WriteString("pinned_ifaces=" + strings.Join(prefs.pinnedIfaces, ",") + "\n")
+	}
+	if prefs.quietHours != "" {
+		b.WriteString("quiet_hours=" + prefs.quietHours + "\n")
+	}
+	for _, iface := range slices.Sorted(maps.Keys(prefs.thresholds)) {
+		b.WriteString("thresholds." + iface + "=" + prefs.thresholds[iface].String() + "\n")
+	}
+	for _, l := range prefs.layouts {
+		b.WriteString("layout." + l.name + "=" + l.spec + "\n")
+	}
+	if prefs.layout != "" {
+		b.WriteString("layout=" + prefs.layout + "\n")
+	}
+	for _, g := range prefs.groups {
+		b.WriteString("group." + g.name + "=" + g.pattern + "\n")
+	}
+	return b.String()
+}
+
+// savePrefs writes the preferences file, creating its directory if needed.
+func savePrefs(prefs statusPrefs) {
+	path := getConfigPath()
+	if path == "" {
+		return
+	}
+	// Ensure directory exists
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, []byte(formatPrefs(prefs)), 0644)
+}
+
+// splitList parses a comma-separated list, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func toSet(items []string) map[string]bool {
+	if len(items) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k, v := range set {
+		if v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
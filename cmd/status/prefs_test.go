@@ -0,0 +1,62 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParsePrefsLegacyCatHidden(t *testing.T) {
+	prefs := parsePrefs("cat_hidden=true\n")
+	if !prefs.catHidden {
+		t.Fatalf("legacy cat_hidden=true should be honored")
+	}
+	if len(prefs.hiddenIfaces) != 0 {
+		t.Fatalf("unexpected hidden interfaces %v", prefs.hiddenIfaces)
+	}
+}
+
+func TestPrefsRoundTrip(t *testing.T) {
+	in := statusPrefs{catHidden: false, hiddenIfaces: []string{"bridge0", "en5"}, pinnedIfaces: []string{"en0"}, quietHours: "22:00-08:00",
+		thresholds: map[string]rateThresholds{"en0": {warnRx: 50, critRx: 100}, "utun3": {warnTx: 2.5}}}
+	out := parsePrefs(formatPrefs(in))
+	if out.catHidden != in.catHidden || !slices.Equal(out.hiddenIfaces, in.hiddenIfaces) || !slices.Equal(out.pinnedIfaces, in.pinnedIfaces) || out.quietHours != in.quietHours || !maps.Equal(out.thresholds, in.thresholds) {
+		t.Fatalf("round trip = %+v, want %+v", out, in)
+	}
+}
+
+func TestPrefsLayouts(t *testing.T) {
+	prefs := parsePrefs("layout.net=panels=network,connections iface_graphs=own min_rate=0.01\n" +
+		"layout.bad=disk_sort=sideways\n" +
+		"layout.all=panels=all disk_sort=free group=true procs=cpu\n" +
+		"layout=all\n")
+	if len(prefs.layouts) != 2 || prefs.layouts[0].name != "net" || prefs.layouts[1].name != "all" || prefs.layout != "all" {
+		t.Fatalf("layouts = %+v (active %q), want net and all in file order, bad skipped", prefs.layouts, prefs.layout)
+	}
+	net, all := prefs.layouts[0], prefs.layouts[1]
+	if !slices.Equal(net.panels, []string{"network", "connections"}) || *net.ifaceGraphs != ifaceGraphsOwn || *net.minRate != 0.01 || net.diskSort != nil {
+		t.Errorf("net = %+v", net)
+	}
+	if all.panels != nil || *all.diskSort != diskByFree || !*all.groupByKind || !*all.procsByCPU {
+		t.Errorf("all = %+v", all)
+	}
+	if out := parsePrefs(formatPrefs(prefs)); len(out.layouts) != 2 || out.layouts[0].spec != net.spec || out.layout != "all" {
+		t.Errorf("round trip = %+v", out)
+	}
+	for _, spec := range []string{"panels", "group=yes", "min_rate=-1", "colour=red"} {
+		if _, err := parseLayout("x", spec); err == nil {
+			t.Errorf("parseLayout(%q) should fail", spec)
+		}
+	}
+}
+
+func TestPrefsGroups(t *testing.T) {
+	prefs := parsePrefs("group.VPN total=^wg\ngroup.bad=wg(\ngroup.empty=\ngroup.docker=^(veth|br-)\n")
+	want := []ifaceGroupSpec{{"VPN total", "^wg"}, {"docker", "^(veth|br-)"}}
+	if !slices.Equal(prefs.groups, want) {
+		t.Fatalf("groups = %+v, want %+v with bad and empty skipped", prefs.groups, want)
+	}
+	if out := parsePrefs(formatPrefs(prefs)); !slices.Equal(out.groups, want) {
+		t.Errorf("round trip = %+v", out.groups)
+	}
+}
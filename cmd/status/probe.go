@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backgroundProbe runs a slow check off the refresh path: poll returns the
+// latest result at once and, when the previous run has finished and every has
+// elapsed, starts the next one in a goroutine. mu guards everything below it,
+// since the goroutine reports back while a refresh may be reading.
+type backgroundProbe[T any] struct {
+	every time.Duration
+
+	mu     sync.Mutex
+	lastAt time.Time
+	busy   bool
+	done   bool // A result has arrived since the last reset.
+	last   T
+}
+
+// poll returns the latest result, and false until the first one arrives.
+func (p *backgroundProbe[T]) poll(now time.Time, run func() T) (T, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.busy && (p.lastAt.IsZero() || now.Sub(p.lastAt) >= p.every) {
+		p.busy = true
+		p.lastAt = now
+		go func() {
+			v := run()
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.last, p.done, p.busy = v, true, false
+		}()
+	}
+	return p.last, p.done
+}
+
+// reset drops the result so the next poll probes again right away, e.g. when
+// the thing being probed changed. A run still in flight lands afterwards.
+func (p *backgroundProbe[T]) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastAt = time.Time{}
+	p.done = false
+}
+
+const proxyCheckTimeout = 3 * time.Second
+
+// proxyCheckResult is one --proxy-check result: whether target accepted a TCP connection.
+type proxyCheckResult struct {
+	target string
+	ok     bool
+}
+
+// proxyCheckSnapshot fills in whether the primary proxy accepts connections,
+// probed in the background; Checking stays set until the first answer for the
+// current proxy arrives. PAC, WPAD and TUN entries have no address to dial.
+func (c *Collector) proxyCheckSnapshot(now time.Time, proxy *ProxyStatus) {
+	target := proxyDialTarget(*proxy)
+	if target == "" {
+		return
+	}
+	if target != c.proxyTarget {
+		c.proxyTarget = target
+		c.proxyProbe.reset()
+	}
+	res, ok := c.proxyProbe.poll(now, func() proxyCheckResult {
+		conn, err := proxyDial("tcp", target, proxyCheckTimeout)
+		if err == nil {
+			conn.Close()
+		}
+		return proxyCheckResult{target: target, ok: err == nil}
+	})
+	if !ok || res.target != target {
+		proxy.Checking = true
+		return
+	}
+	proxy.Reachable = &res.ok
+}
+
+// proxyDial is swapped in tests.
+var proxyDial = net.DialTimeout
+
+// proxyDialTarget is host:port for a proxy that can be dialed, filling in the
+// usual port when the configuration leaves it out.
+func proxyDialTarget(p ProxyStatus) string {
+	if !p.Enabled || p.Host == "" {
+		return ""
+	}
+	port := map[string]string{"HTTP": "80", "HTTPS": "443", "SOCKS": "1080"}[p.Type]
+	if port == "" {
+		return ""
+	}
+	host := p.Host
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	host, _, _ = strings.Cut(host, "/")
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A query is one expression typed at the dashboard's : prompt:
+//
+//	net.en0.rx > 10        compare a snapshot field with a number
+//	proxy.type == HTTP     == and != also compare text
+//	cpu                    show a field's current value
+//	top cpu 5              list the top processes by cpu or mem, 1-5 rows
+//
+// Fields are the keys --flat prints (network.en0.rx_rate_mbs), with the
+// shorthands in queryAliases.
+type query struct {
+	path  string // Flat snapshot key after aliases; empty for top.
+	op    string // Comparison operator; empty shows the value.
+	value string // Right-hand side as typed.
+	top   string // "cpu" or "mem" for a top command.
+	rows  int
+}
+
+// queryOps are the comparison operators, two-character ones first so ">="
+// is not read as ">".
+var queryOps = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// queryAliases expand whole paths, then queryPrefixes the first segment and
+// querySuffixes the last one.
+var (
+	queryAliases  = map[string]string{"cpu": "cpu.usage", "mem": "memory.used_percent", "health": "health_score", "conns": "connections.total"}
+	queryPrefixes = map[string]string{"net": "network", "mem": "memory", "conn": "connections"}
+	querySuffixes = map[string]string{"rx": "rx_rate_mbs", "tx": "tx_rate_mbs"}
+)
+
+// parseQuery reads one expression; it checks the syntax only, since fields
+// are looked up in the snapshot the query runs against.
+func parseQuery(s string) (query, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return query{}, errors.New("empty query")
+	}
+	if fields := strings.Fields(s); fields[0] == "top" {
+		return parseTopQuery(fields[1:])
+	}
+	var q query
+	for _, op := range queryOps {
+		if left, right, ok := strings.Cut(s, op); ok {
+			q.path, q.op, q.value = strings.TrimSpace(left), op, strings.TrimSpace(right)
+			break
+		}
+	}
+	if q.op == "" {
+		q.path = s
+	}
+	if q.path == "" || strings.ContainsAny(q.path, " \t") {
+		return query{}, fmt.Errorf("%q is not a field", q.path)
+	}
+	if q.op != "" && q.value == "" {
+		return query{}, fmt.Errorf("nothing to compare after %s", q.op)
+	}
+	if _, err := strconv.ParseFloat(q.value, 64); err != nil && q.value != "" && q.op != "==" && q.op != "!=" {
+		return query{}, fmt.Errorf("%s needs a number, got %q", q.op, q.value)
+	}
+	q.path = expandQueryPath(q.path)
+	return q, nil
+}
+
+func parseTopQuery(args []string) (query, error) {
+	if len(args) == 0 || len(args) > 2 || (args[0] != "cpu" && args[0] != "mem") {
+		return query{}, errors.New("want top cpu|mem [rows]")
+	}
+	q := query{top: args[0], rows: memProcsTop}
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > memProcsTop {
+			return query{}, fmt.Errorf("top rows must be 1-%d, got %q", memProcsTop, args[1])
+		}
+		q.rows = n
+	}
+	return q, nil
+}
+
+func expandQueryPath(path string) string {
+	if full, ok := queryAliases[path]; ok {
+		return full
+	}
+	parts := strings.Split(path, ".")
+	if full, ok := queryPrefixes[parts[0]]; ok {
+		parts[0] = full
+	}
+	if len(parts) > 1 {
+		if full, ok := querySuffixes[parts[len(parts)-1]]; ok {
+			parts[len(parts)-1] = full
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// eval runs q against a snapshot, returning the field's value and, for a
+// comparison, whether it holds.
+func (q query) eval(s MetricsSnapshot) (value string, holds bool, err error) {
+	lines, err := formatFlat(s)
+	if err != nil {
+		return "", false, err
+	}
+	found := false
+	for _, line := range lines {
+		if key, v, _ := strings.Cut(line, "="); key == q.path {
+			value, found = v, true
+			break
+		}
+	}
+	if !found {
+		return "", false, fmt.Errorf("no field %s", q.path)
+	}
+	if q.op == "" {
+		return value, false, nil
+	}
+	got, gotErr := strconv.ParseFloat(value, 64)
+	want, wantErr := strconv.ParseFloat(q.value, 64)
+	if gotErr != nil || wantErr != nil {
+		if q.op != "==" && q.op != "!=" {
+			return value, false, fmt.Errorf("%s is not a number", q.path)
+		}
+		return value, (value == q.value) == (q.op == "=="), nil
+	}
+	switch q.op {
+	case ">":
+		holds = got > want
+	case ">=":
+		holds = got >= want
+	case "<":
+		holds = got < want
+	case "<=":
+		holds = got <= want
+	case "==":
+		holds = got == want
+	case "!=":
+		holds = got != want
+	}
+	return value, holds, nil
+}
+
+// String spells q back out with its aliases expanded.
+func (q query) String() string {
+	switch {
+	case q.top != "":
+		return fmt.Sprintf("top %s %d", q.top, q.rows)
+	case q.op == "":
+		return q.path
+	}
+	return q.path + " " + q.op + " " + q.value
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want query
+	}{
+		{"net.en0.rx > 10", query{path: "network.en0.rx_rate_mbs", op: ">", value: "10"}},
+		{"cpu>=90", query{path: "cpu.usage", op: ">=", value: "90"}},
+		{"proxy.type == HTTP", query{path: "proxy.type", op: "==", value: "HTTP"}},
+		{" mem ", query{path: "memory.used_percent"}},
+		{"top cpu 3", query{top: "cpu", rows: 3}},
+		{"top mem", query{top: "mem", rows: memProcsTop}},
+	}
+	for _, tt := range tests {
+		got, err := parseQuery(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseQuery(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "net.en0.rx >", "cpu > lots", "top disk", "top cpu 9", "two words"} {
+		if _, err := parseQuery(bad); err == nil {
+			t.Errorf("parseQuery(%q) should fail", bad)
+		}
+	}
+}
+
+func TestQueryEval(t *testing.T) {
+	snap := MetricsSnapshot{
+		CPU:     CPUStatus{Usage: 42},
+		Network: []NetworkStatus{{Name: "en0", RxRateMBs: 12.5}},
+		Proxy:   ProxyStatus{Enabled: true, Type: "HTTP"},
+	}
+	tests := []struct {
+		in    string
+		value string
+		holds bool
+	}{
+		{"net.en0.rx > 10", "12.5", true},
+		{"net.en0.rx <= 10", "12.5", false},
+		{"cpu == 42", "42", true},
+		{"proxy.type != HTTP", "HTTP", false},
+		{"cpu", "42", false},
+	}
+	for _, tt := range tests {
+		q, _ := parseQuery(tt.in)
+		value, holds, err := q.eval(snap)
+		if err != nil || value != tt.value || holds != tt.holds {
+			t.Errorf("%q = %q, %v, %v; want %q, %v", tt.in, value, holds, err, tt.value, tt.holds)
+		}
+	}
+	q, _ := parseQuery("net.en9.rx > 1")
+	if _, _, err := q.eval(snap); err == nil || !strings.Contains(err.Error(), "network.en9.rx_rate_mbs") {
+		t.Errorf("unknown field error = %v", err)
+	}
+}
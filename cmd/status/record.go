@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// recordVersion is the --record file format. The first line is a
+// recordHeader; every following line is one MetricsSnapshot as --json
+// prints it, in collection order.
+const recordVersion = 1
+
+// recordHeader opens a --record file: what wrote it and with which
+// settings, so that a replay can draw the same view.
+type recordHeader struct {
+	Record  int       `json:"mole_record"`
+	Version string    `json:"version"`
+	Started time.Time `json:"started"`
+	Config  string    `json:"config"` // The --export-config output of the recording run.
+}
+
+// sessionRecorder appends every snapshot to a --record file. A write error
+// stops the recording with one event rather than one per sample. A nil
+// *sessionRecorder, as without --record, ignores every call.
+type sessionRecorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	events  *eventRing
+	stopped bool
+}
+
+// newSessionRecorder writes the header carrying config to w.
+func newSessionRecorder(w io.Writer, config string, now time.Time) (*sessionRecorder, error) {
+	header := recordHeader{Record: recordVersion, Version: Version, Started: now, Config: config}
+	if err := json.NewEncoder(w).Encode(header); err != nil {
+		return nil, err
+	}
+	return &sessionRecorder{w: w}, nil
+}
+
+func (r *sessionRecorder) record(snap MetricsSnapshot) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(snap)
+	if err == nil {
+		_, err = r.w.Write(buf.Bytes()) // One write per sample keeps lines whole.
+	}
+	if err != nil {
+		r.stopped = true
+		r.events.add(severityWarn, categorySystem, "recording stopped: "+err.Error())
+	}
+}
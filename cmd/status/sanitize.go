@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"reflect"
+)
+
+// sanitizeSnapshot keeps impossible numbers out of the view and the JSON
+// encoder, which rejects NaN outright. Every NaN or ±Inf anywhere in the
+// snapshot becomes 0, and rates and rate histories, which a counter glitch or
+// a subtracted background can push below zero, are clamped to 0. Signed
+// readings such as battery power are left alone.
+func sanitizeSnapshot(s *MetricsSnapshot) {
+	zeroNonFinite(reflect.ValueOf(s).Elem())
+
+	clampRates(&s.DiskIO.ReadRate, &s.DiskIO.WriteRate)
+	for i := range s.Network {
+		clampRates(&s.Network[i].RxRateMBs, &s.Network[i].TxRateMBs, &s.Network[i].IdleSecs)
+		clampSeries(s.Network[i].RxHistory)
+		clampSeries(s.Network[i].TxHistory)
+	}
+	clampSeries(s.NetworkHistory.RxHistory)
+	clampSeries(s.NetworkHistory.TxHistory)
+	if c := s.Container; c != nil {
+		clampRates(&c.CPUPercent, &c.RxRateMBs, &c.TxRateMBs)
+	}
+	if t := s.TCP; t != nil {
+		clampRates(&t.RetransRate, &t.RetransPercent)
+	}
+	if f := s.IPFamilies; f != nil {
+		clampRates(&f.V4RxMBs, &f.V4TxMBs, &f.V6RxMBs, &f.V6TxMBs)
+	}
+}
+
+func clampRates(rates ...*float64) {
+	for _, r := range rates {
+		*r = max(*r, 0)
+	}
+}
+
+func clampSeries(series []float64) {
+	for i := range series {
+		series[i] = max(series[i], 0)
+	}
+}
+
+// zeroNonFinite walks structs, pointers, slices and maps, replacing NaN and
+// ±Inf float64 values with 0.
+func zeroNonFinite(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			v.SetFloat(0)
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			zeroNonFinite(v.Elem())
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if f := v.Field(i); f.CanSet() {
+				zeroNonFinite(f)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			zeroNonFinite(v.Index(i))
+		}
+	case reflect.Map:
+		// Map values are not addressable; rewrite the entries that need it.
+		if v.Type().Elem().Kind() != reflect.Float64 {
+			return
+		}
+		for _, k := range v.MapKeys() {
+			if f := v.MapIndex(k).Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+				v.SetMapIndex(k, reflect.ValueOf(0.0).Convert(v.Type().Elem()))
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sessionStats accumulates what happened during one mo status run for the exit summary.
+// Byte totals integrate the sampled rates, so they cover the interfaces the dashboard
+// reported (noise interfaces are never counted).
+type sessionStats struct {
+	start   time.Time
+	last    time.Time
+	samples int
+	cpuSum  float64
+	memSum  float64
+	peakRx  float64
+	peakTx  float64
+	ifaces  map[string]*ifaceTotals
+}
+
+type ifaceTotals struct {
+	rx, tx float64 // Bytes.
+}
+
+func newSessionStats(start time.Time) *sessionStats {
+	return &sessionStats{start: start, ifaces: make(map[string]*ifaceTotals)}
+}
+
+// add folds one snapshot into the session.
+func (s *sessionStats) add(snap MetricsSnapshot) {
+	if s == nil || snap.CollectedAt.IsZero() {
+		return
+	}
+	s.samples++
+	s.cpuSum += snap.CPU.Usage
+	s.memSum += snap.Memory.UsedPercent
+
+	var rx, tx float64
+	for _, n := range snap.Network {
+		if !n.inTotals() {
+			continue
+		}
+		rx += n.RxRateMBs
+		tx += n.TxRateMBs
+	}
+	s.peakRx = max(s.peakRx, rx)
+	s.peakTx = max(s.peakTx, tx)
+
+	// Remote snapshots arrive without a monotonic reading, so a stepped
+	// clock on the agent can make dt negative; skip those gaps.
+	if dt := snap.CollectedAt.Sub(s.last).Seconds(); !s.last.IsZero() && dt > 0 {
+		for _, n := range snap.Network {
+			if n.Bond != "" {
+				continue
+			}
+			t := s.ifaces[n.Name]
+			if t == nil && n.Renamed != "" {
+				t = s.ifaces[n.Renamed]
+				delete(s.ifaces, n.Renamed)
+			}
+			if t == nil {
+				t = &ifaceTotals{}
+			}
+			s.ifaces[n.Name] = t
+			t.rx += n.RxRateMBs * 1024 * 1024 * dt
+			t.tx += n.TxRateMBs * 1024 * 1024 * dt
+		}
+	}
+	s.last = snap.CollectedAt
+}
+
+// resume leaves the time since the last sample, a pause, out of the byte
+// totals; the next sample starts integrating afresh.
+func (s *sessionStats) resume() {
+	if s != nil {
+		s.last = time.Time{}
+	}
+}
+
+// totals returns the bytes received and sent across all interfaces so far.
+func (s *sessionStats) totals() (rx, tx uint64) {
+	if s == nil {
+		return 0, 0
+	}
+	var sumRx, sumTx float64
+	for _, t := range s.ifaces {
+		sumRx += t.rx
+		sumTx += t.tx
+	}
+	return uint64(sumRx), uint64(sumTx)
+}
+
+// ifaceBytes returns the bytes each interface received and sent so far.
+func (s *sessionStats) ifaceBytes() map[string][2]uint64 {
+	if s == nil {
+		return nil
+	}
+	bytes := make(map[string][2]uint64, len(s.ifaces))
+	for name, t := range s.ifaces {
+		bytes[name] = [2]uint64{uint64(t.rx), uint64(t.tx)}
+	}
+	return bytes
+}
+
+// render formats the exit summary as plain text.
+func (s *sessionStats) render(end time.Time) string {
+	if s == nil || s.samples == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Mole status session: %s\n", end.Sub(s.start).Round(time.Second))
+
+	names := make([]string, 0, len(s.ifaces))
+	for name := range s.ifaces {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, c := s.ifaces[names[i]], s.ifaces[names[j]]
+		if a.rx+a.tx != c.rx+c.tx {
+			return a.rx+a.tx > c.rx+c.tx
+		}
+		return names[i] < names[j]
+	})
+	label := "Network"
+	for _, name := range names {
+		t := s.ifaces[name]
+		fmt.Fprintf(&b, "  %-9s %-8s ↓ %-10s ↑ %s\n", label, name, formatBytes(uint64(t.rx)), formatBytes(uint64(t.tx)))
+		label = ""
+	}
+	fmt.Fprintf(&b, "  %-9s ↓ %s  ↑ %s\n", "Peak", formatRate(s.peakRx), formatRate(s.peakTx))
+	n := float64(s.samples)
+	fmt.Fprintf(&b, "  %-9s CPU %s  Memory %s\n", "Average",
+		strings.TrimSpace(formatPercent(s.cpuSum/n)), strings.TrimSpace(formatPercent(s.memSum/n)))
+	return b.String()
+}
+
+// byteMark is a point in time (m) from which the network card counts bytes
+// per interface instead of showing rates. Baselines are each interface's
+// cumulative counters at the mark, or when it first appeared after it.
+type byteMark struct {
+	at     time.Time
+	rx, tx map[string]uint64
+}
+
+func newByteMark(at time.Time, stats []NetworkStatus) *byteMark {
+	b := &byteMark{at: at, rx: make(map[string]uint64), tx: make(map[string]uint64)}
+	b.observe(stats)
+	return b
+}
+
+// observe baselines interfaces not seen since the mark. A counter below its
+// baseline means the device was reset or recreated, so it counts from zero.
+func (b *byteMark) observe(stats []NetworkStatus) {
+	if b == nil {
+		return
+	}
+	for _, n := range stats {
+		if _, ok := b.rx[n.Name]; !ok {
+			rx, renamed := b.rx[n.Renamed]
+			if !renamed {
+				b.rx[n.Name], b.tx[n.Name] = n.RxBytes, n.TxBytes
+				continue
+			}
+			// Same device under a new name: keep counting from its mark.
+			b.rx[n.Name], b.tx[n.Name] = rx, b.tx[n.Renamed]
+			delete(b.rx, n.Renamed)
+			delete(b.tx, n.Renamed)
+		}
+		if n.RxBytes < b.rx[n.Name] {
+			b.rx[n.Name] = 0
+		}
+		if n.TxBytes < b.tx[n.Name] {
+			b.tx[n.Name] = 0
+		}
+	}
+}
+
+// since returns the bytes n has moved since the mark.
+func (b *byteMark) since(n NetworkStatus) (rx, tx uint64) {
+	if b == nil {
+		return 0, 0
+	}
+	if base, ok := b.rx[n.Name]; ok && n.RxBytes >= base {
+		rx = n.RxBytes - base
+	}
+	if base, ok := b.tx[n.Name]; ok && n.TxBytes >= base {
+		tx = n.TxBytes - base
+	}
+	return rx, tx
+}
+
+// rateTare is a background rate captured per interface and subtracted from
+// the displayed rates, like the tare on a scale (b).
+type rateTare struct {
+	rx, tx           map[string]float64
+	totalRx, totalTx float64 // Latest aggregate history point at capture.
+}
+
+func newRateTare(stats []NetworkStatus, history NetworkHistory) *rateTare {
+	t := &rateTare{rx: make(map[string]float64), tx: make(map[string]float64)}
+	for _, n := range stats {
+		t.rx[n.Name], t.tx[n.Name] = n.RxRateMBs, n.TxRateMBs
+	}
+	if len(history.RxHistory) > 0 {
+		t.totalRx = history.RxHistory[len(history.RxHistory)-1]
+	}
+	if len(history.TxHistory) > 0 {
+		t.totalTx = history.TxHistory[len(history.TxHistory)-1]
+	}
+	return t
+}
+
+// apply returns copies of stats and history with the baseline taken off,
+// never below zero. Interfaces that appeared after the capture are untouched.
+func (t *rateTare) apply(stats []NetworkStatus, history NetworkHistory) ([]NetworkStatus, NetworkHistory) {
+	out := slices.Clone(stats)
+	for i := range out {
+		out[i].RxRateMBs = max(out[i].RxRateMBs-t.rx[out[i].Name], 0)
+		out[i].TxRateMBs = max(out[i].TxRateMBs-t.tx[out[i].Name], 0)
+	}
+	return out, NetworkHistory{RxHistory: tareSeries(history.RxHistory, t.totalRx), TxHistory: tareSeries(history.TxHistory, t.totalTx)}
+}
+
+func tareSeries(values []float64, base float64) []float64 {
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = max(v-base, 0)
+	}
+	return out
+}
+
+// Focus-follows-activity (F) averages each interface's rate over
+// followWindow samples, as --rank-window does for the row order, and hands
+// the selection over only when the leader beats the selected row by
+// followMargin, so short bursts elsewhere do not yank it around.
+const (
+	followWindow = 5
+	followMargin = 1.5
+)
+
+// activityFollow moves the interface selection to the busiest row.
+type activityFollow struct {
+	recent map[string][]float64
+}
+
+func newActivityFollow() *activityFollow {
+	return &activityFollow{recent: make(map[string][]float64)}
+}
+
+// pick records a sample and returns the row to select among names, the rows
+// the cursor can reach. Interfaces that left the list are forgotten.
+func (f *activityFollow) pick(stats []NetworkStatus, names []string, current string) string {
+	visible := toSet(names)
+	means := make(map[string]float64, len(names))
+	for _, n := range stats {
+		if !visible[n.Name] {
+			continue
+		}
+		recent := append(f.recent[n.Name], n.RxRateMBs+n.TxRateMBs)
+		if len(recent) > followWindow {
+			recent = recent[len(recent)-followWindow:]
+		}
+		f.recent[n.Name] = recent
+		var sum float64
+		for _, v := range recent {
+			sum += v
+		}
+		means[n.Name] = sum / float64(len(recent))
+	}
+	for name := range f.recent {
+		if !visible[name] {
+			delete(f.recent, name)
+		}
+	}
+	leader := current
+	for _, name := range names {
+		if _, ok := means[leader]; !ok || means[name] > means[leader] {
+			leader = name
+		}
+	}
+	if cur, ok := means[current]; ok && means[leader] <= cur*followMargin {
+		return current
+	}
+	return leader
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionStatsSummary(t *testing.T) {
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	s := newSessionStats(start)
+
+	s.add(MetricsSnapshot{
+		CollectedAt: start.Add(time.Second),
+		CPU:         CPUStatus{Usage: 10},
+		Memory:      MemoryStatus{UsedPercent: 40},
+		Network:     []NetworkStatus{{Name: "en0", RxRateMBs: 1, TxRateMBs: 0.5}},
+	})
+	s.add(MetricsSnapshot{
+		CollectedAt: start.Add(3 * time.Second),
+		CPU:         CPUStatus{Usage: 30},
+		Memory:      MemoryStatus{UsedPercent: 60},
+		Network:     []NetworkStatus{{Name: "en0", RxRateMBs: 4, TxRateMBs: 1}},
+	})
+
+	// Only the second sample has a preceding interval: 4 MB/s for 2s.
+	if got := s.ifaces["en0"].rx; got != 8*1024*1024 {
+		t.Fatalf("en0 rx bytes = %v, want 8 MiB", got)
+	}
+
+	out := s.render(start.Add(5 * time.Minute))
+	for _, want := range []string{"session: 5m0s", "en0", "8.0 MB", "Peak", "↓ 4.0 MB/s", "CPU 20.0%", "Memory 50.0%"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+
+	if got := newSessionStats(start).render(start); got != "" {
+		t.Errorf("empty session should render nothing, got %q", got)
+	}
+}
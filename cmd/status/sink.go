@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricGroup is one measurement with its tags and numeric fields, the common
+// shape of the StatsD and InfluxDB line protocol outputs.
+type metricGroup struct {
+	measurement string
+	tags        [][2]string // Ordered key/value pairs.
+	fields      [][2]string // Ordered name/value pairs, values already formatted.
+}
+
+// metricGroups flattens the headline numbers of a snapshot. Interface names
+// become an iface tag rather than part of the metric name.
+func metricGroups(s MetricsSnapshot) []metricGroup {
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	groups := []metricGroup{
+		{measurement: "cpu", fields: [][2]string{{"usage_percent", num(s.CPU.Usage)}}},
+		{measurement: "memory", fields: [][2]string{{"used_percent", num(s.Memory.UsedPercent)}}},
+		{measurement: "disk_io", fields: [][2]string{{"read_mbs", num(s.DiskIO.ReadRate)}, {"write_mbs", num(s.DiskIO.WriteRate)}}},
+		{measurement: "connections", fields: [][2]string{{"total", strconv.Itoa(s.Connections.Total)}}},
+		{measurement: "health", fields: [][2]string{{"score", strconv.Itoa(s.HealthScore)}}},
+	}
+	for _, n := range s.Network {
+		groups = append(groups, metricGroup{
+			measurement: "net",
+			tags:        [][2]string{{"iface", n.Name}, {"kind", n.Kind}},
+			fields:      [][2]string{{"rx_mbs", num(n.RxRateMBs)}, {"tx_mbs", num(n.TxRateMBs)}},
+		})
+	}
+	if l := s.Latency; l != nil && l.Error == "" {
+		groups = append(groups, metricGroup{
+			measurement: "latency",
+			tags:        [][2]string{{"target", l.Target}},
+			fields:      [][2]string{{"current_ms", num(l.CurrentMs)}},
+		})
+	}
+	return groups
+}
+
+// formatInflux renders a snapshot as InfluxDB line protocol, one line per group.
+func formatInflux(s MetricsSnapshot) string {
+	escape := strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+	var lines []string
+	for _, g := range metricGroups(s) {
+		var b strings.Builder
+		b.WriteString("mole_" + g.measurement)
+		if s.Host != "" {
+			b.WriteString(",host=" + escape.Replace(s.Host))
+		}
+		for _, t := range g.tags {
+			if t[1] != "" {
+				b.WriteString("," + t[0] + "=" + escape.Replace(t[1]))
+			}
+		}
+		for i, f := range g.fields {
+			sep := ","
+			if i == 0 {
+				sep = " "
+			}
+			b.WriteString(sep + f[0] + "=" + f[1])
+		}
+		fmt.Fprintf(&b, " %d", s.CollectedAt.UnixNano())
+		lines = append(lines, b.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// statsdLines renders a snapshot as StatsD gauges, with tags in the widely
+// supported DogStatsD "|#key:value" form.
+func statsdLines(s MetricsSnapshot) []string {
+	var lines []string
+	for _, g := range metricGroups(s) {
+		var tags []string
+		for _, t := range g.tags {
+			if t[1] != "" {
+				tags = append(tags, t[0]+":"+t[1])
+			}
+		}
+		suffix := ""
+		if len(tags) > 0 {
+			suffix = "|#" + strings.Join(tags, ",")
+		}
+		for _, f := range g.fields {
+			lines = append(lines, "mole."+g.measurement+"."+f[0]+":"+f[1]+"|g"+suffix)
+		}
+	}
+	return lines
+}
+
+// csvHeader names the --log-csv columns: one row per metric per sample, long
+// format, so interfaces coming and going never change the columns.
+var csvHeader = []string{"time", "host", "measurement", "iface", "kind", "target", "field", "value"}
+
+// csvRows flattens a snapshot into --log-csv rows.
+func csvRows(s MetricsSnapshot) [][]string {
+	at := s.CollectedAt.UTC().Format(time.RFC3339Nano)
+	var rows [][]string
+	for _, g := range metricGroups(s) {
+		tags := make(map[string]string, len(g.tags))
+		for _, t := range g.tags {
+			tags[t[0]] = t[1]
+		}
+		for _, f := range g.fields {
+			rows = append(rows, []string{at, s.Host, g.measurement, tags["iface"], tags["kind"], tags["target"], f[0], f[1]})
+		}
+	}
+	return rows
+}
+
+// writeCSV appends a snapshot's rows to w in one write, with the header
+// first when header is set.
+func writeCSV(w io.Writer, s MetricsSnapshot, header bool) error {
+	var b strings.Builder
+	cw := csv.NewWriter(&b)
+	if header {
+		_ = cw.Write(csvHeader)
+	}
+	_ = cw.WriteAll(csvRows(s))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// historyCSVHeader names the --history-csv columns: one row per interface
+// per sample, wide enough to plot straight away.
+var historyCSVHeader = []string{"time", "iface", "rx_mbs", "tx_mbs"}
+
+// writeHistoryCSV appends a sample's interface rates to w in one write,
+// stamped with when the sample was taken. The warm-up sample has no rates
+// yet and writes nothing.
+func writeHistoryCSV(w io.Writer, s MetricsSnapshot, header bool) error {
+	var b strings.Builder
+	cw := csv.NewWriter(&b)
+	if header {
+		_ = cw.Write(historyCSVHeader)
+	}
+	if !s.NetworkWarmup {
+		at := s.CollectedAt.UTC().Format(time.RFC3339Nano)
+		num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+		for _, n := range s.Network {
+			_ = cw.Write([]string{at, n.Name, num(n.RxRateMBs), num(n.TxRateMBs)})
+		}
+	}
+	cw.Flush()
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// statsdMaxPacket keeps datagrams under a typical 1500-byte MTU.
+const statsdMaxPacket = 1400
+
+// statsdSink sends every snapshot to a StatsD daemon over UDP (--statsd).
+type statsdSink struct {
+	conn net.Conn
+}
+
+func newStatsdSink(addr string) (*statsdSink, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid --statsd %q: want host:port", addr)
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	return &statsdSink{conn: conn}, nil
+}
+
+func (s *statsdSink) send(snap MetricsSnapshot) {
+	for _, p := range statsdPackets(statsdLines(snap), statsdMaxPacket) {
+		_, _ = s.conn.Write(p) // UDP: a missing daemon is not our problem.
+	}
+}
+
+// statsdPackets packs newline-separated lines into datagrams of at most max bytes.
+func statsdPackets(lines []string, max int) [][]byte {
+	var packets [][]byte
+	var cur []byte
+	for _, line := range lines {
+		if len(cur) > 0 && len(cur)+1+len(line) > max {
+			packets = append(packets, cur)
+			cur = nil
+		}
+		if len(cur) > 0 {
+			cur = append(cur, '\n')
+		}
+		cur = append(cur, line...)
+	}
+	if len(cur) > 0 {
+		packets = append(packets, cur)
+	}
+	return packets
+}
+
+// sinkSource forwards every snapshot its source produces to the sinks: the
+// metric exporters, the --listen API and the log files. The dashboard or
+// output loop keeps driving collection, so each sample is taken once
+// however many consumers there are.
+type sinkSource struct {
+	snapshotSource
+	sinks *sinkFanout
+}
+
+func (s sinkSource) Collect() (MetricsSnapshot, error) {
+	snap, err := s.snapshotSource.Collect()
+	if !snap.CollectedAt.IsZero() {
+		s.sinks.publish(snap)
+	}
+	return snap, err
+}
+
+// sinkCloseWait bounds how long exiting waits for the sinks to drain.
+const sinkCloseWait = 2 * time.Second
+
+// sinkQueueSize is how many samples a sink may fall behind before its
+// oldest are dropped.
+const sinkQueueSize = 4
+
+// sinkFanout hands every snapshot to each sink's own goroutine through a
+// queue of its own, so a slow sink (a stalled disk, a hung exporter) loses
+// its oldest samples instead of holding up collection or the other sinks.
+type sinkFanout struct {
+	sinks  []*snapshotSink
+	events *eventRing // Sinks falling behind and failing.
+	wg     sync.WaitGroup
+	mu     sync.Mutex // Guards closed against a collect still publishing.
+	closed bool
+}
+
+type snapshotSink struct {
+	name    string
+	consume func(MetricsSnapshot) error
+	queue   chan MetricsSnapshot
+	behind  atomic.Bool // Dropped a sample since the last one it kept up with.
+	failing bool        // The last consume failed; owned by the sink's goroutine.
+}
+
+// add starts a sink; name appears in its events.
+func (f *sinkFanout) add(name string, consume func(MetricsSnapshot) error) {
+	s := &snapshotSink{name: name, consume: consume, queue: make(chan MetricsSnapshot, sinkQueueSize)}
+	f.sinks = append(f.sinks, s)
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		for snap := range s.queue {
+			err := s.consume(snap)
+			switch {
+			case err != nil && !s.failing:
+				f.events.add(severityWarn, categorySystem, name+": "+err.Error())
+			case err == nil && s.failing:
+				f.events.add(severityInfo, categorySystem, name+" working again")
+			}
+			s.failing = err != nil
+		}
+	}()
+}
+
+// publish queues snap for every sink, dropping a sink's oldest queued
+// sample when it is full. After close it drops snap: the dashboard may
+// still be collecting when it quits.
+func (f *sinkFanout) publish(snap MetricsSnapshot) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	for _, s := range f.sinks {
+		if s.push(snap) {
+			if !s.behind.Swap(true) {
+				f.events.add(severityWarn, categorySystem, s.name+" is falling behind; dropping its oldest samples")
+			}
+		} else if len(s.queue) <= 1 {
+			s.behind.Store(false)
+		}
+	}
+}
+
+// push queues snap, making room by dropping the oldest queued sample.
+func (s *snapshotSink) push(snap MetricsSnapshot) (dropped bool) {
+	for {
+		select {
+		case s.queue <- snap:
+			return dropped
+		default:
+		}
+		select {
+		case <-s.queue:
+			dropped = true
+		default: // The sink took one meanwhile.
+		}
+	}
+}
+
+// close stops taking samples and waits up to timeout for the sinks to
+// finish what is queued, so a log file ends with the last sample.
+func (f *sinkFanout) close(timeout time.Duration) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	for _, s := range f.sinks {
+		close(s.queue)
+	}
+	f.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// closeSinks flushes src's sinks, if it has any.
+func closeSinks(src snapshotSource, timeout time.Duration) {
+	if s, ok := src.(sinkSource); ok {
+		s.sinks.close(timeout)
+	}
+}
+
+// localCollector returns the Collector behind src, if it collects locally.
+func localCollector(src snapshotSource) (*Collector, bool) {
+	if s, ok := src.(sinkSource); ok {
+		src = s.snapshotSource
+	}
+	c, ok := src.(*Collector)
+	return c, ok
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sinkTestSnapshot() MetricsSnapshot {
+	return MetricsSnapshot{
+		CollectedAt: time.Unix(1700000000, 5),
+		Host:        "build box",
+		CPU:         CPUStatus{Usage: 12.5},
+		Network:     []NetworkStatus{{Name: "en0", Kind: "physical", RxRateMBs: 1.5, TxRateMBs: 0.25}},
+	}
+}
+
+func TestFormatInflux(t *testing.T) {
+	lines := strings.Split(formatInflux(sinkTestSnapshot()), "\n")
+	for _, want := range []string{
+		`mole_cpu,host=build\ box usage_percent=12.5 1700000000000000005`,
+		`mole_net,host=build\ box,iface=en0,kind=physical rx_mbs=1.5,tx_mbs=0.25 1700000000000000005`,
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("formatInflux() missing %q in:\n%s", want, strings.Join(lines, "\n"))
+		}
+	}
+}
+
+func TestStatsdLinesAndPackets(t *testing.T) {
+	lines := statsdLines(sinkTestSnapshot())
+	for _, want := range []string{"mole.cpu.usage_percent:12.5|g", "mole.net.rx_mbs:1.5|g|#iface:en0,kind:physical"} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("statsdLines() missing %q in %v", want, lines)
+		}
+	}
+
+	packets := statsdPackets([]string{"aaaa", "bbbb", "cccc"}, 9)
+	if len(packets) != 2 || string(packets[0]) != "aaaa\nbbbb" || string(packets[1]) != "cccc" {
+		t.Fatalf("statsdPackets() = %q", packets)
+	}
+}
+
+func TestStatsdSinkSends(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("udp unavailable: %v", err)
+	}
+	defer pc.Close()
+
+	sink, err := newStatsdSink(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("newStatsdSink() error = %v", err)
+	}
+	sink.send(sinkTestSnapshot())
+
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, statsdMaxPacket)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil || !strings.Contains(string(buf[:n]), "mole.cpu.usage_percent:12.5|g") {
+		t.Fatalf("ReadFrom() = %q, %v", buf[:n], err)
+	}
+
+	if _, err := newStatsdSink("localhost"); err == nil {
+		t.Fatalf("newStatsdSink(localhost) expected error")
+	}
+}
+
+func TestAPIServer(t *testing.T) {
+	api := &apiServer{cors: "*"}
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/snapshot")
+	if err != nil {
+		t.Fatalf("GET /api/snapshot error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("before a sample: status %d, want 503", resp.StatusCode)
+	}
+
+	snap := sinkTestSnapshot()
+	snap.NetworkHistory = NetworkHistory{RxHistory: []float64{1, 2}, TxHistory: []float64{3, 4}}
+	api.record(snap)
+
+	resp, err = http.Get(srv.URL + "/api/snapshot")
+	if err != nil {
+		t.Fatalf("GET /api/snapshot error = %v", err)
+	}
+	var got MetricsSnapshot
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	if err != nil || got.Host != "build box" || got.CPU.Usage != 12.5 {
+		t.Fatalf("snapshot = %+v, %v", got, err)
+	}
+	if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Fatalf("Access-Control-Allow-Origin = %q", origin)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/history/network")
+	if err != nil {
+		t.Fatalf("GET /api/history/network error = %v", err)
+	}
+	var history NetworkHistory
+	err = json.NewDecoder(resp.Body).Decode(&history)
+	resp.Body.Close()
+	if err != nil || !slices.Equal(history.RxHistory, []float64{1, 2}) || !slices.Equal(history.TxHistory, []float64{3, 4}) {
+		t.Fatalf("history = %+v, %v", history, err)
+	}
+
+	req, _ := http.NewRequest(http.MethodOptions, srv.URL+"/api/snapshot", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("OPTIONS error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("preflight status %d, want 204", resp.StatusCode)
+	}
+}
+
+func TestFormatPrometheus(t *testing.T) {
+	out := formatPrometheus(sinkTestSnapshot(), promNaming{})
+	for _, want := range []string{
+		"# TYPE mole_cpu_usage_percent gauge\n",
+		`mole_cpu_usage_percent{host="build box"} 12.5`,
+		`mole_net_rx_mbs{host="build box",iface="en0",kind="physical"} 1.5`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("formatPrometheus() missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "# TYPE mole_cpu_usage_percent ") != 1 {
+		t.Errorf("duplicate TYPE lines:\n%s", out)
+	}
+
+	labels, err := parseMetricLabels(nil, "dc=us-east")
+	if err == nil {
+		labels, err = parseMetricLabels(labels, `rack=r4 "b"`)
+	}
+	if err != nil {
+		t.Fatalf("parseMetricLabels() error = %v", err)
+	}
+	out = formatPrometheus(sinkTestSnapshot(), promNaming{prefix: "myhost_", labels: labels})
+	if want := `myhost_net_rx_mbs{host="build box",dc="us-east",rack="r4 \"b\"",iface="en0",kind="physical"} 1.5`; !strings.Contains(out, want) {
+		t.Errorf("formatPrometheus() missing %q in:\n%s", want, out)
+	}
+	if strings.Contains(out, "mole_") {
+		t.Errorf("the prefix should replace mole_:\n%s", out)
+	}
+	for _, bad := range []string{"dc", "dc=", "1dc=x", "d-c=x", "__name=x", "host=x", "dc=\xff", "dc=a,dc=b"} {
+		if _, err := parseMetricLabels(nil, bad); err == nil {
+			t.Errorf("parseMetricLabels(%q) should fail", bad)
+		}
+	}
+	if _, err := parseMetricLabels(labels, "dc=eu"); err == nil {
+		t.Errorf("a label repeated across flags should fail")
+	}
+	for prefix, ok := range map[string]bool{"myhost_": true, "ns:mole_": true, "": false, "9host_": false, "my-host_": false} {
+		if err := checkMetricPrefix(prefix); (err == nil) != ok {
+			t.Errorf("checkMetricPrefix(%q) error = %v, want ok=%v", prefix, err, ok)
+		}
+	}
+}
+
+type fixedSource struct{ snap MetricsSnapshot }
+
+func (s fixedSource) Collect() (MetricsSnapshot, error) { return s.snap, nil }
+
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	w.writes++
+	return 0, errors.New("disk full")
+}
+
+func TestSessionRecorder(t *testing.T) {
+	var out strings.Builder
+	rec, err := newSessionRecorder(&out, "interval: 1s\n", time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinks := &sinkFanout{}
+	sinks.add("--record", func(s MetricsSnapshot) error { rec.record(s); return nil })
+	src := sinkSource{snapshotSource: fixedSource{sinkTestSnapshot()}, sinks: sinks}
+	for range 2 {
+		if _, err := src.Collect(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closeSinks(src, time.Second)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("recording has %d lines, want a header and 2 snapshots:\n%s", len(lines), out.String())
+	}
+	var header recordHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil || header.Record != recordVersion || header.Config != "interval: 1s\n" {
+		t.Fatalf("header = %+v, %v", header, err)
+	}
+	var snap MetricsSnapshot
+	if err := json.Unmarshal([]byte(lines[2]), &snap); err != nil || snap.Host != "build box" || snap.Network[0].RxRateMBs != 1.5 {
+		t.Fatalf("snapshot line = %+v, %v", snap, err)
+	}
+
+	// A failed write stops the recording with a single event.
+	w := &failingWriter{}
+	rec = &sessionRecorder{w: w, events: &eventRing{}}
+	rec.record(sinkTestSnapshot())
+	rec.record(sinkTestSnapshot())
+	if events := rec.events.recent(); w.writes != 1 || len(events) != 1 || !strings.Contains(events[0].Message, "disk full") {
+		t.Fatalf("after a failed write: %d writes, events %+v", w.writes, events)
+	}
+	var none *sessionRecorder
+	none.record(sinkTestSnapshot())
+}
+
+func TestSinkFanout(t *testing.T) {
+	f := &sinkFanout{events: &eventRing{}}
+	release := make(chan struct{})
+	var slow, fast []int
+	var mu sync.Mutex
+	f.add("slow", func(s MetricsSnapshot) error {
+		<-release
+		mu.Lock()
+		slow = append(slow, s.HealthScore)
+		mu.Unlock()
+		return nil
+	})
+	f.add("fast", func(s MetricsSnapshot) error {
+		mu.Lock()
+		fast = append(fast, s.HealthScore)
+		mu.Unlock()
+		if s.HealthScore == 2 {
+			return errors.New("disk full")
+		}
+		return nil
+	})
+
+	// The slow sink holds one sample and queues sinkQueueSize more; beyond
+	// that its oldest queued go, while the fast one keeps up with every
+	// sample and publishing never blocks.
+	total := sinkQueueSize + 4
+	for i := 1; i <= total; i++ {
+		f.publish(MetricsSnapshot{HealthScore: i})
+		for mu.Lock(); len(fast) < i; mu.Lock() {
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+		mu.Unlock()
+	}
+	close(release)
+	f.close(time.Second)
+	// A dashboard collect still running at exit publishes after close.
+	f.publish(MetricsSnapshot{HealthScore: total + 1})
+	f.close(time.Second)
+
+	if len(fast) != total {
+		t.Fatalf("fast sink got %v, want all %d samples", fast, total)
+	}
+	if len(slow) > sinkQueueSize+1 || slow[len(slow)-1] != total {
+		t.Fatalf("slow sink got %v, want at most %d ending with the latest", slow, sinkQueueSize+1)
+	}
+	var behind, failed, recovered int
+	for _, e := range f.events.recent() {
+		switch {
+		case e.Message == "slow is falling behind; dropping its oldest samples":
+			behind++
+		case e.Message == "fast: disk full":
+			failed++
+		case e.Message == "fast working again":
+			recovered++
+		}
+	}
+	if behind != 1 || failed != 1 || recovered != 1 {
+		t.Fatalf("events = %+v", f.events.recent())
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b strings.Builder
+	if err := writeCSV(&b, sinkTestSnapshot(), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCSV(&b, sinkTestSnapshot(), false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if lines[0] != "time,host,measurement,iface,kind,target,field,value" {
+		t.Fatalf("header = %q", lines[0])
+	}
+	if !slices.Contains(lines, "2023-11-14T22:13:20.000000005Z,build box,net,en0,physical,,rx_mbs,1.5") {
+		t.Fatalf("no en0 rx row in:\n%s", b.String())
+	}
+	if n := len(csvRows(sinkTestSnapshot())); len(lines) != 1+2*n {
+		t.Fatalf("got %d lines, want a header and %d rows per sample", len(lines), n)
+	}
+}
+
+func TestWriteHistoryCSV(t *testing.T) {
+	warmup := sinkTestSnapshot()
+	warmup.NetworkWarmup = true
+	later := sinkTestSnapshot()
+	later.CollectedAt = later.CollectedAt.Add(time.Second)
+	later.Network = append(later.Network, NetworkStatus{Name: "utun3", RxRateMBs: 0.125})
+	var b strings.Builder
+	for i, s := range []MetricsSnapshot{warmup, sinkTestSnapshot(), later} {
+		if err := writeHistoryCSV(&b, s, i == 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := `time,iface,rx_mbs,tx_mbs
+2023-11-14T22:13:20.000000005Z,en0,1.5,0.25
+2023-11-14T22:13:21.000000005Z,en0,1.5,0.25
+2023-11-14T22:13:21.000000005Z,utun3,0.125,0
+`
+	if b.String() != want {
+		t.Errorf("history CSV =\n%s\nwant\n%s", b.String(), want)
+	}
+}
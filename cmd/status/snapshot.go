@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	snapshotFilePrefix = "status-"
+	snapshotFileSuffix = ".json"
+	snapshotTimeLayout = "20060102-150405"
+)
+
+// snapshotWriter periodically dumps snapshots into a directory as discrete JSON files.
+type snapshotWriter struct {
+	dir    string
+	every  time.Duration
+	keep   int           // Max files to retain; 0 = unlimited.
+	maxAge time.Duration // Remove files older than this; 0 = never.
+	bucket time.Duration // History resolution in the files (--history-bucket); 0 = every sample.
+	last   time.Time
+}
+
+func newSnapshotWriter(dir string, every time.Duration, keep int, maxAge time.Duration) *snapshotWriter {
+	return &snapshotWriter{dir: dir, every: every, keep: keep, maxAge: maxAge}
+}
+
+// maybeWrite writes s when the interval has elapsed since the previous file.
+func (w *snapshotWriter) maybeWrite(s MetricsSnapshot) error {
+	if w == nil || s.CollectedAt.IsZero() {
+		return nil
+	}
+	if !w.last.IsZero() && s.CollectedAt.Sub(w.last) < w.every {
+		return nil
+	}
+	w.last = s.CollectedAt
+
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return fmt.Errorf("snapshot dir: %w", err)
+	}
+	data, err := json.MarshalIndent(exportSnapshot(s, w.bucket), "", "  ")
+	if err != nil {
+		return fmt.Errorf("snapshot encode: %w", err)
+	}
+	name := snapshotFilePrefix + s.CollectedAt.UTC().Format(snapshotTimeLayout) + snapshotFileSuffix
+	if err := writeFileAtomic(filepath.Join(w.dir, name), append(data, '\n')); err != nil {
+		return fmt.Errorf("snapshot write: %w", err)
+	}
+	return w.prune(s.CollectedAt)
+}
+
+// prune enforces the count and age limits, oldest files first.
+func (w *snapshotWriter) prune(now time.Time) error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("snapshot prune: %w", err)
+	}
+
+	type snapFile struct {
+		name string
+		at   time.Time
+	}
+	var files []snapFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, snapshotFilePrefix) || !strings.HasSuffix(name, snapshotFileSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, snapshotFilePrefix), snapshotFileSuffix)
+		at, err := time.Parse(snapshotTimeLayout, stamp)
+		if err != nil {
+			continue // Not one of ours.
+		}
+		files = append(files, snapFile{name: name, at: at})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].at.Before(files[j].at) })
+
+	for i, f := range files {
+		expired := w.maxAge > 0 && now.Sub(f.at) > w.maxAge
+		overCount := w.keep > 0 && len(files)-i > w.keep
+		if !expired && !overCount {
+			continue
+		}
+		if err := os.Remove(filepath.Join(w.dir, f.name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("snapshot prune: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and renames it
+// into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotWriterHonorsInterval(t *testing.T) {
+	dir := t.TempDir()
+	w := newSnapshotWriter(dir, 5*time.Minute, 0, 0)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, offset := range []time.Duration{0, time.Minute, 5 * time.Minute} {
+		if err := w.maybeWrite(MetricsSnapshot{CollectedAt: start.Add(offset), Host: "box"}); err != nil {
+			t.Fatalf("maybeWrite() error = %v", err)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 snapshot files, got %d", len(entries))
+	}
+	if entries[0].Name() != "status-20260102-030405.json" {
+		t.Fatalf("unexpected file name %q", entries[0].Name())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("snapshot is not valid JSON: %v", err)
+	}
+	if decoded["host"] != "box" {
+		t.Fatalf("snapshot host = %v, want box", decoded["host"])
+	}
+}
+
+func TestSnapshotWriterPrunesByCountAndAge(t *testing.T) {
+	dir := t.TempDir()
+	// Unrelated files must survive pruning.
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newSnapshotWriter(dir, time.Minute, 3, 0)
+	start := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		if err := w.maybeWrite(MetricsSnapshot{CollectedAt: start.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("maybeWrite() error = %v", err)
+		}
+	}
+	names := snapshotNames(t, dir)
+	if len(names) != 3 || names[0] != "status-20260102-000200.json" {
+		t.Fatalf("count pruning kept %v", names)
+	}
+
+	w.keep = 0
+	w.maxAge = 90 * time.Second
+	if err := w.maybeWrite(MetricsSnapshot{CollectedAt: start.Add(5 * time.Minute)}); err != nil {
+		t.Fatalf("maybeWrite() error = %v", err)
+	}
+	names = snapshotNames(t, dir)
+	if len(names) != 2 || names[0] != "status-20260102-000400.json" {
+		t.Fatalf("age pruning kept %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatalf("unrelated file was removed: %v", err)
+	}
+}
+
+func snapshotNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), snapshotFilePrefix) {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestSanitizeSnapshot(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	s := MetricsSnapshot{
+		CPU:            CPUStatus{Usage: nan, PerCore: []float64{inf, 20}},
+		Network:        []NetworkStatus{{Name: "en0", RxRateMBs: -0.5, TxRateMBs: nan}},
+		NetworkHistory: NetworkHistory{RxHistory: []float64{1, -2, nan}, TxHistory: []float64{math.Inf(-1)}},
+		DiskIO:         DiskIOStatus{ReadRate: -1, WriteRate: 2},
+		Batteries:      []BatteryStatus{{Percent: 80}},
+		Thermal:        ThermalStatus{BatteryPower: -12.5}, // Charging; signed on purpose.
+		TCP:            &TCPStatus{RetransRate: inf},
+	}
+	sanitizeSnapshot(&s)
+
+	if s.CPU.Usage != 0 || !slices.Equal(s.CPU.PerCore, []float64{0, 20}) {
+		t.Errorf("CPU = %+v", s.CPU)
+	}
+	if n := s.Network[0]; n.RxRateMBs != 0 || n.TxRateMBs != 0 {
+		t.Errorf("network rates = %v / %v, want 0 / 0", n.RxRateMBs, n.TxRateMBs)
+	}
+	if !slices.Equal(s.NetworkHistory.RxHistory, []float64{1, 0, 0}) || !slices.Equal(s.NetworkHistory.TxHistory, []float64{0}) {
+		t.Errorf("history = %+v", s.NetworkHistory)
+	}
+	if s.DiskIO.ReadRate != 0 || s.DiskIO.WriteRate != 2 || s.TCP.RetransRate != 0 {
+		t.Errorf("disk %+v, tcp %+v", s.DiskIO, *s.TCP)
+	}
+	if s.Thermal.BatteryPower != -12.5 {
+		t.Errorf("battery power = %v, want the signed value kept", s.Thermal.BatteryPower)
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Errorf("sanitized snapshot does not encode: %v", err)
+	}
+}
+
+func TestExportSnapshotBuckets(t *testing.T) {
+	if got := bucketHistory([]float64{1, 5, 2, 0, 3, 9, 4}, 3); !slices.Equal(got, []float64{1, 5, 9}) {
+		t.Errorf("bucketHistory() = %v, want peaks with the partial bucket oldest", got)
+	}
+
+	history := []float64{1, 2, 3, 4}
+	s := MetricsSnapshot{CPU: CPUStatus{History: history}, NetworkHistory: NetworkHistory{RxHistory: history, TxHistory: history}}
+	got := exportSnapshot(s, 2*refreshInterval)
+	if !slices.Equal(got.NetworkHistory.RxHistory, []float64{2, 4}) || !slices.Equal(got.CPU.History, []float64{2, 4}) || got.HistoryBucket != 2 {
+		t.Fatalf("exportSnapshot() = %+v", got)
+	}
+	if !slices.Equal(s.NetworkHistory.RxHistory, []float64{1, 2, 3, 4}) {
+		t.Fatalf("exportSnapshot() changed the source history: %v", s.NetworkHistory.RxHistory)
+	}
+	if got := exportSnapshot(s, 0); got.HistoryBucket != 0 || len(got.CPU.History) != 4 {
+		t.Fatalf("exportSnapshot(0) = %+v, want it unchanged", got)
+	}
+
+	dir := t.TempDir()
+	w := newSnapshotWriter(dir, time.Minute, 0, 0)
+	w.bucket = 2 * refreshInterval
+	s.CollectedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := w.maybeWrite(s); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "status-20260102-030405.json"))
+	var written MetricsSnapshot
+	if err := json.Unmarshal(data, &written); err != nil || len(written.NetworkHistory.TxHistory) != 2 {
+		t.Fatalf("snapshot file history = %v, %v", written.NetworkHistory.TxHistory, err)
+	}
+}
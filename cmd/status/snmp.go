@@ -0,0 +1,346 @@
+package main
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal SNMP GET client: just enough BER to ask an agent for a handful
+// of OIDs over UDP, with v2c communities or v3 USM (snmp_usm.go). Mole only
+// reads counters, so there is no SET, WALK or trap support.
+
+// BER tags used by SNMP.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+	berCounter32   = 0x41
+	berGauge32     = 0x42
+	berTimeTicks   = 0x43
+	berCounter64   = 0x46
+	berNoSuchObj   = 0x80 // noSuchObject, noSuchInstance and endOfMibView
+	berNoSuchInst  = 0x81 // carry no value.
+	berEndOfMib    = 0x82
+	pduGetRequest  = 0xa0
+	pduResponse    = 0xa2
+	pduReport      = 0xa8
+)
+
+const (
+	snmpVersion2c      = 1 // msgVersion on the wire; v1 is 0.
+	snmpVersion3       = 3
+	snmpDefaultPort    = "161"
+	snmpMaxMessage     = 65507
+	defaultSNMPTimeout = 2 * time.Second
+)
+
+// berTLV is one decoded element. value aliases the received packet and keeps
+// its capacity, so usmOffset can find where a field sits in the message.
+type berTLV struct {
+	tag   byte
+	value []byte
+}
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func berEncode(tag byte, value []byte) []byte {
+	return append(append([]byte{tag}, berLength(len(value))...), value...)
+}
+
+func berSeq(tag byte, items ...[]byte) []byte {
+	var body []byte
+	for _, item := range items {
+		body = append(body, item...)
+	}
+	return berEncode(tag, body)
+}
+
+func berInt(v int64) []byte {
+	b := binary.BigEndian.AppendUint64(nil, uint64(v))
+	// Drop leading bytes that only repeat the sign.
+	for len(b) > 1 && (b[0] == 0 && b[1]&0x80 == 0 || b[0] == 0xff && b[1]&0x80 != 0) {
+		b = b[1:]
+	}
+	return berEncode(berInteger, b)
+}
+
+func berOctets(s []byte) []byte { return berEncode(berOctetString, s) }
+
+// berOIDValue encodes a dotted OID such as 1.3.6.1.2.1.2.2.1.10.2.
+func berOIDValue(oid string) ([]byte, error) {
+	var arcs []uint64
+	for part := range strings.SplitSeq(oid, ".") {
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad OID %q", oid)
+		}
+		arcs = append(arcs, v)
+	}
+	if len(arcs) < 2 || arcs[0] > 2 {
+		return nil, fmt.Errorf("bad OID %q", oid)
+	}
+	body := base128(arcs[0]*40 + arcs[1])
+	for _, a := range arcs[2:] {
+		body = append(body, base128(a)...)
+	}
+	return berEncode(berOID, body), nil
+}
+
+func base128(v uint64) []byte {
+	b := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+// berDecodeOID is the inverse of berOIDValue for a value's bytes.
+func berDecodeOID(b []byte) string {
+	var arcs []uint64
+	var v uint64
+	for _, c := range b {
+		v = v<<7 | uint64(c&0x7f)
+		if c&0x80 == 0 {
+			arcs = append(arcs, v)
+			v = 0
+		}
+	}
+	if len(arcs) == 0 {
+		return ""
+	}
+	// The first subidentifier packs the first two arcs.
+	first := min(arcs[0]/40, 2)
+	parts := []string{strconv.FormatUint(first, 10), strconv.FormatUint(arcs[0]-first*40, 10)}
+	for _, a := range arcs[1:] {
+		parts = append(parts, strconv.FormatUint(a, 10))
+	}
+	return strings.Join(parts, ".")
+}
+
+// berNext splits the first element off b.
+func berNext(b []byte) (berTLV, []byte, error) {
+	if len(b) < 2 {
+		return berTLV{}, nil, errors.New("truncated packet")
+	}
+	tag, n, rest := b[0], int(b[1]), b[2:]
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 || len(rest) < size {
+			return berTLV{}, nil, errors.New("bad length")
+		}
+		n = 0
+		for _, c := range rest[:size] {
+			n = n<<8 | int(c)
+		}
+		rest = rest[size:]
+	}
+	if n > len(rest) {
+		return berTLV{}, nil, errors.New("truncated packet")
+	}
+	return berTLV{tag: tag, value: rest[:n]}, rest[n:], nil
+}
+
+// berItems decodes every element of a constructed value, checking each tag
+// against want where one is given (0 accepts any).
+func berItems(b []byte, want ...byte) ([]berTLV, error) {
+	var items []berTLV
+	for len(b) > 0 {
+		item, rest, err := berNext(b)
+		if err != nil {
+			return nil, err
+		}
+		if i := len(items); i < len(want) && want[i] != 0 && item.tag != want[i] {
+			return nil, fmt.Errorf("unexpected element 0x%02x", item.tag)
+		}
+		items = append(items, item)
+		b = rest
+	}
+	if len(items) < len(want) {
+		return nil, errors.New("short message")
+	}
+	return items, nil
+}
+
+func berUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func berSigned(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// snmpValue is one varbind's result.
+type snmpValue struct {
+	tag   byte
+	num   uint64 // Counters, gauges, integers.
+	text  string // OCTET STRING values.
+	found bool   // false for noSuchObject, noSuchInstance and endOfMibView.
+}
+
+// snmpClient polls one agent. v3 is nil for v2c.
+type snmpClient struct {
+	addr      string
+	community string
+	v3        *snmpUSM
+	timeout   time.Duration
+	retries   int
+}
+
+// get fetches oids in one request, keyed by OID.
+func (c *snmpClient) get(oids []string) (map[string]snmpValue, error) {
+	values, err := c.send(oids)
+	if err != nil {
+		return nil, fmt.Errorf("snmp %s: %w", c.addr, err)
+	}
+	return values, nil
+}
+
+func (c *snmpClient) send(oids []string) (map[string]snmpValue, error) {
+	conn, err := net.Dial("udp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if c.v3 != nil {
+		return c.v3.get(conn, c, oids)
+	}
+	id := snmpRequestID()
+	pdu, err := getRequestPDU(id, oids)
+	if err != nil {
+		return nil, err
+	}
+	msg := berSeq(berSequence, berInt(snmpVersion2c), berOctets([]byte(c.community)), pdu)
+	resp, err := c.exchange(conn, msg, func(b []byte) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	items, err := berItems(resp, berSequence)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := berItems(items[0].value, berInteger, berOctetString, 0)
+	if err != nil {
+		return nil, err
+	}
+	return parseResponsePDU(parts[2], id)
+}
+
+// exchange sends msg and waits for a reply accepted by match, resending up to
+// retries times on timeout. A community mismatch gets no answer from most
+// agents, so it shows up as a timeout too.
+func (c *snmpClient) exchange(conn net.Conn, msg []byte, match func([]byte) bool) ([]byte, error) {
+	buf := make([]byte, snmpMaxMessage)
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(c.timeout)
+		for {
+			conn.SetReadDeadline(deadline)
+			n, err := conn.Read(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			if match(buf[:n]) {
+				return append([]byte(nil), buf[:n]...), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no response after %d tries", c.retries+1)
+}
+
+func snmpRequestID() int64 {
+	var b [4]byte
+	rand.Read(b[:])
+	return int64(binary.BigEndian.Uint32(b[:]) & 0x7fffffff)
+}
+
+func getRequestPDU(id int64, oids []string) ([]byte, error) {
+	var binds [][]byte
+	for _, oid := range oids {
+		enc, err := berOIDValue(oid)
+		if err != nil {
+			return nil, err
+		}
+		binds = append(binds, berSeq(berSequence, enc, berEncode(berNull, nil)))
+	}
+	return berSeq(pduGetRequest, berInt(id), berInt(0), berInt(0), berSeq(berSequence, binds...)), nil
+}
+
+// snmpErrors names the error-status values an agent may return for a GET.
+var snmpErrors = map[int64]string{1: "tooBig", 2: "noSuchName", 3: "badValue", 4: "readOnly", 5: "genErr", 16: "authorizationError"}
+
+// parseResponsePDU reads the varbinds of a Response PDU for request id.
+func parseResponsePDU(pdu berTLV, id int64) (map[string]snmpValue, error) {
+	if pdu.tag != pduResponse {
+		return nil, fmt.Errorf("unexpected PDU 0x%02x", pdu.tag)
+	}
+	fields, err := berItems(pdu.value, berInteger, berInteger, berInteger, berSequence)
+	if err != nil {
+		return nil, err
+	}
+	if got := berSigned(fields[0].value); got != id {
+		return nil, fmt.Errorf("response to request %d, want %d", got, id)
+	}
+	if status := berSigned(fields[1].value); status != 0 {
+		name := cmp.Or(snmpErrors[status], strconv.FormatInt(status, 10))
+		return nil, fmt.Errorf("agent returned %s", name)
+	}
+	binds, err := berItems(fields[3].value)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]snmpValue, len(binds))
+	for _, bind := range binds {
+		parts, err := berItems(bind.value, berOID, 0)
+		if err != nil {
+			return nil, err
+		}
+		v := parts[1]
+		value := snmpValue{tag: v.tag, found: true}
+		switch v.tag {
+		case berCounter32, berGauge32, berTimeTicks, berCounter64:
+			value.num = berUint(v.value)
+		case berInteger:
+			value.num = uint64(berSigned(v.value))
+		case berOctetString:
+			value.text = string(v.value)
+		case berNoSuchObj, berNoSuchInst, berEndOfMib, berNull:
+			value.found = false
+		}
+		values[berDecodeOID(parts[0].value)] = value
+	}
+	return values, nil
+}
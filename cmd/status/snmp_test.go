@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeAgent serves handle on a loopback UDP socket; it builds the reply to
+// each request, or returns nil to stay silent.
+func fakeAgent(t *testing.T, handle func(req []byte) []byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no loopback UDP: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, snmpMaxMessage)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := handle(append([]byte(nil), buf[:n]...)); resp != nil {
+				conn.WriteTo(resp, from)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// agentResponse answers a GetRequest PDU from values, with noSuchInstance
+// for OIDs it does not know.
+func agentResponse(t *testing.T, pdu berTLV, values map[string][]byte) []byte {
+	t.Helper()
+	fields, err := berItems(pdu.value, berInteger, berInteger, berInteger, berSequence)
+	if err != nil {
+		t.Errorf("agent: bad PDU: %v", err)
+		return nil
+	}
+	binds, _ := berItems(fields[3].value)
+	var out [][]byte
+	for _, bind := range binds {
+		parts, _ := berItems(bind.value, berOID)
+		oid := berDecodeOID(parts[0].value)
+		enc, _ := berOIDValue(oid)
+		value, ok := values[oid]
+		if !ok {
+			value = berEncode(berNoSuchInst, nil)
+		}
+		out = append(out, berSeq(berSequence, enc, value))
+	}
+	return berSeq(pduResponse, berInt(berSigned(fields[0].value)), berInt(0), berInt(0), berSeq(berSequence, out...))
+}
+
+func counter64(v uint64) []byte {
+	b := []byte{0}
+	for i := 56; i >= 0; i -= 8 {
+		b = append(b, byte(v>>i))
+	}
+	return berEncode(berCounter64, b)
+}
+
+func TestSNMPv2c(t *testing.T) {
+	// The agent runs in its own goroutine, so its counter is shared atomically.
+	var octets atomic.Uint64
+	octets.Store(1 << 40)
+	addr := fakeAgent(t, func(req []byte) []byte {
+		items, _ := berItems(req, berSequence)
+		parts, err := berItems(items[0].value, berInteger, berOctetString, 0)
+		if err != nil || string(parts[1].value) != "secret" {
+			return nil // Agents ignore a wrong community.
+		}
+		in := octets.Add(10 << 20)
+		values := map[string][]byte{
+			oidIfName + ".2":        berOctets([]byte("ge-0/0/1")),
+			oidIfHCInOctets + ".2":  counter64(in),
+			oidIfHCOutOctets + ".2": counter64(in / 2),
+			oidIfInOctets + ".3":    berEncode(berCounter32, []byte{0x00, 0xff, 0xff, 0xff, 0xf0}),
+			oidIfOutOctets + ".3":   berEncode(berCounter32, []byte{0x10}),
+		}
+		return berSeq(berSequence, berInt(snmpVersion2c), berOctets([]byte("secret")), agentResponse(t, parts[2], values))
+	})
+
+	client := &snmpClient{addr: addr, community: "secret", timeout: time.Second}
+	p := newSNMPPoller(client, "router", []int{2, 3})
+	var rows []NetworkStatus
+	for i := range 2 {
+		counters, err := p.fetch()
+		if err != nil {
+			t.Fatalf("fetch: %v", err)
+		}
+		if counters[3].hc || !counters[2].hc {
+			t.Fatalf("counters = %+v, want ifHC* for 2 and 32-bit for 3", counters)
+		}
+		rows = p.rates(snmpPoll{at: time.Duration(i) * time.Second, counters: counters}, 10)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rows = %+v, want two after the baseline", rows)
+	}
+	if r := rows[0]; r.Name != "ge-0/0/1@router" || r.RxRateMBs != 10 || r.TxRateMBs != 5 || r.Kind != ifaceKindRemote || r.inTotals() {
+		t.Errorf("row = %+v, want ge-0/0/1@router at 10/5 MB/s, remote and untotaled", r)
+	}
+	if rows[1].Name != "if3@router" || len(rows[0].RxHistory) != 1 {
+		t.Errorf("rows = %+v, want the index as a fallback name and one history point", rows)
+	}
+
+	// The 32-bit counter wrapped between polls.
+	p.prev[3] = snmpCounters{rx: 1<<32 - 16, tx: 0x10}
+	in := octets.Load()
+	p.prev[2] = snmpCounters{rx: in, tx: in / 2, hc: true}
+	rows = p.rates(snmpPoll{at: 2 * time.Second, counters: map[int]snmpCounters{2: p.prev[2], 3: {rx: 16, tx: 0x10}}}, 10)
+	if got := rows[1].RxRateMBs * 1024 * 1024; got != 32 {
+		t.Errorf("rate across a 32-bit wrap = %v B/s, want 32", got)
+	}
+
+	bad := &snmpClient{addr: addr, community: "public", timeout: 50 * time.Millisecond, retries: 1}
+	if _, err := bad.get([]string{oidIfName + ".2"}); err == nil || !strings.Contains(err.Error(), "no response after 2 tries") {
+		t.Errorf("wrong community: err = %v, want a timeout", err)
+	}
+	if _, err := newSNMPPoller(client, "router", []int{7}).fetch(); err == nil {
+		t.Errorf("unknown ifIndex should fail")
+	}
+}
+
+func TestSNMPSnapshotEvents(t *testing.T) {
+	c := &Collector{events: &eventRing{}, clock: newMonoClock(), rxHistoryBuf: NewRingBuffer(10)}
+	c.snmp = newSNMPPoller(&snmpClient{addr: "127.0.0.1:9", timeout: 20 * time.Millisecond}, "10.0.0.1", []int{2})
+	deadline := time.Now().Add(2 * time.Second)
+	for c.events.recent() == nil && time.Now().Before(deadline) {
+		c.snmpSnapshot(time.Now())
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := c.events.recent()
+	if len(got) != 1 || !strings.Contains(got[0].Message, "snmp") {
+		t.Fatalf("events = %+v, want one snmp failure", got)
+	}
+}
+
+func TestSNMPv3(t *testing.T) {
+	// RFC 3414 appendix A.3.
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+	if got := hex.EncodeToString(usmPasswordKey(md5.New, "maplesyrup", engineID)); got != "526f5eed9fcce26f8964c2930787d82b" {
+		t.Errorf("MD5 localized key = %s", got)
+	}
+	if got := hex.EncodeToString(usmPasswordKey(sha1.New, "maplesyrup", engineID)); got != "6695febc9288e36282235fc7151f128497b38f3f" {
+		t.Errorf("SHA localized key = %s", got)
+	}
+
+	for _, bad := range []string{"auth=sha:longenough", "user=u,auth=des:longenough", "user=u,priv=aes:longenough", "user=u,auth=sha:short"} {
+		if _, err := parseSNMPv3(bad); err == nil {
+			t.Errorf("parseSNMPv3(%q) should fail", bad)
+		}
+	}
+	u, err := parseSNMPv3("user=mole,auth=sha:authpass1,priv=aes:privpass1")
+	if err != nil {
+		t.Fatalf("parseSNMPv3: %v", err)
+	}
+
+	// A v3 agent with the same credentials, rebooted once the first
+	// request is answered so the client has to rediscover.
+	agentEngine := []byte("\x80\x00\x1f\x88\x80mole-test")
+	authKey := usmPasswordKey(sha1.New, "authpass1", agentEngine)
+	privKey := usmPasswordKey(sha1.New, "privpass1", agentEngine)[:16]
+	boots, requests := int64(7), 0
+	oid := oidIfHCInOctets + ".2"
+	reply := func(msgID int64, flags byte, pdu []byte) []byte {
+		scoped := berSeq(berSequence, berOctets(agentEngine), berOctets(nil), pdu)
+		salt := []byte("saltsalt")
+		data := scoped
+		var authParams, privParams []byte
+		if flags&usmFlagAuth != 0 {
+			authParams = make([]byte, usmAuthParamLen)
+		}
+		if flags&usmFlagPriv != 0 {
+			sealed, _ := usmAES(privKey, boots, 100, salt, scoped, true)
+			data, privParams = berOctets(sealed), salt
+		}
+		msg, at := usmMessage(msgID, flags, usmSecurityParams(agentEngine, boots, 100, "mole", authParams, privParams), data)
+		if authParams != nil {
+			mac := hmac.New(sha1.New, authKey)
+			mac.Write(msg)
+			copy(msg[at:], mac.Sum(nil)[:usmAuthParamLen])
+		}
+		return msg
+	}
+	report := func(msgID int64, oid string) []byte {
+		enc, _ := berOIDValue(oid)
+		bind := berSeq(berSequence, enc, berEncode(berCounter32, []byte{1}))
+		return reply(msgID, 0, berSeq(pduReport, berInt(0), berInt(0), berInt(0), berSeq(berSequence, bind)))
+	}
+	addr := fakeAgent(t, func(req []byte) []byte {
+		m, err := parseUSMMessage(req)
+		if err != nil {
+			t.Errorf("agent: %v", err)
+			return nil
+		}
+		if len(m.engineID) == 0 {
+			return report(m.msgID, "1.3.6.1.6.3.15.1.1.4.0") // Discovery.
+		}
+		if m.boots != boots {
+			return report(m.msgID, usmNotInTimeWindow)
+		}
+		if m.flags != usmFlagAuth|usmFlagPriv|usmFlagReportable {
+			t.Errorf("agent: request flags %#x, want auth, priv and reportable", m.flags)
+			return nil
+		}
+		if probe := (snmpUSM{authNew: sha1.New, authKey: authKey}); !probe.verify(req, m) {
+			return report(m.msgID, "1.3.6.1.6.3.15.1.1.5.0")
+		}
+		plain, _ := usmAES(privKey, m.boots, m.time, m.privParams, m.data, false)
+		item, _, _ := berNext(plain)
+		parts, err := berItems(item.value, berOctetString, berOctetString, 0)
+		if err != nil {
+			t.Errorf("agent: bad scoped PDU: %v", err)
+			return nil
+		}
+		requests++
+		resp := agentResponse(t, parts[2], map[string][]byte{oid: counter64(42)})
+		out := reply(m.msgID, usmFlagAuth|usmFlagPriv, resp)
+		if requests == 1 {
+			boots++ // Reboot after this answer.
+		}
+		return out
+	})
+
+	client := &snmpClient{addr: addr, v3: u, timeout: time.Second}
+	for i := range 2 {
+		values, err := client.get([]string{oid})
+		if err != nil {
+			t.Fatalf("get %d: %v", i, err)
+		}
+		if v := values[oid]; !v.found || v.num != 42 {
+			t.Fatalf("get %d = %+v, want 42", i, v)
+		}
+	}
+	if u.engineBoot != 8 {
+		t.Errorf("engine boots = %d, want 8 after rediscovery", u.engineBoot)
+	}
+
+	wrong, _ := parseSNMPv3("user=mole,auth=sha:wrongpass1,priv=aes:privpass1")
+	if _, err := (&snmpClient{addr: addr, v3: wrong, timeout: time.Second}).get([]string{oid}); err == nil || !strings.Contains(err.Error(), "wrong digest") {
+		t.Errorf("wrong auth password: err = %v, want a wrong digest report", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// snapshotSource produces the snapshots the dashboard renders: the local
+// Collector, or a remote Mole instance via --source-url.
+type snapshotSource interface {
+	Collect() (MetricsSnapshot, error)
+}
+
+const (
+	remoteTimeout    = 3 * time.Second
+	remoteMaxBackoff = 30 * time.Second
+)
+
+// remoteSource polls a remote JSON snapshot endpoint. While the endpoint is
+// unreachable it returns the last good snapshot and backs off exponentially.
+type remoteSource struct {
+	url      string
+	client   *http.Client
+	last     MetricsSnapshot
+	failures int
+	retryAt  time.Time
+	lastErr  error
+	events   *eventRing // Agent going away and coming back, and the agent's own events.
+	eventsAt time.Time  // The newest agent event already relayed.
+}
+
+func newRemoteSource(rawURL string) (*remoteSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid --source-url %q: want http(s)://host[:port]/path", rawURL)
+	}
+	return &remoteSource{url: rawURL, client: &http.Client{Timeout: remoteTimeout}, events: &eventRing{}}, nil
+}
+
+func (r *remoteSource) Collect() (MetricsSnapshot, error) {
+	now := time.Now()
+	if now.Before(r.retryAt) {
+		return r.last, r.unreachable(now)
+	}
+
+	snapshot, err := r.fetch()
+	if err != nil {
+		r.failures++
+		if r.failures == 1 {
+			r.events.add(severityError, categorySystem, "source unreachable: "+err.Error())
+		}
+		r.lastErr = err
+		r.retryAt = now.Add(remoteBackoff(r.failures))
+		return r.last, r.unreachable(now)
+	}
+	if r.failures > 0 {
+		r.events.add(severityInfo, categorySystem, "source reachable again")
+	}
+	r.failures = 0
+	r.lastErr = nil
+	sanitizeSnapshot(&snapshot) // A remote agent may be older or buggier than us.
+	r.relayEvents(snapshot)
+	snapshot.Events = r.events.recent()
+	r.last = snapshot
+	return snapshot, nil
+}
+
+func (r *remoteSource) fetch() (MetricsSnapshot, error) {
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		return MetricsSnapshot{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return MetricsSnapshot{}, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var snapshot MetricsSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return MetricsSnapshot{}, fmt.Errorf("decode snapshot: %w", err)
+	}
+	// Snapshots from agents predating schema_version decode as 0 and are fine.
+	if snapshot.SchemaVersion > snapshotSchemaVersion {
+		return MetricsSnapshot{}, fmt.Errorf("snapshot schema_version %d is newer than supported %d; upgrade mo", snapshot.SchemaVersion, snapshotSchemaVersion)
+	}
+	return snapshot, nil
+}
+
+// relayEvents adds the agent's events (--json-events on its side) that are
+// newer than the last one relayed, marked with its host name. Each snapshot
+// repeats the agent's recent ones, so only the new ones are added.
+func (r *remoteSource) relayEvents(snapshot MetricsSnapshot) {
+	for _, ev := range snapshot.RecentEvents {
+		if !ev.At.After(r.eventsAt) {
+			continue
+		}
+		r.eventsAt = ev.At
+		if snapshot.Host != "" {
+			ev.Message = snapshot.Host + ": " + ev.Message
+		}
+		r.events.addEvent(ev)
+	}
+}
+
+func (r *remoteSource) unreachable(now time.Time) error {
+	return fmt.Errorf("source unreachable, retrying in %s: %w", r.retryAt.Sub(now).Round(time.Second), r.lastErr)
+}
+
+// remoteBackoff doubles the retry delay per consecutive failure, capped at remoteMaxBackoff.
+func remoteBackoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	d := time.Second << min(failures-1, 10)
+	return min(d, remoteMaxBackoff)
+}
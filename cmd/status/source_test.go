@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoteSourceCollect(t *testing.T) {
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(MetricsSnapshot{Host: "agent", Network: []NetworkStatus{{Name: "eth0", RxRateMBs: 2}}})
+	}))
+	defer srv.Close()
+
+	src, err := newRemoteSource(srv.URL + "/api/snapshot")
+	if err != nil {
+		t.Fatalf("newRemoteSource() error = %v", err)
+	}
+	got, err := src.Collect()
+	if err != nil || got.Host != "agent" || len(got.Network) != 1 {
+		t.Fatalf("Collect() = %+v, %v", got, err)
+	}
+
+	// Failures keep the last snapshot and schedule a retry.
+	up = false
+	got, err = src.Collect()
+	if err == nil || !strings.Contains(err.Error(), "source unreachable") {
+		t.Fatalf("Collect() error = %v, want source unreachable", err)
+	}
+	if got.Host != "agent" {
+		t.Fatalf("Collect() should keep the last snapshot while unreachable, got %+v", got)
+	}
+	if src.retryAt.IsZero() {
+		t.Fatalf("expected a retry to be scheduled")
+	}
+}
+
+func TestRemoteSourceRejectsNewerSchema(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(MetricsSnapshot{SchemaVersion: snapshotSchemaVersion + 1, Host: "agent"})
+	}))
+	defer srv.Close()
+
+	src, err := newRemoteSource(srv.URL)
+	if err != nil {
+		t.Fatalf("newRemoteSource() error = %v", err)
+	}
+	if _, err := src.Collect(); err == nil || !strings.Contains(err.Error(), "schema_version") {
+		t.Fatalf("Collect() error = %v, want schema_version error", err)
+	}
+}
+
+func TestRemoteSourceRelaysEvents(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	agentEvents := []StatusEvent{{At: at, Severity: severityWarn, Category: categoryProxy, Message: "proxy unreachable"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(MetricsSnapshot{Host: "agent", RecentEvents: agentEvents})
+	}))
+	defer srv.Close()
+
+	src, err := newRemoteSource(srv.URL)
+	if err != nil {
+		t.Fatalf("newRemoteSource() error = %v", err)
+	}
+	if _, err := src.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	// The next snapshot repeats the first event; only the new one is added.
+	agentEvents = append(agentEvents, StatusEvent{At: at.Add(time.Minute), Severity: severityInfo, Category: categoryProxy, Message: "proxy reachable"})
+	got, err := src.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Events) != 2 || got.Events[0].Message != "agent: proxy unreachable" || !got.Events[0].At.Equal(at) || got.Events[1].Message != "agent: proxy reachable" {
+		t.Fatalf("Events = %+v, want the agent's two events once each", got.Events)
+	}
+}
+
+func TestRemoteBackoff(t *testing.T) {
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, w := range want {
+		if got := remoteBackoff(i + 1); got != w {
+			t.Errorf("remoteBackoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	for _, bad := range []string{"agent:9100", "ftp://agent/x", "http://"} {
+		if _, err := newRemoteSource(bad); err == nil {
+			t.Errorf("newRemoteSource(%q) expected error", bad)
+		}
+	}
+}
+
+func TestHelperSocket(t *testing.T) {
+	path := t.TempDir() + "/helper.sock"
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("no unix sockets: %v", err)
+	}
+	defer ln.Close()
+	real := helperCollectors["connections"]
+	t.Cleanup(func() { helperCollectors["connections"], helperAnswers = real, &helperAnswerCache{} })
+	helperAnswers = &helperAnswerCache{}
+	helperCollectors["connections"] = func(_ context.Context, r *helperResponse) error {
+		r.Connections = &ConnectionStatus{Total: 42, Listeners: []Listener{{Port: 22, PID: 1, Process: "sshd"}}}
+		return nil
+	}
+	go serveHelper(ln)
+
+	c := NewCollector()
+	c.helper = &helperClient{path: path}
+	got, err := c.collectConnections()
+	if err != nil || got.Total != 42 || len(got.Listeners) != 1 || got.Listeners[0].Process != "sshd" {
+		t.Fatalf("collectConnections() via helper = %+v, %v", got, err)
+	}
+	if _, err := c.helper.call("disks"); err == nil || !strings.Contains(err.Error(), `unknown collector "disks"`) {
+		t.Errorf("unknown collector error = %v", err)
+	}
+	if resp := answerHelper([]byte(`{"v":2,"collect":"ping"}`)); !strings.Contains(resp.Error, "unsupported protocol version 2") {
+		t.Errorf("newer protocol answer = %+v", resp)
+	}
+
+	// With the helper gone, collection falls back to local and says so once.
+	ln.Close()
+	for range 2 {
+		c.collectConnections()
+	}
+	var warned int
+	for _, ev := range c.events.recent() {
+		if strings.HasPrefix(ev.Message, "privileged helper unavailable") {
+			warned++
+		}
+	}
+	if warned != 1 || !c.helperDown {
+		t.Errorf("helper loss logged %d times, want once", warned)
+	}
+}
+
+func TestHelperAnswerCache(t *testing.T) {
+	var runs int
+	collect := func(_ context.Context, r *helperResponse) error {
+		runs++
+		r.Thermal = &helperThermal{Level: "nominal"}
+		return nil
+	}
+	h := &helperAnswerCache{}
+	for range 5 {
+		if resp := h.get("thermal", collect); resp.Thermal == nil || resp.Thermal.Level != "nominal" {
+			t.Fatalf("cached answer = %+v", resp)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("collector ran %d times within the TTL, want once", runs)
+	}
+	h.at["thermal"] = time.Now().Add(-helperCacheTTL)
+	h.get("thermal", collect)
+	if runs != 2 {
+		t.Errorf("collector ran %d times after the TTL, want twice", runs)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sparkMinGap keeps `mo status spark` from sampling faster than the counters
+// move: below it most points would read zero.
+const sparkMinGap = 100 * time.Millisecond
+
+// runSpark prints one line with the rx and tx sparklines of iface, or of
+// the totals when iface is empty, and exits (`mo status spark`). A source
+// that brings its own history, such as --source-url, answers from the first
+// sample; otherwise it samples width points spread over window, so a
+// shorter window answers sooner with noisier points.
+func runSpark(w io.Writer, collector snapshotSource, iface string, width int, window time.Duration) error {
+	gap := max(window/time.Duration(width), sparkMinGap)
+	deadline := time.Now().Add(window)
+	var rx, tx []float64
+	for {
+		snapshot, err := collector.Collect()
+		if err != nil {
+			return err
+		}
+		// A warmup sample only sets the rate baseline and lists no interfaces.
+		if !snapshot.NetworkWarmup {
+			if rx, tx, err = sparkHistory(snapshot, iface); err != nil {
+				return err
+			}
+			if len(rx) >= width || !time.Now().Before(deadline) {
+				break
+			}
+		}
+		time.Sleep(gap)
+	}
+	line := plainSparkLine(rx, tx, width)
+	if asciiOutput {
+		line = toASCII(line)
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// sparkHistory picks the rx/tx history of one interface, or the totals.
+func sparkHistory(s MetricsSnapshot, iface string) (rx, tx []float64, err error) {
+	if iface == "" {
+		return s.NetworkHistory.RxHistory, s.NetworkHistory.TxHistory, nil
+	}
+	names := make([]string, 0, len(s.Network))
+	for _, n := range s.Network {
+		if n.Name == iface {
+			return n.RxHistory, n.TxHistory, nil
+		}
+		names = append(names, n.Name)
+	}
+	return nil, nil, fmt.Errorf("no interface %q (have %s)", iface, strings.Join(names, ", "))
+}
+
+// plainSparkLine draws rx and tx as "↓▁▂▅ ↑▁▁▂" without colors, for a status
+// bar or prompt. Both share one scale so their heights compare.
+func plainSparkLine(rx, tx []float64, width int) string {
+	rx, tx = sparkWindow(rx, width), sparkWindow(tx, width)
+	peak := math.Max(0.1, math.Max(slices.Max(rx), slices.Max(tx)))
+	glyph := sparkGlyphs[sparkStyle]
+	var b strings.Builder
+	draw := func(label string, points []float64) {
+		b.WriteString(label)
+		for _, v := range points {
+			b.WriteRune(glyph(v / peak))
+		}
+	}
+	draw("↓", rx)
+	draw(" ↑", tx)
+	return b.String()
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// speedtestHello opens every --speedtest connection: the protocol version
+// and the direction, as seen from the client.
+const speedtestHello = "MOLE-SPEEDTEST 1 "
+
+const (
+	speedtestChunk     = 64 << 10
+	speedtestAckWait   = 5 * time.Second // For the server's byte count after an upload.
+	speedtestGraphCols = 20
+	speedtestMaxTime   = time.Minute // Longest --speedtest-time; the server cuts off tests past it.
+	speedtestMaxConns  = 4           // Tests the server runs at once; it refuses more.
+)
+
+// speedResult is one direction of a throughput test.
+type speedResult struct {
+	direction string // download or upload.
+	bytes     int64
+	elapsed   time.Duration
+}
+
+// rate is the average in MB/s, the unit of the interface rates.
+func (r speedResult) rate() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+	return float64(r.bytes) / 1024 / 1024 / r.elapsed.Seconds()
+}
+
+// speedMeter shows a test in progress: on a terminal one line redrawn each
+// second with a sparkline of the per-second rates, elsewhere a line per second.
+type speedMeter struct {
+	w         io.Writer
+	live      bool
+	direction string
+	start     time.Time
+	lastAt    time.Time
+	lastBytes int64
+	rates     []float64
+}
+
+func newSpeedMeter(w io.Writer, live bool, direction string) *speedMeter {
+	now := time.Now()
+	return &speedMeter{w: w, live: live, direction: direction, start: now, lastAt: now}
+}
+
+// update records total bytes moved so far, drawing once per refreshInterval.
+func (m *speedMeter) update(total int64) {
+	now := time.Now()
+	if now.Sub(m.lastAt) < refreshInterval {
+		return
+	}
+	m.rates = append(m.rates, float64(total-m.lastBytes)/1024/1024/now.Sub(m.lastAt).Seconds())
+	m.lastAt, m.lastBytes = now, total
+	line := fmt.Sprintf("%-8s %3ds %s %s", m.direction, int(now.Sub(m.start).Seconds()), m.graph(), formatRate(m.rates[len(m.rates)-1]))
+	if m.live {
+		fmt.Fprint(m.w, "\r"+line+"\x1b[K")
+	} else {
+		fmt.Fprintln(m.w, line)
+	}
+}
+
+func (m *speedMeter) graph() string {
+	recent := m.rates[max(len(m.rates)-speedtestGraphCols, 0):]
+	peak := 0.1
+	for _, v := range recent {
+		peak = max(peak, v)
+	}
+	glyph := sparkGlyphs[sparkStyle]
+	var b strings.Builder
+	for _, v := range recent {
+		b.WriteRune(glyph(v / peak))
+	}
+	return fmt.Sprintf("%-*s", speedtestGraphCols, b.String())
+}
+
+// done replaces the meter with the result.
+func (m *speedMeter) done(r speedResult) {
+	if m.live {
+		fmt.Fprint(m.w, "\r\x1b[K")
+	}
+	fmt.Fprintf(m.w, "%-8s %s (%s in %.1fs)\n", r.direction, formatRate(r.rate()), formatBytes(uint64(r.bytes)), r.elapsed.Seconds())
+}
+
+// runSpeedtest measures throughput to target for d in each direction
+// (--speedtest). A host:port is a mo status --speedtest-serve endpoint and is
+// tested both ways; an http(s) URL is downloaded, which tests only that way.
+func runSpeedtest(w io.Writer, live bool, target string, d time.Duration) error {
+	fmt.Fprintf(w, "Speed test to %s, %s each way. This sends real traffic.\n", target, d)
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		meter := newSpeedMeter(w, live, "download")
+		r, err := speedtestHTTP(target, d, meter.update)
+		if err != nil {
+			return err
+		}
+		meter.done(r)
+		fmt.Fprintln(w, "upload   skipped (needs a --speedtest-serve endpoint)")
+		return nil
+	}
+	for _, direction := range []string{"download", "upload"} {
+		meter := newSpeedMeter(w, live, direction)
+		r, err := speedtestTCP(target, direction, d, meter.update)
+		if err != nil {
+			return err
+		}
+		meter.done(r)
+	}
+	return nil
+}
+
+// speedtestHTTP times a GET of url for up to d, reporting progress after
+// each read. A body that ends sooner gives the rate over the whole body.
+func speedtestHTTP(url string, d time.Duration, progress func(int64)) (speedResult, error) {
+	r := speedResult{direction: "download"}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return r, err
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return r, fmt.Errorf("speedtest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return r, fmt.Errorf("speedtest: %s answered %s", url, resp.Status)
+	}
+	buf := make([]byte, speedtestChunk)
+	for {
+		n, err := resp.Body.Read(buf)
+		r.bytes += int64(n)
+		progress(r.bytes)
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				return r, fmt.Errorf("speedtest: %w", err)
+			}
+			break
+		}
+	}
+	r.elapsed = time.Since(start)
+	return r, nil
+}
+
+// speedtestTCP runs one direction against a --speedtest-serve endpoint for d.
+// A download counts what arrives; an upload counts what the server says it
+// received, not what went into the local socket buffer.
+func speedtestTCP(addr, direction string, d time.Duration, progress func(int64)) (speedResult, error) {
+	r := speedResult{direction: direction}
+	conn, err := net.DialTimeout("tcp", addr, remoteTimeout)
+	if err != nil {
+		return r, fmt.Errorf("speedtest: %w", err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, speedtestHello+direction+"\n"); err != nil {
+		return r, fmt.Errorf("speedtest: %w", err)
+	}
+	start := time.Now()
+	_ = conn.SetDeadline(start.Add(d))
+	buf := make([]byte, speedtestChunk)
+	for {
+		var n int
+		if direction == "download" {
+			n, err = conn.Read(buf)
+		} else {
+			n, err = conn.Write(buf)
+		}
+		r.bytes += int64(n)
+		progress(r.bytes)
+		if err != nil {
+			break
+		}
+	}
+	r.elapsed = time.Since(start)
+	var timeout net.Error
+	if !errors.As(err, &timeout) || !timeout.Timeout() {
+		return r, fmt.Errorf("speedtest: connection ended early: %w", err)
+	}
+	if direction == "download" {
+		return r, nil
+	}
+
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return r, nil
+	}
+	_ = conn.SetDeadline(time.Now().Add(speedtestAckWait))
+	if err := tcp.CloseWrite(); err != nil {
+		return r, nil
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	count, perr := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(line), "received "), 10, 64)
+	if err == nil && perr == nil {
+		r.bytes = count
+		r.elapsed = time.Since(start)
+	}
+	return r, nil
+}
+
+// runSpeedtestServer answers --speedtest clients on addr until interrupted
+// (--speedtest-serve).
+func runSpeedtestServer(w io.Writer, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("--speedtest-serve: %w", err)
+	}
+	fmt.Fprintf(w, "Speed test server listening on %s\n", ln.Addr())
+	return serveSpeedtest(ln)
+}
+
+// serveSpeedtest handles each connection on ln: after the hello line it
+// sends data until the client hangs up (download), or reads until the client
+// closes its side and replies with the byte count (upload). A test ends
+// after speedtestMaxTime whatever the client does, and beyond
+// speedtestMaxConns tests at once new connections are closed unanswered,
+// since a queued client would time the wait.
+func serveSpeedtest(ln net.Listener) error {
+	slots := make(chan struct{}, speedtestMaxConns)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			fmt.Fprintln(os.Stderr, "mo status: speedtest: busy, refusing", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer func() { <-slots }()
+			defer conn.Close()
+			rd := bufio.NewReader(conn)
+			_ = conn.SetReadDeadline(time.Now().Add(remoteTimeout))
+			hello, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			_ = conn.SetDeadline(time.Now().Add(speedtestMaxTime + speedtestAckWait))
+			switch strings.TrimSpace(hello) {
+			case speedtestHello + "download":
+				buf := make([]byte, speedtestChunk)
+				for {
+					if _, err := conn.Write(buf); err != nil {
+						return
+					}
+				}
+			case speedtestHello + "upload":
+				n, err := io.Copy(io.Discard, rd)
+				if err == nil {
+					fmt.Fprintf(conn, "received %d\n", n)
+				}
+			default:
+				fmt.Fprintln(os.Stderr, "mo status: speedtest: unknown request from", conn.RemoteAddr())
+			}
+		}()
+	}
+}
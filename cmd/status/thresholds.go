@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rateThresholds color an interface's rates yellow from warn and red from
+// crit, in MB/s. Zero leaves that level off.
+type rateThresholds struct {
+	warnRx, critRx, warnTx, critTx float64
+	// Fixed sparkline scales (--rx-ceiling, --tx-ceiling); 0 auto-scales
+	// to the largest value drawn.
+	rxCeiling, txCeiling float64
+}
+
+// thresholdKeys name the fields in the prefs file, in the order they are written.
+var thresholdKeys = []string{"warn_rx", "crit_rx", "warn_tx", "crit_tx", "rx_ceiling", "tx_ceiling"}
+
+func (t *rateThresholds) field(key string) *float64 {
+	switch key {
+	case "warn_rx":
+		return &t.warnRx
+	case "crit_rx":
+		return &t.critRx
+	case "warn_tx":
+		return &t.warnTx
+	case "crit_tx":
+		return &t.critTx
+	case "rx_ceiling":
+		return &t.rxCeiling
+	case "tx_ceiling":
+		return &t.txCeiling
+	}
+	return nil
+}
+
+// parseRateThresholds parses "warn_rx=50,crit_rx=100"; unlisted fields stay zero.
+func parseRateThresholds(value string) (rateThresholds, error) {
+	var t rateThresholds
+	for _, item := range splitList(value) {
+		key, raw, _ := strings.Cut(item, "=")
+		f := t.field(strings.TrimSpace(key))
+		if f == nil {
+			return rateThresholds{}, fmt.Errorf("unknown threshold %q (want %s)", key, strings.Join(thresholdKeys, ", "))
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil || v < 0 {
+			return rateThresholds{}, fmt.Errorf("threshold %s must be a non-negative MB/s value, got %q", key, raw)
+		}
+		*f = v
+	}
+	return t, t.check()
+}
+
+// check rejects a critical level below its warning level.
+func (t rateThresholds) check() error {
+	if t.critRx > 0 && t.critRx < t.warnRx {
+		return fmt.Errorf("crit_rx %g is below warn_rx %g", t.critRx, t.warnRx)
+	}
+	if t.critTx > 0 && t.critTx < t.warnTx {
+		return fmt.Errorf("crit_tx %g is below warn_tx %g", t.critTx, t.warnTx)
+	}
+	return nil
+}
+
+// String formats the set fields the way parseRateThresholds reads them.
+func (t rateThresholds) String() string {
+	var parts []string
+	for _, key := range thresholdKeys {
+		if v := *t.field(key); v > 0 {
+			parts = append(parts, key+"="+strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// over returns t with every field it leaves unset taken from base, so a
+// per-interface entry only needs the levels it changes.
+func (t rateThresholds) over(base rateThresholds) rateThresholds {
+	for _, key := range thresholdKeys {
+		if f := t.field(key); *f == 0 {
+			*f = *base.field(key)
+		}
+	}
+	return t
+}
+
+// thresholdsFor returns the levels for an interface: its own entry from the
+// prefs file first, then the global --warn-*/--crit-* flags.
+func thresholdsFor(name string, global rateThresholds, perIface map[string]rateThresholds) rateThresholds {
+	if t, ok := perIface[name]; ok {
+		return t.over(global)
+	}
+	return global
+}
+
+// graphCeiling is the fixed scale of a combined rx+tx graph: the sum of the
+// ceilings that are set, or 0 to auto-scale when neither is.
+func (t rateThresholds) graphCeiling() float64 {
+	return t.rxCeiling + t.txCeiling
+}
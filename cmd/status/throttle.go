@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Throttled collectors, by the name used with --collector-interval.
+const (
+	collectorConnections = "connections"
+	collectorProcesses   = "processes"
+	collectorDisks       = "disks"
+	collectorAppProxies  = "app-proxies"
+	collectorPing        = "ping"
+	collectorGateways    = "gateways"
+	collectorPublicIP    = "public-ip"
+	collectorLinks       = "links"
+	collectorProxyCheck  = "proxy-check"
+	collectorRoutes      = "routes"
+	collectorWiFi        = "wifi"
+	collectorSNMP        = "snmp"
+	collectorARP         = "arp"
+	collectorThermal     = "thermal"
+	collectorFirewall    = "firewall"
+)
+
+// defaultCollectorIntervals is how often each expensive collector actually runs.
+// Network and disk I/O counters are cheap and stay on every refresh so the
+// sparklines remain smooth.
+var defaultCollectorIntervals = map[string]time.Duration{
+	collectorConnections: 5 * time.Second,  // Walks /proc on Linux, lsof on macOS.
+	collectorProcesses:   2 * time.Second,  // ps plus a pass over every process.
+	collectorDisks:       5 * time.Second,  // statfs per mount; usage moves slowly.
+	collectorAppProxies:  30 * time.Second, // Spawns git; config rarely changes.
+	collectorPing:        5 * time.Second,  // One probe per interval is plenty.
+	collectorGateways:    10 * time.Second, // Two commands; neighbor state is slow to change.
+	collectorPublicIP:    5 * time.Minute,  // Third-party endpoint; be polite.
+	collectorLinks:       30 * time.Second, // One command per wired interface; renegotiation is rare.
+	collectorProxyCheck:  30 * time.Second, // A TCP connect to the proxy.
+	collectorRoutes:      30 * time.Second, // One command; only VPNs and link changes move it.
+	collectorWiFi:        5 * time.Second,  // The driver averages the level; faster adds noise, not detail.
+	collectorSNMP:        5 * time.Second,  // A UDP round trip to another device; many agents cache counters for a few seconds.
+	collectorARP:         30 * time.Second, // arping sends probes onto the LAN; conflicts last minutes, not seconds.
+	collectorThermal:     5 * time.Second,  // powermetrics samples for 200ms as root; pressure changes over tens of seconds.
+	collectorFirewall:    time.Minute,      // Up to four commands; rules change when someone edits them.
+}
+
+// throttled caches a collector result and refreshes it at most once per interval.
+type throttled[T any] struct {
+	every time.Duration
+	last  time.Time
+	value T
+}
+
+// get returns the cached value, calling fetch only once the interval has elapsed.
+// A failed fetch keeps the previous value and still waits out the interval, so a
+// broken collector does not retry on every cycle.
+func (t *throttled[T]) get(now time.Time, fetch func() (T, error)) (T, error) {
+	if !t.last.IsZero() && now.Sub(t.last) < t.every {
+		return t.value, nil
+	}
+	t.last = now
+	v, err := fetch()
+	if err != nil {
+		return t.value, err
+	}
+	t.value = v
+	return v, nil
+}
+
+// setCollectorIntervals overrides the refresh interval of named collectors.
+func (c *Collector) setCollectorIntervals(intervals map[string]time.Duration) {
+	for name, every := range intervals {
+		switch name {
+		case collectorConnections:
+			c.conns.every = every
+		case collectorProcesses:
+			c.topProcs.every = every
+			c.memProcs.every = every
+			c.procStates.every = every
+		case collectorDisks:
+			c.disks.every = every
+		case collectorAppProxies:
+			c.appProxyCache.every = every
+		case collectorPing:
+			c.pingEvery = every
+		case collectorGateways:
+			c.gateways.every = every
+		case collectorPublicIP:
+			c.publicEvery = every
+		case collectorLinks:
+			c.links.every = every
+		case collectorProxyCheck:
+			c.proxyProbe.every = every
+		case collectorRoutes:
+			c.routes.every = every
+		case collectorWiFi:
+			c.wifi.every = every
+		case collectorSNMP:
+			c.snmpProbe.every = every
+		case collectorARP:
+			c.arpProbe.every = every
+		case collectorThermal:
+			c.pressure.every = every
+		case collectorFirewall:
+			c.firewall.every = every
+		}
+	}
+}
+
+// parseCollectorIntervals parses "name=duration" pairs, e.g. "connections=10s,disks=1m".
+func parseCollectorIntervals(value string) (map[string]time.Duration, error) {
+	intervals := make(map[string]time.Duration)
+	for _, pair := range splitList(value) {
+		name, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("collector interval %q: want name=duration", pair)
+		}
+		name = strings.TrimSpace(name)
+		if _, known := defaultCollectorIntervals[name]; !known {
+			return nil, fmt.Errorf("unknown collector %q (want %s)", name, strings.Join(collectorNames(), ", "))
+		}
+		every, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil || every < 0 {
+			return nil, fmt.Errorf("collector %s: invalid interval %q", name, raw)
+		}
+		intervals[name] = every
+	}
+	return intervals, nil
+}
+
+// formatCollectorIntervals renders the effective interval of every collector,
+// defaults included, in the form parseCollectorIntervals reads.
+func formatCollectorIntervals(overrides map[string]time.Duration) string {
+	var pairs []string
+	for _, name := range collectorNames() {
+		every := defaultCollectorIntervals[name]
+		if d, ok := overrides[name]; ok {
+			every = d
+		}
+		pairs = append(pairs, name+"="+every.String())
+	}
+	return strings.Join(pairs, ",")
+}
+
+func collectorNames() []string {
+	names := make([]string, 0, len(defaultCollectorIntervals))
+	for name := range defaultCollectorIntervals {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
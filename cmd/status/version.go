@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Build metadata, set with -ldflags "-X main.Version=... -X main.Commit=... -X main.BuildTime=...".
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// formatVersion renders the build metadata for --version. The first line is
+// "mo status <version>" so scripts can cut the version from it.
+func formatVersion() string {
+	commit, built := Commit, BuildTime
+	// Plain `go build` from a checkout still records VCS info.
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && commit == "":
+				commit = s.Value
+			case s.Key == "vcs.time" && built == "":
+				built = s.Value
+			}
+		}
+	}
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "mo status %s\n", Version)
+	fmt.Fprintf(&b, "commit: %s\n", orUnknown(commit))
+	fmt.Fprintf(&b, "built:  %s\n", orUnknown(built))
+	fmt.Fprintf(&b, "go:     %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return b.String()
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
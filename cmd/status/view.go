@@ -1,10 +1,18 @@
+//go:build !agent
+
 package main
 
 import (
+	"cmp"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -20,6 +28,64 @@ var (
 	primaryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#BD93F9"))
 )
 
+// dimView darkens rendered output for --dim-after: 24-bit colors keep level
+// of their brightness, and all text is drawn faint, which also covers the
+// default foreground and 256-color palettes lipgloss falls back to.
+func dimView(s string, level float64) string {
+	const faint = "\x1b[2m"
+	var b strings.Builder
+	b.Grow(len(s) + len(s)/8)
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(faint)
+		for {
+			start := strings.Index(line, "\x1b[")
+			end := -1
+			if start >= 0 {
+				end = strings.IndexByte(line[start:], 'm')
+			}
+			if end < 0 {
+				b.WriteString(line)
+				break
+			}
+			b.WriteString(line[:start])
+			b.WriteString(dimSGR(line[start+2:start+end], level))
+			line = line[start+end+1:]
+		}
+	}
+	return b.String()
+}
+
+// dimSGR rewrites one SGR parameter list: 24-bit colors are scaled, and a
+// reset turns faint back on.
+func dimSGR(params string, level float64) string {
+	fields := strings.Split(params, ";")
+	reset := false
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "", "0":
+			reset = true
+		case "38", "48":
+			if i+4 < len(fields) && fields[i+1] == "2" {
+				for j := i + 2; j <= i+4; j++ {
+					if v, err := strconv.Atoi(fields[j]); err == nil {
+						fields[j] = strconv.Itoa(int(float64(v) * level))
+					}
+				}
+				i += 4
+			} else if i+1 < len(fields) && fields[i+1] == "5" {
+				i += 2
+			}
+		}
+	}
+	if reset {
+		fields = append(fields, "2")
+	}
+	return "\x1b[" + strings.Join(fields, ";") + "m"
+}
+
 const (
 	colWidth    = 38
 	iconCPU     = "◉"
@@ -124,10 +190,71 @@ func getMoleFrame(animFrame int, termWidth int) string {
 	return strings.Join(lines, "\n")
 }
 
+// graphMode selects how the network history is drawn.
+type graphMode int
+
+const (
+	graphSeparate  graphMode = iota // Independent rx and tx sparklines.
+	graphStacked                    // Mirrored waveform: rx above the baseline, tx below.
+	graphHistogram                  // Distribution of recent rates, low buckets on the left.
+)
+
+// next cycles to the following graph mode.
+func (g graphMode) next() graphMode {
+	if g == graphHistogram {
+		return graphSeparate
+	}
+	return g + 1
+}
+
+// viewState carries interactive display toggles from the model into the card renderers.
+type viewState struct {
+	netGraph        graphMode
+	showContainers  bool                   // Expand the collapsed container interfaces row.
+	selectedIface   string                 // Interface row under the cursor.
+	ifaceConns      map[string][]Conn      // The snapshot's connections by interface, listed under the selected row.
+	heavyPorts      map[string][]PortShare // The snapshot's busiest service ports by interface, hinted under the selected row.
+	hiddenIfaces    map[string]bool        // Interfaces the user hid from the list.
+	pinned          map[string]bool        // Interfaces kept at the top and past --min-rate.
+	showHidden      bool                   // Temporarily list hidden interfaces so they can be restored.
+	excludeHidden   bool                   // Hidden interfaces also drop out of the totals.
+	minRate         float64                // Rows below this combined MB/s are omitted (totals keep them).
+	procsByCPU      bool                   // Sort the top-memory panel by CPU instead of RSS.
+	procRows        int                    // Rows in the top-memory panel, from a : top query; 0 = memProcsTop.
+	collapsed       map[string]bool        // Panels reduced to their one-line summary, by card id.
+	diskSort        diskSort               // Disk panel row order.
+	connGroup       connGroup              // Connections panel grouping (s).
+	ifaceGraphs     ifaceGraphs            // Sparklines under the interface rows (i).
+	ephemeralAlert  float64                // Percent of the ephemeral port range in use that raises an alert; 0 = off.
+	asymRatio       float64                // One direction this many times the other marks a row as asymmetric; 0 = off.
+	asymWindow      int                    // Recent samples the skew must hold for.
+	listenersOnly   bool                   // Connections panel lists listening ports instead (l).
+	showTotals      bool                   // Show bytes moved this session under the rates.
+	bootTotals      bool                   // Those totals are the OS counters since boot instead (T).
+	alignRates      bool                   // Right-align interface rates in fixed-width columns (--aligned).
+	rateWidth       int                    // Width of those columns; 0 = fit the widest expected value.
+	netColumns      []string               // Interface table columns (--net-columns); empty = the default.
+	dirRatio        float64                // How many times the other way one direction must be to lead (--dir-ratio).
+	dirTotals       bool                   // Judge direction on session totals instead of current rates (--dir-totals).
+	sessionBytes    map[string][2]uint64   // Per-interface bytes received and sent this session, for dirTotals.
+	totalRxBytes    uint64
+	totalTxBytes    uint64
+	mark            *byteMark                 // Count bytes since this mark instead of showing rates (m).
+	rawCounters     bool                      // Show the OS byte counters instead of rates (a); wins over mark.
+	groupByKind     bool                      // Section interface rows into physical, VPN and virtual (G).
+	collapseBridges bool                      // Fold bridge ports into their bridge's row (B).
+	tare            *rateTare                 // Background rates subtracted from the network panel (b).
+	follow          *activityFollow           // Keeps the busiest interface selected (F); nil = off.
+	thresholds      rateThresholds            // Global interface rate colors (--warn-rx, ...).
+	ifaceLevels     map[string]rateThresholds // Per-interface overrides from the prefs file.
+}
+
 type cardData struct {
-	icon  string
-	title string
-	lines []string
+	id      string // Stable panel key for per-panel state; titles can change.
+	icon    string
+	title   string
+	lines   []string
+	summary string // One-line stand-in when collapsed; defaults to the first line.
 }
 
 func renderHeader(m MetricsSnapshot, errMsg string, animFrame int, termWidth int, catHidden bool) (string, string) {
@@ -219,6 +346,174 @@ func renderHeader(m MetricsSnapshot, errMsg string, animFrame int, termWidth int
 	return headerLine, mole
 }
 
+// renderSummaryLine builds the at-a-glance line shown under the header.
+func renderSummaryLine(m MetricsSnapshot, fields []string) string {
+	var rx, tx float64
+	for _, n := range m.Network {
+		rx += n.RxRateMBs
+		tx += n.TxRateMBs
+	}
+
+	var parts []string
+	for _, field := range fields {
+		switch field {
+		case "down":
+			parts = append(parts, "↓ "+formatRate(rx))
+		case "up":
+			parts = append(parts, "↑ "+formatRate(tx))
+		case "cpu":
+			parts = append(parts, "CPU "+colorizePercent(m.CPU.Usage, strings.TrimSpace(formatPercent(m.CPU.Usage))))
+		case "mem":
+			parts = append(parts, "Mem "+colorizePercent(m.Memory.UsedPercent, strings.TrimSpace(formatPercent(m.Memory.UsedPercent))))
+		case "conns":
+			parts = append(parts, fmt.Sprintf("%d conns", m.Connections.Total))
+		case "proxy":
+			if m.Proxy.Enabled {
+				parts = append(parts, "Proxy "+m.Proxy.Type)
+			} else {
+				parts = append(parts, subtleStyle.Render("Proxy off"))
+			}
+		}
+	}
+	return strings.Join(parts, subtleStyle.Render(" · "))
+}
+
+// renderFooter shows the wall clock and how old the last successful sample is.
+// The age turns red once it exceeds two refresh intervals, which means the
+// collector is stuck (for example on a blocked scutil call).
+func renderFooter(lastUpdated, now time.Time, interval time.Duration) string {
+	clock := subtleStyle.Render(now.Format("15:04:05"))
+	if lastUpdated.IsZero() {
+		return clock
+	}
+	age := max(now.Sub(lastUpdated), 0)
+	ageText := "updated " + formatAge(age) + " ago"
+	if age > 2*interval {
+		return clock + subtleStyle.Render(" · ") + dangerStyle.Render(ageText)
+	}
+	return clock + subtleStyle.Render(" · "+ageText)
+}
+
+// minViewWidth is the narrowest terminal the card layout is drawn for; cards
+// wrap into garbage below it, e.g. in split panes.
+const minViewWidth = 40
+
+// renderNarrowView is the single-column fallback for terminals narrower than
+// minViewWidth: the headline numbers, one per line, cut to the width.
+func renderNarrowView(m MetricsSnapshot, width int) string {
+	var rx, tx float64
+	for _, n := range m.Network {
+		if n.inTotals() {
+			rx += n.RxRateMBs
+			tx += n.TxRateMBs
+		}
+	}
+	lines := []string{
+		"CPU " + strings.TrimSpace(formatPercent(m.CPU.Usage)),
+		"Mem " + strings.TrimSpace(formatPercent(m.Memory.UsedPercent)),
+		"↓ " + formatRate(rx),
+		"↑ " + formatRate(tx),
+		"Too narrow, widen to " + strconv.Itoa(minViewWidth),
+	}
+	for i, line := range lines {
+		if r := []rune(line); len(r) > width {
+			line = string(r[:max(width, 0)])
+		}
+		if i == len(lines)-1 {
+			line = subtleStyle.Render(line)
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// eventLogRows is how many events the log panel shows at terminal height h.
+func eventLogRows(h int) int {
+	return max(h-4, 5) // Title, blank line and key hint around the list.
+}
+
+// clampEventScroll keeps offset, counted in events back from the newest,
+// within what the list can scroll.
+func clampEventScroll(offset, events, rows int) int {
+	return min(max(offset, 0), max(events-rows, 0))
+}
+
+// renderEventLog draws the event log panel (e) with the newest event at the
+// bottom, scrolled back by offset events.
+func renderEventLog(events []StatusEvent, offset, width, height int) string {
+	rows := eventLogRows(height)
+	end := len(events) - clampEventScroll(offset, len(events), rows)
+	start := max(end-rows, 0)
+
+	lines := []string{titleStyle.Render("Event log") + subtleStyle.Render(fmt.Sprintf("  %d of %d", end-start, len(events)))}
+	if len(events) == 0 {
+		lines = append(lines, subtleStyle.Render("No events yet"))
+	}
+	for _, ev := range events[start:end] {
+		line := fmt.Sprintf("%s  %-5s  %-7s  %s", ev.At.Format("15:04:05"), ev.Severity, ev.Category, ev.Message)
+		if r := []rune(line); len(r) > width {
+			line = string(r[:max(width, 0)])
+		}
+		lines = append(lines, severityStyle(ev.Severity).Render(line))
+	}
+	lines = append(lines, "", subtleStyle.Render("↑/↓ scroll · e or esc closes"))
+	return strings.Join(lines, "\n")
+}
+
+// renderKeyHelp lists the bindings of keymap, one per line, for the ? overlay.
+func renderKeyHelp(bindings []keyBinding, width int) string {
+	lines := []string{titleStyle.Render("Keys")}
+	for _, b := range bindings {
+		label := cmp.Or(b.label, b.keys[0])
+		line := fmt.Sprintf("  %-5s %s", label, b.help)
+		if r := []rune(line); len(r) > width {
+			line = string(r[:max(width, 0)])
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", subtleStyle.Render("? or esc closes"))
+	return strings.Join(lines, "\n")
+}
+
+func severityStyle(severity string) lipgloss.Style {
+	switch severity {
+	case severityError:
+		return dangerStyle
+	case severityWarn:
+		return warnStyle
+	}
+	return lipgloss.NewStyle()
+}
+
+// renderAlerts returns the footer alert text, or "" when nothing needs attention.
+func renderAlerts(m MetricsSnapshot, zombieThreshold int, ephemeralThreshold float64) string {
+	var alerts []string
+	if z := m.ProcessStates[procStateZombie]; zombieThreshold > 0 && z > zombieThreshold {
+		alerts = append(alerts, dangerStyle.Render(fmt.Sprintf("⚠ %d zombie processes", z)))
+	}
+	if e := m.Connections.Ephemeral; ephemeralOver(e, ephemeralThreshold) {
+		alerts = append(alerts, dangerStyle.Render("⚠ "+ephemeralAlertText(e)))
+	}
+	for _, p := range m.TopMemory {
+		if p.FDGrowing {
+			alerts = append(alerts, warnStyle.Render(fmt.Sprintf("⚠ %s (%d) descriptors climbing: %d of %d", p.Name, p.PID, p.NumFDs, p.FDLimit)))
+		}
+	}
+	return strings.Join(alerts, subtleStyle.Render(" · "))
+}
+
+// formatAge renders a duration in its largest whole unit (5s, 3m, 2h).
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+}
+
 func getScoreStyle(score int) lipgloss.Style {
 	switch {
 	case score >= 90:
@@ -240,10 +535,13 @@ func renderCPUCard(cpu CPUStatus, thermal ThermalStatus) cardData {
 	// Line 1: Usage + Temp (Format: 15% @ 30.4°C)
 	usageBar := progressBar(cpu.Usage)
 
-	headerText := fmt.Sprintf("%5.1f%%", cpu.Usage)
+	headerText := formatPercent(cpu.Usage) + trendArrow(cpu.History, true)
 	if thermal.CPUTemp > 0 {
 		headerText += fmt.Sprintf(" @ %s°C", colorizeTemp(thermal.CPUTemp))
 	}
+	if thermal.Throttled {
+		headerText += " " + dangerStyle.Render("THROTTLING")
+	}
 
 	lines = append(lines, fmt.Sprintf("Total  %s  %s", usageBar, headerText))
 
@@ -263,7 +561,7 @@ func renderCPUCard(cpu CPUStatus, thermal ThermalStatus) cardData {
 		maxCores := min(len(cores), 3)
 		for i := 0; i < maxCores; i++ {
 			c := cores[i]
-			lines = append(lines, fmt.Sprintf("Core%-2d %s  %5.1f%%", c.idx+1, progressBar(c.val), c.val))
+			lines = append(lines, fmt.Sprintf("Core%-2d %s  %s", c.idx+1, progressBar(c.val), formatPercent(c.val)))
 		}
 	}
 
@@ -275,8 +573,21 @@ func renderCPUCard(cpu CPUStatus, thermal ThermalStatus) cardData {
 		lines = append(lines, fmt.Sprintf("Load   %.2f / %.2f / %.2f, %d cores",
 			cpu.Load1, cpu.Load5, cpu.Load15, cpu.LogicalCPU))
 	}
+	if len(cpu.History) > 1 {
+		lines = append(lines, fmt.Sprintf("Trend  %s", percentSparkline(cpu.History, 16, cpu.Usage)))
+	}
+	switch {
+	case thermal.CPUSpeedLimit > 0 && thermal.CPUSpeedLimit < 100:
+		lines = append(lines, fmt.Sprintf("Therm  %s", dangerStyle.Render(fmt.Sprintf("speed limited to %d%%", thermal.CPUSpeedLimit))))
+	case thermal.ThermalPressure == thermalModerate:
+		lines = append(lines, fmt.Sprintf("Therm  %s", warnStyle.Render("moderate pressure")))
+	case thermal.Throttled:
+		lines = append(lines, fmt.Sprintf("Therm  %s", dangerStyle.Render(thermal.ThermalPressure+" pressure")))
+	case thermal.ThermalPressure == thermalUnknown:
+		lines = append(lines, fmt.Sprintf("Therm  %s", subtleStyle.Render("pressure unknown (needs root)")))
+	}
 
-	return cardData{icon: iconCPU, title: "CPU", lines: lines}
+	return cardData{id: "cpu", icon: iconCPU, title: "CPU", lines: lines}
 }
 
 func renderMemoryCard(mem MemoryStatus, cardWidth int) cardData {
@@ -285,11 +596,11 @@ func renderMemoryCard(mem MemoryStatus, cardWidth int) cardData {
 
 	var lines []string
 	// Line 1: Used
-	lines = append(lines, fmt.Sprintf("Used   %s  %5.1f%%", progressBar(mem.UsedPercent), mem.UsedPercent))
+	lines = append(lines, fmt.Sprintf("Used   %s  %s", progressBar(mem.UsedPercent), formatPercent(mem.UsedPercent))+trendArrow(mem.History, true))
 
 	// Line 2: Free
 	freePercent := 100 - mem.UsedPercent
-	lines = append(lines, fmt.Sprintf("Free   %s  %5.1f%%", progressBar(freePercent), freePercent))
+	lines = append(lines, fmt.Sprintf("Free   %s  %s", progressBar(freePercent), formatPercent(freePercent)))
 
 	if hasSwap {
 		// Layout with Swap:
@@ -300,7 +611,7 @@ func renderMemoryCard(mem MemoryStatus, cardWidth int) cardData {
 		if mem.SwapTotal > 0 {
 			swapPercent = (float64(mem.SwapUsed) / float64(mem.SwapTotal)) * 100.0
 		}
-		swapLine := fmt.Sprintf("Swap   %s  %5.1f%%", progressBar(swapPercent), swapPercent)
+		swapLine := fmt.Sprintf("Swap   %s  %s", progressBar(swapPercent), formatPercent(swapPercent))
 		swapText := fmt.Sprintf("%s/%s", humanBytesCompact(mem.SwapUsed), humanBytesCompact(mem.SwapTotal))
 		swapLineWithText := swapLine + " " + swapText
 		if cardWidth > 0 && lipgloss.Width(swapLineWithText) <= cardWidth {
@@ -343,15 +654,18 @@ func renderMemoryCard(mem MemoryStatus, cardWidth int) cardData {
 		}
 		lines = append(lines, pressureStyle.Render(pressureText))
 	}
-	return cardData{icon: iconMemory, title: "Memory", lines: lines}
+	if len(mem.History) > 1 {
+		lines = append(lines, fmt.Sprintf("Trend  %s", percentSparkline(mem.History, 16, mem.UsedPercent)))
+	}
+	return cardData{id: "memory", icon: iconMemory, title: "Memory", lines: lines}
 }
 
-func renderDiskCard(disks []DiskStatus, io DiskIOStatus) cardData {
+func renderDiskCard(disks []DiskStatus, io DiskIOStatus, by diskSort) cardData {
 	var lines []string
 	if len(disks) == 0 {
 		lines = append(lines, subtleStyle.Render("Collecting..."))
 	} else {
-		internal, external := splitDisks(disks)
+		internal, external := splitDisks(sortDisks(disks, by))
 		addGroup := func(prefix string, list []DiskStatus) {
 			if len(list) == 0 {
 				return
@@ -369,9 +683,17 @@ func renderDiskCard(disks []DiskStatus, io DiskIOStatus) cardData {
 	}
 	readBar := ioBar(io.ReadRate)
 	writeBar := ioBar(io.WriteRate)
-	lines = append(lines, fmt.Sprintf("Read   %s  %.1f MB/s", readBar, io.ReadRate))
-	lines = append(lines, fmt.Sprintf("Write  %s  %.1f MB/s", writeBar, io.WriteRate))
-	return cardData{icon: iconDisk, title: "Disk", lines: lines}
+	lines = append(lines, fmt.Sprintf("Read   %s  %s", readBar, formatIORate(io.ReadRate)))
+	lines = append(lines, fmt.Sprintf("Write  %s  %s", writeBar, formatIORate(io.WriteRate)))
+	if len(io.Devices) > 0 {
+		busiest := io.Devices[0]
+		lines = append(lines, fmt.Sprintf("Busy   %s  %s %s", ioBusyBar(busiest.BusyPercent), formatPercent(busiest.BusyPercent), busiest.Name))
+	}
+	title := "Disk"
+	if by != diskBySize {
+		title += " by " + by.String()
+	}
+	return cardData{id: "disk", icon: iconDisk, title: title, lines: lines}
 }
 
 func splitDisks(disks []DiskStatus) (internal, external []DiskStatus) {
@@ -399,7 +721,7 @@ func formatDiskLine(label string, d DiskStatus) string {
 	bar := progressBar(d.UsedPercent)
 	used := humanBytesShort(d.Used)
 	total := humanBytesShort(d.Total)
-	return fmt.Sprintf("%-6s %s  %5.1f%%, %s/%s", label, bar, d.UsedPercent, used, total)
+	return fmt.Sprintf("%-6s %s  %s, %s/%s", label, bar, formatPercent(d.UsedPercent), used, total)
 }
 
 func ioBar(rate float64) string {
@@ -417,6 +739,13 @@ func ioBar(rate float64) string {
 	return okStyle.Render(bar)
 }
 
+// ioBusyBar mirrors ioBar for device utilization, saturating at 100%.
+func ioBusyBar(percent float64) string {
+	filled := min(max(int(percent/20), 0), 5)
+	bar := strings.Repeat("▮", filled) + strings.Repeat("▯", 5-filled)
+	return colorizePercent(percent, bar)
+}
+
 func renderProcessCard(procs []ProcessInfo) cardData {
 	var lines []string
 	maxProcs := 3
@@ -426,30 +755,368 @@ func renderProcessCard(procs []ProcessInfo) cardData {
 		}
 		name := shorten(p.Name, 12)
 		cpuBar := miniBar(p.CPU)
-		lines = append(lines, fmt.Sprintf("%-12s  %s  %5.1f%%", name, cpuBar, p.CPU))
+		lines = append(lines, fmt.Sprintf("%-12s  %s  %s", name, cpuBar, formatPercent(p.CPU)))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, subtleStyle.Render("No data"))
+	}
+	return cardData{id: "processes", icon: iconProcs, title: "Processes", lines: lines}
+}
+
+// renderMemoryProcsCard lists the heaviest processes by RSS, or by CPU when toggled.
+func renderMemoryProcsCard(procs []MemProcessInfo, state viewState) cardData {
+	title := "Top Memory"
+	sorted := slices.Clone(procs)
+	if state.procsByCPU {
+		title = "Top CPU"
+		slices.SortStableFunc(sorted, func(a, b MemProcessInfo) int { return compareDesc(a.CPU, b.CPU) })
+	}
+
+	var lines []string
+	for i, p := range sorted {
+		if i >= cmp.Or(state.procRows, memProcsTop) {
+			break
+		}
+		line := fmt.Sprintf("%6d  %-14s  %9s  %s  %s", p.PID, shorten(p.Name, 14), humanBytes(p.RSS), formatPercent(p.CPU), formatFDs(p))
+		if p.FDGrowing {
+			line = warnStyle.Render(line)
+		}
+		lines = append(lines, line)
 	}
 	if len(lines) == 0 {
 		lines = append(lines, subtleStyle.Render("No data"))
 	}
-	return cardData{icon: iconProcs, title: "Processes", lines: lines}
+	return cardData{id: "top-memory", icon: iconProcs, title: title, lines: lines}
+}
+
+// formatFDs renders a process's open descriptor count, with the limit when
+// it is known, or "n/a" when the count is not readable.
+func formatFDs(p MemProcessInfo) string {
+	if p.NumFDs < 0 {
+		return "fd n/a"
+	}
+	if p.FDLimit > 0 && p.FDLimit < math.MaxInt32 {
+		return fmt.Sprintf("fd %d/%d", p.NumFDs, p.FDLimit)
+	}
+	return fmt.Sprintf("fd %d", p.NumFDs)
+}
+
+// renderLatencyCard shows the --ping round trip history with min/avg/max.
+func renderLatencyCard(l LatencyStatus, cardWidth int) cardData {
+	graphWidth := min(max(cardWidth-22, 5), 16)
+	var lines []string
+	if len(l.History) == 0 {
+		lines = append(lines, subtleStyle.Render("Probing "+l.Target+"..."))
+	} else {
+		lines = append(lines, fmt.Sprintf("RTT    %s  %s", styledSparkline(l.History, graphWidth, latencyStyle(l.CurrentMs)), formatLatency(l.CurrentMs)))
+		lines = append(lines, fmt.Sprintf("min %s · avg %s · max %s", formatLatency(l.MinMs), formatLatency(l.AvgMs), formatLatency(l.MaxMs)))
+	}
+	info := l.Target + " · " + strings.ToUpper(l.Method)
+	if l.Note != "" {
+		info += " · " + l.Note
+	}
+	lines = append(lines, subtleStyle.Render(info))
+	if l.Error != "" {
+		lines = append(lines, dangerStyle.Render("Last probe failed"))
+	}
+	return cardData{id: "latency", icon: iconNetwork, title: "Latency", lines: lines}
+}
+
+// renderContainerCard shows the --container target's usage, or its state
+// once it has stopped, or why Docker could not be asked.
+func renderContainerCard(c ContainerStatus) cardData {
+	var lines []string
+	switch {
+	case c.Error != "":
+		lines = append(lines, dangerStyle.Render(c.Error))
+	case c.State == containerGone:
+		lines = append(lines, warnStyle.Render("Container no longer exists"))
+	case c.State != "running":
+		lines = append(lines, warnStyle.Render("Not running ("+cmp.Or(c.State, "unknown")+")"))
+	default:
+		lines = append(lines, fmt.Sprintf("CPU    %s  %s", progressBar(c.CPUPercent), formatPercent(c.CPUPercent)))
+		mem := humanBytes(c.MemUsed)
+		if c.MemLimit > 0 {
+			percent := float64(c.MemUsed) / float64(c.MemLimit) * 100
+			lines = append(lines, fmt.Sprintf("Mem    %s  %s", progressBar(percent), formatPercent(percent)))
+			mem += " / " + humanBytes(c.MemLimit)
+		}
+		lines = append(lines, fmt.Sprintf("Net    %s ↓ / %s ↑", formatRate(c.RxRateMBs), formatRate(c.TxRateMBs)))
+		lines = append(lines, subtleStyle.Render(mem))
+	}
+	info := c.Name
+	if c.ID != "" {
+		info += " · " + c.ID
+	}
+	lines = append(lines, subtleStyle.Render(info))
+	return cardData{id: "container", icon: iconProcs, title: "Container", lines: lines}
+}
+
+// renderUnitCard shows the --unit service's usage, or its state once it
+// has stopped, or why it could not be read.
+func renderUnitCard(u UnitStatus) cardData {
+	var lines []string
+	switch {
+	case u.Error != "":
+		lines = append(lines, dangerStyle.Render(u.Error))
+	case u.State == unitGone:
+		lines = append(lines, warnStyle.Render("No such unit"))
+	case !u.running():
+		lines = append(lines, warnStyle.Render("Not running ("+cmp.Or(u.State, "unknown")+")"))
+	default:
+		lines = append(lines, fmt.Sprintf("CPU    %s  %s", progressBar(u.CPUPercent), formatPercent(u.CPUPercent)))
+		mem := humanBytes(u.MemUsed)
+		if u.MemLimit > 0 {
+			percent := float64(u.MemUsed) / float64(u.MemLimit) * 100
+			lines = append(lines, fmt.Sprintf("Mem    %s  %s", progressBar(percent), formatPercent(percent)))
+			mem += " / " + humanBytes(u.MemLimit)
+		}
+		if u.NetCounted {
+			lines = append(lines, fmt.Sprintf("Net    %s ↓ / %s ↑", formatRate(u.RxRateMBs), formatRate(u.TxRateMBs)))
+		} else {
+			lines = append(lines, subtleStyle.Render("Net    no nftables counter for its cgroup"))
+		}
+		lines = append(lines, subtleStyle.Render(fmt.Sprintf("%s · %d tasks", mem, u.Tasks)))
+	}
+	info := u.Name
+	if u.State != "" && u.State != unitGone {
+		info += " · " + u.State
+	}
+	lines = append(lines, subtleStyle.Render(info))
+	return cardData{id: "unit", icon: iconProcs, title: "Service", lines: lines}
+}
+
+// renderSecurityCard shows the host firewall state, in warning colors when
+// it is off and subdued when it could not be read.
+func renderSecurityCard(f FirewallStatus) cardData {
+	line := "Firewall  "
+	switch f.State {
+	case firewallEnabled:
+		line += okStyle.Render("on")
+	case firewallDisabled:
+		line += warnStyle.Render("off")
+	default:
+		line += subtleStyle.Render("unknown")
+	}
+	var info []string
+	if f.Tool != "" {
+		info = append(info, f.Tool)
+	}
+	if f.Rules >= 0 && f.State != firewallUnknown {
+		unit := "rules"
+		if f.Tool == "socketfilterfw" {
+			unit = "apps"
+		}
+		info = append(info, fmt.Sprintf("%d %s", f.Rules, unit))
+	}
+	if len(info) > 0 {
+		line += subtleStyle.Render(" · " + strings.Join(info, " · "))
+	}
+	lines := []string{line}
+	if f.Note != "" {
+		lines = append(lines, subtleStyle.Render(f.Note))
+	}
+	return cardData{id: "security", icon: iconProcs, title: "Security", lines: lines}
+}
+
+// renderListenersCard answers "what is listening on :8080": each listening
+// port with its owner, or "—" where the OS would not name it.
+func renderListenersCard(c ConnectionStatus) cardData {
+	var lines []string
+	for i, l := range c.Listeners {
+		if i == maxConnRows {
+			lines = append(lines, subtleStyle.Render(fmt.Sprintf("… %d more", len(c.Listeners)-i)))
+			break
+		}
+		port := strconv.FormatUint(uint64(l.Port), 10)
+		addr := ":" + port
+		if l.Addr != "" && l.Addr != "0.0.0.0" && l.Addr != "::" && l.Addr != "*" {
+			addr = net.JoinHostPort(l.Addr, port)
+		}
+		owner := "—"
+		if l.PID > 0 {
+			owner = fmt.Sprintf("%s (%d)", cmp.Or(l.Process, "?"), l.PID)
+		}
+		lines = append(lines, fmt.Sprintf("%-16s %-4s %s", addr, l.Proto, owner))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, subtleStyle.Render("No listening sockets"))
+	}
+	summary := fmt.Sprintf("%d listening", len(c.Listeners))
+	lines = append(lines, subtleStyle.Render(summary+" · l for all connections"))
+	return cardData{id: "connections", icon: iconNetwork, title: "Listening", lines: lines, summary: summary}
+}
+
+// proxyCheckText is the --proxy-check verdict for the primary proxy, if any.
+func proxyCheckText(p ProxyStatus) string {
+	switch {
+	case p.Checking:
+		return subtleStyle.Render(" checking…")
+	case p.Reachable == nil:
+		return ""
+	case *p.Reachable:
+		return okStyle.Render(" reachable")
+	}
+	return dangerStyle.Render(" unreachable")
+}
+
+// maxConnRows bounds the connections panel; the rest fold into one line.
+const maxConnRows = 6
+
+// renderConnectionsCard lists socket counts in the chosen grouping, largest
+// first, with the total in the summary.
+func renderConnectionsCard(c ConnectionStatus, by connGroup, threshold float64) cardData {
+	counts := c.ByState
+	switch by {
+	case connByProto:
+		counts = c.ByProto
+	case connByRemote:
+		counts = c.ByRemote
+	}
+	var lines []string
+	keys := sortedCounts(counts)
+	for i, k := range keys {
+		if i == maxConnRows {
+			rest := 0
+			for _, k := range keys[i:] {
+				rest += counts[k]
+			}
+			lines = append(lines, subtleStyle.Render(fmt.Sprintf("… %d more in %d groups", rest, len(keys)-i)))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%5d  %s", counts[k], k))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, subtleStyle.Render("No "+by.String()+" data"))
+	}
+	if c.Ephemeral != nil {
+		lines = append(lines, ephemeralLine(c.Ephemeral, threshold))
+	}
+	summary := fmt.Sprintf("%d connections", c.Total)
+	lines = append(lines, subtleStyle.Render(summary+" · s to group"))
+	return cardData{id: "connections", icon: iconNetwork, title: "Connections by " + by.String(), lines: lines, summary: summary}
 }
 
-func buildCards(m MetricsSnapshot, width int) []cardData {
+// ephemeralOver reports whether more than threshold percent of the
+// ephemeral range is in use; a zero threshold never is.
+func ephemeralOver(e *EphemeralStatus, threshold float64) bool {
+	return e != nil && threshold > 0 && e.Percent > threshold
+}
+
+func ephemeralAlertText(e *EphemeralStatus) string {
+	text := fmt.Sprintf("ephemeral ports %.0f%% used", e.Percent)
+	if e.TimeWait > 0 {
+		text += fmt.Sprintf(", %d in TIME_WAIT", e.TimeWait)
+	}
+	return text
+}
+
+// ephemeralLine shows how full the ephemeral port range is on the
+// connections card, yellow from half the alert threshold.
+func ephemeralLine(e *EphemeralStatus, threshold float64) string {
+	style := lipgloss.NewStyle()
+	switch {
+	case ephemeralOver(e, threshold):
+		style = dangerStyle
+	case ephemeralOver(e, threshold/2):
+		style = warnStyle
+	}
+	line := style.Render(fmt.Sprintf("%4.1f%%", e.Percent)) + fmt.Sprintf(" of ephemeral ports %d–%d", e.Low, e.High)
+	if e.TimeWait > 0 {
+		line += subtleStyle.Render(fmt.Sprintf(" · %d TIME_WAIT", e.TimeWait))
+	}
+	return line
+}
+
+func latencyStyle(ms float64) lipgloss.Style {
+	if ms > 150 {
+		return dangerStyle
+	}
+	if ms > 60 {
+		return warnStyle
+	}
+	return okStyle
+}
+
+func formatLatency(ms float64) string {
+	if ms < 10 {
+		return fmt.Sprintf("%.1f ms", ms)
+	}
+	return fmt.Sprintf("%.0f ms", ms)
+}
+
+func buildCards(m MetricsSnapshot, width int, state viewState) []cardData {
+	if state.tare != nil {
+		m.Network, m.NetworkHistory = state.tare.apply(m.Network, m.NetworkHistory)
+	}
+	state.ifaceConns = m.Connections.ByIface
+	state.heavyPorts = m.Connections.HeavyPorts
+	network := renderNetworkCard(m.Network, m.NetworkHistory, m.Proxy, m.NetworkWarmup, width, state)
+	if m.TCP != nil {
+		network.lines = append(network.lines, tcpLine(*m.TCP))
+	}
+	if m.IPFamilies != nil {
+		network.lines = append(network.lines, ipFamilyLine(*m.IPFamilies))
+	}
+	if m.PublicIP != nil {
+		network.lines = append(network.lines, publicIPLine(*m.PublicIP))
+	}
+	if m.RouteCount > 0 {
+		network.lines = append(network.lines, subtleStyle.Render(fmt.Sprintf("Routes %d in the main table", m.RouteCount)))
+	}
 	cards := []cardData{
 		renderCPUCard(m.CPU, m.Thermal),
 		renderMemoryCard(m.Memory, width),
-		renderDiskCard(m.Disks, m.DiskIO),
+		renderDiskCard(m.Disks, m.DiskIO, state.diskSort),
 		renderBatteryCard(m.Batteries, m.Thermal),
 		renderProcessCard(m.TopProcesses),
-		renderNetworkCard(m.Network, m.NetworkHistory, m.Proxy, width),
+		renderMemoryProcsCard(m.TopMemory, state),
+		network,
+	}
+	if m.Connections.Total > 0 {
+		if state.listenersOnly {
+			cards = append(cards, renderListenersCard(m.Connections))
+		} else {
+			cards = append(cards, renderConnectionsCard(m.Connections, state.connGroup, state.ephemeralAlert))
+		}
+	}
+	if m.Latency != nil {
+		cards = append(cards, renderLatencyCard(*m.Latency, width))
+	}
+	if m.Container != nil {
+		cards = append(cards, renderContainerCard(*m.Container))
+	}
+	if m.Unit != nil {
+		cards = append(cards, renderUnitCard(*m.Unit))
+	}
+	if m.Firewall != nil {
+		cards = append(cards, renderSecurityCard(*m.Firewall))
 	}
 	// Sensors card disabled - redundant with CPU temp
 	// if hasSensorData(m.Sensors) {
 	// 	cards = append(cards, renderSensorsCard(m.Sensors))
 	// }
+	for i := range cards {
+		if state.collapsed[cards[i].id] {
+			cards[i] = collapseCard(cards[i])
+		}
+	}
 	return cards
 }
 
+// collapseCard reduces a card to its header and one-line summary.
+func collapseCard(c cardData) cardData {
+	summary := c.summary
+	if summary == "" && len(c.lines) > 0 {
+		summary = c.lines[0]
+	}
+	c.lines = []string{summary}
+	return c
+}
+
+// panelIDs are the card ids buildCards can produce.
+var panelIDs = []string{"cpu", "memory", "disk", "power", "processes", "top-memory", "network", "connections", "latency", "container", "unit", "security"}
+
 func miniBar(percent float64) string {
 	filled := min(int(percent/20), 5)
 	if filled < 0 {
@@ -458,21 +1125,25 @@ func miniBar(percent float64) string {
 	return colorizePercent(percent, strings.Repeat("▮", filled)+strings.Repeat("▯", 5-filled))
 }
 
-func renderNetworkCard(netStats []NetworkStatus, history NetworkHistory, proxy ProxyStatus, cardWidth int) cardData {
+func renderNetworkCard(netStats []NetworkStatus, history NetworkHistory, proxy ProxyStatus, warmup bool, cardWidth int, state viewState) cardData {
 	var lines []string
 	var totalRx, totalTx float64
 	var primaryIP string
 
 	for _, n := range netStats {
-		totalRx += n.RxRateMBs
-		totalTx += n.TxRateMBs
+		if n.inTotals() && (!state.excludeHidden || !state.hiddenIfaces[n.Name]) {
+			totalRx += n.RxRateMBs
+			totalTx += n.TxRateMBs
+		}
 		if primaryIP == "" && n.IP != "" && n.Name == "en0" {
 			primaryIP = n.IP
 		}
 	}
 
-	if len(netStats) == 0 {
-		lines = []string{subtleStyle.Render("Collecting...")}
+	if warmup {
+		lines = []string{subtleStyle.Render("Warming up…")}
+	} else if len(netStats) == 0 {
+		lines = []string{subtleStyle.Render("No active interfaces")}
 	} else {
 		// Calculate dynamic width
 		// Layout: "Down   " (7) + graph + "  " (2) + rate (approx 10-12)
@@ -486,15 +1157,78 @@ func renderNetworkCard(netStats []NetworkStatus, history NetworkHistory, proxy P
 			graphWidth = 16 // Match progressBar fixed width
 		}
 
-		// sparkline graphs
-		rxSparkline := sparkline(history.RxHistory, totalRx, graphWidth)
-		txSparkline := sparkline(history.TxHistory, totalTx, graphWidth)
-		lines = append(lines, fmt.Sprintf("Down   %s  %s", rxSparkline, formatRate(totalRx)))
-		lines = append(lines, fmt.Sprintf("Up     %s  %s", txSparkline, formatRate(totalTx)))
+		var rxSparkline, txSparkline string
+		switch state.netGraph {
+		case graphStacked:
+			ceiling := max(state.thresholds.rxCeiling, state.thresholds.txCeiling)
+			rxSparkline, txSparkline = mirroredSparkline(history.RxHistory, history.TxHistory, totalRx, totalTx, graphWidth, ceiling)
+		case graphHistogram:
+			rxSparkline = histogramLine(history.RxHistory, totalRx, graphWidth)
+			txSparkline = histogramLine(history.TxHistory, totalTx, graphWidth)
+		default:
+			rxSparkline = ceilingSparkline(history.RxHistory, totalRx, graphWidth, state.thresholds.rxCeiling)
+			txSparkline = ceilingSparkline(history.TxHistory, totalTx, graphWidth, state.thresholds.txCeiling)
+		}
+		lines = append(lines, fmt.Sprintf("Down   %s  %s", rxSparkline, formatRate(totalRx))+trendArrow(history.RxHistory, false))
+		lines = append(lines, fmt.Sprintf("Up     %s  %s", txSparkline, formatRate(totalTx))+trendArrow(history.TxHistory, false))
+		if state.showTotals {
+			// Session totals integrate the rates since start; boot totals
+			// are the raw counters, which the OS keeps from boot.
+			rx, tx, since := state.totalRxBytes, state.totalTxBytes, "since start"
+			if state.bootTotals {
+				rx, tx, since = 0, 0, "since boot"
+				for _, n := range netStats {
+					if n.inTotals() && (!state.excludeHidden || !state.hiddenIfaces[n.Name]) {
+						rx, tx = rx+n.RxBytes, tx+n.TxBytes
+					}
+				}
+			}
+			lines = append(lines, fmt.Sprintf("Total  %s ↓ / %s ↑", formatBytes(rx), formatBytes(tx))+subtleStyle.Render(" "+since))
+		}
+		if state.rawCounters || state.mark != nil {
+			var rx, tx uint64
+			for _, n := range netStats {
+				if n.inTotals() && (!state.excludeHidden || !state.hiddenIfaces[n.Name]) {
+					r, t := state.mark.since(n)
+					if state.rawCounters {
+						r, t = n.RxBytes, n.TxBytes
+					}
+					rx, tx = rx+r, tx+t
+				}
+			}
+			if state.rawCounters {
+				lines = append(lines, primaryStyle.Render(fmt.Sprintf("OS     %s ↓ / %s ↑ counted", formatBytes(rx), formatBytes(tx)))+subtleStyle.Render(" · a for rates"))
+			} else {
+				lines = append(lines, primaryStyle.Render(fmt.Sprintf("Since  %s  %s ↓ / %s ↑", state.mark.at.Format("15:04:05"), formatBytes(rx), formatBytes(tx))))
+			}
+		}
+		if state.tare != nil {
+			lines = append(lines, primaryStyle.Render(fmt.Sprintf("Tare   −%s ↓ / −%s ↑", formatRate(state.tare.totalRx), formatRate(state.tare.totalTx))))
+		}
+		if state.netGraph == graphHistogram {
+			peakRx := slices.Max(append([]float64{0}, history.RxHistory...))
+			peakTx := slices.Max(append([]float64{0}, history.TxHistory...))
+			lines = append(lines, subtleStyle.Render(fmt.Sprintf("Rate spread 0 → %s / %s", formatRate(peakRx), formatRate(peakTx))))
+		}
+		lines = append(lines, networkRows(netStats, state)...)
+		for _, n := range netStats {
+			if gw := n.Gateway; gw != nil && !gw.Reachable {
+				lines = append(lines, dangerStyle.Render(fmt.Sprintf("Gateway %s on %s unreachable (%s)", gw.IP, n.Name, gw.State)))
+			}
+		}
 		// Show proxy and IP on one line.
 		var infoParts []string
-		if proxy.Enabled {
-			infoParts = append(infoParts, "Proxy "+proxy.Type)
+		if proxy.Enabled && len(proxy.Schemes) == 0 {
+			infoParts = append(infoParts, "Proxy "+proxy.Type+proxyCheckText(proxy))
+		}
+		// Differing proxies per scheme get a line each, in precedence order;
+		// the first is the primary one the reachability check dials.
+		for i, p := range proxy.Schemes {
+			line := fmt.Sprintf("Proxy  %-5s  %s %s", p.Scheme, p.Type, p.Host)
+			if i == 0 {
+				line += proxyCheckText(proxy)
+			}
+			lines = append(lines, line)
 		}
 		if primaryIP != "" {
 			infoParts = append(infoParts, primaryIP)
@@ -502,68 +1236,1062 @@ func renderNetworkCard(netStats []NetworkStatus, history NetworkHistory, proxy P
 		if len(infoParts) > 0 {
 			lines = append(lines, strings.Join(infoParts, " · "))
 		}
+		if len(proxy.Apps) > 0 {
+			var apps []string
+			for _, p := range proxy.Apps {
+				apps = append(apps, p.Source+" "+p.Host)
+			}
+			lines = append(lines, subtleStyle.Render("Apps "+strings.Join(apps, " · ")))
+		}
 	}
-	return cardData{icon: iconNetwork, title: "Network", lines: lines}
+	summary := fmt.Sprintf("↓ %s  ↑ %s", formatRate(totalRx), formatRate(totalTx))
+	return cardData{id: "network", icon: iconNetwork, title: "Network", lines: lines, summary: summary}
 }
 
-// 8 levels: ▁▂▃▄▅▆▇█
-func sparkline(history []float64, current float64, width int) string {
-	blocks := []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+// publicIPLine reports the --public-ip result for the network card. With a
+// VPN up, an address matching the one seen without it means traffic is not
+// going through the tunnel.
+func publicIPLine(p PublicIPStatus) string {
+	switch {
+	case p.IP == "":
+		return subtleStyle.Render("Public unknown")
+	case p.Leak:
+		return dangerStyle.Render(fmt.Sprintf("Public %s matches the non-VPN IP while %s is up", p.IP, p.VPN))
+	case p.VPN != "":
+		return fmt.Sprintf("Public %s · via %s", p.IP, p.VPN)
+	}
+	return "Public " + p.IP
+}
 
-	data := make([]float64, 0, width)
-	if len(history) > 0 {
-		// Take the most recent points.
-		start := 0
-		if len(history) > width {
-			start = len(history) - width
-		}
-		data = append(data, history[start:]...)
-	}
-	// padding with zeros at the start
-	for len(data) < width {
-		data = append([]float64{0}, data...)
-	}
-	if len(data) > width {
-		data = data[len(data)-width:]
+// tcpLine shows the host TCP retransmit rate; a few percent of segments
+// resent already means a noticeably lossy path.
+func tcpLine(t TCPStatus) string {
+	line := fmt.Sprintf("Retrans %s/s · %.1f%% of sent", compactNumber(t.RetransRate), t.RetransPercent)
+	switch {
+	case t.RetransPercent >= 5:
+		return dangerStyle.Render(line)
+	case t.RetransPercent >= 1:
+		return warnStyle.Render(line)
 	}
+	return subtleStyle.Render(line)
+}
 
-	maxVal := 0.1
-	for _, v := range data {
-		if v > maxVal {
-			maxVal = v
+// ipFamilyLine shows the host-wide split that interface rows cannot.
+func ipFamilyLine(f IPFamilyStatus) string {
+	return subtleStyle.Render(fmt.Sprintf("IPv4 ↓ %s ↑ %s · IPv6 ↓ %s ↑ %s", formatRate(f.V4RxMBs), formatRate(f.V4TxMBs), formatRate(f.V6RxMBs), formatRate(f.V6TxMBs)))
+}
+
+// maxContainerRows caps the expanded container list so it cannot swamp the card.
+const maxContainerRows = 8
+
+// splitInterfaces separates regular and container interfaces, dropping idle ones
+// (below --min-rate, unless pinned) and hidden ones unless they are being shown
+// for restoring. Pinned regular interfaces come first.
+func splitInterfaces(netStats []NetworkStatus, state viewState) (regular, containers []NetworkStatus, hidden, idle int) {
+	defer func() {
+		pinFirst(regular, state.pinned)
+		if !state.groupByKind {
+			regular = nestBridgePorts(regular, state.collapseBridges)
+		}
+	}()
+	for _, n := range netStats {
+		if state.minRate > 0 && n.RxRateMBs+n.TxRateMBs < state.minRate && !state.pinned[n.Name] {
+			idle++
+			continue
+		}
+		if state.hiddenIfaces[n.Name] {
+			hidden++
+			if !state.showHidden {
+				continue
+			}
+		}
+		if n.Kind == ifaceKindContainer {
+			containers = append(containers, n)
+		} else {
+			regular = append(regular, n)
 		}
 	}
+	return regular, containers, hidden, idle
+}
 
-	var builder strings.Builder
-	for _, v := range data {
-		level := int((v / maxVal) * float64(len(blocks)-1))
-		if level < 0 {
-			level = 0
+// nestBridgePorts moves the ports of each listed bridge to just after it, or
+// drops them when collapse is set. Ports whose bridge is not listed stay
+// where they are.
+func nestBridgePorts(rows []NetworkStatus, collapse bool) []NetworkStatus {
+	listed := make(map[string]bool, len(rows))
+	for _, n := range rows {
+		listed[n.Name] = true
+	}
+	nested := func(n NetworkStatus) bool { return n.Bridge != "" && listed[n.Bridge] }
+	if !slices.ContainsFunc(rows, nested) {
+		return rows
+	}
+	out := make([]NetworkStatus, 0, len(rows))
+	for _, n := range rows {
+		if nested(n) {
+			continue
+		}
+		out = append(out, n)
+		if collapse {
+			continue
 		}
-		if level >= len(blocks) {
-			level = len(blocks) - 1
+		for _, port := range rows {
+			if port.Bridge == n.Name && nested(port) {
+				out = append(out, port)
+			}
 		}
-		builder.WriteRune(blocks[level])
 	}
+	return out
+}
 
-	result := builder.String()
-	if current > 8 {
-		return dangerStyle.Render(result)
+// selectableInterfaces returns the interface rows the cursor can visit, in display order.
+func selectableInterfaces(netStats []NetworkStatus, state viewState) []string {
+	regular, containers, _, _ := splitInterfaces(netStats, state)
+	var names []string
+	for _, n := range regular {
+		names = append(names, n.Name)
+	}
+	if state.showContainers {
+		for i, n := range containers {
+			if i == maxContainerRows {
+				break
+			}
+			names = append(names, n.Name)
+		}
 	}
-	if current > 3 {
-		return warnStyle.Render(result)
+	return names
+}
+
+// moveSelection steps the cursor through names, starting at the first row when
+// nothing (or a vanished interface) is selected.
+func moveSelection(names []string, current string, step int) string {
+	if len(names) == 0 {
+		return ""
+	}
+	idx := slices.Index(names, current)
+	if idx < 0 {
+		return names[0]
 	}
-	return okStyle.Render(result)
+	return names[min(max(idx+step, 0), len(names)-1)]
 }
 
-func renderBatteryCard(batts []BatteryStatus, thermal ThermalStatus) cardData {
-	var lines []string
-	if len(batts) == 0 {
-		lines = append(lines, subtleStyle.Render("No battery"))
-	} else {
-		b := batts[0]
+// networkRows lists interfaces individually, folding container interfaces into
+// one summary row unless expanded. A lone interface is already covered by the totals.
+func networkRows(netStats []NetworkStatus, state viewState) []string {
+	regular, containers, hidden, idle := splitInterfaces(netStats, state)
+	if len(regular) == 0 && len(containers) == 0 && idle > 0 {
+		return []string{subtleStyle.Render("No active traffic")}
+	}
+	if len(regular) <= 1 && len(containers) == 0 && hidden == 0 {
+		return nil
+	}
+
+	shown := make([]string, 0, len(regular)+len(containers))
+	for _, n := range regular {
+		shown = append(shown, n.Name)
+	}
+	if state.showContainers {
+		for _, n := range containers {
+			shown = append(shown, n.Name)
+		}
+	}
+	colors := ifaceColors(shown)
+
+	// Ports listed under their bridge are drawn as a tree; the rest, and
+	// every port in the grouped view, name their bridge instead.
+	bridged := make(map[string]bool)
+	bridgePortCount := make(map[string]int)
+	for _, n := range netStats {
+		if n.Bridge != "" {
+			bridgePortCount[n.Bridge]++
+		}
+	}
+	if !state.groupByKind {
+		for _, n := range regular {
+			if n.Bridge != "" && slices.Contains(shown, n.Bridge) {
+				bridged[n.Name] = true
+			}
+		}
+	}
+
+	var width int
+	if state.alignRates {
+		width = cmp.Or(state.rateWidth, rateColumnWidth(append(slices.Clone(regular), containers...), state))
+	}
+
+	// Rates, the raw counters, or bytes moved since the mark while one is set.
+	// Only rows drawn in their own color show threshold levels; others render
+	// in one style.
+	values := func(n NetworkStatus, levels rateThresholds) string {
+		if state.rawCounters {
+			return interfaceRowBytes(n.RxBytes, n.TxBytes, width)
+		}
+		if state.mark != nil {
+			rx, tx := state.mark.since(n)
+			return interfaceRowBytes(rx, tx, width)
+		}
+		if n.RateUnknown {
+			return interfaceRowUnknown(width)
+		}
+		return interfaceRowRates(n.RxRateMBs, n.TxRateMBs, levels, width)
+	}
+	// Any other --net-columns set is laid out as a table, its columns sized
+	// over every row that may be drawn.
+	var cols []tableColumn[netCell]
+	var widths []int
+	if len(state.netColumns) > 0 && !slices.Equal(state.netColumns, defaultNetColumns) {
+		cols = netTableColumns(state, width)
+		cells := make([]netCell, 0, len(regular)+len(containers))
+		for _, n := range append(slices.Clone(regular), containers...) {
+			cells = append(cells, netCell{n: n})
+		}
+		widths = tableWidths(cells, cols)
+	}
+	row := func(label string, n NetworkStatus) string {
+		text := fmt.Sprintf("%-6s", label) + values(n, rateThresholds{})
+		if cols != nil {
+			text = tableRow(netCell{label: label, n: n}, cols, widths)
+		}
+		if n.Bond != "" {
+			text += " in " + n.Bond
+		}
+		tail := idleSuffix(n) + asymmetrySuffix(n, state.asymRatio, state.asymWindow)
+		if n.Bridge != "" && !bridged[n.Name] {
+			tail = " in " + n.Bridge + tail
+		}
+		if ports := bridgePortCount[n.Name]; ports > 0 && state.collapseBridges {
+			tail = fmt.Sprintf(" +%d ports", ports) + tail
+		}
+		if state.pinned[n.Name] {
+			tail = " pinned" + tail
+		}
+		if state.follow != nil && n.Name == state.selectedIface {
+			tail = " following" + tail
+		}
+		// Half duplex on a modern wired link is almost always a mismatch.
+		var duplex string
+		if n.Duplex == "half" {
+			duplex = " half-duplex"
+		}
+		switch {
+		case n.Name == state.selectedIface:
+			return primaryStyle.Render(text + duplex + tail)
+		case state.hiddenIfaces[n.Name], !n.inTotals():
+			// Bond members and --totals-exclude rows are dimmed like hidden
+			// ones: shown, but not totaled.
+			return subtleStyle.Render(text + duplex + tail)
+		}
+		style := lipgloss.NewStyle().Foreground(ifacePalette[colors[n.Name]])
+		levels := thresholdsFor(n.Name, state.thresholds, state.ifaceLevels)
+		if cols != nil {
+			return tableRow(netCell{label: label, n: n, levels: levels, color: &style}, cols, widths) + warnStyle.Render(duplex) + subtleStyle.Render(tail)
+		}
+		return style.Render(fmt.Sprintf("%-6s", label)) + values(n, levels) + warnStyle.Render(duplex) + subtleStyle.Render(tail)
+	}
+
+	// With a shared scale every interface graph is drawn against the busiest.
+	var graphScale float64
+	if state.ifaceGraphs == ifaceGraphsShared {
+		for _, n := range regular {
+			graphScale = max(graphScale, slices.Max(append(ifaceGraphSeries(n), 0)))
+		}
+	}
+
+	var lines []string
+	addRow := func(label string, n NetworkStatus) {
+		lines = append(lines, row(label, n))
+		if state.ifaceGraphs != ifaceGraphsOff {
+			ceiling := thresholdsFor(n.Name, state.thresholds, state.ifaceLevels).graphCeiling()
+			if graph := ifaceGraphLine(n, graphScale, ceiling); graph != "" {
+				lines = append(lines, graph)
+			}
+		}
+		if signal := signalLine(n); signal != "" {
+			lines = append(lines, signal)
+		}
+		// The selected row expands with its share of all traffic.
+		if n.Name == state.selectedIface {
+			detail := trafficShare(netStats, n) + " of total"
+			if state.alignRates {
+				detail = fmt.Sprintf("%4s of total", trafficShare(netStats, n))
+			}
+			if n.Media != "" {
+				detail += " · " + n.Media
+			}
+			if n.Duplex != "" {
+				detail += " · " + n.Duplex + " duplex"
+			}
+			// Only the IPv4 address is listed, but the counters are not IPv4-only.
+			detail += " · IPv4+IPv6"
+			lines = append(lines, subtleStyle.Render("      "+detail))
+			if hint := heavyPortsHint(state.heavyPorts[n.Name]); hint != "" {
+				lines = append(lines, subtleStyle.Render("      "+hint))
+			}
+			lines = append(lines, ifaceConnLines(n.Name, state.ifaceConns)...)
+		}
+	}
+	// subtotal sums a section's rates, counters, or bytes since the mark.
+	subtotal := func(list []NetworkStatus) string {
+		var rx, tx float64
+		var rxBytes, txBytes uint64
+		for _, n := range list {
+			if !n.inTotals() {
+				continue
+			}
+			rx += n.RxRateMBs
+			tx += n.TxRateMBs
+			r, t := state.mark.since(n)
+			if state.rawCounters {
+				r, t = n.RxBytes, n.TxBytes
+			}
+			rxBytes, txBytes = rxBytes+r, txBytes+t
+		}
+		if state.mark != nil || state.rawCounters {
+			return fmt.Sprintf(" ↓ %*s ↑ %*s", width, formatBytes(rxBytes), width, formatBytes(txBytes))
+		}
+		return fmt.Sprintf(" ↓ %*s ↑ %*s", width, formatRate(rx), width, formatRate(tx))
+	}
+
+	if state.groupByKind {
+		for _, g := range ifaceKindGroups {
+			var members []NetworkStatus
+			for _, n := range regular {
+				if kind := cmp.Or(n.Kind, classifyInterface(n.Name)); kind == g.kind {
+					members = append(members, n) // Still in throughput order.
+				}
+			}
+			if len(members) == 0 {
+				continue
+			}
+			lines = append(lines, subtleStyle.Render(g.title)+subtotal(members))
+			for _, n := range members {
+				addRow("  "+shorten(n.Name, 4), n)
+			}
+		}
+	} else {
+		for i, n := range regular {
+			label := shorten(n.Name, 6)
+			if bridged[n.Name] {
+				branch := "├ "
+				if i+1 == len(regular) || regular[i+1].Bridge != n.Bridge {
+					branch = "└ "
+				}
+				label = branch + shorten(n.Name, 4)
+			}
+			addRow(label, n)
+		}
+	}
+
+	if len(containers) > 0 {
+		marker := "▸"
+		if state.showContainers {
+			marker = "▾"
+		}
+		lines = append(lines, subtleStyle.Render(fmt.Sprintf("%s Containers (%d)", marker, len(containers)))+subtotal(containers))
+		if state.showContainers {
+			for i, n := range containers {
+				if i == maxContainerRows {
+					lines = append(lines, subtleStyle.Render(fmt.Sprintf("  … %d more", len(containers)-maxContainerRows)))
+					break
+				}
+				addRow("  "+shorten(n.Name, 4), n)
+			}
+		}
+	}
+
+	if hidden > 0 && !state.showHidden {
+		lines = append(lines, subtleStyle.Render(fmt.Sprintf("%d hidden · H to show", hidden)))
+	}
+	return lines
+}
+
+// ifaceConnLines lists the connections on name's addresses under its
+// selected row: the local port, the peer, and the owning process when known.
+// Nothing is listed before the first connections sample.
+func ifaceConnLines(name string, byIface map[string][]Conn) []string {
+	if byIface == nil {
+		return nil
+	}
+	conns := byIface[name]
+	if len(conns) == 0 {
+		return []string{subtleStyle.Render("      no connections on its addresses")}
+	}
+	lines := make([]string, 0, len(conns))
+	for _, c := range conns {
+		port := c.Local[strings.LastIndex(c.Local, ":")+1:]
+		line := fmt.Sprintf("      %-4s :%-5s → %s", c.Proto, port, c.Remote)
+		if c.State != "" && c.State != "ESTABLISHED" {
+			line += " " + c.State
+		}
+		if c.Process != "" {
+			line += " " + c.Process
+		}
+		lines = append(lines, subtleStyle.Render(line))
+	}
+	return lines
+}
+
+// heavyPortsHint names the service port most of an interface's connected
+// sockets use, e.g. "mostly :443 (https), 78% of connections (estimate)", or
+// the leading ones when none has a majority. Without connection data there
+// is no hint.
+func heavyPortsHint(shares []PortShare) string {
+	if len(shares) == 0 {
+		return ""
+	}
+	port := func(p PortShare) string {
+		if p.Service != "" {
+			return fmt.Sprintf(":%d (%s)", p.Port, p.Service)
+		}
+		return fmt.Sprintf(":%d", p.Port)
+	}
+	if shares[0].Percent > 50 {
+		return fmt.Sprintf("mostly %s, %.0f%% of connections (estimate)", port(shares[0]), shares[0].Percent)
+	}
+	parts := make([]string, 0, len(shares))
+	for _, p := range shares {
+		parts = append(parts, fmt.Sprintf("%s %.0f%%", port(p), p.Percent))
+	}
+	return "mixed: " + strings.Join(parts, ", ") + " of connections (estimate)"
+}
+
+// trafficShare formats n's part of the combined rx+tx of all reported
+// interfaces, or "—" when nothing is moving. Bond members are counted on
+// their bond only.
+func trafficShare(netStats []NetworkStatus, n NetworkStatus) string {
+	var total float64
+	for _, s := range netStats {
+		if s.Bond == "" {
+			total += s.RxRateMBs + s.TxRateMBs
+		}
+	}
+	if total <= 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.0f%%", (n.RxRateMBs+n.TxRateMBs)/total*100)
+}
+
+// idleShowAfter is how long an interface must stay quiet before its row says
+// so; shorter lulls are normal between bursts.
+const idleShowAfter = 30 * time.Second
+
+// ifaceGraphWidth is the width of the sparkline under an interface row.
+const ifaceGraphWidth = 16
+
+// ifaceGraphSeries is an interface's recent rx+tx, as wide as its graph.
+func ifaceGraphSeries(n NetworkStatus) []float64 {
+	rx, tx := sparkWindow(n.RxHistory, ifaceGraphWidth), sparkWindow(n.TxHistory, ifaceGraphWidth)
+	series := make([]float64, len(rx))
+	for i := range rx {
+		series[i] = rx[i] + tx[i]
+	}
+	return series
+}
+
+// ifaceGraphLine draws an interface's combined traffic under its row,
+// labeled with the peak it shows. scale is the shared maximum; zero scales
+// the graph to its own peak. A ceiling, when set, fixes the scale instead.
+func ifaceGraphLine(n NetworkStatus, scale, ceiling float64) string {
+	if len(n.RxHistory) < 2 {
+		return ""
+	}
+	series := ifaceGraphSeries(n)
+	peak := slices.Max(series)
+	style := rateStyle(n.RxRateMBs + n.TxRateMBs)
+	if ceiling > 0 {
+		graph := clippedSparkline(series, ifaceGraphWidth, ceiling, style)
+		return "      " + graph + subtleStyle.Render(" peak "+formatRate(peak)+" · scale "+formatRate(ceiling))
+	}
+	if scale == 0 {
+		scale = peak
+	}
+	graph := scaledSparkline(series, ifaceGraphWidth, max(scale, 0.1), style)
+	return "      " + graph + subtleStyle.Render(" peak "+formatRate(peak))
+}
+
+// signalLine draws a Wi-Fi interface's recent signal levels under its row,
+// from -100 dBm (empty) to -30 dBm (full), so a fading signal can be lined
+// up against a dip in its throughput.
+func signalLine(n NetworkStatus) string {
+	if n.SignalDBm == 0 || len(n.SignalHistory) < 2 {
+		return ""
+	}
+	levels := make([]float64, len(n.SignalHistory))
+	for i, dbm := range n.SignalHistory {
+		levels[i] = min(max(dbm+100, 0), 70)
+	}
+	style := okStyle
+	switch {
+	case n.SignalDBm <= -75:
+		style = dangerStyle
+	case n.SignalDBm <= -67:
+		style = warnStyle
+	}
+	graph := scaledSparkline(levels, ifaceGraphWidth, 70, style)
+	if sparkStyle == sparkDigits {
+		graph = style.Render(digitReadout(n.SignalHistory, ifaceGraphWidth)) // The dBm values, not the shifted levels.
+	}
+	return "      " + graph + subtleStyle.Render(fmt.Sprintf(" signal %d dBm", n.SignalDBm))
+}
+
+// asymMinRate is the MB/s the busy direction must average before a skew
+// counts: a quiet link sending only keepalives one way is not a routing hint.
+const asymMinRate = 0.01
+
+// asymmetrySuffix marks a row whose traffic has gone overwhelmingly one way
+// for each of its last window samples, e.g. " one-way ↑ 45:1". Sent traffic
+// with nothing coming back (or the reverse) on a busy link suggests replies
+// take another path, or a link that only works in one direction.
+func asymmetrySuffix(n NetworkStatus, ratio float64, window int) string {
+	if ratio <= 0 || window < 1 || len(n.RxHistory) < window || len(n.TxHistory) < window {
+		return ""
+	}
+	rx, tx := n.RxHistory[len(n.RxHistory)-window:], n.TxHistory[len(n.TxHistory)-window:]
+	var sumRx, sumTx float64
+	upCount, downCount := 0, 0
+	for i := range rx {
+		sumRx, sumTx = sumRx+rx[i], sumTx+tx[i]
+		if tx[i] >= ratio*rx[i] {
+			upCount++
+		}
+		if rx[i] >= ratio*tx[i] {
+			downCount++
+		}
+	}
+	arrow, busy, quiet := "↑", sumTx, sumRx
+	switch {
+	case upCount == window && sumTx > 0:
+	case downCount == window && sumRx > 0:
+		arrow, busy, quiet = "↓", sumRx, sumTx
+	default:
+		return ""
+	}
+	if busy/float64(window) < asymMinRate {
+		return ""
+	}
+	if quiet == 0 {
+		return " one-way " + arrow + " only"
+	}
+	return fmt.Sprintf(" one-way %s %.0f:1", arrow, busy/quiet)
+}
+
+// idleSuffix marks a row whose link has gone quiet, e.g. " idle 2m".
+func idleSuffix(n NetworkStatus) string {
+	d := time.Duration(n.IdleSecs * float64(time.Second))
+	if d < idleShowAfter {
+		return ""
+	}
+	return " idle " + formatAge(d)
+}
+
+const (
+	trendWindow = 10  // Earlier samples trendArrow averages.
+	trendBand   = 0.1 // Share of that average the newest sample may differ by and still read as steady.
+)
+
+// trendArrow compares the newest sample of history with the average of the
+// trendWindow before it: ▲ above, ▼ below, ▬ within trendBand. With upIsWarn
+// a rise is tinted as a warning, for CPU and memory; more traffic is not a
+// problem in itself, so rates keep their arrows neutral.
+func trendArrow(history []float64, upIsWarn bool) string {
+	if !showTrends || len(history) < 3 {
+		return ""
+	}
+	cur := history[len(history)-1]
+	earlier := history[max(len(history)-1-trendWindow, 0) : len(history)-1]
+	var sum float64
+	for _, v := range earlier {
+		sum += v
+	}
+	avg := sum / float64(len(earlier))
+	band := max(avg*trendBand, 0.01) // Keep near-zero rates from flickering.
+	switch {
+	case cur > avg+band:
+		if upIsWarn {
+			return " " + warnStyle.Render("▲")
+		}
+		return " ▲"
+	case cur < avg-band:
+		return " " + subtleStyle.Render("▼")
+	}
+	return " " + subtleStyle.Render("▬")
+}
+
+// rateLevelStyle picks the style for a rate against its warn and crit levels.
+func rateLevelStyle(rate, warn, crit float64) lipgloss.Style {
+	switch {
+	case crit > 0 && rate >= crit:
+		return dangerStyle
+	case warn > 0 && rate >= warn:
+		return warnStyle
+	}
+	return lipgloss.NewStyle()
+}
+
+// interfaceRowRates formats a row's rates, each colored against its levels.
+// A width right-aligns both in columns that wide; 0 keeps the ragged layout.
+func interfaceRowRates(rx, tx float64, levels rateThresholds, width int) string {
+	rxCol, txCol := fmt.Sprintf("%-10s", formatRate(rx)), formatRate(tx)
+	if width > 0 {
+		rxCol, txCol = fmt.Sprintf("%*s", width, formatRate(rx)), fmt.Sprintf("%*s", width, formatRate(tx))
+	}
+	rxText := rateLevelStyle(rx, levels.warnRx, levels.critRx).Render(rxCol)
+	txText := rateLevelStyle(tx, levels.warnTx, levels.critTx).Render(txCol)
+	return " ↓ " + rxText + " ↑ " + txText
+}
+
+// interfaceRowUnknown fills the rate columns of a row whose counters reset
+// this sample, laid out like interfaceRowRates.
+func interfaceRowUnknown(width int) string {
+	rxCol, txCol := fmt.Sprintf("%-10s", "—"), "— reset"
+	if width > 0 {
+		rxCol, txCol = fmt.Sprintf("%*s", width, "—"), fmt.Sprintf("%*s", width, "—")+" reset"
+	}
+	return " ↓ " + subtleStyle.Render(rxCol) + " ↑ " + subtleStyle.Render(txCol)
+}
+
+// rateColumnWidth sizes the aligned columns for rows: wide enough for the
+// busiest rate still in any row's history, not just the current one, so the
+// columns hold still while a burst comes and goes. Byte counts grow
+// steadily, so their current values are enough.
+func rateColumnWidth(rows []NetworkStatus, state viewState) int {
+	width := len(formatRate(0))
+	for _, n := range rows {
+		if state.rawCounters || state.mark != nil {
+			rx, tx := n.RxBytes, n.TxBytes
+			if !state.rawCounters {
+				rx, tx = state.mark.since(n)
+			}
+			width = max(width, len(formatBytes(rx)), len(formatBytes(tx)))
+			continue
+		}
+		for _, v := range append(append([]float64{n.RxRateMBs, n.TxRateMBs}, n.RxHistory...), n.TxHistory...) {
+			width = max(width, len(formatRate(v)))
+		}
+	}
+	return width
+}
+
+// ifaceKindGroups orders the sections of the grouped interface list (G).
+// Containers keep their own collapsible row.
+var ifaceKindGroups = []struct{ kind, title string }{
+	{ifaceKindPhysical, "Physical"},
+	{ifaceKindVPN, "VPN"},
+	{ifaceKindVirtual, "Virtual"},
+	{ifaceKindRemote, "SNMP"},
+	{ifaceKindCgroup, "Cgroups"},
+	{ifaceKindGroup, "Groups"},
+}
+
+func interfaceRowBytes(rx, tx uint64, width int) string {
+	if width > 0 {
+		return fmt.Sprintf(" ↓ %*s ↑ %*s", width, formatBytes(rx), width, formatBytes(tx))
+	}
+	return fmt.Sprintf(" ↓ %-10s ↑ %s", formatBytes(rx), formatBytes(tx))
+}
+
+// tableColumn is one column of a text table. Cells may be styled; widths
+// are measured as displayed.
+type tableColumn[T any] struct {
+	mark  string // Written before each cell, e.g. ↓.
+	right bool
+	min   int
+	cell  func(T) string
+}
+
+// tableWidths sizes each column to its widest cell over rows.
+func tableWidths[T any](rows []T, cols []tableColumn[T]) []int {
+	widths := make([]int, len(cols))
+	for i, c := range cols {
+		widths[i] = c.min
+		for _, r := range rows {
+			widths[i] = max(widths[i], lipgloss.Width(c.cell(r)))
+		}
+	}
+	return widths
+}
+
+// tableRow joins a row's cells padded to widths. A left-aligned last cell
+// is not padded, which would only add trailing space.
+func tableRow[T any](row T, cols []tableColumn[T], widths []int) string {
+	var b strings.Builder
+	for i, c := range cols {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		if c.mark != "" {
+			b.WriteString(c.mark + " ")
+		}
+		cell := c.cell(row)
+		pad := strings.Repeat(" ", max(widths[i]-lipgloss.Width(cell), 0))
+		switch {
+		case c.right:
+			b.WriteString(pad + cell)
+		case i == len(cols)-1:
+			b.WriteString(cell)
+		default:
+			b.WriteString(cell + pad)
+		}
+	}
+	return b.String()
+}
+
+// netCell is one interface row of the --net-columns table.
+type netCell struct {
+	label  string
+	n      NetworkStatus
+	levels rateThresholds
+	color  *lipgloss.Style // For the label; nil leaves it plain.
+}
+
+// netTableColumns builds the --net-columns table in the configured order.
+// Like the default row, rx and tx show bytes while a mark is set or the raw
+// counters are on; width, from --aligned, is their minimum.
+func netTableColumns(state viewState, width int) []tableColumn[netCell] {
+	moved := func(n NetworkStatus) (uint64, uint64, bool) {
+		switch {
+		case state.rawCounters:
+			return n.RxBytes, n.TxBytes, true
+		case state.mark != nil:
+			rx, tx := state.mark.since(n)
+			return rx, tx, true
+		}
+		return 0, 0, false
+	}
+	cols := make([]tableColumn[netCell], 0, len(state.netColumns))
+	for _, name := range state.netColumns {
+		var col tableColumn[netCell]
+		switch name {
+		case "name":
+			col = tableColumn[netCell]{min: 6, cell: func(c netCell) string {
+				if c.color != nil {
+					return c.color.Render(c.label)
+				}
+				return c.label
+			}}
+		case "ip":
+			col.cell = func(c netCell) string { return cmp.Or(c.n.IP, "—") }
+		case "rx", "tx":
+			rx := name == "rx"
+			col = tableColumn[netCell]{mark: "↓", right: true, min: width, cell: func(c netCell) string {
+				if r, t, isBytes := moved(c.n); isBytes && rx {
+					return formatBytes(r)
+				} else if isBytes {
+					return formatBytes(t)
+				}
+				if c.n.RateUnknown {
+					return subtleStyle.Render("—")
+				}
+				if rx {
+					return rateLevelStyle(c.n.RxRateMBs, c.levels.warnRx, c.levels.critRx).Render(formatRate(c.n.RxRateMBs))
+				}
+				return rateLevelStyle(c.n.TxRateMBs, c.levels.warnTx, c.levels.critTx).Render(formatRate(c.n.TxRateMBs))
+			}}
+			if !rx {
+				col.mark = "↑"
+			}
+		case "total":
+			col = tableColumn[netCell]{mark: "⇅", right: true, min: width, cell: func(c netCell) string {
+				if r, t, isBytes := moved(c.n); isBytes {
+					return formatBytes(r + t)
+				}
+				if c.n.RateUnknown {
+					return subtleStyle.Render("—")
+				}
+				return formatRate(c.n.RxRateMBs + c.n.TxRateMBs)
+			}}
+		case "dir":
+			col.cell = func(c netCell) string {
+				if state.dirTotals {
+					b := state.sessionBytes[c.n.Name]
+					return trafficDirection(float64(b[0]), float64(b[1]), 0, state.dirRatio)
+				}
+				return trafficDirection(c.n.RxRateMBs, c.n.TxRateMBs, asymMinRate, state.dirRatio)
+			}
+		case "errors":
+			col = tableColumn[netCell]{mark: "err", cell: func(c netCell) string {
+				text := fmt.Sprintf("%d/%d", c.n.RxErrors, c.n.TxErrors)
+				if c.n.RxErrors+c.n.TxErrors > 0 {
+					return warnStyle.Render(text)
+				}
+				return text
+			}}
+		case "util":
+			col = tableColumn[netCell]{right: true, cell: func(c netCell) string {
+				if u, ok := linkUtilization(c.n); ok {
+					return fmt.Sprintf("%.0f%%", u)
+				}
+				return "—"
+			}}
+		case "kind":
+			col.cell = func(c netCell) string { return cmp.Or(c.n.Kind, classifyInterface(c.n.Name)) }
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// trafficDirection labels which way traffic mostly flows: down or up when
+// one direction is at least ratio times the other, both when neither leads
+// by that much, and idle when neither reaches floor.
+func trafficDirection(rx, tx, floor, ratio float64) string {
+	switch {
+	case rx <= floor && tx <= floor:
+		return "· idle"
+	case rx >= tx*ratio:
+		return "↓ down"
+	case tx >= rx*ratio:
+		return "↑ up"
+	}
+	return "⇅ both"
+}
+
+// linkUtilization is the busier direction's share of the link speed, in
+// percent, when the speed is known.
+func linkUtilization(n NetworkStatus) (float64, bool) {
+	if n.LinkMbps <= 0 {
+		return 0, false
+	}
+	mbps := max(n.RxRateMBs, n.TxRateMBs) * 1024 * 1024 * 8 / 1e6
+	return mbps / float64(n.LinkMbps) * 100, true
+}
+
+// ifacePalette holds the per-interface label colors.
+var ifacePalette = []lipgloss.Color{"#8BE9FD", "#FFB86C", "#50FA7B", "#FF79C6", "#F1FA8C", "#6EB5FF", "#FF9E9E", "#B4A7F5"}
+
+// ifaceColors maps each interface to a palette index derived from a hash of its
+// name, so en0 keeps its color across restarts and reorderings. Collisions are
+// resolved by probing in name order, which keeps the result independent of row
+// order and distinct whenever the set fits in the palette.
+func ifaceColors(names []string) map[string]int {
+	sorted := slices.Clone(names)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	colors := make(map[string]int, len(sorted))
+	used := make(map[int]bool, len(ifacePalette))
+	for _, name := range sorted {
+		idx := int(fnv32(name) % uint32(len(ifacePalette)))
+		for i := 0; i < len(ifacePalette) && used[idx]; i++ {
+			idx = (idx + 1) % len(ifacePalette)
+		}
+		used[idx] = true
+		colors[name] = idx
+	}
+	return colors
+}
+
+// fnv32 hashes with FNV-1a; stable across runs, unlike map order or maphash.
+func fnv32(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// 8 levels: ▁▂▃▄▅▆▇█
+func sparkline(history []float64, current float64, width int) string {
+	return styledSparkline(history, width, rateStyle(current))
+}
+
+// ceilingSparkline is sparkline on the fixed scale ceiling, or auto-scaled
+// when ceiling is 0.
+func ceilingSparkline(history []float64, current float64, width int, ceiling float64) string {
+	if ceiling <= 0 {
+		return sparkline(history, current, width)
+	}
+	return clippedSparkline(history, width, ceiling, rateStyle(current))
+}
+
+// clippedSparkline renders the most recent width points against ceiling,
+// drawing the ones above it as full cells in the danger color so a clipped
+// burst stands out from one that just reaches the top.
+func clippedSparkline(history []float64, width int, ceiling float64, style lipgloss.Style) string {
+	if sparkStyle == sparkDigits {
+		return style.Render(digitReadout(history, width))
+	}
+	glyph := sparkGlyphs[sparkStyle]
+	var builder strings.Builder
+	for _, v := range sparkWindow(history, width) {
+		if v > ceiling {
+			builder.WriteString(dangerStyle.Render(string(glyph(1))))
+			continue
+		}
+		builder.WriteString(style.Render(string(glyph(v / ceiling))))
+	}
+	return builder.String()
+}
+
+// styledSparkline renders the most recent width points scaled to their max.
+func styledSparkline(history []float64, width int, style lipgloss.Style) string {
+	maxVal := 0.1
+	for _, v := range sparkWindow(history, width) {
+		if v > maxVal {
+			maxVal = v
+		}
+	}
+	return scaledSparkline(history, width, maxVal, style)
+}
+
+// percentSparkline draws a 0-100 history on a fixed scale, so a CPU hovering
+// at 3% stays flat instead of filling the graph.
+func percentSparkline(history []float64, width int, current float64) string {
+	return scaledSparkline(history, width, 100, percentStyle(current))
+}
+
+// scaledSparkline renders the most recent width points against maxVal.
+func scaledSparkline(history []float64, width int, maxVal float64, style lipgloss.Style) string {
+	if sparkStyle == sparkDigits {
+		return style.Render(digitReadout(history, width))
+	}
+	data := sparkWindow(history, width)
+	glyph := sparkGlyphs[sparkStyle]
+	var builder strings.Builder
+	for _, v := range data {
+		builder.WriteRune(glyph(v / maxVal))
+	}
+
+	return style.Render(builder.String())
+}
+
+// digitReadout prints as many of the newest values as fit in width columns,
+// oldest on the left, right-aligned like a sparkline.
+func digitReadout(history []float64, width int) string {
+	var cells []string
+	used := -1 // No separator before the first cell.
+	for i := len(history) - 1; i >= 0; i-- {
+		cell := compactNumber(history[i])
+		if used+1+len(cell) > width {
+			break
+		}
+		used += 1 + len(cell)
+		cells = append(cells, cell)
+	}
+	slices.Reverse(cells)
+	line := strings.Join(cells, " ")
+	return strings.Repeat(" ", max(width-len(line), 0)) + line
+}
+
+// compactNumber formats v in at most four columns: one decimal below 10,
+// whole numbers below 1000, then k and M suffixes.
+func compactNumber(v float64) string {
+	switch v = max(v, 0); {
+	case v < 9.95:
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	case v < 999.5:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	case v < 999_500:
+		return strconv.FormatFloat(v/1000, 'f', 0, 64) + "k"
+	}
+	return strconv.FormatFloat(v/1_000_000, 'f', 0, 64) + "M"
+}
+
+// rateHistogram counts samples into equal-width buckets spanning 0 to the observed max.
+func rateHistogram(samples []float64, buckets int) []int {
+	counts := make([]int, buckets)
+	if buckets == 0 || len(samples) == 0 {
+		return counts
+	}
+	maxVal := slices.Max(samples)
+	for _, v := range samples {
+		i := 0
+		if maxVal > 0 {
+			i = int(v / maxVal * float64(buckets))
+		}
+		counts[min(max(i, 0), buckets-1)]++
+	}
+	return counts
+}
+
+// histogramLine draws rateHistogram as bars: a tall bar on the left means mostly
+// quiet, bars spread to the right mean bursty or sustained traffic.
+func histogramLine(history []float64, current float64, width int) string {
+	counts := rateHistogram(history, width)
+	peak := slices.Max(append(counts, 1))
+	glyph := sparkGlyphs[sparkStyle]
+	var builder strings.Builder
+	for _, n := range counts {
+		if n == 0 {
+			builder.WriteRune(' ')
+			continue
+		}
+		builder.WriteRune(glyph(float64(n) / float64(peak)))
+	}
+	return rateStyle(current).Render(builder.String())
+}
+
+// mirroredSparkline renders rx growing up from a shared baseline and tx hanging below it.
+// Both halves use the same scale so their heights are directly comparable:
+// ceiling when set, with points above it in the danger color, else the
+// largest point.
+func mirroredSparkline(rxHistory, txHistory []float64, currentRx, currentTx float64, width int, ceiling float64) (string, string) {
+	// Lower eighths; tx cells reuse them in reverse video so the bar is anchored at the top.
+	blocks := []rune{' ', '▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+
+	rx := sparkWindow(rxHistory, width)
+	tx := sparkWindow(txHistory, width)
+	maxVal := 0.1
+	for i := range rx {
+		maxVal = max(maxVal, rx[i], tx[i])
+	}
+	if ceiling > 0 {
+		maxVal = ceiling
+	}
+	styleOf := func(v float64, base lipgloss.Style) lipgloss.Style {
+		if ceiling > 0 && v > ceiling {
+			return dangerStyle
+		}
+		return base
+	}
+
+	levelOf := func(v float64) int {
+		level := int(math.Round(v / maxVal * float64(len(blocks)-1)))
+		return min(max(level, 0), len(blocks)-1)
+	}
+
+	rxStyle := rateStyle(currentRx)
+	txStyle := rateStyle(currentTx)
+	var top, bottom strings.Builder
+	if sparkStyle != sparkBlocks {
+		// Only block glyphs can be flipped with reverse video; other sets
+		// draw both halves upright on the shared scale.
+		glyph := sparkGlyphs[sparkStyle]
+		for i := range rx {
+			top.WriteString(styleOf(rx[i], rxStyle).Render(string(glyph(rx[i] / maxVal))))
+			bottom.WriteString(styleOf(tx[i], txStyle).Render(string(glyph(tx[i] / maxVal))))
+		}
+		return top.String(), bottom.String()
+	}
+	for i := range rx {
+		top.WriteString(styleOf(rx[i], rxStyle).Render(string(blocks[levelOf(rx[i])])))
+		level := levelOf(tx[i])
+		if level == 0 {
+			bottom.WriteRune(' ')
+			continue
+		}
+		bottom.WriteString(styleOf(tx[i], txStyle).Reverse(true).Render(string(blocks[len(blocks)-1-level])))
+	}
+	return top.String(), bottom.String()
+}
+
+// rateStyle colors a network rate (MB/s) by load.
+func rateStyle(current float64) lipgloss.Style {
+	if current > 8 {
+		return dangerStyle
+	}
+	if current > 3 {
+		return warnStyle
+	}
+	return okStyle
+}
+
+func renderBatteryCard(batts []BatteryStatus, thermal ThermalStatus) cardData {
+	var lines []string
+	if len(batts) == 0 {
+		lines = append(lines, subtleStyle.Render("No battery"))
+	} else {
+		b := batts[0]
 		statusLower := strings.ToLower(b.Status)
-		percentText := fmt.Sprintf("%5.1f%%", b.Percent)
+		percentText := formatPercent(b.Percent)
 		if b.Percent < 20 && statusLower != "charging" && statusLower != "charged" {
 			percentText = dangerStyle.Render(percentText)
 		}
@@ -630,7 +2358,7 @@ func renderBatteryCard(batts []BatteryStatus, thermal ThermalStatus) cardData {
 		}
 	}
 
-	return cardData{icon: iconBattery, title: "Power", lines: lines}
+	return cardData{id: "power", icon: iconBattery, title: "Power", lines: lines}
 }
 
 func renderCard(data cardData, width int, height int) string {
@@ -670,7 +2398,7 @@ func wrapToWidth(text string, width int) []string {
 
 func progressBar(percent float64) string {
 	total := 16
-	if percent < 0 {
+	if percent < 0 || math.IsNaN(percent) {
 		percent = 0
 	}
 	if percent > 100 {
@@ -711,13 +2439,18 @@ func batteryProgressBar(percent float64) string {
 }
 
 func colorizePercent(percent float64, s string) string {
+	return percentStyle(percent).Render(s)
+}
+
+// percentStyle picks the ok/warn/danger style for a utilisation percentage.
+func percentStyle(percent float64) lipgloss.Style {
 	switch {
 	case percent >= 85:
-		return dangerStyle.Render(s)
+		return dangerStyle
 	case percent >= 60:
-		return warnStyle.Render(s)
+		return warnStyle
 	default:
-		return okStyle.Render(s)
+		return okStyle
 	}
 }
 
@@ -743,64 +2476,6 @@ func colorizeTemp(t float64) string {
 	}
 }
 
-func formatRate(mb float64) string {
-	if mb < 0.01 {
-		return "0 MB/s"
-	}
-	if mb < 1 {
-		return fmt.Sprintf("%.2f MB/s", mb)
-	}
-	if mb < 10 {
-		return fmt.Sprintf("%.1f MB/s", mb)
-	}
-	return fmt.Sprintf("%.0f MB/s", mb)
-}
-
-func humanBytes(v uint64) string {
-	switch {
-	case v > 1<<40:
-		return fmt.Sprintf("%.1f TB", float64(v)/(1<<40))
-	case v > 1<<30:
-		return fmt.Sprintf("%.1f GB", float64(v)/(1<<30))
-	case v > 1<<20:
-		return fmt.Sprintf("%.1f MB", float64(v)/(1<<20))
-	case v > 1<<10:
-		return fmt.Sprintf("%.1f KB", float64(v)/(1<<10))
-	default:
-		return strconv.FormatUint(v, 10) + " B"
-	}
-}
-
-func humanBytesShort(v uint64) string {
-	switch {
-	case v >= 1<<40:
-		return fmt.Sprintf("%.0fT", float64(v)/(1<<40))
-	case v >= 1<<30:
-		return fmt.Sprintf("%.0fG", float64(v)/(1<<30))
-	case v >= 1<<20:
-		return fmt.Sprintf("%.0fM", float64(v)/(1<<20))
-	case v >= 1<<10:
-		return fmt.Sprintf("%.0fK", float64(v)/(1<<10))
-	default:
-		return strconv.FormatUint(v, 10)
-	}
-}
-
-func humanBytesCompact(v uint64) string {
-	switch {
-	case v >= 1<<40:
-		return fmt.Sprintf("%.1fT", float64(v)/(1<<40))
-	case v >= 1<<30:
-		return fmt.Sprintf("%.1fG", float64(v)/(1<<30))
-	case v >= 1<<20:
-		return fmt.Sprintf("%.1fM", float64(v)/(1<<20))
-	case v >= 1<<10:
-		return fmt.Sprintf("%.1fK", float64(v)/(1<<10))
-	default:
-		return strconv.FormatUint(v, 10)
-	}
-}
-
 func shorten(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
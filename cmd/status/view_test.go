@@ -1,9 +1,17 @@
+//go:build !agent
+
 package main
 
 import (
+	"io"
+	"math"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
@@ -44,6 +52,89 @@ func TestFormatRate(t *testing.T) {
 	}
 }
 
+func TestFormatRateWithPrecision(t *testing.T) {
+	defer func(prev int) { valuePrecision = prev }(valuePrecision)
+
+	tests := []struct {
+		precision int
+		input     float64
+		want      string
+	}{
+		{0, 12.34, "12 MB/s"},
+		{0, 12.5, "12 MB/s"}, // Round half to even, like strconv.
+		{0, 0.4, "0 MB/s"},
+		{1, 0.96, "1.0 MB/s"}, // Rounds up instead of truncating.
+		{2, 12.345, "12.35 MB/s"},
+		{3, 0.0004, "0 MB/s"},
+	}
+
+	for _, tt := range tests {
+		valuePrecision = tt.precision
+		if got := formatRate(tt.input); got != tt.want {
+			t.Errorf("formatRate(%v) with precision %d = %q, want %q", tt.input, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestFormatByteRate(t *testing.T) {
+	defer func(prev int) { valuePrecision = prev }(valuePrecision)
+
+	tests := []struct {
+		precision int
+		input     float64
+		want      string
+	}{
+		{-1, 0, "0 B/s"},
+		{-1, 0.4, "0 B/s"},
+		{-1, 180, "180 B/s"},
+		{-1, 1023, "1023 B/s"},
+		{-1, 1536, "1.5 KB/s"},
+		{-1, 300 * 1024, "300 KB/s"},
+		{-1, 1023.96 * 1024, "1.0 MB/s"}, // Rounds into the next unit.
+		{-1, 1.5 * 1024 * 1024, "1.5 MB/s"},
+		{-1, 9.96 * 1024 * 1024, "10 MB/s"},
+		{-1, 2.5 * 1024 * 1024 * 1024, "2.5 GB/s"},
+		{-1, 4096 * 1024 * 1024 * 1024, "4096 GB/s"},
+		{2, 300 * 1024, "300.00 KB/s"},
+		{2, 180, "180 B/s"}, // Bytes stay whole.
+	}
+	for _, tt := range tests {
+		valuePrecision = tt.precision
+		if got := formatByteRate(tt.input); got != tt.want {
+			t.Errorf("formatByteRate(%v) with precision %d = %q, want %q", tt.input, tt.precision, got, tt.want)
+		}
+	}
+
+	valuePrecision = -1
+	defer func(prev bool) { adaptiveRateUnits = prev }(adaptiveRateUnits)
+	adaptiveRateUnits = true
+	if got := formatRate(0.3); got != "307 KB/s" {
+		t.Errorf("formatRate(0.3) with --rate-units auto = %q, want 307 KB/s", got)
+	}
+}
+
+func TestFormatPercentWithPrecision(t *testing.T) {
+	defer func(prev int) { valuePrecision = prev }(valuePrecision)
+
+	tests := []struct {
+		precision int
+		input     float64
+		want      string
+	}{
+		{-1, 45.25, " 45.2%"},
+		{0, 45.6, " 46%"},
+		{2, 5.5, "  5.50%"},
+		{3, 100, "100.000%"},
+	}
+
+	for _, tt := range tests {
+		valuePrecision = tt.precision
+		if got := formatPercent(tt.input); got != tt.want {
+			t.Errorf("formatPercent(%v) with precision %d = %q, want %q", tt.input, tt.precision, got, tt.want)
+		}
+	}
+}
+
 func TestColorizePercent(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -488,14 +579,29 @@ func TestIsNoiseInterface(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := isNoiseInterface(tt.input)
+			got := isNoiseInterfaceOn(tt.input, "darwin")
 			if got != tt.want {
-				t.Errorf("isNoiseInterface(%q) = %v, want %v", tt.input, got, tt.want)
+				t.Errorf("isNoiseInterfaceOn(%q, darwin) = %v, want %v", tt.input, got, tt.want)
 			}
 		})
 	}
 }
 
+func TestIsNoiseInterfaceApPrefixDarwinOnly(t *testing.T) {
+	for _, name := range []string{"ap0", "anpi0"} {
+		if !isNoiseInterfaceOn(name, "darwin") {
+			t.Errorf("%s should be hidden on darwin", name)
+		}
+		if isNoiseInterfaceOn(name, "linux") {
+			t.Errorf("%s should be visible on linux", name)
+		}
+	}
+	// Shared prefixes stay noise everywhere.
+	if !isNoiseInterfaceOn("lo", "linux") {
+		t.Errorf("lo should be hidden on linux")
+	}
+}
+
 func TestParsePMSet(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -922,6 +1028,108 @@ func TestSparkline(t *testing.T) {
 	}
 }
 
+func TestMirroredSparkline(t *testing.T) {
+	top, bottom := mirroredSparkline([]float64{0, 4, 8}, []float64{8, 4, 0}, 8, 0, 5, 0)
+
+	topClean := []rune(stripANSI(top))
+	bottomClean := []rune(stripANSI(bottom))
+	if len(topClean) != 5 || len(bottomClean) != 5 {
+		t.Fatalf("mirroredSparkline() widths = %d/%d, want 5/5", len(topClean), len(bottomClean))
+	}
+	// Padding and zero samples leave the baseline empty on both halves.
+	if topClean[0] != ' ' || bottomClean[4] != ' ' {
+		t.Fatalf("mirroredSparkline() expected blank cells for zero samples, got %q / %q", string(topClean), string(bottomClean))
+	}
+	// Shared scale: the rx peak and the tx peak reach the same height.
+	if topClean[4] != '█' {
+		t.Fatalf("mirroredSparkline() rx peak = %q, want full block", topClean[4])
+	}
+	if topClean[3] != '▄' {
+		t.Fatalf("mirroredSparkline() rx half = %q, want half block", topClean[3])
+	}
+	// tx cells are reversed lower blocks, so a half-height tx sample uses the half block too.
+	if bottomClean[3] != '▄' {
+		t.Fatalf("mirroredSparkline() tx half = %q, want half block", bottomClean[3])
+	}
+}
+
+func TestSparklineStyles(t *testing.T) {
+	defer func(prev string) { sparkStyle = prev }(sparkStyle)
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{sparkBlocks, "▁▄█"},
+		{sparkBraille, "⡀⣤⣿"},
+		{sparkASCII, "_-#"},
+	}
+	for _, tt := range tests {
+		sparkStyle = tt.style
+		if got := stripANSI(sparkline([]float64{0, 0.5, 1}, 0, 3)); got != tt.want {
+			t.Errorf("sparkline(%s) = %q, want %q", tt.style, got, tt.want)
+		}
+	}
+}
+
+func TestDigitReadout(t *testing.T) {
+	defer func(prev string) { sparkStyle = prev }(sparkStyle)
+
+	tests := []struct {
+		history []float64
+		width   int
+		want    string
+	}{
+		{[]float64{0.25, 3, 42, 1234}, 16, "   0.2 3.0 42 1k"},
+		{[]float64{0.25, 3, 42, 1234}, 8, "   42 1k"},
+		{[]float64{12.5}, 4, "  12"},
+		{nil, 3, "   "},
+	}
+	for _, tt := range tests {
+		if got := digitReadout(tt.history, tt.width); got != tt.want {
+			t.Errorf("digitReadout(%v, %d) = %q, want %q", tt.history, tt.width, got, tt.want)
+		}
+	}
+
+	// n swaps every sparkline for digits and restores the previous style.
+	sparkStyle = sparkBraille
+	m := model{}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if got := stripANSI(sparkline([]float64{1, 2}, 2, 7)); sparkStyle != sparkDigits || got != "1.0 2.0" {
+		t.Fatalf("digit mode sparkline = %q (style %s)", got, sparkStyle)
+	}
+	next.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if sparkStyle != sparkBraille {
+		t.Fatalf("second n should restore braille, got %s", sparkStyle)
+	}
+}
+
+func TestPercentSparklineUsesFixedScale(t *testing.T) {
+	defer func(prev string) { sparkStyle = prev }(sparkStyle)
+	sparkStyle = sparkASCII
+
+	// A quiet 0-5% CPU must not fill the graph the way a relative scale would.
+	if got := stripANSI(percentSparkline([]float64{0, 5}, 2, 5)); got != "__" {
+		t.Errorf("percentSparkline(low) = %q, want %q", got, "__")
+	}
+	if got := stripANSI(percentSparkline([]float64{0, 100}, 2, 100)); got != "_#" {
+		t.Errorf("percentSparkline(full) = %q, want %q", got, "_#")
+	}
+}
+
+func TestRenderCardsShowTrendWithHistory(t *testing.T) {
+	mem := renderMemoryCard(MemoryStatus{UsedPercent: 40, History: []float64{20, 40}}, 60)
+	if last := stripANSI(mem.lines[len(mem.lines)-1]); !strings.HasPrefix(last, "Trend  ") {
+		t.Errorf("memory card last line = %q, want trend", last)
+	}
+	cpu := renderCPUCard(CPUStatus{Usage: 10, History: []float64{10}}, ThermalStatus{})
+	for _, line := range cpu.lines {
+		if strings.HasPrefix(stripANSI(line), "Trend") {
+			t.Errorf("cpu card with one sample should not show a trend, got %q", line)
+		}
+	}
+}
+
 func TestRenderHeaderErrorReturnsMoleOnce(t *testing.T) {
 	header, mole := renderHeader(MetricsSnapshot{}, "boom", 0, 120, false)
 
@@ -1083,26 +1291,6 @@ func TestModelViewErrorRendersSingleMole(t *testing.T) {
 	}
 }
 
-func stripANSI(s string) string {
-	var result strings.Builder
-	i := 0
-	for i < len(s) {
-		if i < len(s)-1 && s[i] == '\x1b' && s[i+1] == '[' {
-			i += 2
-			for i < len(s) && (s[i] < 'A' || s[i] > 'Z') && (s[i] < 'a' || s[i] > 'z') {
-				i++
-			}
-			if i < len(s) {
-				i++
-			}
-		} else {
-			result.WriteByte(s[i])
-			i++
-		}
-	}
-	return result.String()
-}
-
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || containsMiddle(s, substr)))
 }
@@ -1115,3 +1303,975 @@ func containsMiddle(s, substr string) bool {
 	}
 	return false
 }
+
+func TestRenderFooterSampleAge(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.Local)
+
+	if got := stripANSI(renderFooter(time.Time{}, now, time.Second)); got != "15:04:05" {
+		t.Fatalf("renderFooter() before first sample = %q", got)
+	}
+
+	fresh := renderFooter(now.Add(-time.Second), now, time.Second)
+	if got := stripANSI(fresh); got != "15:04:05 · updated 1s ago" {
+		t.Fatalf("renderFooter() fresh = %q", got)
+	}
+
+	stale := renderFooter(now.Add(-3*time.Minute), now, time.Second)
+	if got := stripANSI(stale); !strings.Contains(got, "updated 3m ago") {
+		t.Fatalf("renderFooter() stale = %q", got)
+	}
+}
+
+func TestRenderSummaryLine(t *testing.T) {
+	m := MetricsSnapshot{
+		CPU:         CPUStatus{Usage: 12.3},
+		Memory:      MemoryStatus{UsedPercent: 45.6},
+		Network:     []NetworkStatus{{RxRateMBs: 1.5, TxRateMBs: 0.25}},
+		Connections: ConnectionStatus{Total: 42},
+	}
+
+	got := stripANSI(renderSummaryLine(m, summaryFields))
+	want := "↓ 1.5 MB/s · ↑ 0.25 MB/s · CPU 12.3% · Mem 45.6% · 42 conns · Proxy off"
+	if got != want {
+		t.Fatalf("renderSummaryLine() = %q, want %q", got, want)
+	}
+
+	m.Proxy = ProxyStatus{Enabled: true, Type: "HTTP"}
+	if got := stripANSI(renderSummaryLine(m, []string{"proxy", "cpu"})); got != "Proxy HTTP · CPU 12.3%" {
+		t.Fatalf("renderSummaryLine() custom fields = %q", got)
+	}
+}
+
+func TestBuildCardsCollapsed(t *testing.T) {
+	m := MetricsSnapshot{
+		CPU:     CPUStatus{Usage: 42},
+		Network: []NetworkStatus{{Name: "en0", RxRateMBs: 1.5, TxRateMBs: 0.5}},
+	}
+	cards := buildCards(m, 60, viewState{collapsed: toSet(panelIDs)})
+	for _, c := range cards {
+		if len(c.lines) != 1 {
+			t.Errorf("collapsed %s card has %d lines, want 1", c.id, len(c.lines))
+		}
+		if c.id == "network" && !strings.Contains(stripANSI(c.lines[0]), "↓ 1.5 MB/s") {
+			t.Errorf("collapsed network summary = %q", stripANSI(c.lines[0]))
+		}
+	}
+
+	expanded := buildCards(m, 60, viewState{})
+	for _, c := range expanded {
+		if c.id == "cpu" && len(c.lines) < 2 {
+			t.Errorf("expanded cpu card should show detail lines, got %d", len(c.lines))
+		}
+	}
+}
+
+func TestRateHistogram(t *testing.T) {
+	got := rateHistogram([]float64{0, 0, 0, 1, 2, 4}, 4)
+	// Buckets of width 1 up to the max of 4; the max lands in the last bucket.
+	want := []int{3, 1, 1, 1}
+	if !slices.Equal(got, want) {
+		t.Fatalf("rateHistogram() = %v, want %v", got, want)
+	}
+	if got := rateHistogram(nil, 3); !slices.Equal(got, []int{0, 0, 0}) {
+		t.Fatalf("rateHistogram(nil) = %v, want zero buckets", got)
+	}
+	if got := stripANSI(histogramLine([]float64{0, 0, 4}, 0, 4)); len([]rune(got)) != 4 {
+		t.Fatalf("histogramLine() width = %d, want 4", len([]rune(got)))
+	}
+}
+
+func TestZombieAlertRaisedOnce(t *testing.T) {
+	m := model{zombieThreshold: 2}
+	m.metrics.ProcessStates = map[string]int{procStateZombie: 3}
+
+	if alert := stripANSI(renderAlerts(m.metrics, m.zombieThreshold, 0)); !strings.Contains(alert, "3 zombie processes") {
+		t.Fatalf("renderAlerts() = %q, want zombie alert", alert)
+	}
+	m.checkAlerts()
+	if !m.zombieAlerted {
+		t.Fatalf("expected alert to be raised")
+	}
+
+	m.metrics.ProcessStates[procStateZombie] = 1
+	m.checkAlerts()
+	if m.zombieAlerted || renderAlerts(m.metrics, m.zombieThreshold, 0) != "" {
+		t.Fatalf("alert should clear below the threshold")
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	at := func(hhmm string) time.Time {
+		t, _ := time.ParseInLocation("15:04", hhmm, time.Local)
+		return t
+	}
+	overnight, err := parseQuietHours("22:00-08:00")
+	if err != nil {
+		t.Fatalf("parseQuietHours() error = %v", err)
+	}
+	daytime, _ := parseQuietHours("12:30-13:30")
+	tests := []struct {
+		q    quietHours
+		at   string
+		want bool
+	}{
+		{overnight, "23:15", true},
+		{overnight, "03:00", true},
+		{overnight, "08:00", false},
+		{overnight, "21:59", false},
+		{daytime, "12:30", true},
+		{daytime, "13:30", false},
+		{quietHours{}, "03:00", false},
+	}
+	for _, tt := range tests {
+		if got := tt.q.contains(at(tt.at)); got != tt.want {
+			t.Errorf("%s.contains(%s) = %v, want %v", tt.q, tt.at, got, tt.want)
+		}
+	}
+	if overnight.String() != "22:00-08:00" {
+		t.Errorf("String() = %q", overnight.String())
+	}
+
+	// The window is read in the time's own zone: 23:00 UTC is 08:00 in Tokyo.
+	tokyo := time.FixedZone("JST", 9*60*60)
+	utc := time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC)
+	if !overnight.contains(utc) || overnight.contains(utc.In(tokyo)) {
+		t.Errorf("contains() should use the wall clock of the given location")
+	}
+
+	for _, bad := range []string{"22:00", "25:00-08:00", "8am-9am", "09:00-09:00"} {
+		if _, err := parseQuietHours(bad); err == nil {
+			t.Errorf("parseQuietHours(%q) expected error", bad)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		in   uint64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{10*1024 - 1, "10 KB"},
+		{340 << 20, "340 MB"},
+		{1<<20 - 1, "1.0 MB"},
+		{1 << 20, "1.0 MB"},
+		{1288490188, "1.2 GB"},
+		{1<<40 - 1, "1.0 TB"},
+		{5 << 40, "5.0 TB"},
+		{1 << 50, "1024 TB"},
+		{math.MaxUint64, "16777216 TB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.in); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNetworkCardTotals(t *testing.T) {
+	stats := []NetworkStatus{{Name: "en0", RxRateMBs: 1, TxRateMBs: 0.5}}
+	state := viewState{showTotals: true, totalRxBytes: 1288490188, totalTxBytes: 340 << 20}
+	card := renderNetworkCard(stats, NetworkHistory{}, ProxyStatus{}, false, 60, state)
+	if got := stripANSI(strings.Join(card.lines, "\n")); !strings.Contains(got, "Total  1.2 GB ↓ / 340 MB ↑") {
+		t.Fatalf("network card missing totals line:\n%s", got)
+	}
+
+	stats = []NetworkStatus{
+		{Name: "en0", RxBytes: 3 << 30, TxBytes: 1 << 30},
+		{Name: "bridge0", RxBytes: 1 << 30, Untotaled: true},
+	}
+	state.bootTotals = true
+	card = renderNetworkCard(stats, NetworkHistory{}, ProxyStatus{}, false, 60, state)
+	if got := stripANSI(strings.Join(card.lines, "\n")); !strings.Contains(got, "Total  3.0 GB ↓ / 1.0 GB ↑ since boot") {
+		t.Fatalf("boot totals should sum the raw counters of totaled rows:\n%s", got)
+	}
+
+	m := model{}
+	for _, want := range []viewState{{showTotals: true, bootTotals: true}, {showTotals: true}} {
+		next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("T")})
+		m = next.(model)
+		if m.display.showTotals != want.showTotals || m.display.bootTotals != want.bootTotals {
+			t.Fatalf("T: showTotals=%v bootTotals=%v, want %v %v", m.display.showTotals, m.display.bootTotals, want.showTotals, want.bootTotals)
+		}
+	}
+}
+
+func TestManualRefreshRetiresPendingTick(t *testing.T) {
+	m := model{ready: true}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = next.(model)
+	if cmd == nil || !m.collecting || m.tickGen != 1 {
+		t.Fatalf("r should start a sample and bump the tick generation, got collecting=%v gen=%d", m.collecting, m.tickGen)
+	}
+
+	// The tick scheduled before the refresh must not start a second sample.
+	m.collecting = false
+	if _, cmd := m.Update(tickMsg{gen: 0}); cmd != nil {
+		t.Fatalf("stale tick should be dropped")
+	}
+
+	next, _ = m.Update(metricsMsg{data: MetricsSnapshot{CollectedAt: time.Now()}})
+	m = next.(model)
+	if m.forced || !strings.Contains(stripANSI(m.footer()), "refreshed") {
+		t.Fatalf("footer should confirm the refresh once the sample lands: %q", stripANSI(m.footer()))
+	}
+}
+
+func TestPauseUnfocused(t *testing.T) {
+	m := model{ready: true}
+	next, _ := m.Update(tea.BlurMsg{})
+	if m = next.(model); m.unfocused {
+		t.Fatalf("blur should not pause without --pause-unfocused")
+	}
+
+	m.pauseUnfocused = true
+	next, _ = m.Update(tea.BlurMsg{})
+	m = next.(model)
+	if _, cmd := m.Update(tickMsg{gen: 0}); cmd != nil {
+		t.Fatalf("ticks should be dropped while unfocused")
+	}
+	if footer := stripANSI(m.footer()); !strings.Contains(footer, "paused while unfocused") {
+		t.Fatalf("footer should say sampling is paused: %q", footer)
+	}
+
+	next, cmd := m.Update(tea.FocusMsg{})
+	m = next.(model)
+	if cmd == nil || m.unfocused || !m.rebaseline || m.tickGen != 1 {
+		t.Fatalf("focus should resume on a new schedule with a fresh baseline, got unfocused=%v rebaseline=%v gen=%d", m.unfocused, m.rebaseline, m.tickGen)
+	}
+	next, _ = m.Update(tickMsg{gen: 1})
+	if m = next.(model); !m.collecting || m.rebaseline {
+		t.Fatalf("the resumed sample should carry the rebaseline, got collecting=%v rebaseline=%v", m.collecting, m.rebaseline)
+	}
+
+	c := NewCollector()
+	c.netWindow.advance(time.Second)
+	c.rebaseline()
+	if _, ok := c.netWindow.advance(time.Hour); ok {
+		t.Fatalf("a rebaselined collector should not average rates over the pause")
+	}
+}
+
+func TestSortDisks(t *testing.T) {
+	disks := []DiskStatus{
+		{Mount: "/", Used: 400 << 30, Total: 500 << 30},
+		{Mount: "/data", Used: 100 << 30, Total: 2000 << 30},
+		{Mount: "/boot", Used: 9 << 30, Total: 10 << 30},
+	}
+	mounts := func(list []DiskStatus) string {
+		var names []string
+		for _, d := range list {
+			names = append(names, d.Mount)
+		}
+		return strings.Join(names, " ")
+	}
+	tests := []struct {
+		by   diskSort
+		want string
+	}{
+		{diskBySize, "/ /data /boot"},
+		{diskByFree, "/boot / /data"},
+		{diskByMount, "/ /boot /data"},
+	}
+	for _, tt := range tests {
+		if got := mounts(sortDisks(disks, tt.by)); got != tt.want {
+			t.Errorf("sortDisks(%s) = %q, want %q", tt.by, got, tt.want)
+		}
+	}
+	if mounts(disks) != "/ /data /boot" {
+		t.Fatalf("sortDisks must not reorder its input")
+	}
+	if by, err := parseDiskSort("free"); err != nil || by != diskByFree {
+		t.Fatalf("parseDiskSort(free) = %v, %v", by, err)
+	}
+	if _, err := parseDiskSort("used"); err == nil {
+		t.Fatalf("parseDiskSort(used) expected error")
+	}
+}
+
+func TestFreezeOnSpike(t *testing.T) {
+	trigger := spikeTrigger{cpu: 90, rate: 50}
+	calm := MetricsSnapshot{CollectedAt: time.Now(), CPU: CPUStatus{Usage: 20}}
+	spike := MetricsSnapshot{CollectedAt: time.Now(), Network: []NetworkStatus{{Name: "en0", TxRateMBs: 80}},
+		NetworkHistory: NetworkHistory{TxHistory: []float64{1, 2, 80}}}
+	if got := trigger.check(calm); got != "" {
+		t.Fatalf("check(calm) = %q, want no trigger", got)
+	}
+	if got := trigger.check(spike); got != "en0 ↑ 80 MB/s" {
+		t.Fatalf("check(spike) = %q", got)
+	}
+
+	m := model{trigger: trigger}
+	update := func(s MetricsSnapshot) {
+		next, _ := m.Update(metricsMsg{data: s, spike: trigger.check(s)})
+		m = next.(model)
+	}
+	update(calm)
+	update(spike)
+	if m.frozen == nil || !strings.Contains(stripANSI(m.footer()), "Frozen on en0") {
+		t.Fatalf("spike should freeze the view, footer %q", stripANSI(m.footer()))
+	}
+	update(calm)
+	if len(m.metrics.NetworkHistory.TxHistory) != 3 {
+		t.Fatalf("frozen view should keep the spike snapshot and its history")
+	}
+
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	m = next.(model)
+	update(calm)
+	if m.frozen != nil || m.metrics.CPU.Usage != 20 {
+		t.Fatalf("f should resume live updates")
+	}
+}
+
+func TestNarrowViewFitsWidth(t *testing.T) {
+	m := model{ready: true, width: 20, metrics: MetricsSnapshot{
+		CPU:     CPUStatus{Usage: 42},
+		Network: []NetworkStatus{{Name: "en0", RxRateMBs: 1.5}},
+	}}
+	out := stripANSI(m.View())
+	if !strings.Contains(out, "CPU 42.0%") || !strings.Contains(out, "↓ 1.5 MB/s") {
+		t.Fatalf("narrow view missing headline numbers:\n%s", out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if n := lipgloss.Width(line); n > 20 {
+			t.Errorf("line %q is %d wide, want <= 20", line, n)
+		}
+	}
+
+	m.width = minViewWidth
+	if strings.Contains(stripANSI(m.View()), "Too narrow") {
+		t.Fatalf("card layout should be used at the minimum width")
+	}
+}
+
+func TestKioskMode(t *testing.T) {
+	cards := []cardData{{id: "cpu"}, {id: "memory"}, {id: "network"}}
+	for current, want := range map[string]string{"": "cpu", "cpu": "memory", "network": "cpu", "latency": "cpu"} {
+		if got := nextKioskFocus(cards, current); got != want {
+			t.Errorf("nextKioskFocus(%q) = %q, want %q", current, got, want)
+		}
+	}
+	if c := kioskCollapsed("memory"); c["memory"] || !c["cpu"] {
+		t.Fatalf("kioskCollapsed(memory) = %v", c)
+	}
+
+	m := model{kiosk: true}
+	ctrlC := tea.KeyMsg{Type: tea.KeyCtrlC}
+	for _, key := range []tea.KeyMsg{{Type: tea.KeyRunes, Runes: []rune("q")}, {Type: tea.KeyEsc}, {Type: tea.KeyRunes, Runes: []rune("g")}} {
+		next, cmd := m.Update(key)
+		if cmd != nil || next.(model).display.netGraph != graphSeparate {
+			t.Fatalf("kiosk mode should ignore %q", key.String())
+		}
+	}
+	next, cmd := m.Update(ctrlC)
+	if cmd != nil {
+		t.Fatalf("a single ctrl+c must not quit in kiosk mode")
+	}
+	if _, cmd = next.(model).Update(ctrlC); cmd == nil {
+		t.Fatalf("a second ctrl+c should quit")
+	}
+}
+
+func TestKeyHelpOverlay(t *testing.T) {
+	m := model{ready: true, width: 100}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("?")})
+	m = next.(model)
+	if !m.showHelp {
+		t.Fatal("? should open the help")
+	}
+	view := stripANSI(m.View())
+	for _, b := range keymap {
+		if !strings.Contains(view, b.help) {
+			t.Errorf("help is missing %q", b.help)
+		}
+	}
+	// Other keys do nothing while it is open.
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("G")})
+	if m = next.(model); m.display.groupByKind {
+		t.Error("keys should not act through the help overlay")
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if next.(model).showHelp {
+		t.Error("esc should close the help")
+	}
+
+	seen := map[string]bool{}
+	for _, b := range keymap {
+		for _, k := range b.keys {
+			if seen[k] {
+				t.Errorf("key %q is bound twice", k)
+			}
+			seen[k] = true
+		}
+	}
+}
+
+func TestInterfaceRateThresholds(t *testing.T) {
+	global := rateThresholds{warnRx: 10, critRx: 20}
+	perIface := map[string]rateThresholds{"en0": {warnRx: 50, critRx: 100}, "en1": {critTx: 5}}
+
+	if got := thresholdsFor("en0", global, perIface); got != (rateThresholds{warnRx: 50, critRx: 100}) {
+		t.Errorf("en0 levels = %+v, want its own", got)
+	}
+	// Fields an entry leaves unset fall back to the flags.
+	if got := thresholdsFor("en1", global, perIface); got != (rateThresholds{warnRx: 10, critRx: 20, critTx: 5}) {
+		t.Errorf("en1 levels = %+v, want merged", got)
+	}
+	if got := thresholdsFor("eth9", global, perIface); got != global {
+		t.Errorf("eth9 levels = %+v, want global", got)
+	}
+
+	// 30 MB/s is critical globally but below both en0 levels.
+	levels := thresholdsFor("en0", global, perIface)
+	if got, want := interfaceRowRates(30, 0, levels, 0), interfaceRowRates(30, 0, rateThresholds{}, 0); got != want {
+		t.Errorf("en0 at 30 MB/s = %q, want uncolored %q", got, want)
+	}
+	want := " ↓ " + dangerStyle.Render(formatRate(30)+"   ") + " ↑ " + lipgloss.NewStyle().Render(formatRate(0))
+	if got := interfaceRowRates(30, 0, global, 0); got != want {
+		t.Errorf("eth9 at 30 MB/s = %q, want %q", got, want)
+	}
+
+	parsed, err := parseRateThresholds("warn_rx=50, crit_rx=100")
+	if err != nil || parsed != perIface["en0"] || parsed.String() != "warn_rx=50,crit_rx=100" {
+		t.Errorf("parseRateThresholds = %+v (%q), %v", parsed, parsed.String(), err)
+	}
+	for _, bad := range []string{"warn_rx=fast", "warn_up=1", "warn_rx=50,crit_rx=10", "crit_tx=-1"} {
+		if _, err := parseRateThresholds(bad); err == nil {
+			t.Errorf("parseRateThresholds(%q) expected error", bad)
+		}
+	}
+}
+
+func TestConnectionsCard(t *testing.T) {
+	conns := ConnectionStatus{
+		Total:    9,
+		ByState:  map[string]int{"ESTABLISHED": 6, "LISTEN": 2, "TIME_WAIT": 1},
+		ByProto:  map[string]int{"TCP": 8, "UDP": 1},
+		ByRemote: map[string]int{"2001:db8::1": 4, "10.0.0.2": 2},
+	}
+	m := model{ready: true, width: 100}
+	var titles, first []string
+	for range connGroupNames {
+		card := renderConnectionsCard(conns, m.display.connGroup, 0)
+		titles = append(titles, card.title)
+		first = append(first, stripANSI(card.lines[0]))
+		next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+		m = next.(model)
+	}
+	if want := []string{"Connections by state", "Connections by proto", "Connections by remote"}; !slices.Equal(titles, want) {
+		t.Fatalf("titles = %q, want %q", titles, want)
+	}
+	if want := []string{"    6  ESTABLISHED", "    8  TCP", "    4  2001:db8::1"}; !slices.Equal(first, want) {
+		t.Fatalf("first rows = %q, want %q", first, want)
+	}
+	if m.display.connGroup != connByState {
+		t.Fatalf("s should cycle back to state, got %v", m.display.connGroup)
+	}
+	if card := renderConnectionsCard(conns, connByState, 0); card.summary != "9 connections" {
+		t.Fatalf("summary = %q", card.summary)
+	}
+}
+
+func TestNetworkRowsDuplex(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "eth0", Kind: ifaceKindPhysical, RxRateMBs: 2, Duplex: "half", Media: "100Mb/s Twisted Pair"},
+		{Name: "eth1", Kind: ifaceKindPhysical, RxRateMBs: 1, Duplex: "full", Media: "1000Mb/s Twisted Pair"},
+	}
+	got := stripANSI(strings.Join(networkRows(stats, viewState{selectedIface: "eth1"}), "\n"))
+	if !strings.Contains(got, "half-duplex") || strings.Count(got, "half-duplex") != 1 {
+		t.Errorf("only eth0 should be flagged half-duplex:\n%s", got)
+	}
+	if !strings.Contains(got, "of total · 1000Mb/s Twisted Pair · full duplex") {
+		t.Errorf("selected row should list its media and duplex:\n%s", got)
+	}
+}
+
+func TestSparklineNaN(t *testing.T) {
+	nan := math.NaN()
+	for _, style := range []string{sparkBlocks, sparkBraille, sparkASCII} {
+		sparkStyle = style
+		got := stripANSI(sparkline([]float64{nan, math.Inf(1), nan}, nan, 3))
+		runes := []rune(got)
+		if len(runes) != 3 {
+			t.Fatalf("%s: sparkline(NaN) = %q, want 3 cells", style, got)
+		}
+		base := sparkGlyphs[style](0)
+		if runes[0] != base || runes[2] != base {
+			t.Errorf("%s: sparkline(NaN) = %q, want NaN drawn as the baseline %q", style, got, base)
+		}
+	}
+	sparkStyle = sparkBlocks
+	if got := stripANSI(progressBar(nan)); got != strings.Repeat("░", 16) {
+		t.Errorf("progressBar(NaN) = %q, want an empty bar", got)
+	}
+}
+
+func TestSamplesLimitQuits(t *testing.T) {
+	m := model{samples: 2, interval: time.Millisecond, session: newSessionStats(time.Now())}
+	for i := range 3 {
+		next, cmd := m.Update(metricsMsg{data: MetricsSnapshot{CollectedAt: time.Now(), NetworkWarmup: i == 0}})
+		m = next.(model)
+		_, quit := cmd().(tea.QuitMsg)
+		// The baseline sample plus two counted ones, then quit.
+		if want := i == 2; quit != want {
+			t.Fatalf("sample %d: quit = %v, want %v", i, quit, want)
+		}
+	}
+}
+
+func TestNetworkRowsRawCounters(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "eth0", Kind: ifaceKindPhysical, RxRateMBs: 2, RxBytes: 3 << 30, TxBytes: 512 << 20},
+		{Name: "eth1", Kind: ifaceKindPhysical, RxRateMBs: 1, RxBytes: 1 << 30},
+	}
+	m := model{metrics: MetricsSnapshot{Network: stats}}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	state := next.(model).display
+	if !state.rawCounters {
+		t.Fatal("a should switch the network rows to raw counters")
+	}
+	got := stripANSI(strings.Join(networkRows(stats, state), "\n"))
+	if want := formatBytes(3 << 30); !strings.Contains(got, want) || strings.Contains(got, "MB/s") {
+		t.Errorf("rows should show the counters (%s), not rates:\n%s", want, got)
+	}
+	card := stripANSI(strings.Join(renderNetworkCard(stats, NetworkHistory{}, ProxyStatus{}, false, 80, state).lines, "\n"))
+	if want := "OS     " + formatBytes(4<<30) + " ↓ / " + formatBytes(512<<20) + " ↑ counted"; !strings.Contains(card, want) {
+		t.Errorf("card should total the counters as %q:\n%s", want, card)
+	}
+}
+
+func TestNetworkRowsRateUnknown(t *testing.T) {
+	stats := []NetworkStatus{{Name: "eth0", RxRateMBs: 2, TxRateMBs: 1}, {Name: "wlan0", RateUnknown: true}}
+	got := stripANSI(strings.Join(networkRows(stats, viewState{}), "\n"))
+	if !strings.Contains(got, "↓ —") || !strings.Contains(got, "↑ — reset") {
+		t.Errorf("a reset row should show no rate:\n%s", got)
+	}
+}
+
+func TestTrendArrow(t *testing.T) {
+	steady := []float64{20, 20, 20, 20}
+	for _, tc := range []struct {
+		history []float64
+		want    string
+	}{
+		{[]float64{20, 20}, ""}, // Too short to say.
+		{append(slices.Clone(steady), 21), " ▬"},
+		{append(slices.Clone(steady), 40), " ▲"},
+		{append(slices.Clone(steady), 5), " ▼"},
+		{[]float64{0, 0, 0.005}, " ▬"}, // Near-zero rates stay steady.
+	} {
+		if got := stripANSI(trendArrow(tc.history, false)); got != tc.want {
+			t.Errorf("trendArrow(%v) = %q, want %q", tc.history, got, tc.want)
+		}
+	}
+
+	rising := append(slices.Clone(steady), 40)
+	if trendArrow(rising, true) != " "+warnStyle.Render("▲") || trendArrow(rising, false) != " ▲" {
+		t.Error("only CPU and memory should tint a rise as a warning")
+	}
+	card := renderCPUCard(CPUStatus{Usage: 40, History: rising}, ThermalStatus{})
+	if !strings.Contains(stripANSI(card.lines[0]), "40.0% ▲") {
+		t.Errorf("CPU total line = %q, want a trend arrow", stripANSI(card.lines[0]))
+	}
+
+	m := model{}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	defer func() { showTrends = true }()
+	if showTrends || trendArrow(rising, false) != "" {
+		t.Error("t should hide the trend arrows")
+	}
+}
+
+func TestIfaceGraphScale(t *testing.T) {
+	stats := []NetworkStatus{
+		{Name: "wan0", Kind: ifaceKindPhysical, RxRateMBs: 100, RxHistory: []float64{50, 100}, TxHistory: []float64{0, 0}},
+		{Name: "lan0", Kind: ifaceKindPhysical, RxRateMBs: 1, RxHistory: []float64{0.5, 1}, TxHistory: []float64{0, 0}},
+	}
+	sparkStyle = sparkBlocks
+	graphs := func(mode ifaceGraphs) []string {
+		var out []string
+		for _, line := range networkRows(stats, viewState{ifaceGraphs: mode}) {
+			if line = stripANSI(line); strings.Contains(line, "peak") {
+				out = append(out, strings.TrimSpace(line))
+			}
+		}
+		return out
+	}
+	if got := graphs(ifaceGraphsOff); len(got) != 0 {
+		t.Fatalf("graphs off should draw none, got %q", got)
+	}
+	own := graphs(ifaceGraphsOwn)
+	if len(own) != 2 || !strings.HasSuffix(own[1], "▄█ peak 1.0 MB/s") {
+		t.Fatalf("own scale should fill lan0's graph to its peak: %q", own)
+	}
+	shared := graphs(ifaceGraphsShared)
+	if len(shared) != 2 || !strings.HasSuffix(shared[0], "▄█ peak 100 MB/s") || !strings.HasSuffix(shared[1], "▁▁ peak 1.0 MB/s") {
+		t.Fatalf("shared scale should draw lan0 against wan0's peak: %q", shared)
+	}
+}
+
+func TestFocusFollowsActivity(t *testing.T) {
+	sample := func(eth0, wlan0 float64) []NetworkStatus {
+		return []NetworkStatus{{Name: "eth0", RxRateMBs: eth0}, {Name: "wlan0", RxRateMBs: wlan0}}
+	}
+	names := []string{"eth0", "wlan0"}
+	f := newActivityFollow()
+	if got := f.pick(sample(5, 1), names, ""); got != "eth0" {
+		t.Fatalf("first pick = %q, want the busiest, eth0", got)
+	}
+	f.pick(sample(5, 1), names, "eth0")
+	f.pick(sample(5, 1), names, "eth0")
+	// One burst on wlan0 does not lift its average far enough.
+	if got := f.pick(sample(5, 20), names, "eth0"); got != "eth0" {
+		t.Fatalf("pick after a blip = %q, want eth0 kept", got)
+	}
+	got := "eth0"
+	for range followWindow {
+		got = f.pick(sample(1, 20), names, got)
+	}
+	if got != "wlan0" {
+		t.Fatalf("pick after sustained traffic = %q, want wlan0", got)
+	}
+	if got := f.pick(sample(1, 20), []string{"eth0"}, "wlan0"); got != "eth0" {
+		t.Fatalf("pick with the selection gone = %q, want eth0", got)
+	}
+
+	m := model{metrics: MetricsSnapshot{Network: sample(1, 9)}}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("F")})
+	m = next.(model)
+	if m.display.follow == nil || m.display.selectedIface != "wlan0" {
+		t.Fatalf("F should select the busiest interface, got %q", m.display.selectedIface)
+	}
+	if row := strings.Join(networkRows(m.metrics.Network, m.display), "\n"); !strings.Contains(row, "following") {
+		t.Errorf("selected row should be marked following:\n%s", row)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	if next.(model).display.follow != nil {
+		t.Errorf("moving the cursor should stop following")
+	}
+}
+
+func TestSparklineCeiling(t *testing.T) {
+	history := []float64{50, 100, 200}
+	if got := stripANSI(ceilingSparkline(history, 200, 3, 0)); got != "▂▄█" {
+		t.Errorf("auto-scaled = %q, want ▂▄█", got)
+	}
+	if got := stripANSI(ceilingSparkline(history, 200, 3, 100)); got != "▄██" {
+		t.Errorf("ceiling 100 = %q, want ▄██ with the last point clipped", got)
+	}
+
+	levels, err := parseRateThresholds("warn_rx=50,rx_ceiling=100,tx_ceiling=20")
+	if err != nil || levels.rxCeiling != 100 || levels.graphCeiling() != 120 {
+		t.Fatalf("parseRateThresholds() = %+v, %v", levels, err)
+	}
+	if got := levels.String(); got != "warn_rx=50,rx_ceiling=100,tx_ceiling=20" {
+		t.Errorf("String() = %q", got)
+	}
+	n := NetworkStatus{Name: "en0", RxHistory: []float64{1, 150}, TxHistory: []float64{0, 0}}
+	if line := stripANSI(ifaceGraphLine(n, 0, levels.graphCeiling())); !strings.Contains(line, "scale") {
+		t.Errorf("ifaceGraphLine() with a ceiling = %q, want the scale labeled", line)
+	}
+	if _, err := parseOptions([]string{"--rx-ceiling", "-1"}, io.Discard); err == nil {
+		t.Errorf("a negative ceiling should be rejected")
+	}
+}
+
+func TestCPUCardThrottling(t *testing.T) {
+	card := renderCPUCard(CPUStatus{Usage: 50}, ThermalStatus{CPUTemp: 95, ThermalPressure: thermalHeavy, Throttled: true})
+	if !strings.Contains(stripANSI(card.lines[0]), "THROTTLING") {
+		t.Errorf("CPU header = %q, want a THROTTLING badge", stripANSI(card.lines[0]))
+	}
+	card = renderCPUCard(CPUStatus{Usage: 50}, ThermalStatus{ThermalPressure: thermalUnknown})
+	if last := stripANSI(card.lines[len(card.lines)-1]); strings.Contains(stripANSI(card.lines[0]), "THROTTLING") || !strings.Contains(last, "unknown") {
+		t.Errorf("CPU card without root = %q, want pressure unknown and no badge", last)
+	}
+}
+
+func TestAlignedRates(t *testing.T) {
+	rows := func(state viewState, wanRx float64) []string {
+		stats := []NetworkStatus{
+			{Name: "wan0", RxRateMBs: wanRx, TxRateMBs: 0.5, RxHistory: []float64{250, wanRx}},
+			{Name: "lan0", RxRateMBs: 0.02, TxRateMBs: 12},
+		}
+		var out []string
+		for _, line := range networkRows(stats, state) {
+			out = append(out, stripANSI(line))
+		}
+		return out
+	}
+	state := viewState{alignRates: true}
+	before, after := rows(state, 250), rows(state, 3)
+	if len(before) != 2 || len(after) != 2 {
+		t.Fatalf("rows = %q / %q, want two", before, after)
+	}
+	for i := range before {
+		if strings.Index(before[i], "↑") != strings.Index(after[i], "↑") || utf8.RuneCountInString(before[i]) != utf8.RuneCountInString(after[i]) {
+			t.Errorf("row %d moved: %q then %q", i, before[i], after[i])
+		}
+	}
+	if !strings.HasPrefix(after[0], "wan0   ↓  3.0 MB/s ↑ 0.50 MB/s") {
+		t.Errorf("aligned row = %q, want right-aligned columns sized to the 250 MB/s peak", after[0])
+	}
+	if got := rows(viewState{alignRates: true, rateWidth: 12}, 3)[1]; !strings.HasPrefix(got, "lan0   ↓    0.02 MB/s ↑      12 MB/s") {
+		t.Errorf("row with --rate-width 12 = %q", got)
+	}
+}
+
+func TestLayoutKey(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	prefs := parsePrefs("layout.net=panels=network disk_sort=mount\nlayout.all=panels=all\n")
+	m := model{layouts: prefs.layouts}
+	m.display.diskSort = diskByFree
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("L")})
+	m = next.(model)
+	if m.layout != "net" || m.display.diskSort != diskByMount || m.display.collapsed["network"] || !m.display.collapsed["cpu"] {
+		t.Fatalf("after L: layout %q, %+v", m.layout, m.display)
+	}
+	if !strings.Contains(stripANSI(m.footer()), "layout net") {
+		t.Errorf("footer = %q, want the layout name", stripANSI(m.footer()))
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("L")})
+	m = next.(model)
+	if m.layout != "all" || m.display.collapsed != nil || m.display.diskSort != diskByMount {
+		t.Errorf("second L: layout %q, %+v, want everything expanded and the sort kept", m.layout, m.display)
+	}
+	if saved := loadPrefs(); saved.layout != "all" || len(saved.layouts) != 2 {
+		t.Errorf("saved prefs = %+v, want the active layout persisted", saved)
+	}
+}
+
+func TestASCIIOutput(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+	for _, tc := range []struct {
+		vars map[string]string
+		want bool
+	}{
+		{map[string]string{"LANG": "en_US.UTF-8"}, true},
+		{map[string]string{"LANG": "de_DE.utf8"}, true},
+		{map[string]string{"LANG": "C"}, false},
+		{map[string]string{"LC_ALL": "POSIX", "LANG": "en_US.UTF-8"}, false},
+		{map[string]string{"LC_CTYPE": "en_US.ISO-8859-1"}, false},
+		{nil, true},
+	} {
+		if got := localeIsUTF8(env(tc.vars)); got != tc.want {
+			t.Errorf("localeIsUTF8(%v) = %v, want %v", tc.vars, got, tc.want)
+		}
+	}
+
+	if got := toASCII("⇅ Network · ↓ 1.2 MB/s ▲ ▁▄▇█ ⠀⣿ 58°C ● über"); got != "* Network - v 1.2 MB/s ^ _-*# _# 58 C * ?ber" {
+		t.Errorf("toASCII = %q", got)
+	}
+	m := model{ready: true, width: 120, height: 40, metrics: MetricsSnapshot{
+		CPU:     CPUStatus{Usage: 40, History: []float64{10, 40}},
+		Network: []NetworkStatus{{Name: "en0", RxRateMBs: 2, TxRateMBs: 0.5, RxHistory: []float64{1, 2}, TxHistory: []float64{0.5, 0.5}}},
+	}}
+	frame := m.view()
+	asciiOutput = true
+	defer func() { asciiOutput = false }()
+	out := m.View()
+	for i, r := range out {
+		if r >= 0x80 {
+			t.Fatalf("ASCII frame has %q at %d", r, i)
+		}
+	}
+	got, want := strings.Split(stripANSI(out), "\n"), strings.Split(stripANSI(frame), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("ASCII frame has %d lines, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if len(got[i]) != utf8.RuneCountInString(want[i]) {
+			t.Errorf("ASCII line %d = %q, want the width of %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDimView(t *testing.T) {
+	in := "\x1b[1;38;2;200;100;50mhot\x1b[0m plain\n\x1b[38;5;240mgrey\x1b[m"
+	want := "\x1b[2m\x1b[1;38;2;80;40;20mhot\x1b[0;2m plain\n\x1b[2m\x1b[38;5;240mgrey\x1b[;2m"
+	if got := dimView(in, 0.4); got != want {
+		t.Errorf("dimView() = %q, want %q", got, want)
+	}
+	if got := stripANSI(dimView(in, 0.4)); got != stripANSI(in) {
+		t.Errorf("dimView() changed the text: %q", got)
+	}
+}
+
+func TestColorizeTempThresholds(t *testing.T) {
+	tests := []struct {
+		temp     float64
+		expected string
+	}{
+		{temp: 30.0, expected: "30.0"}, // Normal - should use okStyle (green)
+		{temp: 55.9, expected: "55.9"}, // Just below warning threshold
+		{temp: 56.0, expected: "56.0"}, // Warning threshold - should use warnStyle (yellow)
+		{temp: 65.0, expected: "65.0"}, // Mid warning range
+		{temp: 75.9, expected: "75.9"}, // Just below danger threshold
+		{temp: 76.0, expected: "76.0"}, // Danger threshold - should use dangerStyle (red)
+		{temp: 90.0, expected: "90.0"}, // High temperature
+		{temp: 0.0, expected: "0.0"},   // Edge case: zero
+	}
+
+	for _, tt := range tests {
+		result := colorizeTemp(tt.temp)
+		// Check that result contains the formatted temperature value
+		if !strings.Contains(result, tt.expected) {
+			t.Errorf("colorizeTemp(%.1f) = %q, should contain %q", tt.temp, result, tt.expected)
+		}
+		// Verify output is not empty and contains the temperature
+		if result == "" {
+			t.Errorf("colorizeTemp(%.1f) returned empty string", tt.temp)
+		}
+	}
+}
+
+func TestColorizeTempStyleRanges(t *testing.T) {
+	normalTemp := colorizeTemp(40.0)
+	warningTemp := colorizeTemp(65.0)
+	dangerTemp := colorizeTemp(85.0)
+
+	if normalTemp == "" || warningTemp == "" || dangerTemp == "" {
+		t.Fatal("colorizeTemp should not return empty strings")
+	}
+
+	if !strings.Contains(normalTemp, "40.0") {
+		t.Errorf("normal temp should contain '40.0', got: %s", normalTemp)
+	}
+	if !strings.Contains(warningTemp, "65.0") {
+		t.Errorf("warning temp should contain '65.0', got: %s", warningTemp)
+	}
+	if !strings.Contains(dangerTemp, "85.0") {
+		t.Errorf("danger temp should contain '85.0', got: %s", dangerTemp)
+	}
+}
+
+func TestQueryPrompt(t *testing.T) {
+	m := model{ready: true, metrics: MetricsSnapshot{CPU: CPUStatus{Usage: 95}}}
+	typeKeys := func(keys ...tea.KeyMsg) {
+		for _, k := range keys {
+			next, _ := m.Update(k)
+			m = next.(model)
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	typeKeys(runes(":"), runes("cpu"), tea.KeyMsg{Type: tea.KeySpace}, runes(">"), tea.KeyMsg{Type: tea.KeySpace}, runes("9"), enter)
+	if m.queryErr != "" || m.queryOpen {
+		t.Fatalf("prompt should accept %q, error %q", m.queryInput, m.queryErr)
+	}
+	if m.queryInput != "cpu > 9" {
+		t.Fatalf("typed %q", m.queryInput)
+	}
+	if footer := stripANSI(m.footer()); !strings.Contains(footer, "yes cpu.usage > 9 (95)") {
+		t.Fatalf("footer = %q, want the query verdict", footer)
+	}
+	next, _ := m.Update(metricsMsg{data: MetricsSnapshot{CollectedAt: m.lastUpdated, CPU: CPUStatus{Usage: 5}}})
+	m = next.(model)
+	if footer := stripANSI(m.footer()); !strings.Contains(footer, "no cpu.usage > 9 (5)") {
+		t.Fatalf("footer = %q, want the verdict re-run on the new sample", footer)
+	}
+
+	typeKeys(runes(":"), runes("top cpu 2"), enter)
+	if !m.display.procsByCPU || m.display.procRows != 2 {
+		t.Fatalf("top cpu 2 should reconfigure the panel, display %+v", m.display)
+	}
+	typeKeys(runes(":"), runes("top"), enter)
+	if !m.queryOpen || m.queryErr == "" {
+		t.Fatalf("a bad query should keep the prompt open with an error")
+	}
+	typeKeys(tea.KeyMsg{Type: tea.KeyEsc}, runes(":"), enter)
+	if m.query != nil || m.queryResult != "" {
+		t.Fatalf("an empty query should clear the standing one")
+	}
+}
+
+func TestByteMark(t *testing.T) {
+	mark := newByteMark(time.Now(), []NetworkStatus{{Name: "en0", RxBytes: 1000, TxBytes: 500}})
+	// en1 shows up after the mark and counts from its first sighting.
+	mark.observe([]NetworkStatus{{Name: "en0", RxBytes: 4000, TxBytes: 700}, {Name: "en1", RxBytes: 9000}})
+
+	if rx, tx := mark.since(NetworkStatus{Name: "en0", RxBytes: 4000, TxBytes: 700}); rx != 3000 || tx != 200 {
+		t.Fatalf("since(en0) = %d/%d, want 3000/200", rx, tx)
+	}
+	if rx, _ := mark.since(NetworkStatus{Name: "en1", RxBytes: 9500}); rx != 500 {
+		t.Fatalf("since(en1) = %d, want 500", rx)
+	}
+	// A counter reset starts over from zero instead of underflowing.
+	mark.observe([]NetworkStatus{{Name: "en0", RxBytes: 100, TxBytes: 800}})
+	if rx, tx := mark.since(NetworkStatus{Name: "en0", RxBytes: 100, TxBytes: 800}); rx != 100 || tx != 300 {
+		t.Fatalf("since(en0) after reset = %d/%d, want 100/300", rx, tx)
+	}
+
+	stats := []NetworkStatus{{Name: "en0", RxBytes: 2 << 20, RxRateMBs: 1}, {Name: "en1", RxBytes: 9000 + 3<<20}}
+	card := renderNetworkCard(stats, NetworkHistory{}, ProxyStatus{}, false, 60, viewState{mark: mark})
+	text := stripANSI(strings.Join(card.lines, "\n"))
+	if !strings.Contains(text, "Since  ") || !strings.Contains(text, "en0    ↓ 2.0 MB") || !strings.Contains(text, "en1    ↓ 3.0 MB") {
+		t.Fatalf("marked network card should show bytes since the mark:\n%s", text)
+	}
+}
+
+func TestRateTare(t *testing.T) {
+	background := []NetworkStatus{{Name: "en0", RxRateMBs: 0.5, TxRateMBs: 0.2}}
+	tare := newRateTare(background, NetworkHistory{RxHistory: []float64{0.4, 0.5}, TxHistory: []float64{0.2}})
+
+	stats := []NetworkStatus{{Name: "en0", RxRateMBs: 2.5, TxRateMBs: 0.1}, {Name: "utun3", RxRateMBs: 1}}
+	got, history := tare.apply(stats, NetworkHistory{RxHistory: []float64{0.3, 3.5}, TxHistory: []float64{0.1}})
+	// Below the baseline reads as zero; interfaces new since the capture keep their rate.
+	if got[0].RxRateMBs != 2 || got[0].TxRateMBs != 0 || got[1].RxRateMBs != 1 {
+		t.Fatalf("tared rates = %+v", got)
+	}
+	if !slices.Equal(history.RxHistory, []float64{0, 3}) || !slices.Equal(history.TxHistory, []float64{0}) {
+		t.Fatalf("tared history = %+v", history)
+	}
+	if stats[0].RxRateMBs != 2.5 {
+		t.Fatal("apply must not modify the snapshot")
+	}
+
+	m := model{metrics: MetricsSnapshot{Network: background}}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	if next.(model).display.tare == nil {
+		t.Fatal("b should capture a baseline")
+	}
+	next, _ = next.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	if next.(model).display.tare != nil {
+		t.Fatal("a second b should clear it")
+	}
+}
+
+func TestFormatFDs(t *testing.T) {
+	for p, want := range map[*MemProcessInfo]string{
+		{NumFDs: -1}:                  "fd n/a",
+		{NumFDs: 12}:                  "fd 12",
+		{NumFDs: 900, FDLimit: 1024}:  "fd 900/1024",
+		{NumFDs: 9, FDLimit: 1 << 62}: "fd 9",
+	} {
+		if got := formatFDs(*p); got != want {
+			t.Errorf("formatFDs(%+v) = %q, want %q", *p, got, want)
+		}
+	}
+}
+
+func TestContainerCard(t *testing.T) {
+	got := ContainerStatus{Name: "web", ID: "0123456789ab", State: "running", CPUPercent: 10,
+		MemUsed: 256 << 20, MemLimit: 2 << 30, RxRateMBs: 1, TxRateMBs: 0.5}
+	if lines := stripANSI(strings.Join(renderContainerCard(got).lines, "\n")); !strings.Contains(lines, "256.0 MB / 2.0 GB") || !strings.Contains(lines, "web · 0123456789ab") {
+		t.Errorf("card = %q", lines)
+	}
+	if lines := renderContainerCard(ContainerStatus{Name: "web", Error: "docker socket not found"}).lines; len(lines) != 2 {
+		t.Errorf("unreachable card = %q", lines)
+	}
+}
+
+func TestUnitCard(t *testing.T) {
+	got := UnitStatus{Name: "web.service", State: "active/running", CGroup: "/system.slice/web.service", Tasks: 7,
+		CPUPercent: 25, MemUsed: 256 << 20, MemLimit: 1 << 30}
+	if lines := stripANSI(strings.Join(renderUnitCard(got).lines, "\n")); !strings.Contains(lines, "256.0 MB / 1024.0 MB · 7 tasks") || !strings.Contains(lines, "no nftables counter") {
+		t.Errorf("unit card:\n%s", lines)
+	}
+}